@@ -24,6 +24,10 @@ import (
 // Flags contains a field for all the cli flags that can be set.
 type Flags struct {
 	ConfigFile              string
+	AutoConfig              bool
+	BenchmarkSet            string
+	ScanType                string
+	ListBenchmarkSets       bool
 	ResultFile              string
 	ChrootPath              string
 	MySQLDatabase           string
@@ -42,9 +46,31 @@ type Flags struct {
 
 // ValidateFlags validates the passed command line flags.
 func ValidateFlags(flags *Flags) error {
-	if len(flags.ConfigFile) == 0 {
+	if flags.ListBenchmarkSets {
+		return nil
+	}
+	configSources := 0
+	for _, set := range []bool{len(flags.ConfigFile) > 0, flags.AutoConfig, len(flags.BenchmarkSet) > 0} {
+		if set {
+			configSources++
+		}
+	}
+	if configSources == 0 {
 		return errors.New("--config not set")
 	}
+	if configSources > 1 {
+		return errors.New("only one of --config, --auto-config and --benchmark-set can be specified")
+	}
+	if len(flags.ScanType) > 0 {
+		if len(flags.ConfigFile) > 0 {
+			return errors.New("--scan-type can only be used with --auto-config or --benchmark-set")
+		}
+		switch flags.ScanType {
+		case "instance", "vm_image", "container_image":
+		default:
+			return fmt.Errorf("invalid --scan-type %q: must be instance, vm_image or container_image", flags.ScanType)
+		}
+	}
 	if len(flags.ResultFile) == 0 {
 		return errors.New("--result not set")
 	}
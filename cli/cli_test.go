@@ -49,6 +49,72 @@ func TestValidateFlags(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			desc: "Auto config",
+			flags: &cli.Flags{
+				AutoConfig:         true,
+				ResultFile:         "result.textproto",
+				MaxCisProfileLevel: 3,
+			},
+			expectError: false,
+		},
+		{
+			desc: "Config and auto config",
+			flags: &cli.Flags{
+				ConfigFile:         "config.textproto",
+				AutoConfig:         true,
+				ResultFile:         "result.textproto",
+				MaxCisProfileLevel: 3,
+			},
+			expectError: true,
+		},
+		{
+			desc: "Benchmark set",
+			flags: &cli.Flags{
+				BenchmarkSet:       "ubuntu_22",
+				ScanType:           "vm_image",
+				ResultFile:         "result.textproto",
+				MaxCisProfileLevel: 3,
+			},
+			expectError: false,
+		},
+		{
+			desc: "Benchmark set and auto config",
+			flags: &cli.Flags{
+				BenchmarkSet:       "ubuntu_22",
+				AutoConfig:         true,
+				ResultFile:         "result.textproto",
+				MaxCisProfileLevel: 3,
+			},
+			expectError: true,
+		},
+		{
+			desc: "Invalid scan type",
+			flags: &cli.Flags{
+				BenchmarkSet:       "ubuntu_22",
+				ScanType:           "image",
+				ResultFile:         "result.textproto",
+				MaxCisProfileLevel: 3,
+			},
+			expectError: true,
+		},
+		{
+			desc: "Scan type with config file",
+			flags: &cli.Flags{
+				ConfigFile:         "config.textproto",
+				ScanType:           "instance",
+				ResultFile:         "result.textproto",
+				MaxCisProfileLevel: 3,
+			},
+			expectError: true,
+		},
+		{
+			desc: "List benchmark sets",
+			flags: &cli.Flags{
+				ListBenchmarkSets: true,
+			},
+			expectError: false,
+		},
 		{
 			desc: "Result missing",
 			flags: &cli.Flags{
@@ -0,0 +1,182 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/facts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// FallbackDir contains the distribution independent configs used if there's
+// no config for the scanned OS.
+const FallbackDir = "reduced/fallback"
+
+// The prefixes of the CPE URIs of the supported OSes, keyed by the ID field
+// of os-release. The prefix is followed by the OS version in the CPE URI.
+var osCPEPrefixes = map[string]string{
+	"centos": "cpe:/o:centos:centos:",
+	"cos":    "cpe:/o:cos:cos_linux:",
+	"debian": "cpe:/o:debian:debian_linux:",
+	"rocky":  "cpe:/o:rockylinux:rockylinux:",
+	"ubuntu": "cpe:/o:canonical:ubuntu_linux:",
+}
+
+// The IDs of the OSes whose minor versions are point releases of the same
+// major version, so a config for one minor version also applies to the others.
+var pointReleaseOSes = map[string]bool{
+	"centos": true,
+	"rocky":  true,
+}
+
+// Files that only exist in container images.
+var containerMarkerPaths = []string{"/.dockerenv", "/run/.containerenv"}
+
+// DetectScanType returns the reduced config file name for the scan: Instance
+// scanning if the running machine is scanned, container or VM image scanning
+// if an image mounted to a disk is scanned.
+func DetectScanType(ctx context.Context, fs scanapi.Filesystem, isImage bool) (string, error) {
+	if !isImage {
+		return InstanceScanning, nil
+	}
+	for _, p := range containerMarkerPaths {
+		f, err := fs.OpenFile(ctx, p)
+		if err == nil {
+			f.Close()
+			return ContainerImageScanning, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return VMImageScanning, nil
+}
+
+// SelectConfig returns the path of the embedded reduced config whose CPE URI
+// matches the ID and VERSION_ID in the os-release file of the scanned machine.
+// For OSes with point releases, a config for another minor version of the same
+// major version is used if there's none for the exact version. Returns the
+// fallback config if there's no config for the OS and scan type.
+func SelectConfig(ctx context.Context, fs scanapi.Filesystem, scanType string) (string, error) {
+	fallback := path.Join(FallbackDir, scanType)
+	if _, err := configSet.Open(fallback); err != nil {
+		return "", fmt.Errorf("unknown scan type %s", scanType)
+	}
+	osRelease, err := facts.ReadOSRelease(ctx, fs)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fallback, nil
+		}
+		return "", fmt.Errorf("unable to read os-release: %w", err)
+	}
+	dirs, err := configSet.ReadDir("reduced")
+	if err != nil {
+		return "", err
+	}
+	id, versionID := osRelease["ID"], osRelease["VERSION_ID"]
+	// The first config of the same major version, used if no config matches
+	// the exact version.
+	var sameMajorPath, sameMajorCPE string
+	for _, d := range dirs {
+		configPath := path.Join("reduced", d.Name(), scanType)
+		content, err := configSet.ReadFile(configPath)
+		if err != nil {
+			// The OS has no config for this scan type.
+			continue
+		}
+		reduced := &apb.PerOsBenchmarkConfig{}
+		if err := prototext.Unmarshal(content, reduced); err != nil {
+			return "", fmt.Errorf("error reading %s: %w", configPath, err)
+		}
+		cpeURI := reduced.GetVersion().GetCpeUri()
+		if cpeMatchesOS(cpeURI, id, versionID) {
+			return configPath, nil
+		}
+		if sameMajorPath == "" && pointReleaseOSes[id] && cpeMatchesMajorVersion(cpeURI, id, versionID) {
+			sameMajorPath, sameMajorCPE = configPath, cpeURI
+		}
+	}
+	if sameMajorPath != "" {
+		log.Printf("No config for %s %s, using the config for %s of the same major version\n", id, versionID, sameMajorCPE)
+		return sameMajorPath, nil
+	}
+	if _, ok := osCPEPrefixes[id]; ok {
+		log.Printf("warning: No config for %s %s, using the distribution independent config\n", id, versionID)
+	}
+	return fallback, nil
+}
+
+// cpeMatchesOS returns whether the CPE URI describes the given OS version. The
+// CPE URI can be more specific than the version, e.g. "7.%02" matches CentOS 7.
+func cpeMatchesOS(cpeURI string, id string, versionID string) bool {
+	prefix, ok := osCPEPrefixes[id]
+	if !ok || versionID == "" {
+		return false
+	}
+	version, found := strings.CutPrefix(cpeURI, prefix)
+	if !found {
+		return false
+	}
+	return version == versionID || strings.HasPrefix(version, versionID+".")
+}
+
+// cpeMatchesMajorVersion returns whether the CPE URI describes a version of
+// the OS with the same major version as the given one, e.g. "8.5" for 8.9.
+func cpeMatchesMajorVersion(cpeURI string, id string, versionID string) bool {
+	prefix, ok := osCPEPrefixes[id]
+	if !ok || versionID == "" {
+		return false
+	}
+	version, found := strings.CutPrefix(cpeURI, prefix)
+	if !found {
+		return false
+	}
+	major, _, _ := strings.Cut(versionID, ".")
+	return version == major || strings.HasPrefix(version, major+".")
+}
+
+// AutoConfig selects the embedded config matching the scanned machine and
+// returns the full scan config along with the path of the selected config.
+// The scan type name, e.g. "instance", is detected if it's empty.
+func AutoConfig(ctx context.Context, fs scanapi.Filesystem, isImage bool, scanType string) (*apb.ScanConfig, string, error) {
+	var scanTypeFile string
+	var err error
+	if scanType != "" {
+		scanTypeFile, err = ScanTypeFileName(scanType)
+	} else {
+		scanTypeFile, err = DetectScanType(ctx, fs, isImage)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	configPath, err := SelectConfig(ctx, fs, scanTypeFile)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := FullConfig(configPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return config, configPath, nil
+}
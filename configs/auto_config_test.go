@@ -0,0 +1,159 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"github.com/google/localtoast/configs"
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// fakeFilesystem serves the files from a map of file paths to contents.
+type fakeFilesystem struct {
+	files map[string]string
+}
+
+func (f *fakeFilesystem) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	content, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+func (f *fakeFilesystem) OpenDir(ctx context.Context, path string) (scanapi.DirReader, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestAutoConfig(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		files    map[string]string
+		isImage  bool
+		scanType string
+		wantPath string
+	}{
+		{
+			desc:     "Debian instance",
+			files:    map[string]string{"/etc/os-release": "ID=debian\nVERSION_ID=\"12\"\n"},
+			wantPath: "reduced/debian_12/instance_scanning.textproto",
+		},
+		{
+			desc:     "Ubuntu VM image",
+			files:    map[string]string{"/usr/lib/os-release": "ID=ubuntu\nVERSION_ID=\"22.04\"\n"},
+			isImage:  true,
+			wantPath: "reduced/ubuntu_22/vm_image_scanning.textproto",
+		},
+		{
+			desc:     "CPE more specific than the version",
+			files:    map[string]string{"/etc/os-release": "ID=\"centos\"\nVERSION_ID=\"7\"\n"},
+			wantPath: "reduced/centos_7/instance_scanning.textproto",
+		},
+		{
+			desc:     "other minor version of a point release OS",
+			files:    map[string]string{"/etc/os-release": "ID=\"rocky\"\nVERSION_ID=\"8.9\"\n"},
+			wantPath: "reduced/rocky_85/instance_scanning.textproto",
+		},
+		{
+			desc:     "other major version of a point release OS",
+			files:    map[string]string{"/etc/os-release": "ID=\"rocky\"\nVERSION_ID=\"9.3\"\n"},
+			wantPath: "reduced/fallback/instance_scanning.textproto",
+		},
+		{
+			desc:     "unsupported OS version",
+			files:    map[string]string{"/etc/os-release": "ID=debian\nVERSION_ID=\"11\"\n"},
+			wantPath: "reduced/fallback/instance_scanning.textproto",
+		},
+		{
+			desc:     "no config for the scan type",
+			files:    map[string]string{"/etc/os-release": "ID=debian\nVERSION_ID=\"10\"\n"},
+			wantPath: "reduced/fallback/instance_scanning.textproto",
+		},
+		{
+			desc: "container image",
+			files: map[string]string{
+				"/etc/os-release": "ID=debian\nVERSION_ID=\"12\"\n",
+				"/.dockerenv":     "",
+			},
+			isImage:  true,
+			wantPath: "reduced/fallback/container_image_scanning.textproto",
+		},
+		{
+			desc:     "scan type set",
+			files:    map[string]string{"/etc/os-release": "ID=debian\nVERSION_ID=\"12\"\n"},
+			scanType: "vm_image",
+			wantPath: "reduced/debian_12/vm_image_scanning.textproto",
+		},
+		{
+			desc:     "no os-release",
+			files:    map[string]string{},
+			isImage:  true,
+			wantPath: "reduced/fallback/vm_image_scanning.textproto",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config, gotPath, err := configs.AutoConfig(context.Background(), &fakeFilesystem{files: tc.files}, tc.isImage, tc.scanType)
+			if err != nil {
+				t.Fatalf("configs.AutoConfig() returned an error: %v", err)
+			}
+			if gotPath != tc.wantPath {
+				t.Errorf("configs.AutoConfig() selected %s, want %s", gotPath, tc.wantPath)
+			}
+			reduced := &apb.PerOsBenchmarkConfig{}
+			if err := prototext.Unmarshal(reducedScanConfigs[tc.wantPath], reduced); err != nil {
+				t.Fatalf("error reading %s: %v", tc.wantPath, err)
+			}
+			if len(config.GetBenchmarkConfigs()) != len(reduced.GetBenchmarkId()) {
+				t.Errorf("configs.AutoConfig() returned %d benchmarks, want %d", len(config.GetBenchmarkConfigs()), len(reduced.GetBenchmarkId()))
+			}
+		})
+	}
+}
+
+func TestAllEmbeddedConfigsCanBeGenerated(t *testing.T) {
+	for filePath := range reducedScanConfigs {
+		if _, err := configs.FullConfig(filePath); err != nil {
+			t.Errorf("configs.FullConfig(%s) returned an error: %v", filePath, err)
+		}
+	}
+}
+
+func TestSelectConfigUnknownScanTypeReturnsError(t *testing.T) {
+	if _, err := configs.SelectConfig(context.Background(), &fakeFilesystem{}, "unknown.textproto"); err == nil {
+		t.Errorf("configs.SelectConfig() didn't return an error")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configs embeds the benchmark definitions and the reduced per-OS scan
+// configs into the scanner binaries and generates the full scan configs from
+// them.
+package configs
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"github.com/google/localtoast/configs/genfullconfig/genfullconfiglib"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// The file names of the reduced configs for each scan type.
+const (
+	InstanceScanning       = "instance_scanning.textproto"
+	VMImageScanning        = "vm_image_scanning.textproto"
+	ContainerImageScanning = "container_image_scanning.textproto"
+)
+
+// The suffix of the reduced config file names, following the scan type name.
+const scanTypeSuffix = "_scanning.textproto"
+
+//go:embed defs/*.textproto reduced/*/*.textproto
+var configSet embed.FS
+
+// The parsed config definitions, shared by all generated configs.
+var (
+	configDefsOnce sync.Once
+	configDefs     []*apb.ScanConfig
+	configDefsErr  error
+)
+
+// FullConfig creates the full scan config for the given embedded reduced
+// config from the embedded config definitions.
+func FullConfig(configPath string) (*apb.ScanConfig, error) {
+	reduced, err := readReducedConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	reduced, err = genfullconfiglib.ResolveExtends(reduced, genfullconfiglib.ReducedConfigName(configPath), func(name string) (*apb.PerOsBenchmarkConfig, error) {
+		return readReducedConfig(path.Join("reduced", name+path.Ext(configPath)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	configDefsOnce.Do(func() {
+		configDefs, configDefsErr = readConfigDefs()
+	})
+	if configDefsErr != nil {
+		return nil, configDefsErr
+	}
+	// The descriptions aren't needed for scanning.
+	return genfullconfiglib.CreateFullConfig(reduced, path.Base(configPath), configDefs, true)
+}
+
+func readReducedConfig(configPath string) (*apb.PerOsBenchmarkConfig, error) {
+	content, err := configSet.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	reduced := &apb.PerOsBenchmarkConfig{}
+	if err := prototext.Unmarshal(content, reduced); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", configPath, err)
+	}
+	return reduced, nil
+}
+
+func readConfigDefs() ([]*apb.ScanConfig, error) {
+	defFiles, err := configSet.ReadDir("defs")
+	if err != nil {
+		return nil, err
+	}
+	defs := make([]*apb.ScanConfig, 0, len(defFiles))
+	for _, f := range defFiles {
+		defPath := path.Join("defs", f.Name())
+		content, err := configSet.ReadFile(defPath)
+		if err != nil {
+			return nil, err
+		}
+		def := &apb.ScanConfig{}
+		if err := prototext.Unmarshal(content, def); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", defPath, err)
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// ScanTypeFileName returns the reduced config file name for a scan type name,
+// e.g. "instance_scanning.textproto" for "instance".
+func ScanTypeFileName(scanType string) (string, error) {
+	fileName := scanType + scanTypeSuffix
+	switch fileName {
+	case InstanceScanning, VMImageScanning, ContainerImageScanning:
+		return fileName, nil
+	}
+	return "", fmt.Errorf("unknown scan type %q, must be one of instance, vm_image or container_image", scanType)
+}
+
+// BenchmarkSet is a per-OS or per-application set of benchmarks embedded into
+// the binary, e.g. "ubuntu_22".
+type BenchmarkSet struct {
+	Name string
+	// The CPE URI of the OS or application the benchmarks apply to.
+	CpeURI string
+	// The names of the scan types the set has configs for, e.g. "instance".
+	ScanTypes []string
+}
+
+// ListBenchmarkSets returns the embedded benchmark sets, sorted by name.
+func ListBenchmarkSets() ([]*BenchmarkSet, error) {
+	dirs, err := configSet.ReadDir("reduced")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*BenchmarkSet, 0, len(dirs))
+	for _, d := range dirs {
+		files, err := configSet.ReadDir(path.Join("reduced", d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		set := &BenchmarkSet{Name: d.Name()}
+		for _, f := range files {
+			configPath := path.Join("reduced", d.Name(), f.Name())
+			content, err := configSet.ReadFile(configPath)
+			if err != nil {
+				return nil, err
+			}
+			reduced := &apb.PerOsBenchmarkConfig{}
+			if err := prototext.Unmarshal(content, reduced); err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", configPath, err)
+			}
+			set.CpeURI = reduced.GetVersion().GetCpeUri()
+			set.ScanTypes = append(set.ScanTypes, strings.TrimSuffix(f.Name(), scanTypeSuffix))
+		}
+		sort.Strings(set.ScanTypes)
+		result = append(result, set)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// BenchmarkSetConfig creates the full scan config of the named embedded
+// benchmark set for the given scan type name, e.g. "instance".
+func BenchmarkSetConfig(name string, scanType string) (*apb.ScanConfig, error) {
+	fileName, err := ScanTypeFileName(scanType)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid benchmark set %q", name)
+	}
+	configPath := path.Join("reduced", name, fileName)
+	if _, err := configSet.Open(configPath); err != nil {
+		return nil, fmt.Errorf("benchmark set %q has no %s scan config, see --list-benchmark-sets", name, scanType)
+	}
+	return FullConfig(configPath)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configs_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/configs"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+func TestListBenchmarkSets(t *testing.T) {
+	sets, err := configs.ListBenchmarkSets()
+	if err != nil {
+		t.Fatalf("configs.ListBenchmarkSets() returned an error: %v", err)
+	}
+	got := make(map[string]*configs.BenchmarkSet)
+	for _, s := range sets {
+		got[s.Name] = s
+	}
+	want := map[string]*configs.BenchmarkSet{
+		"ubuntu_22": {
+			Name:      "ubuntu_22",
+			CpeURI:    "cpe:/o:canonical:ubuntu_linux:22.04",
+			ScanTypes: []string{"instance", "vm_image"},
+		},
+		"fallback": {
+			Name:      "fallback",
+			CpeURI:    "fallback",
+			ScanTypes: []string{"container_image", "instance", "vm_image"},
+		},
+	}
+	for name, w := range want {
+		if diff := cmp.Diff(w, got[name]); diff != "" {
+			t.Errorf("configs.ListBenchmarkSets() returned unexpected diff for %s (-want +got):\n%s", name, diff)
+		}
+	}
+	if len(sets) != len(reducedConfigDirs()) {
+		t.Errorf("configs.ListBenchmarkSets() returned %d sets, want %d", len(sets), len(reducedConfigDirs()))
+	}
+}
+
+func reducedConfigDirs() map[string]bool {
+	dirs := make(map[string]bool)
+	for filePath := range reducedScanConfigs {
+		dirs[path.Dir(filePath)] = true
+	}
+	return dirs
+}
+
+func TestBenchmarkSetConfig(t *testing.T) {
+	config, err := configs.BenchmarkSetConfig("ubuntu_22", "vm_image")
+	if err != nil {
+		t.Fatalf("configs.BenchmarkSetConfig() returned an error: %v", err)
+	}
+	if len(config.GetBenchmarkConfigs()) == 0 {
+		t.Errorf("configs.BenchmarkSetConfig() returned no benchmarks")
+	}
+	for _, b := range config.GetBenchmarkConfigs() {
+		if b.GetComplianceNote().GetDescription() != "" {
+			t.Errorf("configs.BenchmarkSetConfig() returned benchmark %s with a description, expected it to be omitted", b.GetId())
+		}
+	}
+}
+
+func TestBenchmarkSetConfigInvalidArgsReturnError(t *testing.T) {
+	for _, tc := range []struct {
+		set      string
+		scanType string
+	}{
+		{set: "ubuntu_22", scanType: "container_image"},
+		{set: "ubuntu_22", scanType: "image"},
+		{set: "windows_11", scanType: "instance"},
+		{set: "../defs", scanType: "instance"},
+	} {
+		if _, err := configs.BenchmarkSetConfig(tc.set, tc.scanType); err == nil {
+			t.Errorf("configs.BenchmarkSetConfig(%q, %q) didn't return an error", tc.set, tc.scanType)
+		}
+	}
+}
+
+func TestExtendingConfigsOnlyChangeVersion(t *testing.T) {
+	for filePath, configBytes := range reducedScanConfigs {
+		reduced := &apb.PerOsBenchmarkConfig{}
+		if err := prototext.Unmarshal(configBytes, reduced); err != nil {
+			t.Fatalf("error reading %s: %v", filePath, err)
+		}
+		if reduced.GetExtends() == "" || len(reduced.GetAddBenchmarkId()) > 0 ||
+			len(reduced.GetRemoveBenchmarkId()) > 0 || len(reduced.GetProfileLevelOverride()) > 0 {
+			continue
+		}
+		got, err := configs.FullConfig(filePath)
+		if err != nil {
+			t.Fatalf("configs.FullConfig(%s) returned an error: %v", filePath, err)
+		}
+		want, err := configs.FullConfig(path.Join("reduced", reduced.GetExtends()+path.Ext(filePath)))
+		if err != nil {
+			t.Fatalf("configs.FullConfig(%s) returned an error: %v", reduced.GetExtends(), err)
+		}
+		for _, b := range want.GetBenchmarkConfigs() {
+			b.GetComplianceNote().Version = []*cpb.ComplianceVersion{reduced.GetVersion()}
+		}
+		if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+			t.Errorf("configs.FullConfig(%s) returned unexpected diff to the extended config (-want +got):\n%s", filePath, diff)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package configs_test
 
 import (
 	"embed"
+	"fmt"
 	"io/fs"
 	"log"
 	"path"
@@ -27,6 +28,7 @@ import (
 	"google.golang.org/protobuf/proto"
 	"bitbucket.org/creachadair/stringset"
 	spb "github.com/google/localtoast/scannerlib/proto/severity_go_proto"
+	"github.com/google/localtoast/configs/genfullconfig/genfullconfiglib"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
 )
@@ -178,16 +180,48 @@ func TestFallbackBenchmarkDefsHaveExpectedIdFormat(t *testing.T) {
 	}
 }
 
+// readReducedConfig parses the per-OS config at the given path of the embedded
+// reduced configs.
+func readReducedConfig(filePath string) (*apb.PerOsBenchmarkConfig, error) {
+	configBytes, ok := reducedScanConfigs[filePath]
+	if !ok {
+		return nil, fmt.Errorf("config %s not found", filePath)
+	}
+	config := &apb.PerOsBenchmarkConfig{}
+	if err := prototext.Unmarshal(configBytes, config); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", filePath, err)
+	}
+	return config, nil
+}
+
+// resolvedReducedConfigs returns the per-OS configs by path with the configs
+// they extend applied.
+func resolvedReducedConfigs(t *testing.T) map[string]*apb.PerOsBenchmarkConfig {
+	t.Helper()
+	readByName := func(name string) (*apb.PerOsBenchmarkConfig, error) {
+		return readReducedConfig(path.Join("reduced", name+".textproto"))
+	}
+	result := make(map[string]*apb.PerOsBenchmarkConfig)
+	for filePath := range reducedScanConfigs {
+		config, err := readReducedConfig(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resolved, err := genfullconfiglib.ResolveExtends(config, genfullconfiglib.ReducedConfigName(filePath), readByName)
+		if err != nil {
+			t.Fatalf("error resolving %s: %v", filePath, err)
+		}
+		result[filePath] = resolved
+	}
+	return result
+}
+
 func TestFallbackPerOsBenchmarksHaveExpectedIdFormat(t *testing.T) {
-	for filePath, configBytes := range reducedScanConfigs {
+	for filePath, config := range resolvedReducedConfigs(t) {
 		if !strings.Contains(filePath, "/fallback/") {
 			continue
 		}
-		config := &apb.PerOsBenchmarkConfig{}
-		if err := prototext.Unmarshal(configBytes, config); err != nil {
-			t.Errorf("error reading %s: %v", filePath, err)
-		}
-		for _, id := range config.BenchmarkId {
+		for _, id := range config.GetBenchmarkId() {
 			if !strings.HasSuffix(id, "-fallback") {
 				t.Errorf("Fallback benchmark ID %q should end with -fallback", id)
 			}
@@ -196,17 +230,16 @@ func TestFallbackPerOsBenchmarksHaveExpectedIdFormat(t *testing.T) {
 }
 
 func TestProfileLevelOverridesUseExistingIDs(t *testing.T) {
-	for filePath, configBytes := range reducedScanConfigs {
-		config := &apb.PerOsBenchmarkConfig{}
-		if err := prototext.Unmarshal(configBytes, config); err != nil {
-			t.Errorf("error reading %s: %v", filePath, err)
+	for filePath, config := range resolvedReducedConfigs(t) {
+		if len(config.GetBenchmarkId()) == 0 {
+			t.Errorf("%s: config doesn't use any benchmarks", filePath)
 		}
 		ids := make(map[string]bool)
-		for _, id := range config.BenchmarkId {
+		for _, id := range config.GetBenchmarkId() {
 			ids[id] = true
 		}
-		for _, o := range config.ProfileLevelOverride {
-			for _, id := range o.BenchmarkId {
+		for _, o := range config.GetProfileLevelOverride() {
+			for _, id := range o.GetBenchmarkId() {
 				if _, ok := ids[id]; !ok {
 					t.Errorf("%s: overridden benchmark ID %q isn't used by the config", filePath, id)
 				}
@@ -215,6 +248,38 @@ func TestProfileLevelOverridesUseExistingIDs(t *testing.T) {
 	}
 }
 
+func TestPerOsBenchmarksHaveDefinitions(t *testing.T) {
+	defined := make(map[string]bool)
+	for filePath, configBytes := range scanConfigDefs {
+		config := &apb.ScanConfig{}
+		if err := prototext.Unmarshal(configBytes, config); err != nil {
+			t.Fatalf("error reading %s: %v", filePath, err)
+		}
+		for _, b := range config.GetBenchmarkConfigs() {
+			defined[b.GetId()] = true
+		}
+	}
+	for filePath := range reducedScanConfigs {
+		config, err := readReducedConfig(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := append(append([]string{}, config.GetAddBenchmarkId()...), config.GetRemoveBenchmarkId()...)
+		for _, id := range ids {
+			if !defined[id] {
+				t.Errorf("%s: added or removed benchmark ID %q isn't defined", filePath, id)
+			}
+		}
+	}
+	for filePath, config := range resolvedReducedConfigs(t) {
+		for _, id := range config.GetBenchmarkId() {
+			if !defined[id] {
+				t.Errorf("%s: benchmark ID %q isn't defined", filePath, id)
+			}
+		}
+	}
+}
+
 func TestConfigDefContainsUniqueNoteDefinitions(t *testing.T) {
 	notePresent := make(map[string]bool)
 	for filePath, configBytes := range scanConfigDefs {
@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genfullconfiglib
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// ReducedConfigReader reads the per-OS config with the given name, e.g.
+// "cos_113/instance_scanning".
+type ReducedConfigReader func(name string) (*apb.PerOsBenchmarkConfig, error)
+
+// ReducedConfigName returns the name other per-OS configs use to extend the
+// config at the given path, e.g. "cos_113/instance_scanning" for
+// "configs/reduced/cos_113/instance_scanning.textproto".
+func ReducedConfigName(configPath string) string {
+	base := path.Base(configPath)
+	return path.Join(path.Base(path.Dir(configPath)), strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// ResolveExtends returns the per-OS config with the benchmark IDs and profile
+// level overrides of the configs it extends applied. name is the config's own
+// name, used to detect cycles. Returns the config unchanged if it doesn't
+// extend another one.
+func ResolveExtends(reduced *apb.PerOsBenchmarkConfig, name string, read ReducedConfigReader) (*apb.PerOsBenchmarkConfig, error) {
+	return resolveExtends(reduced, name, read, map[string]bool{})
+}
+
+func resolveExtends(reduced *apb.PerOsBenchmarkConfig, name string, read ReducedConfigReader, visited map[string]bool) (*apb.PerOsBenchmarkConfig, error) {
+	if reduced.GetExtends() == "" {
+		if len(reduced.GetAddBenchmarkId()) > 0 || len(reduced.GetRemoveBenchmarkId()) > 0 {
+			return nil, fmt.Errorf("%s: add_benchmark_id and remove_benchmark_id require extends to be set", name)
+		}
+		return reduced, nil
+	}
+	if len(reduced.GetBenchmarkId()) > 0 {
+		return nil, fmt.Errorf("%s: benchmark_id can't be set together with extends, use add_benchmark_id instead", name)
+	}
+	visited[name] = true
+	parentName := reduced.GetExtends()
+	if visited[parentName] {
+		return nil, fmt.Errorf("%s: cyclic extends of %s", name, parentName)
+	}
+	parent, err := read(parentName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: error reading extended config %s: %v", name, parentName, err)
+	}
+	if parent, err = resolveExtends(parent, parentName, read, visited); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	for _, id := range parent.GetBenchmarkId() {
+		ids[id] = true
+	}
+	removed := make(map[string]bool)
+	for _, id := range reduced.GetRemoveBenchmarkId() {
+		if !ids[id] {
+			return nil, fmt.Errorf("%s: removed benchmark %s isn't used by %s", name, id, parentName)
+		}
+		removed[id] = true
+	}
+
+	result := &apb.PerOsBenchmarkConfig{Version: reduced.GetVersion()}
+	used := make(map[string]bool)
+	for _, id := range parent.GetBenchmarkId() {
+		if !removed[id] {
+			result.BenchmarkId = append(result.BenchmarkId, id)
+			used[id] = true
+		}
+	}
+	for _, id := range reduced.GetAddBenchmarkId() {
+		if used[id] {
+			return nil, fmt.Errorf("%s: added benchmark %s is already used", name, id)
+		}
+		result.BenchmarkId = append(result.BenchmarkId, id)
+		used[id] = true
+	}
+
+	// The config's own overrides replace the inherited ones for the same IDs.
+	overridden := make(map[string]bool)
+	for _, o := range reduced.GetProfileLevelOverride() {
+		for _, id := range o.GetBenchmarkId() {
+			overridden[id] = true
+		}
+	}
+	for _, o := range parent.GetProfileLevelOverride() {
+		inherited := &apb.ProfileLevelOverride{Level: o.GetLevel()}
+		for _, id := range o.GetBenchmarkId() {
+			if !removed[id] && !overridden[id] {
+				inherited.BenchmarkId = append(inherited.BenchmarkId, id)
+			}
+		}
+		if len(inherited.BenchmarkId) > 0 {
+			result.ProfileLevelOverride = append(result.ProfileLevelOverride, inherited)
+		}
+	}
+	for _, o := range reduced.GetProfileLevelOverride() {
+		result.ProfileLevelOverride = append(result.ProfileLevelOverride, proto.Clone(o).(*apb.ProfileLevelOverride))
+	}
+	return result, nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genfullconfiglib_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	gpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/configs/genfullconfig/genfullconfiglib"
+	"github.com/google/localtoast/protofilehandler"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+var (
+	baseVersion = &gpb.ComplianceVersion{CpeUri: "cpe:/o:cos:cos_linux:113", Version: "1.0.0"}
+	newVersion  = &gpb.ComplianceVersion{CpeUri: "cpe:/o:cos:cos_linux:117", Version: "1.0.0"}
+)
+
+func readFromMap(configs map[string]*apb.PerOsBenchmarkConfig) genfullconfiglib.ReducedConfigReader {
+	return func(name string) (*apb.PerOsBenchmarkConfig, error) {
+		config, ok := configs[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return config, nil
+	}
+}
+
+var baseConfigs = map[string]*apb.PerOsBenchmarkConfig{
+	"base/instance_scanning": {
+		Version:     baseVersion,
+		BenchmarkId: []string{"id1", "id2", "id3"},
+		ProfileLevelOverride: []*apb.ProfileLevelOverride{
+			{Level: 2, BenchmarkId: []string{"id1", "id2"}},
+		},
+	},
+	"middle/instance_scanning": {
+		Version:           baseVersion,
+		Extends:           "base/instance_scanning",
+		RemoveBenchmarkId: []string{"id3"},
+	},
+}
+
+func TestResolveExtends(t *testing.T) {
+	testCases := []struct {
+		description string
+		reduced     *apb.PerOsBenchmarkConfig
+		want        *apb.PerOsBenchmarkConfig
+	}{
+		{
+			description: "no extends",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, BenchmarkId: []string{"id1"}},
+			want:        &apb.PerOsBenchmarkConfig{Version: newVersion, BenchmarkId: []string{"id1"}},
+		},
+		{
+			description: "inherits benchmarks and overrides",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "base/instance_scanning"},
+			want: &apb.PerOsBenchmarkConfig{
+				Version:     newVersion,
+				BenchmarkId: []string{"id1", "id2", "id3"},
+				ProfileLevelOverride: []*apb.ProfileLevelOverride{
+					{Level: 2, BenchmarkId: []string{"id1", "id2"}},
+				},
+			},
+		},
+		{
+			description: "adds and removes benchmarks",
+			reduced: &apb.PerOsBenchmarkConfig{
+				Version:           newVersion,
+				Extends:           "base/instance_scanning",
+				AddBenchmarkId:    []string{"id4"},
+				RemoveBenchmarkId: []string{"id1"},
+			},
+			want: &apb.PerOsBenchmarkConfig{
+				Version:     newVersion,
+				BenchmarkId: []string{"id2", "id3", "id4"},
+				ProfileLevelOverride: []*apb.ProfileLevelOverride{
+					{Level: 2, BenchmarkId: []string{"id2"}},
+				},
+			},
+		},
+		{
+			description: "own overrides replace inherited ones",
+			reduced: &apb.PerOsBenchmarkConfig{
+				Version: newVersion,
+				Extends: "base/instance_scanning",
+				ProfileLevelOverride: []*apb.ProfileLevelOverride{
+					{Level: 1, BenchmarkId: []string{"id2"}},
+				},
+			},
+			want: &apb.PerOsBenchmarkConfig{
+				Version:     newVersion,
+				BenchmarkId: []string{"id1", "id2", "id3"},
+				ProfileLevelOverride: []*apb.ProfileLevelOverride{
+					{Level: 2, BenchmarkId: []string{"id1"}},
+					{Level: 1, BenchmarkId: []string{"id2"}},
+				},
+			},
+		},
+		{
+			description: "extends transitively",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "middle/instance_scanning"},
+			want: &apb.PerOsBenchmarkConfig{
+				Version:     newVersion,
+				BenchmarkId: []string{"id1", "id2"},
+				ProfileLevelOverride: []*apb.ProfileLevelOverride{
+					{Level: 2, BenchmarkId: []string{"id1", "id2"}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := genfullconfiglib.ResolveExtends(tc.reduced, "new/instance_scanning", readFromMap(baseConfigs))
+			if err != nil {
+				t.Fatalf("genfullconfiglib.ResolveExtends(%v) returned an error: %v", tc.reduced, err)
+			}
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Errorf("genfullconfiglib.ResolveExtends(%v) returned unexpected diff (-want +got):\n%s", tc.reduced, diff)
+			}
+		})
+	}
+}
+
+func TestResolveExtendsInvalidConfigReturnsError(t *testing.T) {
+	configs := map[string]*apb.PerOsBenchmarkConfig{
+		"a/instance_scanning": {Version: baseVersion, Extends: "b/instance_scanning"},
+		"b/instance_scanning": {Version: baseVersion, Extends: "a/instance_scanning"},
+	}
+	for name, config := range baseConfigs {
+		configs[name] = config
+	}
+	testCases := []struct {
+		description string
+		reduced     *apb.PerOsBenchmarkConfig
+	}{
+		{
+			description: "unknown extended config",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "cos_1/instance_scanning"},
+		},
+		{
+			description: "cyclic extends",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "a/instance_scanning"},
+		},
+		{
+			description: "benchmark_id set together with extends",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "base/instance_scanning", BenchmarkId: []string{"id4"}},
+		},
+		{
+			description: "add_benchmark_id without extends",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, AddBenchmarkId: []string{"id4"}},
+		},
+		{
+			description: "adding a used benchmark",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "base/instance_scanning", AddBenchmarkId: []string{"id1"}},
+		},
+		{
+			description: "removing an unused benchmark",
+			reduced:     &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "middle/instance_scanning", RemoveBenchmarkId: []string{"id3"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if _, err := genfullconfiglib.ResolveExtends(tc.reduced, "new/instance_scanning", readFromMap(configs)); err == nil {
+				t.Errorf("genfullconfiglib.ResolveExtends(%v) didn't return an error", tc.reduced)
+			}
+		})
+	}
+}
+
+func TestGenerateResolvesExtends(t *testing.T) {
+	dirs := createTestDirs(t)
+	reducedPath, defPath, outPath := getDefaultConfigPaths(dirs)
+	baseDirPath := path.Join(dirs.testDirPath, "base")
+	if err := os.Mkdir(baseDirPath, 0744); err != nil {
+		t.Fatalf("error while creating directory %s: %v", baseDirPath, err)
+	}
+	writeReducedConfigToFile(t, path.Join(baseDirPath, "instance_scanning.textproto"), "id", baseVersion)
+	reduced := &apb.PerOsBenchmarkConfig{Version: newVersion, Extends: "base/instance_scanning"}
+	if err := protofilehandler.WriteProtoToFile(reducedPath, reduced); err != nil {
+		t.Fatalf("protofilehandler.WriteProtoToFile(%s, %v) returned an error: %v", reducedPath, reduced, err)
+	}
+	writeConfigDefToFile(t, defPath, "id", []*gpb.ComplianceVersion{baseVersion, newVersion}, "generic:{check_alternatives:{}}")
+
+	if err := genfullconfiglib.Generate([]string{reducedPath, defPath}, []string{outPath}, false); err != nil {
+		t.Fatalf("genfullconfiglib.Generate([%v, %v], [%v], false) returned an error: %v", reducedPath, defPath, outPath, err)
+	}
+
+	got := &apb.ScanConfig{}
+	if err := protofilehandler.ReadProtoFromFile(outPath, got); err != nil {
+		t.Fatalf("protofilehandler.ReadProtoFromFile(%s, %v) returned an error: %v", outPath, got, err)
+	}
+	want := createTestScanConfig("id", []*gpb.ComplianceVersion{newVersion}, "check_alternatives:{}", 1)
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("genfullconfiglib.Generate([%v, %v], [%v], false) returned unexpected diff (-want +got):\n%s",
+			reducedPath, defPath, outPath, diff)
+	}
+}
+
+func TestReducedConfigName(t *testing.T) {
+	for _, tc := range []struct {
+		configPath string
+		want       string
+	}{
+		{configPath: "configs/reduced/cos_113/instance_scanning.textproto", want: "cos_113/instance_scanning"},
+		{configPath: "reduced/fallback/container_image_scanning.textproto", want: "fallback/container_image_scanning"},
+	} {
+		if got := genfullconfiglib.ReducedConfigName(tc.configPath); got != tc.want {
+			t.Errorf("genfullconfiglib.ReducedConfigName(%q) = %q, want %q", tc.configPath, got, tc.want)
+		}
+	}
+}
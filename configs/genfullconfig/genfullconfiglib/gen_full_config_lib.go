@@ -73,7 +73,11 @@ func Generate(inPaths []string, outPaths []string, omitDescriptions bool) error
 		}
 	}
 
-	configDefs, err := createConfigDefMap(configDefPaths)
+	configDefs, err := readConfigDefs(configDefPaths)
+	if err != nil {
+		return fmt.Errorf("error fetching config definitions: %v", err)
+	}
+	defMap, err := createConfigDefMap(configDefs)
 	if err != nil {
 		return fmt.Errorf("error fetching config definitions: %v", err)
 	}
@@ -84,16 +88,22 @@ func Generate(inPaths []string, outPaths []string, omitDescriptions bool) error
 		if err := protofilehandler.ReadProtoFromFile(p, reduced); err != nil {
 			return err
 		}
-		scanType, err := getScanTypeFromFileName(path.Base(p))
+		// The extended configs are in the same directory structure, e.g.
+		// reduced/cos_113/instance_scanning.textproto.
+		reducedDir := path.Dir(path.Dir(p))
+		reduced, err = ResolveExtends(reduced, ReducedConfigName(p), func(name string) (*apb.PerOsBenchmarkConfig, error) {
+			parent := &apb.PerOsBenchmarkConfig{}
+			if err := protofilehandler.ReadProtoFromFile(path.Join(reducedDir, name+path.Ext(p)), parent); err != nil {
+				return nil, err
+			}
+			return parent, nil
+		})
 		if err != nil {
 			return err
 		}
-		config, err := getFullConfig(reduced, configDefs, scanType)
-		if omitDescriptions {
-			removeDescriptionFields(config)
-		}
+		config, err := createFullConfig(reduced, path.Base(p), defMap, omitDescriptions)
 		if err != nil {
-			return fmt.Errorf("error fetching full config: %v", err)
+			return err
 		}
 		if err = protofilehandler.WriteProtoToFile(outPath, config); err != nil {
 			return fmt.Errorf("error writing %s: %v", outPath, err)
@@ -102,6 +112,33 @@ func Generate(inPaths []string, outPaths []string, omitDescriptions bool) error
 	return nil
 }
 
+// CreateFullConfig creates the full scan config for a reduced per-OS config
+// from the given config definitions. The scan type is determined by the file
+// name of the reduced config, e.g. "instance_scanning.textproto". The reduced
+// config's extends have to be resolved with ResolveExtends first.
+func CreateFullConfig(reduced *apb.PerOsBenchmarkConfig, reducedFileName string, configDefs []*apb.ScanConfig, omitDescriptions bool) (*apb.ScanConfig, error) {
+	defMap, err := createConfigDefMap(configDefs)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching config definitions: %v", err)
+	}
+	return createFullConfig(reduced, reducedFileName, defMap, omitDescriptions)
+}
+
+func createFullConfig(reduced *apb.PerOsBenchmarkConfig, reducedFileName string, defMap configDefMap, omitDescriptions bool) (*apb.ScanConfig, error) {
+	scanType, err := getScanTypeFromFileName(reducedFileName)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getFullConfig(reduced, defMap, scanType)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching full config: %v", err)
+	}
+	if omitDescriptions {
+		removeDescriptionFields(config)
+	}
+	return config, nil
+}
+
 func getScanTypeFromFileName(name string) (scanTypeEnum, error) {
 	switch name {
 	case "instance_scanning.textproto":
@@ -115,13 +152,21 @@ func getScanTypeFromFileName(name string) (scanTypeEnum, error) {
 	}
 }
 
-func createConfigDefMap(configDefPaths []string) (configDefMap, error) {
-	defs := make(configDefMap)
+func readConfigDefs(configDefPaths []string) ([]*apb.ScanConfig, error) {
+	result := make([]*apb.ScanConfig, 0, len(configDefPaths))
 	for _, p := range configDefPaths {
 		benchmarkDefs := &apb.ScanConfig{}
 		if err := protofilehandler.ReadProtoFromFile(p, benchmarkDefs); err != nil {
 			return nil, err
 		}
+		result = append(result, benchmarkDefs)
+	}
+	return result, nil
+}
+
+func createConfigDefMap(configDefs []*apb.ScanConfig) (configDefMap, error) {
+	defs := make(configDefMap)
+	for _, benchmarkDefs := range configDefs {
 		for _, b := range benchmarkDefs.BenchmarkConfigs {
 			for _, v := range b.ComplianceNote.Version {
 				key, err := createConfigDefKey(b.Id, v)
@@ -144,6 +189,9 @@ func createConfigDefKey(id string, version *cpb.ComplianceVersion) (*configDefKe
 }
 
 func getFullConfig(reduced *apb.PerOsBenchmarkConfig, configDefs configDefMap, scanType scanTypeEnum) (*apb.ScanConfig, error) {
+	if reduced.GetExtends() != "" {
+		return nil, fmt.Errorf("extends of %s isn't resolved, see ResolveExtends", reduced.GetExtends())
+	}
 	levelOverrides := make(map[string]int32)
 	for _, o := range reduced.ProfileLevelOverride {
 		for _, id := range o.BenchmarkId {
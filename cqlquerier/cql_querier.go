@@ -25,3 +25,39 @@ func Query(ctx context.Context, db *gocql.Session, query string) (string, error)
 	}
 	return result, nil
 }
+
+// QueryRows executes the query and returns the first column of every result
+// row.
+func QueryRows(ctx context.Context, db *gocql.Session, query string) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("no cassandra database specified. Please provide one using the --cassandra flag")
+	}
+	iter := db.Query(query).Iter()
+	if len(iter.Columns()) == 0 {
+		return []string{}, iter.Close()
+	}
+	// The scanner needs a destination for every column, so the other columns
+	// are scanned into values of their own types.
+	row, err := iter.RowData()
+	if err != nil {
+		iter.Close()
+		return nil, err
+	}
+	var value string
+	dest := row.Values
+	dest[0] = &value
+	scanner := iter.Scanner()
+	result := []string{}
+	for scanner.Next() {
+		// NULL values are scanned as "".
+		value = ""
+		if err := scanner.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
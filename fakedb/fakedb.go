@@ -27,10 +27,14 @@ import (
 // Queries supported by the fake database. Each query will return a different number
 // of rows, but no actual content.
 const (
-	QueryNoRows = "SELECT 1 WHERE FALSE"
-	QueryOneRow = "SELECT 1"
-	QueryError  = "INVALID QUERY"
-	ErrorMsg    = "invalid query"
+	QueryNoRows    = "SELECT 1 WHERE FALSE"
+	QueryOneRow    = "SELECT 1"
+	QueryThreeRows = "SELECT 1 UNION ALL SELECT 2 UNION ALL SELECT 3"
+	// QueryTwoColumns returns two rows of two columns. The first column of the
+	// second row is NULL.
+	QueryTwoColumns = "SELECT 1, 2 UNION ALL SELECT NULL, 3"
+	QueryError      = "INVALID QUERY"
+	ErrorMsg        = "invalid query"
 )
 
 func init() {
@@ -91,6 +95,14 @@ func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
 		return &fakeRows{rowsLeft: 0}, nil
 	case QueryOneRow:
 		return &fakeRows{rowsLeft: 1, columns: []string{"fakeColumn"}}, nil
+	case QueryThreeRows:
+		return &fakeRows{rowsLeft: 3, columns: []string{"fakeColumn"}}, nil
+	case QueryTwoColumns:
+		return &fakeRows{
+			rowsLeft: 2,
+			columns:  []string{"fakeColumn", "otherColumn"},
+			values:   [][]driver.Value{{"fakeValue", "otherValue"}, {nil, "otherValue"}},
+		}, nil
 	case QueryError:
 		return nil, errors.New(ErrorMsg)
 	default:
@@ -99,10 +111,13 @@ func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 // fakeRows is a fake implementation of driver.Rows.
-// We only care about the number of returned rows, so we don't model their content.
+// Unless values are given, we only care about the number of returned rows, so
+// we don't model their content.
 type fakeRows struct {
 	rowsLeft int
 	columns  []string
+	// If set, the values of each row.
+	values [][]driver.Value
 }
 
 // Close is a fake implementation for the driver.Rows interface.
@@ -116,6 +131,10 @@ func (r *fakeRows) Columns() []string { return r.columns }
 func (r *fakeRows) Next(dst []driver.Value) error {
 	if r.rowsLeft > 0 {
 		r.rowsLeft--
+		if r.values != nil {
+			copy(dst, r.values[len(r.values)-r.rowsLeft-1])
+			return nil
+		}
 		dst[0] = "fakeValue"
 		return nil
 	}
@@ -25,6 +25,7 @@ import (
 	"strings"
 	"syscall"
 
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"github.com/google/localtoast/scanapi"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 )
@@ -139,6 +140,73 @@ func FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, e
 	}, nil
 }
 
+// FileStat returns the type, link target and other metadata of the specified
+// file or directory. Symlinks aren't followed.
+func FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+	result := &apb.FileStat{
+		Type:   fileType(fi.Mode()),
+		Nlink:  int64(st.Nlink),
+		Size:   fi.Size(),
+		Mtime:  timestamppb.New(fi.ModTime()),
+		Inode:  st.Ino,
+		Device: uint64(st.Dev),
+	}
+	if result.Type == apb.FileStat_SYMLINK {
+		if result.LinkTarget, err = os.Readlink(path); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// FileXattr returns the value of the given extended attribute of the specified
+// file or directory or nil if the attribute isn't set. Symlinks aren't
+// followed.
+func FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	for {
+		size, err := lgetxattr(path, name, nil)
+		if err != nil {
+			return nil, xattrError(path, err)
+		}
+		value := make([]byte, size)
+		size, err = lgetxattr(path, name, value)
+		if err == syscall.ERANGE {
+			// The attribute grew since we've checked its size.
+			continue
+		}
+		if err != nil {
+			return nil, xattrError(path, err)
+		}
+		return value[:size], nil
+	}
+}
+
+func fileType(mode fs.FileMode) apb.FileStat_FileType {
+	switch {
+	case mode.IsRegular():
+		return apb.FileStat_REGULAR_FILE
+	case mode.IsDir():
+		return apb.FileStat_DIRECTORY
+	case mode&fs.ModeSymlink != 0:
+		return apb.FileStat_SYMLINK
+	case mode&fs.ModeNamedPipe != 0:
+		return apb.FileStat_FIFO
+	case mode&fs.ModeSocket != 0:
+		return apb.FileStat_SOCKET
+	case mode&fs.ModeCharDevice != 0:
+		return apb.FileStat_CHAR_DEVICE
+	case mode&fs.ModeDevice != 0:
+		return apb.FileStat_BLOCK_DEVICE
+	default:
+		return apb.FileStat_UNKNOWN
+	}
+}
+
 // OpenDir opens the specified directory to list its content.
 func OpenDir(ctx context.Context, dirPath string) (scanapi.DirReader, error) {
 	f, err := os.Open(dirPath)
@@ -21,6 +21,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"strconv"
+	"syscall"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -253,3 +254,66 @@ func TestFilePermissionsDontChange(t *testing.T) {
 			testFilePath, perm2, perm1)
 	}
 }
+
+func TestFileStat(t *testing.T) {
+	testDirPath := createTestFiles(t)
+	fifoName := "fifo"
+	if err := syscall.Mkfifo(filepath.Join(testDirPath, fifoName), filePermission); err != nil {
+		t.Fatalf("error creating FIFO %s: %v", filepath.Join(testDirPath, fifoName), err)
+	}
+	testCases := []struct {
+		name           string
+		wantType       apb.FileStat_FileType
+		wantLinkTarget string
+	}{
+		{name: fileName, wantType: apb.FileStat_REGULAR_FILE},
+		{name: dirName, wantType: apb.FileStat_DIRECTORY},
+		{name: fileSymlinkName, wantType: apb.FileStat_SYMLINK, wantLinkTarget: filepath.Join(testDirPath, fileName)},
+		{name: fifoName, wantType: apb.FileStat_FIFO},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(testDirPath, tc.name)
+			stat, err := localfilereader.FileStat(context.Background(), path)
+			if err != nil {
+				t.Fatalf("localfilereader.FileStat(%s) had unexpected error: %v", path, err)
+			}
+			if stat.GetType() != tc.wantType {
+				t.Errorf("localfilereader.FileStat(%s) returned type %v, expected %v", path, stat.GetType(), tc.wantType)
+			}
+			if stat.GetLinkTarget() != tc.wantLinkTarget {
+				t.Errorf("localfilereader.FileStat(%s) returned link target %q, expected %q",
+					path, stat.GetLinkTarget(), tc.wantLinkTarget)
+			}
+			if stat.GetInode() == 0 || stat.GetMtime() == nil {
+				t.Errorf("localfilereader.FileStat(%s) returned %v, expected the inode and mtime to be set", path, stat)
+			}
+		})
+	}
+}
+
+func TestFileStatHardLinks(t *testing.T) {
+	testDirPath := createTestFiles(t)
+	testFilePath := filepath.Join(testDirPath, fileName)
+	if err := os.Link(testFilePath, filepath.Join(testDirPath, "hardlink")); err != nil {
+		t.Fatalf("error creating hard link: %v", err)
+	}
+	stat, err := localfilereader.FileStat(context.Background(), testFilePath)
+	if err != nil {
+		t.Fatalf("localfilereader.FileStat(%s) had unexpected error: %v", testFilePath, err)
+	}
+	if stat.GetNlink() != 2 {
+		t.Errorf("localfilereader.FileStat(%s) returned nlink %d, expected 2", testFilePath, stat.GetNlink())
+	}
+	if stat.GetSize() != int64(len(fileContent)) {
+		t.Errorf("localfilereader.FileStat(%s) returned size %d, expected %d", testFilePath, stat.GetSize(), len(fileContent))
+	}
+}
+
+func TestFileStatPropagatesError(t *testing.T) {
+	testDirPath := createTestFiles(t)
+	nonExistentFilePath := filepath.Join(testDirPath, "non-existent-file")
+	if _, err := localfilereader.FileStat(context.Background(), nonExistentFilePath); !os.IsNotExist(err) {
+		t.Errorf("localfilereader.FileStat(%s) returned %v, expected a not exist error", nonExistentFilePath, err)
+	}
+}
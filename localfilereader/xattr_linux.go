@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localfilereader
+
+import (
+	"io/fs"
+	"syscall"
+	"unsafe"
+)
+
+// lgetxattr is like syscall.Getxattr but doesn't follow symlinks. The syscall
+// package doesn't provide it.
+func lgetxattr(path string, name string, dest []byte) (int, error) {
+	pathPtr, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	namePtr, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	var destPtr unsafe.Pointer
+	if len(dest) > 0 {
+		destPtr = unsafe.Pointer(&dest[0])
+	}
+	size, _, errno := syscall.Syscall6(syscall.SYS_LGETXATTR, uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(namePtr)), uintptr(destPtr), uintptr(len(dest)), 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(size), nil
+}
+
+func xattrError(path string, err error) error {
+	if err == syscall.ENODATA || err == syscall.ENOTSUP {
+		return nil
+	}
+	return &fs.PathError{Op: "lgetxattr", Path: path, Err: err}
+}
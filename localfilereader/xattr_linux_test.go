@@ -0,0 +1,77 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localfilereader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/google/localtoast/localfilereader"
+	"github.com/google/localtoast/scanapi"
+)
+
+func TestFileXattr(t *testing.T) {
+	testDirPath := createTestFiles(t)
+	testFilePath := filepath.Join(testDirPath, fileName)
+	if err := syscall.Setxattr(testFilePath, "user.localtoast", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes not supported by the test filesystem: %v", err)
+	}
+	got, err := localfilereader.FileXattr(context.Background(), testFilePath, "user.localtoast")
+	if err != nil {
+		t.Fatalf("localfilereader.FileXattr(%s) had unexpected error: %v", testFilePath, err)
+	}
+	if string(got) != "value" {
+		t.Errorf("localfilereader.FileXattr(%s) returned %q, expected %q", testFilePath, got, "value")
+	}
+}
+
+func TestFileXattrDoesntFollowSymlinks(t *testing.T) {
+	testDirPath := createTestFiles(t)
+	testFilePath := filepath.Join(testDirPath, fileName)
+	if err := syscall.Setxattr(testFilePath, "user.localtoast", []byte("value"), 0); err != nil {
+		t.Skipf("extended attributes not supported by the test filesystem: %v", err)
+	}
+	symlinkPath := filepath.Join(testDirPath, fileSymlinkName)
+	got, err := localfilereader.FileXattr(context.Background(), symlinkPath, "user.localtoast")
+	if err != nil {
+		t.Fatalf("localfilereader.FileXattr(%s) had unexpected error: %v", symlinkPath, err)
+	}
+	if got != nil {
+		t.Errorf("localfilereader.FileXattr(%s) returned %q, expected the symlink's own attributes", symlinkPath, got)
+	}
+}
+
+func TestFileXattrNotSet(t *testing.T) {
+	testDirPath := createTestFiles(t)
+	testFilePath := filepath.Join(testDirPath, fileName)
+	got, err := localfilereader.FileXattr(context.Background(), testFilePath, "user.nonexistent")
+	if err != nil {
+		t.Fatalf("localfilereader.FileXattr(%s) had unexpected error: %v", testFilePath, err)
+	}
+	if got != nil {
+		t.Errorf("localfilereader.FileXattr(%s) returned %q, expected nil", testFilePath, got)
+	}
+}
+
+func TestFileXattrPropagatesError(t *testing.T) {
+	testDirPath := createTestFiles(t)
+	nonExistentFilePath := filepath.Join(testDirPath, "non-existent-file")
+	if _, err := localfilereader.FileXattr(context.Background(), nonExistentFilePath, scanapi.PosixACLAccessXattr); !os.IsNotExist(err) {
+		t.Errorf("localfilereader.FileXattr(%s) returned %v, expected a not exist error", nonExistentFilePath, err)
+	}
+}
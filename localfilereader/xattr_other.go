@@ -0,0 +1,31 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package localfilereader
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// lgetxattr is only implemented on Linux.
+func lgetxattr(path string, name string, dest []byte) (int, error) {
+	return 0, errors.New("extended attributes are unsupported on this platform")
+}
+
+func xattrError(path string, err error) error {
+	return &fs.PathError{Op: "lgetxattr", Path: path, Err: err}
+}
@@ -55,11 +55,24 @@ func (a *localScanAPIProvider) FilePermissions(ctx context.Context, filePath str
 	return localfilereader.FilePermissions(ctx, a.fullPath(filePath))
 }
 
+func (a *localScanAPIProvider) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	return localfilereader.FileStat(ctx, a.fullPath(filePath))
+}
+
+func (a *localScanAPIProvider) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return localfilereader.FileXattr(ctx, a.fullPath(filePath), name)
+}
+
 func (localScanAPIProvider) SQLQuery(ctx context.Context, query string) (string, error) {
 	// This is intentionally not implemented for the scanner version without SQL.
 	return "", errors.New("not implemented")
 }
 
+func (localScanAPIProvider) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	// This is intentionally not implemented for the scanner version without SQL.
+	return nil, errors.New("not implemented")
+}
+
 func (localScanAPIProvider) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	// This is intentionally not implemented for the scanner version without SQL.
 	return ipb.SQLCheck_DB_UNSPECIFIED, errors.New("not implemented")
@@ -71,6 +84,9 @@ func main() {
 	if os.Getenv("GOGC") == "" {
 		debug.SetGCPercent(1)
 	}
+	if len(os.Args) > 1 && os.Args[1] == scannercommon.LintCommand {
+		os.Exit(scannercommon.RunLint(os.Args[2:], os.Stdout))
+	}
 	flags := scannercommon.ParseFlags()
 	provider := &localScanAPIProvider{chrootPath: flags.ChrootPath}
 	os.Exit(scannercommon.RunScan(flags, provider))
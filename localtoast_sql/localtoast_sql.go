@@ -76,6 +76,14 @@ func (a *localScanAPIProvider) FilePermissions(ctx context.Context, filePath str
 	return localfilereader.FilePermissions(ctx, a.fullPath(filePath))
 }
 
+func (a *localScanAPIProvider) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	return localfilereader.FileStat(ctx, a.fullPath(filePath))
+}
+
+func (a *localScanAPIProvider) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return localfilereader.FileXattr(ctx, a.fullPath(filePath), name)
+}
+
 func (a *localScanAPIProvider) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	if a.dbtype == ipb.SQLCheck_DB_UNSPECIFIED {
 		return a.dbtype, errors.New("no database specified")
@@ -100,7 +108,28 @@ func (a *localScanAPIProvider) SQLQuery(ctx context.Context, query string) (stri
 	return "", errors.New("no database specified. Please provide one using --mysql-database, --cassandra-database or --elasticsearch-database flags")
 }
 
+func (a *localScanAPIProvider) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	dbtype, err := a.SupportedDatabase()
+	if err != nil {
+		return nil, err
+	}
+	if dbtype == ipb.SQLCheck_DB_MYSQL {
+		return sqlquerier.QueryRows(ctx, a.sqldb, query)
+	}
+	if dbtype == ipb.SQLCheck_DB_CASSANDRA {
+		return cqlquerier.QueryRows(ctx, a.cqldb, query)
+	}
+	if dbtype == ipb.SQLCheck_DB_ELASTICSEARCH {
+		// ElasticSearch responses aren't split into rows.
+		return nil, errors.New("querying rows is unsupported for ElasticSearch")
+	}
+	return nil, errors.New("no database specified. Please provide one using --mysql-database, --cassandra-database or --elasticsearch-database flags")
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == scannercommon.LintCommand {
+		os.Exit(scannercommon.RunLint(os.Args[2:], os.Stdout))
+	}
 	flags := scannercommon.ParseFlags()
 
 	var sqldb *sql.DB
@@ -0,0 +1,76 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanapi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// PosixACLAccessXattr is the extended attribute that stores the access ACL of a file.
+const PosixACLAccessXattr = "system.posix_acl_access"
+
+const (
+	posixACLXattrVersion   = 2
+	posixACLXattrEntrySize = 8
+)
+
+// The tag values of the ACL entries in the extended attribute.
+var posixACLTags = map[uint16]apb.PosixAcl_Entry_Tag{
+	0x01: apb.PosixAcl_Entry_USER_OBJ,
+	0x02: apb.PosixAcl_Entry_USER,
+	0x04: apb.PosixAcl_Entry_GROUP_OBJ,
+	0x08: apb.PosixAcl_Entry_GROUP,
+	0x10: apb.PosixAcl_Entry_MASK,
+	0x20: apb.PosixAcl_Entry_OTHER,
+}
+
+// FileACL returns the access ACL of the specified file or directory.
+// Returns nil if the file has no ACL set.
+func FileACL(ctx context.Context, fs Filesystem, path string) (*apb.PosixAcl, error) {
+	value, err := fs.FileXattr(ctx, path, PosixACLAccessXattr)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return ParsePosixACL(value)
+}
+
+// ParsePosixACL parses the value of the system.posix_acl_access extended
+// attribute: A little-endian version header followed by (tag, permissions, id)
+// entries.
+func ParsePosixACL(value []byte) (*apb.PosixAcl, error) {
+	if len(value) < 4 || (len(value)-4)%posixACLXattrEntrySize != 0 {
+		return nil, fmt.Errorf("invalid POSIX ACL of size %d", len(value))
+	}
+	if v := binary.LittleEndian.Uint32(value); v != posixACLXattrVersion {
+		return nil, fmt.Errorf("unsupported POSIX ACL version %d", v)
+	}
+	acl := &apb.PosixAcl{}
+	for i := 4; i < len(value); i += posixACLXattrEntrySize {
+		tag := binary.LittleEndian.Uint16(value[i:])
+		entry := &apb.PosixAcl_Entry{
+			Tag:         posixACLTags[tag],
+			Permissions: int32(binary.LittleEndian.Uint16(value[i+2:])),
+		}
+		if entry.Tag == apb.PosixAcl_Entry_USER || entry.Tag == apb.PosixAcl_Entry_GROUP {
+			entry.Id = int64(binary.LittleEndian.Uint32(value[i+4:]))
+		}
+		acl.Entries = append(acl.Entries, entry)
+	}
+	return acl, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanapi_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+func TestParsePosixACL(t *testing.T) {
+	// user::rw- user:1001:rwx group::r-- mask::r-x other::---
+	value := []byte{
+		0x02, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x06, 0x00, 0xff, 0xff, 0xff, 0xff,
+		0x02, 0x00, 0x07, 0x00, 0xe9, 0x03, 0x00, 0x00,
+		0x04, 0x00, 0x04, 0x00, 0xff, 0xff, 0xff, 0xff,
+		0x10, 0x00, 0x05, 0x00, 0xff, 0xff, 0xff, 0xff,
+		0x20, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff,
+	}
+	want := &apb.PosixAcl{Entries: []*apb.PosixAcl_Entry{
+		{Tag: apb.PosixAcl_Entry_USER_OBJ, Permissions: 6},
+		{Tag: apb.PosixAcl_Entry_USER, Permissions: 7, Id: 1001},
+		{Tag: apb.PosixAcl_Entry_GROUP_OBJ, Permissions: 4},
+		{Tag: apb.PosixAcl_Entry_MASK, Permissions: 5},
+		{Tag: apb.PosixAcl_Entry_OTHER, Permissions: 0},
+	}}
+	got, err := scanapi.ParsePosixACL(value)
+	if err != nil {
+		t.Fatalf("scanapi.ParsePosixACL(%v) had unexpected error: %v", value, err)
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("scanapi.ParsePosixACL(%v) returned unexpected diff (-want +got):\n%s", value, diff)
+	}
+}
+
+func TestParsePosixACLInvalidValueReturnsError(t *testing.T) {
+	for _, value := range [][]byte{
+		{0x02, 0x00},
+		{0x02, 0x00, 0x00, 0x00, 0x01, 0x00},
+		{0x01, 0x00, 0x00, 0x00},
+	} {
+		if _, err := scanapi.ParsePosixACL(value); err == nil {
+			t.Errorf("scanapi.ParsePosixACL(%v) didn't return an error", value)
+		}
+	}
+}
@@ -41,6 +41,15 @@ type Filesystem interface {
 	OpenFile(ctx context.Context, path string) (io.ReadCloser, error)
 	// FilePermissions returns unix permission-related data for the specified file or directory.
 	FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, error)
+	// FileStat returns the type, link target and other metadata of the specified
+	// file or directory. Symlinks aren't followed.
+	// It should return an os.IsNotExist error if the file doesn't exist.
+	FileStat(ctx context.Context, path string) (*apb.FileStat, error)
+	// FileXattr returns the value of the given extended attribute of the
+	// specified file or directory, e.g. "system.posix_acl_access". Symlinks
+	// aren't followed, so the attributes of a symlink itself are returned.
+	// It should return a nil value and no error if the attribute isn't set.
+	FileXattr(ctx context.Context, path string, name string) ([]byte, error)
 	// OpenDir opens the specified directory to list its content.
 	OpenDir(ctx context.Context, path string) (DirReader, error)
 }
@@ -50,6 +59,9 @@ type SQLQuerier interface {
 	// SQLQuery executes SQL queries to a target SQL database and returns first result
 	// row as a string.
 	SQLQuery(ctx context.Context, query string) (string, error)
+	// SQLQueryRows executes SQL queries to a target SQL database and returns the
+	// first column of every result row as a string.
+	SQLQueryRows(ctx context.Context, query string) ([]string, error)
 	// Returns the supported database type
 	SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error)
 }
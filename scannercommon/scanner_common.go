@@ -18,13 +18,18 @@ package scannercommon
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"os"
 	"strings"
 
 	durationpb "google.golang.org/protobuf/types/known/durationpb"
 	"github.com/google/localtoast/cli"
+	"github.com/google/localtoast/configs"
 	"github.com/google/localtoast/protofilehandler"
 	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/configchecks"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 	"github.com/google/localtoast/scannerlib"
 )
@@ -32,6 +37,15 @@ import (
 // ParseFlags parses the scanner binary's cli flags.
 func ParseFlags() *cli.Flags {
 	configFile := flag.String("config", "", "The path of the scan config file")
+	autoConfig := flag.Bool("auto-config", false,
+		"Detect the OS of the scanned machine and use the matching embedded scan config instead of --config")
+	benchmarkSet := flag.String("benchmark-set", "",
+		"The name of the embedded benchmark set to scan for instead of --config, e.g. ubuntu_22")
+	scanType := flag.String("scan-type", "",
+		"The scan type of the embedded config to use: instance, vm_image or container_image. "+
+			"Defaults to instance for --benchmark-set and is detected for --auto-config")
+	listBenchmarkSets := flag.Bool("list-benchmark-sets", false,
+		"List the embedded benchmark sets and their scan types, then exit")
 	resultFile := flag.String("result", "", "The path of the output scan result file")
 	chrootPath := flag.String("chroot", "",
 		"A path that will be prefixed to the paths of the files to be checked. "+
@@ -60,6 +74,10 @@ func ParseFlags() *cli.Flags {
 	flag.Parse()
 	flags := &cli.Flags{
 		ConfigFile:              *configFile,
+		AutoConfig:              *autoConfig,
+		BenchmarkSet:            *benchmarkSet,
+		ScanType:                *scanType,
+		ListBenchmarkSets:       *listBenchmarkSets,
 		ResultFile:              *resultFile,
 		ChrootPath:              *chrootPath,
 		MySQLDatabase:           *mySQLDatabase,
@@ -84,10 +102,15 @@ func ParseFlags() *cli.Flags {
 // RunScan executes the scan with the given CLI flags and API provider.
 // Returns the exit code that the main binary should exit with.
 func RunScan(flags *cli.Flags, api scanapi.ScanAPI) int {
-	log.Printf("Reading scan config from %s\n", flags.ConfigFile)
-	config := &apb.ScanConfig{}
-	if err := protofilehandler.ReadProtoFromFile(flags.ConfigFile, config); err != nil {
-		log.Fatalf("Error reading config file: %v\n", err)
+	if flags.ListBenchmarkSets {
+		if err := printBenchmarkSets(os.Stdout); err != nil {
+			log.Fatalf("Error listing the benchmark sets: %v\n", err)
+		}
+		return 0
+	}
+	config, err := readScanConfig(flags, api)
+	if err != nil {
+		log.Fatalf("Error reading scan config: %v\n", err)
 	}
 	ApplyCLIFlagsToConfig(config, flags)
 
@@ -119,6 +142,79 @@ func RunScan(flags *cli.Flags, api scanapi.ScanAPI) int {
 	return 0
 }
 
+// LintCommand is the name of the subcommand that validates a scan config
+// without scanning, e.g. "localtoast lint --config=config.textproto".
+const LintCommand = "lint"
+
+// RunLint validates the scan config given in the lint subcommand's args and
+// writes the problems found to w, one per line. Returns the exit code that
+// the main binary should exit with.
+func RunLint(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet(LintCommand, flag.ExitOnError)
+	configFile := fs.String("config", "", "The path of the scan config file to validate")
+	fs.Parse(args)
+	if *configFile == "" {
+		log.Printf("--config not set\n")
+		return 1
+	}
+	config := &apb.ScanConfig{}
+	if err := protofilehandler.ReadProtoFromFile(*configFile, config); err != nil {
+		log.Printf("Error reading scan config: %v\n", err)
+		return 1
+	}
+	problems := configchecks.Lint(config)
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		log.Printf("Found %d problems in %s\n", len(problems), *configFile)
+		return 2
+	}
+	return 0
+}
+
+// readScanConfig reads the scan config from the file given in the flags or
+// creates it from the embedded configs with --auto-config and --benchmark-set.
+func readScanConfig(flags *cli.Flags, api scanapi.ScanAPI) (*apb.ScanConfig, error) {
+	if flags.AutoConfig {
+		// Scans of a mounted disk are image scans.
+		config, configPath, err := configs.AutoConfig(context.Background(), api, flags.ChrootPath != "", flags.ScanType)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using embedded scan config %s\n", configPath)
+		return config, nil
+	}
+	if flags.BenchmarkSet != "" {
+		scanType := flags.ScanType
+		if scanType == "" {
+			scanType = "instance"
+		}
+		log.Printf("Using embedded benchmark set %s for %s scanning\n", flags.BenchmarkSet, scanType)
+		return configs.BenchmarkSetConfig(flags.BenchmarkSet, scanType)
+	}
+	log.Printf("Reading scan config from %s\n", flags.ConfigFile)
+	config := &apb.ScanConfig{}
+	if err := protofilehandler.ReadProtoFromFile(flags.ConfigFile, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// printBenchmarkSets writes the embedded benchmark sets to w, one per line.
+func printBenchmarkSets(w io.Writer) error {
+	sets, err := configs.ListBenchmarkSets()
+	if err != nil {
+		return err
+	}
+	for _, s := range sets {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.CpeURI, strings.Join(s.ScanTypes, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ApplyCLIFlagsToConfig applies the given CLI flags to the scan config.
 func ApplyCLIFlagsToConfig(config *apb.ScanConfig, flags *cli.Flags) {
 	config.BenchmarkConfigs = removeOptedOutBenchmarks(config.GetBenchmarkConfigs(), strings.Split(flags.BenchmarkOptOutIDs, ","))
@@ -15,12 +15,14 @@
 package scannercommon_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
 	"io/ioutil"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -53,10 +55,22 @@ func (testAPIProvider) FilePermissions(ctx context.Context, filePath string) (*a
 	return localfilereader.FilePermissions(ctx, path.Join(testDirPath, filePath))
 }
 
+func (testAPIProvider) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	return localfilereader.FileStat(ctx, path.Join(testDirPath, filePath))
+}
+
+func (testAPIProvider) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return localfilereader.FileXattr(ctx, path.Join(testDirPath, filePath), name)
+}
+
 func (testAPIProvider) SQLQuery(ctx context.Context, query string) (string, error) {
 	return "", errors.New("not implemented")
 }
 
+func (testAPIProvider) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (testAPIProvider) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	return ipb.SQLCheck_DB_UNSPECIFIED, errors.New("not implemented")
 }
@@ -138,6 +152,63 @@ func TestRunScan(t *testing.T) {
 	}
 }
 
+func TestRunLint(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.textproto")
+	testCases := []struct {
+		description      string
+		check            *ipb.FileCheck
+		expectedOutput   string
+		expectedExitCode int
+	}{
+		{
+			description: "valid config",
+			check: &ipb.FileCheck{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/file")},
+				CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+			},
+			expectedExitCode: 0,
+		},
+		{
+			description: "invalid regex",
+			check: &ipb.FileCheck{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/file")},
+				CheckType: &ipb.FileCheck_ContentEntry{ContentEntry: &ipb.ContentEntryCheck{
+					MatchType:     ipb.ContentEntryCheck_ALL_MATCH_ANY_ORDER,
+					MatchCriteria: []*ipb.MatchCriterion{{FilterRegex: "(", ExpectedRegex: "("}},
+				}},
+			},
+			expectedOutput:   "test: ",
+			expectedExitCode: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := &apb.ScanConfig{
+				BenchmarkConfigs: []*apb.BenchmarkConfig{
+					testconfigcreator.NewBenchmarkConfig(t, "test", testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{tc.check})),
+				},
+			}
+			if err := protofilehandler.WriteProtoToFile(configPath, config); err != nil {
+				t.Fatalf("Error writing scan config: %v", err)
+			}
+
+			output := &bytes.Buffer{}
+			args := []string{"--config=" + configPath}
+			exitCode := scannercommon.RunLint(args, output)
+			if exitCode != tc.expectedExitCode {
+				t.Errorf("scannercommon.RunLint(%v) returned unexpected exit code, want %d got %d", args, tc.expectedExitCode, exitCode)
+			}
+			if tc.expectedOutput == "" && output.Len() > 0 {
+				t.Errorf("scannercommon.RunLint(%v) printed unexpected problems: %q", args, output.String())
+			}
+			if !strings.HasPrefix(output.String(), tc.expectedOutput) {
+				t.Errorf("scannercommon.RunLint(%v) printed %q, expected it to start with %q", args, output.String(), tc.expectedOutput)
+			}
+		})
+	}
+}
+
 func TestApplyCLIFlagsToConfig(t *testing.T) {
 	testCases := []struct {
 		desc   string
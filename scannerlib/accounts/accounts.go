@@ -0,0 +1,299 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package accounts provides utilities for reading the user and group
+// databases of the scanned machine.
+package accounts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/localtoast/scanapi"
+)
+
+const (
+	// PasswdPath is the path of the user account database.
+	PasswdPath = "/etc/passwd"
+	// ShadowPath is the path of the shadowed password database.
+	ShadowPath = "/etc/shadow"
+	// GroupPath is the path of the group database.
+	GroupPath = "/etc/group"
+	// LoginDefsPath is the path of the shadow password suite configuration.
+	LoginDefsPath = "/etc/login.defs"
+	// UseraddDefaultsPath is the path of the default values for useradd.
+	UseraddDefaultsPath = "/etc/default/useradd"
+
+	defaultUIDMin = 1000
+)
+
+// A comment after a key-value pair, separated from the value by whitespace.
+var trailingCommentRe = regexp.MustCompile(`\s+#.*$`)
+
+// User is an entry of /etc/passwd.
+type User struct {
+	Name  string
+	UID   int
+	GID   int
+	Home  string
+	Shell string
+	// Set if the UID or GID of the entry can't be parsed. The unparsable
+	// fields are -1 in this case.
+	Err error
+}
+
+// HasLoginShell returns whether the user has a shell that allows logging in.
+func (u *User) HasLoginShell() bool {
+	return u.Shell != "/bin/false" && path.Base(u.Shell) != "nologin"
+}
+
+// ShadowEntry is an entry of /etc/shadow. Numeric fields that are left
+// empty in the file are set to -1.
+type ShadowEntry struct {
+	Name         string
+	PasswordHash string
+	// The date of the last password change in days since the epoch.
+	LastChange int
+	MinDays    int
+	MaxDays    int
+	WarnDays   int
+	// The number of days after password expiry until the account is disabled.
+	InactiveDays int
+	// The date of the account expiry in days since the epoch.
+	Expire int
+}
+
+// IsLocked returns whether the password of the account is locked.
+func (e *ShadowEntry) IsLocked() bool {
+	return strings.HasPrefix(e.PasswordHash, "!") || strings.HasPrefix(e.PasswordHash, "*")
+}
+
+// Group is an entry of /etc/group.
+type Group struct {
+	Name    string
+	GID     int
+	Members []string
+}
+
+// UIDRanges are the UID ranges defined in /etc/login.defs. SysUIDMin and
+// SysUIDMax are -1 if they're not defined.
+type UIDRanges struct {
+	UIDMin    int
+	SysUIDMin int
+	SysUIDMax int
+}
+
+// IsSystemUID returns whether the given UID belongs to a system user.
+func (r *UIDRanges) IsSystemUID(uid int) bool {
+	if r.SysUIDMin == -1 || r.SysUIDMax == -1 {
+		// Non-system users' uid starts from UID_MIN.
+		return uid < r.UIDMin
+	}
+	return uid >= r.SysUIDMin && uid <= r.SysUIDMax
+}
+
+// ReadPasswd parses the users from /etc/passwd.
+func ReadPasswd(ctx context.Context, fs scanapi.Filesystem) ([]*User, error) {
+	lines, err := readColonSeparatedFile(ctx, fs, PasswdPath, 7)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*User, 0, len(lines))
+	for _, tokens := range lines {
+		// A malformed entry only affects the checks of that user, so the error
+		// is reported with the user instead of failing the whole file.
+		uid, uidErr := strconv.Atoi(tokens[2])
+		gid, gidErr := strconv.Atoi(tokens[3])
+		user := &User{Name: tokens[0], UID: uid, GID: gid, Home: tokens[5], Shell: tokens[6]}
+		if uidErr != nil {
+			user.UID = -1
+			user.Err = fmt.Errorf("invalid UID for user %s in %s: %w", tokens[0], PasswdPath, uidErr)
+		}
+		if gidErr != nil {
+			user.GID = -1
+			user.Err = errors.Join(user.Err, fmt.Errorf("invalid GID for user %s in %s: %w", tokens[0], PasswdPath, gidErr))
+		}
+		result = append(result, user)
+	}
+	return result, nil
+}
+
+// ReadShadow parses the entries of /etc/shadow.
+func ReadShadow(ctx context.Context, fs scanapi.Filesystem) ([]*ShadowEntry, error) {
+	lines, err := readColonSeparatedFile(ctx, fs, ShadowPath, 8)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*ShadowEntry, 0, len(lines))
+	for _, tokens := range lines {
+		numbers := make([]int, 6)
+		for i := range numbers {
+			if numbers[i], err = parseOptionalInt(tokens[i+2]); err != nil {
+				return nil, fmt.Errorf("invalid field %d for user %s in %s: %w", i+3, tokens[0], ShadowPath, err)
+			}
+		}
+		result = append(result, &ShadowEntry{
+			Name:         tokens[0],
+			PasswordHash: tokens[1],
+			LastChange:   numbers[0],
+			MinDays:      numbers[1],
+			MaxDays:      numbers[2],
+			WarnDays:     numbers[3],
+			InactiveDays: numbers[4],
+			Expire:       numbers[5],
+		})
+	}
+	return result, nil
+}
+
+// ReadGroups parses the groups from /etc/group.
+func ReadGroups(ctx context.Context, fs scanapi.Filesystem) ([]*Group, error) {
+	lines, err := readColonSeparatedFile(ctx, fs, GroupPath, 4)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Group, 0, len(lines))
+	for _, tokens := range lines {
+		gid, err := strconv.Atoi(tokens[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid GID for group %s in %s: %w", tokens[0], GroupPath, err)
+		}
+		members := []string{}
+		if tokens[3] != "" {
+			members = strings.Split(tokens[3], ",")
+		}
+		result = append(result, &Group{Name: tokens[0], GID: gid, Members: members})
+	}
+	return result, nil
+}
+
+// ReadLoginDefs parses the settings from /etc/login.defs into a map.
+func ReadLoginDefs(ctx context.Context, fs scanapi.Filesystem) (map[string]string, error) {
+	r, err := fs.OpenFile(ctx, LoginDefsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ParseKeyValues(r, " \t")
+}
+
+// ReadUseraddDefaults parses the settings from /etc/default/useradd into a map.
+func ReadUseraddDefaults(ctx context.Context, fs scanapi.Filesystem) (map[string]string, error) {
+	r, err := fs.OpenFile(ctx, UseraddDefaultsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ParseKeyValues(r, "=")
+}
+
+// ParseKeyValues parses the lines of a config file in the form "KEY<sep>VALUE",
+// where sep is any of the given separator characters. Comments and empty lines
+// are skipped. If a key is defined multiple times, the last value is used.
+func ParseKeyValues(r io.Reader, separators string) (map[string]string, error) {
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexAny(line, separators)
+		if i < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		// Strip trailing comments, e.g. "UID_MIN 1000 # comment", unless the
+		// value is quoted and can contain "#".
+		if !strings.HasPrefix(value, "\"") && !strings.HasPrefix(value, "'") {
+			value = trailingCommentRe.ReplaceAllString(value, "")
+		}
+		result[line[:i]] = strings.Trim(value, "\"")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReadUIDRanges reads the UID ranges for regular and system users from
+// /etc/login.defs.
+func ReadUIDRanges(ctx context.Context, fs scanapi.Filesystem) (*UIDRanges, error) {
+	defs, err := ReadLoginDefs(ctx, fs)
+	if err != nil {
+		return nil, err
+	}
+	result := &UIDRanges{UIDMin: defaultUIDMin, SysUIDMin: -1, SysUIDMax: -1}
+	for key, dst := range map[string]*int{
+		"UID_MIN":     &result.UIDMin,
+		"SYS_UID_MIN": &result.SysUIDMin,
+		"SYS_UID_MAX": &result.SysUIDMax,
+	} {
+		value, ok := defs[key]
+		if !ok {
+			continue
+		}
+		if *dst, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid %s value in %s: %w", key, LoginDefsPath, err)
+		}
+	}
+	return result, nil
+}
+
+// readColonSeparatedFile reads the lines of a colon-separated database file
+// like /etc/passwd and splits them into tokens. Each line is expected to have
+// at least minTokens tokens.
+func readColonSeparatedFile(ctx context.Context, fs scanapi.Filesystem, filePath string, minTokens int) ([][]string, error) {
+	r, err := fs.OpenFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	result := [][]string{}
+	scanner := bufio.NewScanner(r)
+	i := 0
+	for scanner.Scan() {
+		i++
+		line := scanner.Text()
+		// Lines starting with "+" or "-" include or exclude NIS entries in
+		// compat mode and don't describe local accounts.
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		tokens := strings.Split(line, ":")
+		if len(tokens) < minTokens {
+			return nil, fmt.Errorf("can't parse line %d in %s: expected at least %d tokens, got %d", i, filePath, minTokens, len(tokens))
+		}
+		result = append(result, tokens)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parseOptionalInt(s string) (int, error) {
+	if s == "" {
+		return -1, nil
+	}
+	return strconv.Atoi(s)
+}
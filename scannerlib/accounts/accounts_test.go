@@ -0,0 +1,241 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accounts_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/accounts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// fakeFilesystem serves the files from a map of file paths to contents.
+type fakeFilesystem struct {
+	files map[string]string
+}
+
+func (f *fakeFilesystem) OpenFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	content, ok := f.files[filePath]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+func (f *fakeFilesystem) OpenDir(ctx context.Context, dirPath string) (scanapi.DirReader, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FilePermissions(ctx context.Context, filePath string) (*apb.PosixPermissions, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestReadPasswd(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		accounts.PasswdPath: "root:x:0:0:root:/root:/bin/bash\n" +
+			"\n" +
+			"daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin\n" +
+			"+@admins\n" +
+			"-guest::::::\n" +
+			"+\n",
+	}}
+	got, err := accounts.ReadPasswd(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("accounts.ReadPasswd() returned an error: %v", err)
+	}
+	want := []*accounts.User{
+		{Name: "root", UID: 0, GID: 0, Home: "/root", Shell: "/bin/bash"},
+		{Name: "daemon", UID: 1, GID: 1, Home: "/usr/sbin", Shell: "/usr/sbin/nologin"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("accounts.ReadPasswd() returned unexpected diff (-want +got):\n%s", diff)
+	}
+	if !got[0].HasLoginShell() || got[1].HasLoginShell() {
+		t.Errorf("HasLoginShell() returned unexpected results for %v", got)
+	}
+}
+
+func TestReadPasswdInvalidFileReturnsError(t *testing.T) {
+	content := "invalid"
+	fs := &fakeFilesystem{files: map[string]string{accounts.PasswdPath: content}}
+	if _, err := accounts.ReadPasswd(context.Background(), fs); err == nil {
+		t.Errorf("accounts.ReadPasswd(%q) didn't return an error", content)
+	}
+}
+
+func TestReadPasswdInvalidIDsAreReportedPerUser(t *testing.T) {
+	content := "root:x:0:0:root:/root:/bin/bash\n" +
+		"user1:x:zero:1:user1:/home/user1:/bin/bash\n" +
+		"user2:x:2:two:user2:/home/user2:/bin/bash\n"
+	fs := &fakeFilesystem{files: map[string]string{accounts.PasswdPath: content}}
+	got, err := accounts.ReadPasswd(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("accounts.ReadPasswd(%q) returned an error: %v", content, err)
+	}
+	want := []*accounts.User{
+		{Name: "root", UID: 0, GID: 0, Home: "/root", Shell: "/bin/bash"},
+		{Name: "user1", UID: -1, GID: 1, Home: "/home/user1", Shell: "/bin/bash"},
+		{Name: "user2", UID: 2, GID: -1, Home: "/home/user2", Shell: "/bin/bash"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(accounts.User{}, "Err")); diff != "" {
+		t.Errorf("accounts.ReadPasswd(%q) returned unexpected diff (-want +got):\n%s", content, diff)
+	}
+	for i, u := range got {
+		if wantErr := i > 0; (u.Err != nil) != wantErr {
+			t.Errorf("accounts.ReadPasswd(%q) returned error %v for user %s, expected error: %t", content, u.Err, u.Name, wantErr)
+		}
+	}
+}
+
+func TestReadShadow(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		accounts.ShadowPath: "root:$6$salt$hash:19000:0:99999:7:::\n" +
+			"daemon:*:18000:1:365:7:30:20000:\n" +
+			"guest::19500::::::\n",
+	}}
+	got, err := accounts.ReadShadow(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("accounts.ReadShadow() returned an error: %v", err)
+	}
+	want := []*accounts.ShadowEntry{
+		{Name: "root", PasswordHash: "$6$salt$hash", LastChange: 19000, MinDays: 0, MaxDays: 99999, WarnDays: 7, InactiveDays: -1, Expire: -1},
+		{Name: "daemon", PasswordHash: "*", LastChange: 18000, MinDays: 1, MaxDays: 365, WarnDays: 7, InactiveDays: 30, Expire: 20000},
+		{Name: "guest", PasswordHash: "", LastChange: 19500, MinDays: -1, MaxDays: -1, WarnDays: -1, InactiveDays: -1, Expire: -1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("accounts.ReadShadow() returned unexpected diff (-want +got):\n%s", diff)
+	}
+	if got[0].IsLocked() || !got[1].IsLocked() {
+		t.Errorf("IsLocked() returned unexpected results for %v", got)
+	}
+}
+
+func TestReadGroups(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		accounts.GroupPath: "root:x:0:\nsudo:x:27:alice,bob\n",
+	}}
+	got, err := accounts.ReadGroups(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("accounts.ReadGroups() returned an error: %v", err)
+	}
+	want := []*accounts.Group{
+		{Name: "root", GID: 0, Members: []string{}},
+		{Name: "sudo", GID: 27, Members: []string{"alice", "bob"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("accounts.ReadGroups() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		content    string
+		separators string
+		want       map[string]string
+	}{
+		{
+			desc:       "login.defs format",
+			content:    "# comment\nPASS_MAX_DAYS\t99999\nUID_MIN   1000\n\nPASS_MAX_DAYS 365\n",
+			separators: " \t",
+			want:       map[string]string{"PASS_MAX_DAYS": "365", "UID_MIN": "1000"},
+		},
+		{
+			desc:       "trailing comments",
+			content:    "UID_MIN 1000 # comment\nUMASK\t022\t#comment\nNAME=\"a # b\"\n",
+			separators: " \t=",
+			want:       map[string]string{"UID_MIN": "1000", "UMASK": "022", "NAME": "a # b"},
+		},
+		{
+			desc:       "shell variable format",
+			content:    "GROUP=100\nINACTIVE=30\nSHELL=\"/bin/sh\"\n",
+			separators: "=",
+			want:       map[string]string{"GROUP": "100", "INACTIVE": "30", "SHELL": "/bin/sh"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := accounts.ParseKeyValues(strings.NewReader(tc.content), tc.separators)
+			if err != nil {
+				t.Fatalf("accounts.ParseKeyValues(%q) returned an error: %v", tc.content, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("accounts.ParseKeyValues(%q) returned unexpected diff (-want +got):\n%s", tc.content, diff)
+			}
+		})
+	}
+}
+
+func TestReadUIDRanges(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		loginDefs string
+		want      *accounts.UIDRanges
+		systemUID int
+		userUID   int
+	}{
+		{
+			desc:      "defaults",
+			loginDefs: "",
+			want:      &accounts.UIDRanges{UIDMin: 1000, SysUIDMin: -1, SysUIDMax: -1},
+			systemUID: 999,
+			userUID:   1000,
+		},
+		{
+			desc:      "system UID range",
+			loginDefs: "UID_MIN 2000 # regular users\nSYS_UID_MIN 100\nSYS_UID_MAX 499\n",
+			want:      &accounts.UIDRanges{UIDMin: 2000, SysUIDMin: 100, SysUIDMax: 499},
+			systemUID: 100,
+			userUID:   500,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fs := &fakeFilesystem{files: map[string]string{accounts.LoginDefsPath: tc.loginDefs}}
+			got, err := accounts.ReadUIDRanges(context.Background(), fs)
+			if err != nil {
+				t.Fatalf("accounts.ReadUIDRanges() returned an error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("accounts.ReadUIDRanges() returned unexpected diff (-want +got):\n%s", diff)
+			}
+			if !got.IsSystemUID(tc.systemUID) {
+				t.Errorf("IsSystemUID(%d) returned false, expected true", tc.systemUID)
+			}
+			if got.IsSystemUID(tc.userUID) {
+				t.Errorf("IsSystemUID(%d) returned true, expected false", tc.userUID)
+			}
+		})
+	}
+}
@@ -54,6 +54,22 @@ func (w *apiErrorWrapper) FilePermissions(ctx context.Context, path string) (*ap
 	return p, err
 }
 
+func (w *apiErrorWrapper) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	s, err := w.api.FileStat(ctx, path)
+	if err != nil {
+		err = fmt.Errorf("api.FileStat(%q): %w", path, err)
+	}
+	return s, err
+}
+
+func (w *apiErrorWrapper) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	v, err := w.api.FileXattr(ctx, path, name)
+	if err != nil {
+		err = fmt.Errorf("api.FileXattr(%q, %q): %w", path, name, err)
+	}
+	return v, err
+}
+
 func (w *apiErrorWrapper) SQLQuery(ctx context.Context, query string) (string, error) {
 	res, err := w.api.SQLQuery(ctx, query)
 	if err != nil {
@@ -62,6 +78,14 @@ func (w *apiErrorWrapper) SQLQuery(ctx context.Context, query string) (string, e
 	return res, err
 }
 
+func (w *apiErrorWrapper) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	res, err := w.api.SQLQueryRows(ctx, query)
+	if err != nil {
+		err = fmt.Errorf("api.SQLQueryRows(%q): %w", query, err)
+	}
+	return res, err
+}
+
 func (w *apiErrorWrapper) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	l, err := w.api.SupportedDatabase()
 	if err != nil {
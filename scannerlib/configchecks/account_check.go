@@ -0,0 +1,298 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/accounts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// AccountCheck is an implementation of configchecks.BenchmarkCheck.
+// It checks the password and aging settings of the user accounts.
+type AccountCheck struct {
+	ctx              context.Context
+	benchmarkID      string
+	alternativeID    int
+	checkInstruction *ipb.AccountCheck
+	fs               scanapi.Filesystem
+}
+
+// Exec executes the account check and returns the compliance status.
+func (c *AccountCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	ci := c.checkInstruction
+	users, err := accounts.ReadPasswd(c.ctx, c.fs)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, u := range users {
+		if u.Err != nil {
+			return nil, "", u.Err
+		}
+	}
+	shadowEntries, err := accounts.ReadShadow(c.ctx, c.fs)
+	if err != nil {
+		return nil, "", err
+	}
+	shadow := make(map[string]*accounts.ShadowEntry)
+	for _, e := range shadowEntries {
+		shadow[e.Name] = e
+	}
+	var groups map[int]bool
+	if c.hasAssertion(ipb.AccountAssertion_PRIMARY_GROUP_EXISTS) {
+		groupEntries, err := accounts.ReadGroups(c.ctx, c.fs)
+		if err != nil {
+			return nil, "", err
+		}
+		groups = make(map[int]bool)
+		for _, g := range groupEntries {
+			groups[g.GID] = true
+		}
+	}
+	var uidRanges *accounts.UIDRanges
+	if ci.GetUsers() == ipb.AccountCheck_NON_SYSTEM_USERS {
+		if uidRanges, err = accounts.ReadUIDRanges(c.ctx, c.fs); err != nil {
+			return nil, "", err
+		}
+	}
+
+	nonCompliantFiles := []*cpb.NonCompliantFile{}
+	if ci.GetCheckDefaults() {
+		if nonCompliantFiles, err = c.defaultsViolations(); err != nil {
+			return nil, "", err
+		}
+	}
+	today := int(time.Now().Unix() / (24 * 60 * 60))
+	for _, u := range users {
+		if containsString(ci.GetOptOutUsers(), u.Name) {
+			continue
+		}
+		s := shadow[u.Name]
+		switch ci.GetUsers() {
+		case ipb.AccountCheck_USERS_WITH_PASSWORD:
+			if s == nil || s.PasswordHash == "" || s.IsLocked() {
+				continue
+			}
+		case ipb.AccountCheck_USERS_WITH_LOGIN_SHELL:
+			if !u.HasLoginShell() {
+				continue
+			}
+		case ipb.AccountCheck_NON_SYSTEM_USERS:
+			if uidRanges.IsSystemUID(u.UID) {
+				continue
+			}
+		}
+		for _, a := range ci.GetAssertions() {
+			if a.GetType() == ipb.AccountAssertion_PRIMARY_GROUP_EXISTS {
+				if !groups[u.GID] {
+					nonCompliantFiles = append(nonCompliantFiles, &cpb.NonCompliantFile{
+						Path:   accounts.GroupPath,
+						Reason: fmt.Sprintf("User %s has primary group %d which doesn't exist", u.Name, u.GID),
+					})
+				}
+				continue
+			}
+			// Users without a shadow entry don't have aging settings to check.
+			if s == nil {
+				continue
+			}
+			if reason := shadowViolation(s, a, today); reason != "" {
+				nonCompliantFiles = append(nonCompliantFiles, &cpb.NonCompliantFile{
+					Path:   accounts.ShadowPath,
+					Reason: fmt.Sprintf("User %s %s", u.Name, reason),
+				})
+			}
+		}
+	}
+
+	// Report only the first N non-compliant users.
+	if len(nonCompliantFiles) > MaxNonCompliantFiles {
+		nonCompliantFiles = nonCompliantFiles[:MaxNonCompliantFiles]
+	}
+	if ci.GetNonComplianceMsg() != "" {
+		for _, f := range nonCompliantFiles {
+			f.Reason = ci.GetNonComplianceMsg()
+		}
+	}
+	r := &apb.ComplianceResult{
+		Id: c.benchmarkID,
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{
+			NonCompliantFiles: nonCompliantFiles,
+		},
+	}
+	return ComplianceMap{c.alternativeID: r}, "", nil
+}
+
+func (c *AccountCheck) hasAssertion(t ipb.AccountAssertion_Type) bool {
+	for _, a := range c.checkInstruction.GetAssertions() {
+		if a.GetType() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowViolation returns the reason why the shadow entry doesn't satisfy the
+// assertion or an empty string if it does.
+func shadowViolation(s *accounts.ShadowEntry, a *ipb.AccountAssertion, today int) string {
+	days := int(a.GetDays())
+	switch a.GetType() {
+	case ipb.AccountAssertion_MAX_DAYS_AT_MOST:
+		if s.MaxDays == -1 || s.MaxDays > days {
+			return fmt.Sprintf("has maximum password age %s, expected at most %d", formatDays(s.MaxDays), days)
+		}
+	case ipb.AccountAssertion_MIN_DAYS_AT_LEAST:
+		if s.MinDays < days {
+			return fmt.Sprintf("has minimum password age %s, expected at least %d", formatDays(s.MinDays), days)
+		}
+	case ipb.AccountAssertion_WARN_DAYS_AT_LEAST:
+		if s.WarnDays < days {
+			return fmt.Sprintf("has password expiry warning period %s, expected at least %d", formatDays(s.WarnDays), days)
+		}
+	case ipb.AccountAssertion_INACTIVE_DAYS_AT_MOST:
+		if s.InactiveDays == -1 || s.InactiveDays > days {
+			return fmt.Sprintf("has password inactivity period %s, expected at most %d", formatDays(s.InactiveDays), days)
+		}
+	case ipb.AccountAssertion_PASSWORD_NOT_EMPTY:
+		if s.PasswordHash == "" {
+			return "has an empty password"
+		}
+	case ipb.AccountAssertion_LAST_CHANGE_NOT_IN_FUTURE:
+		if s.LastChange > today {
+			return fmt.Sprintf("has last password change in the future (day %d since epoch)", s.LastChange)
+		}
+	case ipb.AccountAssertion_PASSWORD_LOCKED:
+		if !s.IsLocked() {
+			return "doesn't have a locked password"
+		}
+	}
+	return ""
+}
+
+// defaultsViolations checks the aging assertions against the defaults for new
+// accounts from /etc/login.defs and /etc/default/useradd.
+func (c *AccountCheck) defaultsViolations() ([]*cpb.NonCompliantFile, error) {
+	loginDefs, err := accounts.ReadLoginDefs(c.ctx, c.fs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	useraddDefaults, err := accounts.ReadUseraddDefaults(c.ctx, c.fs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	result := []*cpb.NonCompliantFile{}
+	for _, a := range c.checkInstruction.GetAssertions() {
+		var filePath, key string
+		var atMost bool
+		switch a.GetType() {
+		case ipb.AccountAssertion_MAX_DAYS_AT_MOST:
+			filePath, key, atMost = accounts.LoginDefsPath, "PASS_MAX_DAYS", true
+		case ipb.AccountAssertion_MIN_DAYS_AT_LEAST:
+			filePath, key = accounts.LoginDefsPath, "PASS_MIN_DAYS"
+		case ipb.AccountAssertion_WARN_DAYS_AT_LEAST:
+			filePath, key = accounts.LoginDefsPath, "PASS_WARN_AGE"
+		case ipb.AccountAssertion_INACTIVE_DAYS_AT_MOST:
+			filePath, key, atMost = accounts.UseraddDefaultsPath, "INACTIVE", true
+		default:
+			continue
+		}
+		settings := loginDefs
+		if filePath == accounts.UseraddDefaultsPath {
+			settings = useraddDefaults
+		}
+		days := int(a.GetDays())
+		value, ok := settings[key]
+		if !ok {
+			result = append(result, &cpb.NonCompliantFile{
+				Path:   filePath,
+				Reason: fmt.Sprintf("%s is not set", key),
+			})
+			continue
+		}
+		got, err := strconv.Atoi(value)
+		switch {
+		case err != nil:
+			result = append(result, &cpb.NonCompliantFile{
+				Path:   filePath,
+				Reason: fmt.Sprintf("%s has non-numeric value %q", key, value),
+			})
+		case atMost && (got == -1 || got > days):
+			result = append(result, &cpb.NonCompliantFile{
+				Path:   filePath,
+				Reason: fmt.Sprintf("%s is %d, expected at most %d", key, got, days),
+			})
+		case !atMost && got < days:
+			result = append(result, &cpb.NonCompliantFile{
+				Path:   filePath,
+				Reason: fmt.Sprintf("%s is %d, expected at least %d", key, got, days),
+			})
+		}
+	}
+	return result, nil
+}
+
+func formatDays(days int) string {
+	if days == -1 {
+		return "unset"
+	}
+	return strconv.Itoa(days)
+}
+
+// BenchmarkIDs returns the IDs of the benchmarks associated with this check.
+func (c *AccountCheck) BenchmarkIDs() []string {
+	return []string{c.benchmarkID}
+}
+
+func (c *AccountCheck) String() string {
+	return fmt.Sprintf("[Account check for %s]", c.checkInstruction.GetUsers())
+}
+
+// createAccountChecksFromConfig parses the benchmark config and creates the
+// account checks that it defines.
+func createAccountChecksFromConfig(ctx context.Context, benchmarks []*benchmark, fs scanapi.Filesystem) ([]*AccountCheck, error) {
+	checks := []*AccountCheck{}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, instruction := range alt.proto.GetAccountChecks() {
+				if len(instruction.GetAssertions()) == 0 {
+					return nil, fmt.Errorf("account check %v in benchmark %s has no assertions", instruction, b.id)
+				}
+				for _, a := range instruction.GetAssertions() {
+					if a.GetType() == ipb.AccountAssertion_TYPE_UNSPECIFIED {
+						return nil, fmt.Errorf("account check %v in benchmark %s has an assertion with no type", instruction, b.id)
+					}
+				}
+				checks = append(checks, &AccountCheck{
+					ctx:              ctx,
+					benchmarkID:      b.id,
+					alternativeID:    alt.id,
+					checkInstruction: instruction,
+					fs:               fs,
+				})
+			}
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,219 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+var testAccountFiles = map[string]string{
+	"/etc/passwd": "root:x:0:0:root:/root:/bin/bash\n" +
+		"daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin\n" +
+		"alice:x:1000:1000:Alice:/home/alice:/bin/bash\n" +
+		"bob:x:1001:1001:Bob:/home/bob:/bin/bash\n" +
+		"nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin\n",
+	"/etc/shadow": "root:$6$salt$hash:19000:1:365:7:30::\n" +
+		"daemon:*:18000:0:99999:7:::\n" +
+		"alice:$6$salt$hash:19000:1:90:14:30::\n" +
+		"bob::99999:0:99999:7:::\n",
+	"/etc/group": "root:x:0:\ndaemon:x:1:\nalice:x:1000:\nnogroup:x:65534:\n",
+	"/etc/login.defs": "UID_MIN 1000\n" +
+		"PASS_MAX_DAYS\t99999\n" +
+		"PASS_MIN_DAYS\t1\n",
+	"/etc/default/useradd": "SHELL=/bin/sh\nINACTIVE=30\n",
+}
+
+func TestAccountCheckInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		check *ipb.AccountCheck
+	}{
+		{
+			desc:  "no assertions",
+			check: &ipb.AccountCheck{},
+		},
+		{
+			desc: "assertion without type",
+			check: &ipb.AccountCheck{
+				Assertions: []*ipb.AccountAssertion{{Days: 365}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewAccountScanInstruction([]*ipb.AccountCheck{tc.check})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestAccountCheckComplianceResults(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		check         *ipb.AccountCheck
+		expectedFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc: "max days for users with password",
+			check: &ipb.AccountCheck{
+				Users: ipb.AccountCheck_USERS_WITH_PASSWORD,
+				Assertions: []*ipb.AccountAssertion{
+					{Type: ipb.AccountAssertion_MAX_DAYS_AT_MOST, Days: 365},
+				},
+			},
+			expectedFiles: nil,
+		},
+		{
+			desc: "max days for all users",
+			check: &ipb.AccountCheck{
+				Assertions: []*ipb.AccountAssertion{
+					{Type: ipb.AccountAssertion_MAX_DAYS_AT_MOST, Days: 365},
+				},
+			},
+			expectedFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/shadow", Reason: "User daemon has maximum password age 99999, expected at most 365"},
+				{Path: "/etc/shadow", Reason: "User bob has maximum password age 99999, expected at most 365"},
+			},
+		},
+		{
+			desc: "multiple assertions on users with login shell",
+			check: &ipb.AccountCheck{
+				Users:       ipb.AccountCheck_USERS_WITH_LOGIN_SHELL,
+				OptOutUsers: []string{"root"},
+				Assertions: []*ipb.AccountAssertion{
+					{Type: ipb.AccountAssertion_INACTIVE_DAYS_AT_MOST, Days: 30},
+					{Type: ipb.AccountAssertion_PASSWORD_NOT_EMPTY},
+					{Type: ipb.AccountAssertion_LAST_CHANGE_NOT_IN_FUTURE},
+					{Type: ipb.AccountAssertion_WARN_DAYS_AT_LEAST, Days: 7},
+				},
+			},
+			expectedFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/shadow", Reason: "User bob has password inactivity period unset, expected at most 30"},
+				{Path: "/etc/shadow", Reason: "User bob has an empty password"},
+				{Path: "/etc/shadow", Reason: "User bob has last password change in the future (day 99999 since epoch)"},
+			},
+		},
+		{
+			desc: "non-system users",
+			check: &ipb.AccountCheck{
+				Users: ipb.AccountCheck_NON_SYSTEM_USERS,
+				Assertions: []*ipb.AccountAssertion{
+					{Type: ipb.AccountAssertion_MIN_DAYS_AT_LEAST, Days: 1},
+					{Type: ipb.AccountAssertion_PRIMARY_GROUP_EXISTS},
+				},
+			},
+			expectedFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/shadow", Reason: "User bob has minimum password age 0, expected at least 1"},
+				{Path: "/etc/group", Reason: "User bob has primary group 1001 which doesn't exist"},
+			},
+		},
+		{
+			desc: "locked system accounts",
+			check: &ipb.AccountCheck{
+				Users:       ipb.AccountCheck_USERS_WITH_LOGIN_SHELL,
+				OptOutUsers: []string{"alice", "bob"},
+				Assertions: []*ipb.AccountAssertion{
+					{Type: ipb.AccountAssertion_PASSWORD_LOCKED},
+				},
+			},
+			expectedFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/shadow", Reason: "User root doesn't have a locked password"},
+			},
+		},
+		{
+			desc: "defaults for new accounts",
+			check: &ipb.AccountCheck{
+				Users:         ipb.AccountCheck_USERS_WITH_PASSWORD,
+				CheckDefaults: true,
+				Assertions: []*ipb.AccountAssertion{
+					{Type: ipb.AccountAssertion_MAX_DAYS_AT_MOST, Days: 365},
+					{Type: ipb.AccountAssertion_MIN_DAYS_AT_LEAST, Days: 1},
+					{Type: ipb.AccountAssertion_WARN_DAYS_AT_LEAST, Days: 7},
+					{Type: ipb.AccountAssertion_INACTIVE_DAYS_AT_MOST, Days: 30},
+				},
+			},
+			expectedFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/login.defs", Reason: "PASS_MAX_DAYS is 99999, expected at most 365"},
+				{Path: "/etc/login.defs", Reason: "PASS_WARN_AGE is not set"},
+			},
+		},
+		{
+			desc: "custom non-compliance message",
+			check: &ipb.AccountCheck{
+				Assertions: []*ipb.AccountAssertion{
+					{Type: ipb.AccountAssertion_PASSWORD_NOT_EMPTY},
+				},
+				NonComplianceMsg: "accounts with empty passwords found",
+			},
+			expectedFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/shadow", Reason: "accounts with empty passwords found"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewAccountScanInstruction([]*ipb.AccountCheck{tc.check})
+			check := createSingleCheck(t, scanInstruction, newFakeAPI(withFiles(testAccountFiles)))
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAccountCheckMissingShadowFileReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewAccountScanInstruction([]*ipb.AccountCheck{{
+		Assertions: []*ipb.AccountAssertion{
+			{Type: ipb.AccountAssertion_PASSWORD_NOT_EMPTY},
+		},
+	}})
+	check := createSingleCheck(t, scanInstruction, newFakeAPI(withFiles(map[string]string{"/etc/passwd": testAccountFiles["/etc/passwd"]})))
+	if _, _, err := check.Exec(""); err == nil {
+		t.Errorf("check.Exec() didn't return an error")
+	}
+}
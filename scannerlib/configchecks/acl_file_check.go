@@ -0,0 +1,115 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// aclFileChecker performs checks on the POSIX ACLs of files.
+type aclFileChecker struct {
+	fc *fileCheck
+}
+
+func newACLFileChecker(fc *fileCheck) (*aclFileChecker, error) {
+	ac := fc.checkInstruction.GetAcl()
+	if !ac.GetNoExtendedEntries() && ac.GetNamedEntryClearBits() == 0 {
+		return nil, fmt.Errorf("ACL check %v has no assertions", fc.checkInstruction)
+	}
+	if ac.GetNamedEntryClearBits() & ^7 != 0 {
+		return nil, fmt.Errorf("ACL check %v has invalid permission bits %o", fc.checkInstruction, ac.GetNamedEntryClearBits())
+	}
+	return &aclFileChecker{fc: fc}, nil
+}
+
+func (c *aclFileChecker) exec(ctx context.Context, path string, fs scanapi.Filesystem) error {
+	acl, err := scanapi.FileACL(ctx, fs, path)
+	if err != nil {
+		// Return a non-compliance instead of an error if the file doesn't exist.
+		if errors.Is(err, os.ErrNotExist) {
+			c.fc.addNonCompliantFile(path, "File doesn't exist")
+			return nil
+		}
+		return err
+	}
+	ac := c.fc.checkInstruction.GetAcl()
+	if ac.GetNoExtendedEntries() {
+		extended := []string{}
+		for _, e := range acl.GetEntries() {
+			switch e.GetTag() {
+			case apb.PosixAcl_Entry_USER, apb.PosixAcl_Entry_GROUP, apb.PosixAcl_Entry_MASK:
+				extended = append(extended, formatACLEntry(e))
+			}
+		}
+		if len(extended) > 0 {
+			c.fc.addNonCompliantFile(path, fmt.Sprintf("File has extended ACL entries %s, expected none",
+				strings.Join(extended, ",")))
+		}
+	}
+	if ac.GetNamedEntryClearBits() != 0 {
+		mask := int32(7)
+		for _, e := range acl.GetEntries() {
+			if e.GetTag() == apb.PosixAcl_Entry_MASK {
+				mask = e.GetPermissions()
+			}
+		}
+		for _, e := range acl.GetEntries() {
+			if e.GetTag() != apb.PosixAcl_Entry_USER && e.GetTag() != apb.PosixAcl_Entry_GROUP {
+				continue
+			}
+			if granted := e.GetPermissions() & mask & ac.GetNamedEntryClearBits(); granted != 0 {
+				c.fc.addNonCompliantFile(path, fmt.Sprintf("ACL entry %s grants %s, expected it not to",
+					formatACLEntry(e), formatACLPermissions(granted)))
+			}
+		}
+	}
+	return nil
+}
+
+// formatACLEntry formats an ACL entry the way getfacl does, e.g. "user:1001:rw-".
+func formatACLEntry(e *apb.PosixAcl_Entry) string {
+	qualifier := ""
+	if e.GetTag() == apb.PosixAcl_Entry_USER || e.GetTag() == apb.PosixAcl_Entry_GROUP {
+		qualifier = strconv.FormatInt(e.GetId(), 10)
+	}
+	tag := ""
+	switch e.GetTag() {
+	case apb.PosixAcl_Entry_USER_OBJ, apb.PosixAcl_Entry_USER:
+		tag = "user"
+	case apb.PosixAcl_Entry_GROUP_OBJ, apb.PosixAcl_Entry_GROUP:
+		tag = "group"
+	default:
+		tag = strings.ToLower(e.GetTag().String())
+	}
+	return fmt.Sprintf("%s:%s:%s", tag, qualifier, formatACLPermissions(e.GetPermissions()))
+}
+
+func formatACLPermissions(perms int32) string {
+	result := []byte("---")
+	for i, c := range "rwx" {
+		if perms&(4>>i) != 0 {
+			result[i] = byte(c)
+		}
+	}
+	return string(result)
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+// aclXattr encodes ACL entries given as (tag, permissions, id) triplets in the
+// system.posix_acl_access format.
+func aclXattr(entries ...[3]uint32) []byte {
+	value := binary.LittleEndian.AppendUint32(nil, 2)
+	for _, e := range entries {
+		value = binary.LittleEndian.AppendUint16(value, uint16(e[0]))
+		value = binary.LittleEndian.AppendUint16(value, uint16(e[1]))
+		value = binary.LittleEndian.AppendUint32(value, e[2])
+	}
+	return value
+}
+
+func TestACLCheckInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		check *ipb.AclCheck
+	}{
+		{
+			desc:  "no assertions",
+			check: &ipb.AclCheck{},
+		},
+		{
+			desc:  "invalid permission bits",
+			check: &ipb.AclCheck{NamedEntryClearBits: 0755},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(testFilePath)},
+				CheckType:    &ipb.FileCheck_Acl{Acl: tc.check},
+			}})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestACLCheckComplianceResults(t *testing.T) {
+	const noID = 0xffffffff
+	xattrs := map[string]map[string][]byte{
+		"/etc/minimal": {scanapi.PosixACLAccessXattr: aclXattr(
+			[3]uint32{0x01, 6, noID}, [3]uint32{0x04, 4, noID}, [3]uint32{0x20, 4, noID})},
+		"/etc/named-write": {scanapi.PosixACLAccessXattr: aclXattr(
+			[3]uint32{0x01, 6, noID}, [3]uint32{0x02, 6, 1001}, [3]uint32{0x04, 4, noID},
+			[3]uint32{0x08, 7, 27}, [3]uint32{0x10, 7, noID}, [3]uint32{0x20, 4, noID})},
+		"/etc/masked-write": {scanapi.PosixACLAccessXattr: aclXattr(
+			[3]uint32{0x01, 6, noID}, [3]uint32{0x02, 6, 1001}, [3]uint32{0x04, 4, noID},
+			[3]uint32{0x10, 4, noID}, [3]uint32{0x20, 4, noID})},
+	}
+	testCases := []struct {
+		desc                      string
+		filePath                  string
+		check                     *ipb.AclCheck
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc:                      "no ACL set",
+			filePath:                  "/etc/no-acl",
+			check:                     &ipb.AclCheck{NoExtendedEntries: true, NamedEntryClearBits: 2},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:                      "minimal ACL",
+			filePath:                  "/etc/minimal",
+			check:                     &ipb.AclCheck{NoExtendedEntries: true},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "extended entries",
+			filePath: "/etc/named-write",
+			check:    &ipb.AclCheck{NoExtendedEntries: true},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/named-write",
+				Reason: "File has extended ACL entries user:1001:rw-,group:27:rwx,mask::rwx, expected none",
+			}},
+		},
+		{
+			desc:     "named entries with write",
+			filePath: "/etc/named-write",
+			check:    &ipb.AclCheck{NamedEntryClearBits: 2},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/named-write", Reason: "ACL entry user:1001:rw- grants -w-, expected it not to"},
+				{Path: "/etc/named-write", Reason: "ACL entry group:27:rwx grants -w-, expected it not to"},
+			},
+		},
+		{
+			desc:                      "write removed by mask",
+			filePath:                  "/etc/masked-write",
+			check:                     &ipb.AclCheck{NamedEntryClearBits: 2},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "file doesn't exist",
+			filePath: nonExistentFilePath,
+			check:    &ipb.AclCheck{NoExtendedEntries: true},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   nonExistentFilePath,
+				Reason: "File doesn't exist",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(tc.filePath)},
+				CheckType:    &ipb.FileCheck_Acl{Acl: tc.check},
+			}}, newFakeAPI(withFileXattrs(xattrs)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
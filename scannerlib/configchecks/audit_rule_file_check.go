@@ -0,0 +1,311 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/localtoast/scannerlib/fileset"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// The comparison operators supported by auditctl fields, longest first.
+var auditFieldOperators = []string{"!=", "<=", ">=", "&=", "=", "<", ">", "&"}
+
+// auditRule is the normalized form of a single audit rule.
+type auditRule struct {
+	// The list and action of syscall rules in the form "action,list",
+	// e.g. "always,exit".
+	action string
+	// The path of file watch rules.
+	watch string
+	// The permissions of file watch rules, sorted.
+	perms string
+	// The fields of the rule in the form "name<op>value", sorted.
+	fields []string
+	// The syscalls the rule applies to.
+	syscalls map[string]bool
+	// The keys of the rule.
+	keys []string
+}
+
+// auditRuleFileChecker checks whether the files contain the expected audit rules.
+type auditRuleFileChecker struct {
+	fc            *fileCheck
+	rawRules      []string
+	expectedRules []*auditRule
+	// The rules found in the files of the FileSet.
+	actualRules []*auditRule
+}
+
+func newAuditRuleFileChecker(fc *fileCheck) (*auditRuleFileChecker, error) {
+	rawRules := fc.checkInstruction.GetAuditRule().GetExpectedRules()
+	if len(rawRules) == 0 {
+		return nil, fmt.Errorf("audit rule check %v has no expected rules", fc.checkInstruction)
+	}
+	expectedRules := make([]*auditRule, 0, len(rawRules))
+	for _, r := range rawRules {
+		rule, err := parseAuditRule(r)
+		if err != nil {
+			return nil, err
+		}
+		if rule == nil {
+			return nil, fmt.Errorf("expected audit rule %q is not a syscall or file watch rule", r)
+		}
+		expectedRules = append(expectedRules, rule)
+	}
+	return &auditRuleFileChecker{
+		fc:            fc,
+		rawRules:      rawRules,
+		expectedRules: expectedRules,
+	}, nil
+}
+
+func (c *fileCheckers) execAuditRuleChecksOnFile(path string, openError error, f io.Reader) error {
+	if len(c.auditRuleFileCheckers) == 0 {
+		return nil
+	}
+	exists, err := fileExists(openError)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		for _, checker := range c.auditRuleFileCheckers {
+			checker.fc.addNonCompliantFile(path, "File doesn't exist")
+		}
+		return nil
+	}
+
+	rules := []*auditRule{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseAuditRule(line)
+		if err != nil || rule == nil {
+			// Control lines and rules we can't parse can't match any expected rule.
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, checker := range c.auditRuleFileCheckers {
+		checker.actualRules = append(checker.actualRules, rules...)
+	}
+	return nil
+}
+
+func (c *fileCheckers) execAuditRuleChecksAfterFileTraversal(filesToCheck *ipb.FileSet) {
+	for _, checker := range c.auditRuleFileCheckers {
+		checker.execAfterFileTraversal(filesToCheck)
+	}
+}
+
+func (c *auditRuleFileChecker) execAfterFileTraversal(filesToCheck *ipb.FileSet) {
+	ignoreKeys := c.fc.checkInstruction.GetAuditRule().GetIgnoreKeys()
+	for i, expected := range c.expectedRules {
+		matched := false
+		coveredSyscalls := make(map[string]bool)
+		for _, actual := range c.actualRules {
+			if !expected.matchesIgnoringSyscalls(actual, ignoreKeys) {
+				continue
+			}
+			matched = true
+			for s := range actual.syscalls {
+				coveredSyscalls[s] = true
+			}
+		}
+		if !matched {
+			c.fc.addNonCompliantFile(
+				fileset.FileSetToString(filesToCheck),
+				fmt.Sprintf("No audit rule matching %q found among files", c.rawRules[i]))
+			continue
+		}
+		missing := []string{}
+		for s := range expected.syscalls {
+			if !coveredSyscalls[s] && !coveredSyscalls["all"] {
+				missing = append(missing, s)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			c.fc.addNonCompliantFile(
+				fileset.FileSetToString(filesToCheck),
+				fmt.Sprintf("Audit rules matching %q don't cover the syscalls %s", c.rawRules[i], strings.Join(missing, ",")))
+		}
+	}
+}
+
+// matchesIgnoringSyscalls returns whether the actual rule is equivalent to the
+// expected one apart from the syscalls it applies to. Watch rules may have
+// more permissions than expected.
+func (r *auditRule) matchesIgnoringSyscalls(actual *auditRule, ignoreKeys bool) bool {
+	if r.action != actual.action || r.watch != actual.watch {
+		return false
+	}
+	for _, p := range r.perms {
+		if !strings.ContainsRune(actual.perms, p) {
+			return false
+		}
+	}
+	if strings.Join(r.fields, " ") != strings.Join(actual.fields, " ") {
+		return false
+	}
+	if (len(r.syscalls) == 0) != (len(actual.syscalls) == 0) {
+		return false
+	}
+	if !ignoreKeys {
+		for _, k := range r.keys {
+			if !containsString(actual.keys, k) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// parseAuditRule parses an audit rule in auditctl syntax into its normalized
+// form. Returns nil if the line is a control command (e.g. "-e 2") and not a
+// syscall or file watch rule.
+func parseAuditRule(line string) (*auditRule, error) {
+	tokens := strings.Fields(line)
+	rule := &auditRule{syscalls: make(map[string]bool)}
+	isRule := false
+	for i := 0; i < len(tokens); i++ {
+		option := tokens[i]
+		value := ""
+		switch option {
+		case "-a", "-A", "-w", "-p", "-S", "-F", "-C", "-k":
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("audit rule %q: missing value for %s", line, option)
+			}
+			i++
+			value = tokens[i]
+		default:
+			// Control commands such as -D, -b, -e or -f.
+			return nil, nil
+		}
+		switch option {
+		case "-a", "-A":
+			action, err := normalizeAuditAction(value)
+			if err != nil {
+				return nil, fmt.Errorf("audit rule %q: %w", line, err)
+			}
+			rule.action = action
+			isRule = true
+		case "-w":
+			rule.watch = value
+			isRule = true
+		case "-p":
+			rule.perms = sortChars(value)
+		case "-S":
+			for _, s := range strings.Split(value, ",") {
+				if s != "" {
+					rule.syscalls[s] = true
+				}
+			}
+		case "-k":
+			rule.keys = append(rule.keys, value)
+		case "-F", "-C":
+			name, op, fieldValue, err := splitAuditField(value)
+			if err != nil {
+				return nil, fmt.Errorf("audit rule %q: %w", line, err)
+			}
+			switch {
+			case option == "-F" && name == "key" && op == "=":
+				rule.keys = append(rule.keys, fieldValue)
+			case option == "-F" && name == "perm":
+				rule.fields = append(rule.fields, name+op+sortChars(fieldValue))
+			case option == "-F":
+				rule.fields = append(rule.fields, name+op+normalizeAuditFieldValue(fieldValue))
+			default:
+				// Field comparisons (-C) are kept apart from regular fields.
+				rule.fields = append(rule.fields, "-C "+name+op+fieldValue)
+			}
+		}
+	}
+	if !isRule {
+		return nil, nil
+	}
+	if rule.action != "" && rule.watch != "" {
+		return nil, fmt.Errorf("audit rule %q has both -a and -w set", line)
+	}
+	sort.Strings(rule.fields)
+	sort.Strings(rule.keys)
+	return rule, nil
+}
+
+// normalizeAuditAction converts the "list,action" or "action,list" value of
+// the -a option into the form "action,list".
+func normalizeAuditAction(value string) (string, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid rule action %q", value)
+	}
+	if parts[0] == "always" || parts[0] == "never" {
+		return parts[0] + "," + parts[1], nil
+	}
+	if parts[1] == "always" || parts[1] == "never" {
+		return parts[1] + "," + parts[0], nil
+	}
+	return "", fmt.Errorf("invalid rule action %q", value)
+}
+
+// splitAuditField splits a field expression like "auid>=1000" into its name,
+// operator and value.
+func splitAuditField(field string) (name, op, value string, err error) {
+	i := strings.IndexAny(field, "!=<>&")
+	if i <= 0 {
+		return "", "", "", fmt.Errorf("invalid field %q", field)
+	}
+	for _, o := range auditFieldOperators {
+		if strings.HasPrefix(field[i:], o) {
+			return field[:i], o, field[i+len(o):], nil
+		}
+	}
+	return "", "", "", fmt.Errorf("invalid field %q", field)
+}
+
+// normalizeAuditFieldValue converts the different representations of the
+// unset ID (-1, 4294967295) to the one used by "auditctl -l".
+func normalizeAuditFieldValue(value string) string {
+	if value == "-1" || value == "4294967295" {
+		return "unset"
+	}
+	return value
+}
+
+func sortChars(s string) string {
+	chars := strings.Split(s, "")
+	sort.Strings(chars)
+	return strings.Join(chars, "")
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
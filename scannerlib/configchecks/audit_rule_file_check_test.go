@@ -0,0 +1,287 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	"github.com/google/localtoast/scannerlib/fileset"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+const auditRulesDir = "/etc/audit/rules.d"
+
+var auditRulesDirFileSet = &ipb.FileSet{
+	FilePath: &ipb.FileSet_FilesInDir_{FilesInDir: &ipb.FileSet_FilesInDir{
+		DirPath:       auditRulesDir,
+		FilesOnly:     true,
+		FilenameRegex: `.*\.rules`,
+	}},
+}
+
+func TestAuditRuleCheckComplianceResults(t *testing.T) {
+	testFiles := map[string]string{
+		auditRulesDir + "/10-base.rules": "-D\n-b 8192\n--backlog_wait_time 60000\n",
+		auditRulesDir + "/50-time-change.rules": "-a exit,always -S adjtimex -F arch=b64 -k time-change\n" +
+			"-a always,exit -F arch=b64 -S settimeofday,clock_settime -F key=time-change\n" +
+			"-a always,exit -F arch=b32 -S adjtimex -k time-change\n",
+		auditRulesDir + "/50-identity.rules": "# identity\n" +
+			"-w /etc/group -p aw -k identity\n" +
+			"-w /etc/passwd -p rwa -k identity\n",
+		auditRulesDir + "/50-privileged.rules": "-a always,exit -F path=/usr/bin/sudo -F perm=x -F auid>=1000 -F auid!=4294967295 -k privileged\n",
+		auditRulesDir + "/99-finalize.rules":   "-e 2\n",
+	}
+	dirString := fileset.FileSetToString(auditRulesDirFileSet)
+	testCases := []struct {
+		description               string
+		check                     *ipb.AuditRuleCheck
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			description: "syscalls spread across rules and files",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{
+					"-a always,exit -F arch=b64 -S adjtimex,settimeofday,clock_settime -k time-change",
+				},
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			description: "missing syscall",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{
+					"-a always,exit -F arch=b32 -S adjtimex -S settimeofday -k time-change",
+				},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   dirString,
+				Reason: "Audit rules matching \"-a always,exit -F arch=b32 -S adjtimex -S settimeofday -k time-change\" don't cover the syscalls settimeofday",
+			}},
+		},
+		{
+			description: "watch rules with reordered and additional permissions",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{
+					"-w /etc/group -p wa -k identity",
+					"-w /etc/passwd -k identity -p wa",
+				},
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			description: "missing watch rule",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{"-w /etc/shadow -p wa -k identity"},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   dirString,
+				Reason: "No audit rule matching \"-w /etc/shadow -p wa -k identity\" found among files",
+			}},
+		},
+		{
+			description: "normalized field values",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{
+					"-a always,exit -F perm=x -F auid!=unset -F auid>=1000 -F path=/usr/bin/sudo -F key=privileged",
+				},
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			description: "different key",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{"-w /etc/group -p wa -k group-changes"},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   dirString,
+				Reason: "No audit rule matching \"-w /etc/group -p wa -k group-changes\" found among files",
+			}},
+		},
+		{
+			description: "keys ignored",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{"-w /etc/group -p wa -k group-changes"},
+				IgnoreKeys:    true,
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			description: "different field value",
+			check: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{
+					"-a always,exit -F path=/usr/bin/sudo -F perm=x -F auid>=500 -F auid!=-1 -k privileged",
+				},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   dirString,
+				Reason: "No audit rule matching \"-a always,exit -F path=/usr/bin/sudo -F perm=x -F auid>=500 -F auid!=-1 -k privileged\" found among files",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{auditRulesDirFileSet},
+				CheckType:    &ipb.FileCheck_AuditRule{AuditRule: tc.check},
+			}}, newFakeAPI(withFiles(testFiles)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAuditRuleCheckOnLoadedRulesFile(t *testing.T) {
+	loadedRulesPath := "/var/lib/localtoast/auditctl-l.txt"
+	// Loaded rules as printed by "auditctl -l".
+	testFiles := map[string]string{
+		auditRulesDir + "/50-identity.rules": "-w /etc/group -p wa -k identity\n",
+		loadedRulesPath:                      "No rules\n",
+	}
+	check := &ipb.FileCheck{
+		FilesToCheck: []*ipb.FileSet{
+			auditRulesDirFileSet,
+			testconfigcreator.SingleFileWithPath(loadedRulesPath),
+		},
+		CheckType: &ipb.FileCheck_AuditRule{AuditRule: &ipb.AuditRuleCheck{
+			ExpectedRules: []string{"-w /etc/group -p wa -k identity"},
+		}},
+	}
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{check}))
+	checks, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{BenchmarkConfigs: []*apb.BenchmarkConfig{config}},
+		newFakeAPI(withFiles(testFiles)))
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig([%v]) returned an error: %v", config, err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("Created %d checks, expected 2", len(checks))
+	}
+
+	nonCompliantFiles := []*cpb.NonCompliantFile{}
+	for _, c := range checks {
+		resultMap, _, err := c.Exec("")
+		if err != nil {
+			t.Fatalf("%v.Exec() returned an error: %v", c, err)
+		}
+		result, gotSingleton := singleComplianceResult(resultMap)
+		if !gotSingleton {
+			t.Fatalf("%v.Exec() expected to return 1 result, got %d", c, len(resultMap))
+		}
+		nonCompliantFiles = append(nonCompliantFiles, result.GetComplianceOccurrence().GetNonCompliantFiles()...)
+	}
+	want := []*cpb.NonCompliantFile{{
+		Path:   fmt.Sprintf("single_file:{path:%q}", loadedRulesPath),
+		Reason: "No audit rule matching \"-w /etc/group -p wa -k identity\" found among files",
+	}}
+	if diff := cmp.Diff(want, nonCompliantFiles, protocmp.Transform()); diff != "" {
+		t.Errorf("Exec() returned unexpected non-compliant files diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestAuditRuleCheckBatchedWithContentEntryCheck(t *testing.T) {
+	testFiles := map[string]string{
+		auditRulesDir + "/50-identity.rules": "-w /etc/group -p wa -k identity\n",
+	}
+	check := createFileCheckBatch(t, "id", []*ipb.FileCheck{
+		{
+			FilesToCheck: []*ipb.FileSet{auditRulesDirFileSet},
+			CheckType: &ipb.FileCheck_AuditRule{AuditRule: &ipb.AuditRuleCheck{
+				ExpectedRules: []string{"-w /etc/group -p wa -k identity"},
+			}},
+		},
+		{
+			FilesToCheck: []*ipb.FileSet{auditRulesDirFileSet},
+			CheckType: &ipb.FileCheck_ContentEntry{ContentEntry: &ipb.ContentEntryCheck{
+				MatchType: ipb.ContentEntryCheck_ALL_MATCH_ANY_ORDER,
+				MatchCriteria: []*ipb.MatchCriterion{{
+					FilterRegex:   "-w /etc/group .*",
+					ExpectedRegex: "-w /etc/group -p wa -k identity",
+				}},
+			}},
+		},
+	}, newFakeAPI(withFiles(testFiles)))
+
+	resultMap, _, err := check.Exec("")
+	if err != nil {
+		t.Fatalf("check.Exec() returned an error: %v", err)
+	}
+	result, gotSingleton := singleComplianceResult(resultMap)
+	if !gotSingleton {
+		t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+	}
+	want := &apb.ComplianceResult{
+		Id:                   "id",
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{},
+	}
+	if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+		t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestAuditRuleCheckInvalidExpectedRules(t *testing.T) {
+	testCases := []struct {
+		description string
+		rules       []string
+	}{
+		{description: "no rules", rules: []string{}},
+		{description: "control command", rules: []string{"-e 2"}},
+		{description: "invalid action", rules: []string{"-a sometimes,exit -S adjtimex"}},
+		{description: "missing value", rules: []string{"-w /etc/group -p"}},
+		{description: "invalid field", rules: []string{"-a always,exit -F arch"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{auditRulesDirFileSet},
+				CheckType: &ipb.FileCheck_AuditRule{AuditRule: &ipb.AuditRuleCheck{
+					ExpectedRules: tc.rules,
+				}},
+			}})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{BenchmarkConfigs: []*apb.BenchmarkConfig{config}},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
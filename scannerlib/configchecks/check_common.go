@@ -23,6 +23,7 @@ import (
 	"google.golang.org/protobuf/proto"
 	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
 	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/facts"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
 )
@@ -75,8 +76,9 @@ func (t *timeoutOptions) benchmarkCheckTimeoutNow() time.Time {
 	return t.globalTimeout
 }
 
-// parseCheckAlternatives deserializes the check alternatives from the benchmark config.
-func parseCheckAlternatives(config *apb.BenchmarkConfig, prevAlternativeID int) ([]*checkAlternative, error) {
+// parseCheckAlternatives deserializes the check alternatives from the benchmark config
+// and substitutes the benchmark's parameters in them. Also returns the parameter values used.
+func parseCheckAlternatives(config *apb.BenchmarkConfig, prevAlternativeID int, tailoring *apb.TailoringConfig) ([]*checkAlternative, []*apb.ParameterValue, error) {
 	serialized := config.GetComplianceNote().GetScanInstructions()
 	instruction := &ipb.BenchmarkScanInstruction{}
 	// The scan instructions in the Grafeas Note are serialized since they're
@@ -86,32 +88,61 @@ func parseCheckAlternatives(config *apb.BenchmarkConfig, prevAlternativeID int)
 	if err := bo.Unmarshal(serialized, instruction); err != nil {
 		to := &prototext.UnmarshalOptions{DiscardUnknown: true}
 		if err := to.Unmarshal(serialized, instruction); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 	if len(instruction.GetCheckAlternatives()) == 0 {
-		return nil, fmt.Errorf("scan instruction %v doesn't define any checks", instruction)
+		return nil, nil, fmt.Errorf("scan instruction %v doesn't define any checks", instruction)
+	}
+	values, err := resolveParameters(config.GetId(), instruction.GetParameters(), tailoring)
+	if err != nil {
+		return nil, nil, fmt.Errorf("benchmark %s: %w", config.GetId(), err)
 	}
 	result := make([]*checkAlternative, 0, len(instruction.GetCheckAlternatives()))
 	for _, alt := range instruction.GetCheckAlternatives() {
+		if err := substituteParameters(alt, instruction.GetParameters(), values); err != nil {
+			return nil, nil, fmt.Errorf("benchmark %s: %w", config.GetId(), err)
+		}
 		prevAlternativeID++
 		result = append(result, &checkAlternative{id: prevAlternativeID, proto: alt})
 	}
-	return result, nil
+	return result, values, nil
 }
 
 // CreateChecksFromConfig parses the scan config and creates the benchmark checks defined by it.
 func CreateChecksFromConfig(ctx context.Context, scanConfig *apb.ScanConfig, api scanapi.ScanAPI) ([]BenchmarkCheck, error) {
+	checks, _, err := CreateChecksFromConfigWithFacts(ctx, scanConfig, api, facts.NewCollector(api))
+	return checks, err
+}
+
+// ParameterValues maps benchmark IDs to the values of the benchmarks'
+// parameters.
+type ParameterValues map[string][]*apb.ParameterValue
+
+// CreateChecksFromConfigWithFacts is like CreateChecksFromConfig but reads the
+// host facts used by the scan instructions from the given collector. Only the
+// benchmarks applicable to the host are checked. Also returns the resolved
+// parameter values of the benchmarks.
+func CreateChecksFromConfigWithFacts(ctx context.Context, scanConfig *apb.ScanConfig, api scanapi.ScanAPI, collector *facts.Collector) ([]BenchmarkCheck, ParameterValues, error) {
 	prevAlternativeID := 0
 	benchmarks := make([]*benchmark, 0, len(scanConfig.GetBenchmarkConfigs()))
+	benchmarkParams := make(ParameterValues)
 	for _, b := range scanConfig.GetBenchmarkConfigs() {
-		alts, err := parseCheckAlternatives(b, prevAlternativeID)
+		alts, params, err := parseCheckAlternatives(b, prevAlternativeID, scanConfig.GetTailoringConfig())
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		benchmarks = append(benchmarks, &benchmark{id: b.GetId(), alts: alts})
+		benchmarkParams[b.GetId()] = params
 		prevAlternativeID = alts[len(alts)-1].id
 	}
+	if err := validateTailoring(scanConfig.GetTailoringConfig(), benchmarkParams); err != nil {
+		return nil, nil, err
+	}
+	benchmarks, factErrChecks, err := applyHostFacts(ctx, benchmarks, collector)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	globalTimeout := time.Time{}
 	if scanConfig.GetScanTimeout().AsDuration() > 0 {
@@ -122,40 +153,118 @@ func CreateChecksFromConfig(ctx context.Context, scanConfig *apb.ScanConfig, api
 		benchmarkCheckDuration: scanConfig.GetBenchmarkCheckTimeout().AsDuration(),
 	}
 
-	fileCheckBatches, err := createFileCheckBatchesFromConfig(ctx, benchmarks, scanConfig.GetOptOutConfig(), scanConfig.GetReplacementConfig(), timeout, api)
+	exported, err := collectVariableExports(benchmarks)
+	if err != nil {
+		return nil, nil, err
+	}
+	vars := make(variables)
+	createChecks := func(benchmarks []*benchmark) ([]BenchmarkCheck, error) {
+		return createChecksFromBenchmarks(ctx, benchmarks, scanConfig, timeout, api, collector, vars)
+	}
+	// Checks referencing exported variables are created once the variables
+	// are known.
+	variableChecks := splitVariableChecks(benchmarks, exported, vars, createChecks)
+	checks, err := createChecks(benchmarks)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range variableChecks {
+		checks = append(checks, c)
+	}
+	checks = append(checks, factErrChecks...)
+	checks, err = sortByVariableDependencies(checks)
+	if err != nil {
+		return nil, nil, err
+	}
+	return checks, benchmarkParams, nil
+}
+
+// createChecksFromBenchmarks creates the benchmark checks defined by the given
+// benchmarks.
+func createChecksFromBenchmarks(ctx context.Context, benchmarks []*benchmark, scanConfig *apb.ScanConfig, timeout *timeoutOptions, api scanapi.ScanAPI, collector *facts.Collector, vars variables) ([]BenchmarkCheck, error) {
+	fileCheckBatches, err := createFileCheckBatchesFromConfig(ctx, benchmarks, scanConfig.GetOptOutConfig(), scanConfig.GetReplacementConfig(), timeout, api, collector, vars)
+	if err != nil {
+		return nil, err
+	}
+	sqlChecks, err := createSQLChecksFromConfig(ctx, benchmarks, timeout, api, collector, vars)
+	if err != nil {
+		return nil, err
+	}
+	kernelModuleChecks, err := createKernelModuleChecksFromConfig(ctx, benchmarks, api)
+	if err != nil {
+		return nil, err
+	}
+	mountChecks, err := createMountChecksFromConfig(ctx, benchmarks, api)
+	if err != nil {
+		return nil, err
+	}
+	pamChecks, err := createPamChecksFromConfig(ctx, benchmarks, api)
+	if err != nil {
+		return nil, err
+	}
+	accountChecks, err := createAccountChecksFromConfig(ctx, benchmarks, api)
 	if err != nil {
 		return nil, err
 	}
-	sqlChecks, err := createSQLChecksFromConfig(ctx, benchmarks, timeout, api)
+	listeningServicesChecks, err := createListeningServicesChecksFromConfig(ctx, benchmarks, api)
 	if err != nil {
 		return nil, err
 	}
 
-	checks := make([]BenchmarkCheck, 0, len(fileCheckBatches)+len(sqlChecks))
+	checks := make([]BenchmarkCheck, 0, len(fileCheckBatches)+len(sqlChecks)+len(kernelModuleChecks)+len(mountChecks)+len(pamChecks)+len(accountChecks)+len(listeningServicesChecks))
 	for _, c := range sqlChecks {
 		checks = append(checks, c)
 	}
 	for _, b := range fileCheckBatches {
 		checks = append(checks, b)
 	}
+	for _, c := range kernelModuleChecks {
+		checks = append(checks, c)
+	}
+	for _, c := range mountChecks {
+		checks = append(checks, c)
+	}
+	for _, c := range pamChecks {
+		checks = append(checks, c)
+	}
+	for _, c := range accountChecks {
+		checks = append(checks, c)
+	}
+	for _, c := range listeningServicesChecks {
+		checks = append(checks, c)
+	}
 	return checks, nil
 }
 
 // ValidateScanInstructions validates the scan instructions in the given benchmark config and
 // returns an error if they're invalid.
 func ValidateScanInstructions(config *apb.BenchmarkConfig) error {
-	alts, err := parseCheckAlternatives(config, 0)
+	alts, _, err := parseCheckAlternatives(config, 0, nil)
 	if err != nil {
 		return err
 	}
 	for i, alt := range alts {
-		if len(alt.proto.GetFileChecks()) == 0 && len(alt.proto.GetSqlChecks()) == 0 {
+		if !hasChecks(alt.proto) {
 			return fmt.Errorf("alternative #%d in benchmark %s doesn't have any checks", i, config.GetId())
 		}
+		if _, err := validateFactUsage(alt.proto); err != nil {
+			return fmt.Errorf("alternative #%d in benchmark %s: %w", i, config.GetId(), err)
+		}
 	}
 	return nil
 }
 
+// hasChecks returns whether the given check alternative defines any checks.
+func hasChecks(alt *ipb.CheckAlternative) bool {
+	return len(alt.GetFileChecks()) > 0 ||
+		len(alt.GetSqlChecks()) > 0 ||
+		len(alt.GetKernelModuleChecks()) > 0 ||
+		len(alt.GetMountChecks()) > 0 ||
+		len(alt.GetPamChecks()) > 0 ||
+		len(alt.GetAccountChecks()) > 0 ||
+		len(alt.GetListeningServicesChecks()) > 0
+}
+
 // AddBenchmarkVersionToResults fills out the compliance_occurrence.version field of the
 // given compliance results based on the original benchmark config.
 func AddBenchmarkVersionToResults(results []*apb.ComplianceResult, configs []*apb.BenchmarkConfig) error {
@@ -175,3 +284,16 @@ func AddBenchmarkVersionToResults(results []*apb.ComplianceResult, configs []*ap
 	}
 	return nil
 }
+
+// AddParameterValuesToResults fills out the parameter values used by the
+// benchmarks in their compliance results.
+func AddParameterValuesToResults(results []*apb.ComplianceResult, params ParameterValues) error {
+	for _, r := range results {
+		values, ok := params[r.GetId()]
+		if !ok {
+			return fmt.Errorf("got compliance result with ID not in original benchmark config: %q", r.GetId())
+		}
+		r.ParameterValues = values
+	}
+	return nil
+}
@@ -23,10 +23,14 @@ import (
 	"io"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"testing"
 	"time"
 
 	"github.com/google/localtoast/scanapi"
 	"github.com/google/localtoast/scannerlib/configchecks"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
 )
@@ -53,6 +57,17 @@ type fakeAPI struct {
 	fileContent  string
 	openFileFunc func(ctx context.Context, filePath string) (io.ReadCloser, error)
 	supportedDB  ipb.SQLCheck_SQLDatabase
+	// If set, OpenFile and OpenDir serve the files and directories from this map
+	// of file paths to their contents instead of the fixed test files.
+	files map[string]string
+	// If set, FileStat returns the metadata from this map of file paths.
+	fileStats map[string]*apb.FileStat
+	// If set, FileXattr returns the extended attributes from this map of file
+	// paths to attribute names to values.
+	fileXattrs map[string]map[string][]byte
+	// If set, SQLQuery and SQLQueryRows return the rows from this map of
+	// queries to their results in addition to the fixed test queries.
+	queryRows map[string][]string
 }
 
 type fakeAPIOpt func(r *fakeAPI)
@@ -69,6 +84,35 @@ func withOpenFileFunc(f func(ctx context.Context, filePath string) (io.ReadClose
 	}
 }
 
+// withFiles makes the fake API serve the given files. Directories are
+// derived from the parent paths of the files.
+func withFiles(files map[string]string) fakeAPIOpt {
+	return func(r *fakeAPI) {
+		r.files = files
+	}
+}
+
+// withFileStats makes the fake API return the given file metadata.
+func withFileStats(stats map[string]*apb.FileStat) fakeAPIOpt {
+	return func(r *fakeAPI) {
+		r.fileStats = stats
+	}
+}
+
+// withFileXattrs makes the fake API return the given extended attributes.
+func withFileXattrs(xattrs map[string]map[string][]byte) fakeAPIOpt {
+	return func(r *fakeAPI) {
+		r.fileXattrs = xattrs
+	}
+}
+
+// withQueryRows makes the fake API return the given rows for the queries.
+func withQueryRows(rows map[string][]string) fakeAPIOpt {
+	return func(r *fakeAPI) {
+		r.queryRows = rows
+	}
+}
+
 func withSupportedDatabase(db ipb.SQLCheck_SQLDatabase) fakeAPIOpt {
 	return func(r *fakeAPI) {
 		r.supportedDB = db
@@ -91,6 +135,13 @@ func (r *fakeAPI) OpenFile(ctx context.Context, filePath string) (io.ReadCloser,
 	if r.openFileFunc != nil {
 		return r.openFileFunc(ctx, filePath)
 	}
+	if r.files != nil {
+		content, ok := r.files[filePath]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return io.NopCloser(bytes.NewReader([]byte(content))), nil
+	}
 	switch filePath {
 	case emptyTestFilePath:
 		return io.NopCloser(bytes.NewReader([]byte{})), nil
@@ -103,7 +154,10 @@ func (r *fakeAPI) OpenFile(ctx context.Context, filePath string) (io.ReadCloser,
 	}
 }
 
-func (fakeAPI) OpenDir(ctx context.Context, filePath string) (scanapi.DirReader, error) {
+func (r *fakeAPI) OpenDir(ctx context.Context, filePath string) (scanapi.DirReader, error) {
+	if r.files != nil {
+		return r.openDirFromFiles(filePath)
+	}
 	switch filePath {
 	case testDirPath:
 		return scanapi.SliceToDirReader([]*apb.DirContent{
@@ -117,6 +171,35 @@ func (fakeAPI) OpenDir(ctx context.Context, filePath string) (scanapi.DirReader,
 	}
 }
 
+func (r *fakeAPI) openDirFromFiles(dirPath string) (scanapi.DirReader, error) {
+	prefix := strings.TrimSuffix(dirPath, "/") + "/"
+	entries := make(map[string]bool) // Name -> isDir
+	for p := range r.files {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(p, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			entries[rest[:i]] = true
+		} else {
+			entries[rest] = false
+		}
+	}
+	if len(entries) == 0 {
+		return nil, os.ErrNotExist
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	contents := make([]*apb.DirContent, 0, len(names))
+	for _, name := range names {
+		contents = append(contents, &apb.DirContent{Name: name, IsDir: entries[name]})
+	}
+	return scanapi.SliceToDirReader(contents), nil
+}
+
 func (fakeAPI) FilePermissions(ctx context.Context, filePath string) (*apb.PosixPermissions, error) {
 	switch filePath {
 	case nonExistentFilePath:
@@ -132,7 +215,36 @@ func (fakeAPI) FilePermissions(ctx context.Context, filePath string) (*apb.Posix
 	}
 }
 
-func (fakeAPI) SQLQuery(ctx context.Context, query string) (string, error) {
+func (r *fakeAPI) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	if r.fileStats != nil {
+		stat, ok := r.fileStats[filePath]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return stat, nil
+	}
+	switch filePath {
+	case nonExistentFilePath:
+		return nil, os.ErrNotExist
+	default:
+		return &apb.FileStat{Type: apb.FileStat_REGULAR_FILE, Nlink: 1}, nil
+	}
+}
+
+func (r *fakeAPI) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	if filePath == nonExistentFilePath {
+		return nil, os.ErrNotExist
+	}
+	return r.fileXattrs[filePath][name], nil
+}
+
+func (r *fakeAPI) SQLQuery(ctx context.Context, query string) (string, error) {
+	if rows, ok := r.queryRows[query]; ok {
+		if len(rows) == 0 {
+			return "", nil
+		}
+		return rows[0], nil
+	}
 	switch query {
 	case fakeQueryNoRows:
 		return "", nil
@@ -145,6 +257,22 @@ func (fakeAPI) SQLQuery(ctx context.Context, query string) (string, error) {
 	}
 }
 
+func (r *fakeAPI) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	if rows, ok := r.queryRows[query]; ok {
+		return rows, nil
+	}
+	switch query {
+	case fakeQueryNoRows:
+		return []string{}, nil
+	case fakeQueryOneRow:
+		return []string{"testValue"}, nil
+	case fakeQueryError:
+		return nil, errors.New(queryErrorMsg)
+	default:
+		return nil, fmt.Errorf("the query %q is not supported by fakeAPI", query)
+	}
+}
+
 func (r *fakeAPI) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	return r.supportedDB, nil
 }
@@ -159,3 +287,24 @@ func singleComplianceResult(m configchecks.ComplianceMap) (result *apb.Complianc
 	}
 	return results[0], true
 }
+
+// createSingleCheck creates the checks of a benchmark with the given scan
+// instruction and fails the test unless exactly one check was created.
+func createSingleCheck(t *testing.T, scanInstruction *ipb.BenchmarkScanInstruction, api *fakeAPI) configchecks.BenchmarkCheck {
+	t.Helper()
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+
+	checks, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		api)
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig([%v]) returned an error: %v", config, err)
+	}
+	if len(checks) != 1 {
+		t.Fatalf("Created %d checks, expected only 1", len(checks))
+	}
+	return checks[0]
+}
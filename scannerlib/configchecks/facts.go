@@ -0,0 +1,202 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/localtoast/scannerlib/facts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// Host facts are referenced as "${fact.<name>}" in the scan instructions.
+var factReferenceRe = regexp.MustCompile(`\$\{fact\.([^}]*)\}`)
+
+// applyHostFacts removes the check alternatives whose applicability conditions
+// aren't fulfilled by the host and substitutes the fact references in the
+// remaining ones. Benchmarks without applicable alternatives are removed. The
+// facts are only collected if the benchmarks use them. Benchmarks referencing
+// facts that couldn't be collected are removed as well and returned as checks
+// that report the collection error, so that the other benchmarks still run.
+func applyHostFacts(ctx context.Context, benchmarks []*benchmark, collector *facts.Collector) ([]*benchmark, []BenchmarkCheck, error) {
+	usesFacts := false
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			references, err := validateFactUsage(alt.proto)
+			if err != nil {
+				return nil, nil, fmt.Errorf("benchmark %s: %w", b.id, err)
+			}
+			usesFacts = usesFacts || references
+		}
+	}
+	if !usesFacts {
+		return benchmarks, nil, nil
+	}
+	// Collection errors are reported for the benchmarks using the affected facts.
+	hostFacts, _ := collector.Collect(ctx)
+
+	result := make([]*benchmark, 0, len(benchmarks))
+	errChecks := []BenchmarkCheck{}
+	for _, b := range benchmarks {
+		if err := factCollectionErr(b, collector); err != nil {
+			errChecks = append(errChecks, &factErrorCheck{benchmarkID: b.id, err: err})
+			continue
+		}
+		alts := make([]*checkAlternative, 0, len(b.alts))
+		for _, alt := range b.alts {
+			applicable, err := isApplicable(alt.proto, hostFacts)
+			if err != nil {
+				return nil, nil, fmt.Errorf("benchmark %s: %w", b.id, err)
+			}
+			if !applicable {
+				continue
+			}
+			substituteFacts(alt.proto, hostFacts)
+			alts = append(alts, alt)
+		}
+		if len(alts) > 0 {
+			result = append(result, &benchmark{id: b.id, alts: alts})
+		}
+	}
+	return result, errChecks, nil
+}
+
+// factCollectionErr returns the collection errors of the facts referenced by
+// any of the benchmark's check alternatives.
+func factCollectionErr(b *benchmark, collector *facts.Collector) error {
+	errs := []error{}
+	seen := make(map[string]bool)
+	for _, alt := range b.alts {
+		for _, name := range referencedFacts(alt.proto) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if err := collector.FactErr(name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// factErrorCheck reports that the host facts used by a benchmark couldn't be
+// collected.
+type factErrorCheck struct {
+	benchmarkID string
+	err         error
+}
+
+// Exec returns the fact collection error.
+func (c *factErrorCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	return nil, "", c.err
+}
+
+// BenchmarkIDs returns the ID of the benchmark using the facts.
+func (c *factErrorCheck) BenchmarkIDs() []string {
+	return []string{c.benchmarkID}
+}
+
+func (c *factErrorCheck) String() string {
+	return fmt.Sprintf("[host facts of benchmark %s]", c.benchmarkID)
+}
+
+// validateFactUsage returns an error if the check alternative's conditions or
+// fact references are invalid, and whether it uses any host facts.
+func validateFactUsage(alt *ipb.CheckAlternative) (bool, error) {
+	for _, c := range alt.GetApplicableIf() {
+		if _, err := evaluateFactCondition(c, &apb.HostFacts{}); err != nil {
+			return false, err
+		}
+	}
+	names := referencedFacts(alt)
+	for _, name := range names {
+		if _, _, err := facts.Lookup(&apb.HostFacts{}, name); err != nil {
+			return false, err
+		}
+	}
+	return len(names) > 0, nil
+}
+
+// referencedFacts returns the names of the host facts used in the check
+// alternative's applicability conditions and fact references.
+func referencedFacts(alt *ipb.CheckAlternative) []string {
+	names := []string{}
+	for _, c := range alt.GetApplicableIf() {
+		names = append(names, c.GetFact())
+	}
+	transformStrings(alt.ProtoReflect(), func(s string) string {
+		for _, match := range factReferenceRe.FindAllStringSubmatch(s, -1) {
+			names = append(names, match[1])
+		}
+		return s
+	})
+	return names
+}
+
+// isApplicable returns whether the host facts fulfill all applicability
+// conditions of the check alternative.
+func isApplicable(alt *ipb.CheckAlternative, hostFacts *apb.HostFacts) (bool, error) {
+	for _, c := range alt.GetApplicableIf() {
+		fulfilled, err := evaluateFactCondition(c, hostFacts)
+		if err != nil || !fulfilled {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func evaluateFactCondition(c *ipb.FactCondition, hostFacts *apb.HostFacts) (bool, error) {
+	value, ok, err := facts.Lookup(hostFacts, c.GetFact())
+	if err != nil {
+		return false, err
+	}
+	var fulfilled bool
+	switch c.GetCondition().(type) {
+	case *ipb.FactCondition_Equals:
+		fulfilled = ok && value == c.GetEquals()
+	case *ipb.FactCondition_MatchesRegex:
+		re, err := regexp.Compile("^(?:" + c.GetMatchesRegex() + ")$")
+		if err != nil {
+			return false, fmt.Errorf("invalid regex in condition on fact %s: %w", c.GetFact(), err)
+		}
+		fulfilled = ok && re.MatchString(value)
+	case *ipb.FactCondition_Exists:
+		fulfilled = ok == c.GetExists()
+	default:
+		return false, fmt.Errorf("condition on fact %s doesn't specify what to check", c.GetFact())
+	}
+	return fulfilled != c.GetNegate(), nil
+}
+
+// substituteFacts replaces the fact references in all strings of the check
+// alternative with the facts' values. Facts not set on the host are replaced
+// with an empty string.
+func substituteFacts(alt *ipb.CheckAlternative, hostFacts *apb.HostFacts) {
+	transformStrings(alt.ProtoReflect(), func(s string) string {
+		if !strings.Contains(s, "${fact.") {
+			return s
+		}
+		return factReferenceRe.ReplaceAllStringFunc(s, func(ref string) string {
+			value, _, _ := facts.Lookup(hostFacts, factReferenceRe.FindStringSubmatch(ref)[1])
+			return value
+		})
+	})
+}
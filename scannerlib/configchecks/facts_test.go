@@ -0,0 +1,211 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+var testHostFiles = map[string]string{
+	"/etc/os-release":            "ID=debian\nVERSION_ID=\"12\"\n",
+	"/proc/sys/kernel/osrelease": "6.1.0-18-amd64\n",
+	"/etc/debian/12/sshd_config": "PermitRootLogin no\n",
+}
+
+func createChecksWithConditions(t *testing.T, alts []*ipb.CheckAlternative) ([]configchecks.BenchmarkCheck, error) {
+	t.Helper()
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", &ipb.BenchmarkScanInstruction{CheckAlternatives: alts})
+	return configchecks.CreateChecksFromConfig(context.Background(), &apb.ScanConfig{
+		BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+	}, newFakeAPI(withFiles(testHostFiles)))
+}
+
+func conditionalAlternative(path string, conditions ...*ipb.FactCondition) *ipb.CheckAlternative {
+	return &ipb.CheckAlternative{
+		FileChecks:   []*ipb.FileCheck{existenceFileCheck(path, true)},
+		ApplicableIf: conditions,
+	}
+}
+
+func TestFactConditionsSelectApplicableAlternatives(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		condition      *ipb.FactCondition
+		wantApplicable bool
+	}{
+		{
+			desc:           "equals",
+			condition:      &ipb.FactCondition{Fact: "os.id", Condition: &ipb.FactCondition_Equals{Equals: "debian"}},
+			wantApplicable: true,
+		},
+		{
+			desc:      "doesn't equal",
+			condition: &ipb.FactCondition{Fact: "os.id", Condition: &ipb.FactCondition_Equals{Equals: "rhel"}},
+		},
+		{
+			desc:           "matches regex",
+			condition:      &ipb.FactCondition{Fact: "kernel.release", Condition: &ipb.FactCondition_MatchesRegex{MatchesRegex: `6\.\d+\..*`}},
+			wantApplicable: true,
+		},
+		{
+			desc:      "regex matches only partially",
+			condition: &ipb.FactCondition{Fact: "kernel.release", Condition: &ipb.FactCondition_MatchesRegex{MatchesRegex: `6\.1`}},
+		},
+		{
+			desc:      "unset fact doesn't equal",
+			condition: &ipb.FactCondition{Fact: "package.openssh-server.version", Condition: &ipb.FactCondition_Equals{Equals: ""}},
+		},
+		{
+			desc:           "doesn't exist",
+			condition:      &ipb.FactCondition{Fact: "package.openssh-server.version", Condition: &ipb.FactCondition_Exists{Exists: false}},
+			wantApplicable: true,
+		},
+		{
+			desc:      "negated",
+			condition: &ipb.FactCondition{Fact: "os.id", Condition: &ipb.FactCondition_Equals{Equals: "debian"}, Negate: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			checks, err := createChecksWithConditions(t, []*ipb.CheckAlternative{
+				conditionalAlternative(testFilePath, tc.condition),
+			})
+			if err != nil {
+				t.Fatalf("configchecks.CreateChecksFromConfig() returned an error: %v", err)
+			}
+			if gotApplicable := len(checks) > 0; gotApplicable != tc.wantApplicable {
+				t.Errorf("configchecks.CreateChecksFromConfig() created %d checks, expected applicable: %t", len(checks), tc.wantApplicable)
+			}
+		})
+	}
+}
+
+func TestFactsAreSubstituted(t *testing.T) {
+	checks, err := createChecksWithConditions(t, []*ipb.CheckAlternative{
+		conditionalAlternative("/etc/rhel/sshd_config",
+			&ipb.FactCondition{Fact: "os.id", Condition: &ipb.FactCondition_Equals{Equals: "rhel"}}),
+		conditionalAlternative("/etc/${fact.os.id}/${fact.os.version_id}/sshd_config${fact.os.variant_id}"),
+	})
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig() returned an error: %v", err)
+	}
+	if len(checks) != 1 {
+		t.Fatalf("Created %d checks, expected only 1", len(checks))
+	}
+	resultMap, _, err := checks[0].Exec("")
+	if err != nil {
+		t.Fatalf("check.Exec() returned an error: %v", err)
+	}
+	result, gotSingleton := singleComplianceResult(resultMap)
+	if !gotSingleton {
+		t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+	}
+	want := &apb.ComplianceResult{
+		Id:                   "id",
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{},
+	}
+	if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+		t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestInvalidFactUsageReturnsError(t *testing.T) {
+	testCases := []struct {
+		desc string
+		alt  *ipb.CheckAlternative
+	}{
+		{
+			desc: "unknown fact in condition",
+			alt: conditionalAlternative(testFilePath,
+				&ipb.FactCondition{Fact: "uptime", Condition: &ipb.FactCondition_Exists{Exists: true}}),
+		},
+		{
+			desc: "invalid regex",
+			alt: conditionalAlternative(testFilePath,
+				&ipb.FactCondition{Fact: "os.id", Condition: &ipb.FactCondition_MatchesRegex{MatchesRegex: "("}}),
+		},
+		{
+			desc: "no condition",
+			alt:  conditionalAlternative(testFilePath, &ipb.FactCondition{Fact: "os.id"}),
+		},
+		{
+			desc: "unknown fact reference",
+			alt:  conditionalAlternative("/etc/${fact.uptime}"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := createChecksWithConditions(t, []*ipb.CheckAlternative{tc.alt}); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig() didn't return an error")
+			}
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{tc.alt},
+			})
+			if err := configchecks.ValidateScanInstructions(config); err == nil {
+				t.Errorf("configchecks.ValidateScanInstructions() didn't return an error")
+			}
+		})
+	}
+}
+
+func TestFactCollectionErrorOnlyFailsBenchmarksUsingTheFact(t *testing.T) {
+	files := map[string]string{"/etc/passwd": "invalid\n"}
+	for path, content := range testHostFiles {
+		files[path] = content
+	}
+	config := &apb.ScanConfig{
+		BenchmarkConfigs: []*apb.BenchmarkConfig{
+			testconfigcreator.NewBenchmarkConfig(t, "users", &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{conditionalAlternative("/home/${fact.users}")},
+			}),
+			testconfigcreator.NewBenchmarkConfig(t, "os", &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{conditionalAlternative("/etc/${fact.os.id}/12/sshd_config")},
+			}),
+		},
+	}
+	checks, err := configchecks.CreateChecksFromConfig(context.Background(), config, newFakeAPI(withFiles(files)))
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig(%v) returned an error: %v", config, err)
+	}
+	errored := []string{}
+	compliant := []string{}
+	for _, c := range checks {
+		resultMap, _, err := c.Exec("")
+		if err != nil {
+			errored = append(errored, c.BenchmarkIDs()...)
+			continue
+		}
+		for _, r := range resultMap {
+			compliant = append(compliant, r.GetId())
+		}
+	}
+	if diff := cmp.Diff([]string{"users"}, errored); diff != "" {
+		t.Errorf("configchecks.CreateChecksFromConfig(%v) returned unexpected checks with errors (-want +got):\n%s", config, diff)
+	}
+	if diff := cmp.Diff([]string{"os"}, compliant); diff != "" {
+		t.Errorf("configchecks.CreateChecksFromConfig(%v) returned unexpected compliant checks (-want +got):\n%s", config, diff)
+	}
+}
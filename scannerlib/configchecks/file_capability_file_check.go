@@ -0,0 +1,135 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/localtoast/scanapi"
+)
+
+const (
+	capabilityXattr = "security.capability"
+
+	vfsCapRevisionMask = 0xff000000
+	vfsCapRevision1    = 0x01000000
+	vfsCapRevision2    = 0x02000000
+	vfsCapRevision3    = 0x03000000
+)
+
+// The names of the capabilities, indexed by their number (see capabilities(7)).
+var capabilityNames = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner",
+	"cap_fsetid", "cap_kill", "cap_setgid", "cap_setuid", "cap_setpcap",
+	"cap_linux_immutable", "cap_net_bind_service", "cap_net_broadcast",
+	"cap_net_admin", "cap_net_raw", "cap_ipc_lock", "cap_ipc_owner",
+	"cap_sys_module", "cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace",
+	"cap_sys_pacct", "cap_sys_admin", "cap_sys_boot", "cap_sys_nice",
+	"cap_sys_resource", "cap_sys_time", "cap_sys_tty_config", "cap_mknod",
+	"cap_lease", "cap_audit_write", "cap_audit_control", "cap_setfcap",
+	"cap_mac_override", "cap_mac_admin", "cap_syslog", "cap_wake_alarm",
+	"cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// fileCapabilityFileChecker checks that files don't have unexpected capabilities.
+type fileCapabilityFileChecker struct {
+	fc      *fileCheck
+	allowed map[string]bool
+}
+
+func newFileCapabilityFileChecker(fc *fileCheck) (*fileCapabilityFileChecker, error) {
+	allowed := make(map[string]bool)
+	for _, c := range fc.checkInstruction.GetFileCapabilities().GetAllowedCapabilities() {
+		name := strings.ToLower(c)
+		if !containsString(capabilityNames, name) {
+			return nil, fmt.Errorf("file capability check %v has unknown capability %q", fc.checkInstruction, c)
+		}
+		allowed[name] = true
+	}
+	return &fileCapabilityFileChecker{fc: fc, allowed: allowed}, nil
+}
+
+func (c *fileCapabilityFileChecker) exec(ctx context.Context, path string, fs scanapi.Filesystem) error {
+	value, err := fs.FileXattr(ctx, path, capabilityXattr)
+	if err != nil {
+		// Return a non-compliance instead of an error if the file doesn't exist.
+		if errors.Is(err, os.ErrNotExist) {
+			c.fc.addNonCompliantFile(path, "File doesn't exist")
+			return nil
+		}
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+	capabilities, err := parseFileCapabilities(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	unexpected := []string{}
+	for _, name := range capabilities {
+		if !c.allowed[name] {
+			unexpected = append(unexpected, name)
+		}
+	}
+	if len(unexpected) > 0 {
+		c.fc.addNonCompliantFile(path, fmt.Sprintf("File has the capabilities %s, expected it not to",
+			strings.Join(unexpected, ",")))
+	}
+	return nil
+}
+
+// parseFileCapabilities decodes the value of the security.capability extended
+// attribute (struct vfs_cap_data) and returns the names of the capabilities in
+// the permitted or inheritable set.
+func parseFileCapabilities(value []byte) ([]string, error) {
+	if len(value) < 4 {
+		return nil, fmt.Errorf("invalid file capabilities of size %d", len(value))
+	}
+	words := 0
+	switch revision := binary.LittleEndian.Uint32(value) & vfsCapRevisionMask; revision {
+	case vfsCapRevision1:
+		words = 1
+	case vfsCapRevision2, vfsCapRevision3:
+		words = 2
+	default:
+		return nil, fmt.Errorf("unsupported file capability revision %#x", revision)
+	}
+	if len(value) < 4+words*8 {
+		return nil, fmt.Errorf("invalid file capabilities of size %d", len(value))
+	}
+	result := []string{}
+	for w := 0; w < words; w++ {
+		permitted := binary.LittleEndian.Uint32(value[4+w*8:])
+		inheritable := binary.LittleEndian.Uint32(value[8+w*8:])
+		for bit := 0; bit < 32; bit++ {
+			if (permitted|inheritable)&(1<<bit) == 0 {
+				continue
+			}
+			if n := w*32 + bit; n < len(capabilityNames) {
+				result = append(result, capabilityNames[n])
+			} else {
+				result = append(result, fmt.Sprintf("cap_%d", n))
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+// capabilityXattr encodes a revision 2 security.capability value with the
+// given permitted and inheritable capability bit sets.
+func capabilityXattr(permitted, inheritable uint64) []byte {
+	value := binary.LittleEndian.AppendUint32(nil, 0x02000001)
+	for w := 0; w < 2; w++ {
+		value = binary.LittleEndian.AppendUint32(value, uint32(permitted>>(32*w)))
+		value = binary.LittleEndian.AppendUint32(value, uint32(inheritable>>(32*w)))
+	}
+	return value
+}
+
+func TestFileCapabilityCheckUnknownCapabilityReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(testFilePath)},
+		CheckType: &ipb.FileCheck_FileCapabilities{FileCapabilities: &ipb.FileCapabilityCheck{
+			AllowedCapabilities: []string{"cap_fly"},
+		}},
+	}})
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+	if _, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI()); err == nil {
+		t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+	}
+}
+
+func TestFileCapabilityCheckComplianceResults(t *testing.T) {
+	const (
+		capNetRaw   = 1 << 13
+		capSetuid   = 1 << 7
+		capSetgid   = 1 << 6
+		capPerfmon  = 1 << 38
+		capNetAdmin = 1 << 12
+	)
+	xattrs := map[string]map[string][]byte{
+		"/usr/bin/ping":       {"security.capability": capabilityXattr(capNetRaw, 0)},
+		"/usr/bin/newuidmap":  {"security.capability": capabilityXattr(capSetuid, capSetgid)},
+		"/usr/bin/perf":       {"security.capability": capabilityXattr(capPerfmon|capNetAdmin, 0)},
+		"/usr/bin/no-caps":    {},
+		"/usr/bin/other-attr": {"user.comment": []byte("comment")},
+	}
+	testCases := []struct {
+		desc                      string
+		filePath                  string
+		allowed                   []string
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc:                      "no capabilities",
+			filePath:                  "/usr/bin/no-caps",
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:                      "other extended attributes",
+			filePath:                  "/usr/bin/other-attr",
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:                      "allowed capability",
+			filePath:                  "/usr/bin/ping",
+			allowed:                   []string{"CAP_NET_RAW"},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "permitted and inheritable capabilities",
+			filePath: "/usr/bin/newuidmap",
+			allowed:  []string{"cap_net_raw"},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/usr/bin/newuidmap",
+				Reason: "File has the capabilities cap_setgid,cap_setuid, expected it not to",
+			}},
+		},
+		{
+			desc:     "capabilities in the upper word",
+			filePath: "/usr/bin/perf",
+			allowed:  []string{"cap_net_admin"},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/usr/bin/perf",
+				Reason: "File has the capabilities cap_perfmon, expected it not to",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(tc.filePath)},
+				CheckType: &ipb.FileCheck_FileCapabilities{FileCapabilities: &ipb.FileCapabilityCheck{
+					AllowedCapabilities: tc.allowed,
+				}},
+			}}, newFakeAPI(withFileXattrs(xattrs)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFileCapabilityCheckInvalidValueReturnsError(t *testing.T) {
+	check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/usr/bin/truncated")},
+		CheckType:    &ipb.FileCheck_FileCapabilities{FileCapabilities: &ipb.FileCapabilityCheck{}},
+	}}, newFakeAPI(withFileXattrs(map[string]map[string][]byte{
+		"/usr/bin/truncated": {"security.capability": {0x01, 0x00, 0x00, 0x02, 0x00}},
+	})))
+	if _, _, err := check.Exec(""); err == nil {
+		t.Errorf("check.Exec() didn't return an error")
+	}
+}
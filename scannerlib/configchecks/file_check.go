@@ -17,6 +17,7 @@
 package configchecks
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
@@ -30,7 +31,9 @@ import (
 	"google.golang.org/protobuf/proto"
 	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
 	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/facts"
 	"github.com/google/localtoast/scannerlib/fileset"
+	"github.com/google/localtoast/scannerlib/packages"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
 	"github.com/google/localtoast/scannerlib/repeatconfig"
@@ -49,7 +52,9 @@ type FileCheckBatch struct {
 	filesToCheck *ipb.FileSet
 	timeout      *timeoutOptions
 	fs           scanapi.Filesystem
+	pkgs         *packages.Reader
 	benchmarkIDs []string
+	vars         variables
 }
 
 // Exec executes the file checks batched by the FileCheckBatch.
@@ -58,17 +63,40 @@ func (b *FileCheckBatch) Exec(prvRes string) (ComplianceMap, string, error) {
 
 	fileset.ApplyPipelineTokenReplacement(b.filesToCheck, prvRes)
 
-	err := fileset.WalkFiles(b.ctx, b.filesToCheck, b.fs, b.timeout.benchmarkCheckTimeoutNow(),
+	hasExports := false
+	for _, fc := range b.fileChecks {
+		hasExports = hasExports || len(fc.exports) > 0
+	}
+	// The files the variables are exported from.
+	paths := []string{}
+	err := fileset.WalkFilesWithPackages(b.ctx, b.filesToCheck, b.fs, b.pkgs, b.timeout.benchmarkCheckTimeoutNow(),
 		func(path string, isDir bool, traversingDir bool) error {
+			if hasExports && !isDir {
+				paths = append(paths, path)
+			}
 			return b.fileCheckers.execChecksOnFile(b.ctx, path, isDir, traversingDir, b.fs)
 		})
 	if err != nil {
 		return nil, "", err
 	}
 	b.fileCheckers.execChecksAfterFileTraversal(b.filesToCheck)
+	if err := b.exportVariables(paths); err != nil {
+		return nil, "", err
+	}
 	return aggregateComplianceResults(b.fileChecks)
 }
 
+// exportVariables sets the variables exported by the file checks of the batch
+// from the given files of the batch's file set.
+func (b *FileCheckBatch) exportVariables(paths []string) error {
+	for _, fc := range b.fileChecks {
+		if err := b.vars.exportFromFiles(b.ctx, fc.exports, paths, b.fs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // BenchmarkIDs returns the IDs of the benchmarks associated with this check.
 func (b *FileCheckBatch) BenchmarkIDs() []string {
 	return b.benchmarkIDs
@@ -86,6 +114,7 @@ type fileCheck struct {
 	filesToCheck          *ipb.FileSet
 	contentOptoutRegexes  []*regexp.Regexp
 	filenameOptoutRegexes []*regexp.Regexp
+	exports               []*variableExport
 	nonCompliantFiles     []*cpb.NonCompliantFile
 	err                   error
 }
@@ -123,7 +152,7 @@ type fileCheckBatchMap map[fileCheckBatchCommonProps][]*fileCheck
 // createFileCheckBatchesFromConfig parses the benchmark config and creates the
 // file check batches defined by it.
 func createFileCheckBatchesFromConfig(
-	ctx context.Context, benchmarks []*benchmark, optOut *apb.OptOutConfig, replacement *apb.ReplacementConfig, timeout *timeoutOptions, fs scanapi.Filesystem) ([]*FileCheckBatch, error) {
+	ctx context.Context, benchmarks []*benchmark, optOut *apb.OptOutConfig, replacement *apb.ReplacementConfig, timeout *timeoutOptions, api scanapi.ScanAPI, collector *facts.Collector, vars variables) ([]*FileCheckBatch, error) {
 	batchMap := make(fileCheckBatchMap)
 
 	for _, b := range benchmarks {
@@ -135,7 +164,8 @@ func createFileCheckBatchesFromConfig(
 				options := addFileCheckToBatchMapOptions{
 					fileCheckInstruction,
 					batchMap,
-					fs,
+					api,
+					collector,
 					optOut,
 					replacement,
 					b.id,
@@ -150,7 +180,7 @@ func createFileCheckBatchesFromConfig(
 
 	fileCheckBatches := make([]*FileCheckBatch, 0, len(batchMap))
 	for _, fileChecks := range batchMap {
-		batch, err := newFileCheckBatch(ctx, fileChecks, fileChecks[0].filesToCheck, timeout, fs)
+		batch, err := newFileCheckBatch(ctx, fileChecks, fileChecks[0].filesToCheck, timeout, api, collector.Packages(), vars)
 		if err != nil {
 			return nil, err
 		}
@@ -169,7 +199,8 @@ func validateFileCheckInstruction(instruction *ipb.FileCheck) error {
 type addFileCheckToBatchMapOptions struct {
 	fc            *ipb.FileCheck
 	batchMap      fileCheckBatchMap
-	fs            scanapi.Filesystem
+	api           scanapi.ScanAPI
+	collector     *facts.Collector
 	optOut        *apb.OptOutConfig
 	replacement   *apb.ReplacementConfig
 	benchmarkID   string
@@ -177,7 +208,15 @@ type addFileCheckToBatchMapOptions struct {
 }
 
 func addFileCheckToBatchMap(ctx context.Context, options addFileCheckToBatchMapOptions) error {
-	repeatConfigs, err := repeatconfig.CreateRepeatConfigs(ctx, options.fc.GetRepeatConfig(), options.fs)
+	repeatOptions := options.fc.GetRepeatConfigs()
+	if options.fc.GetRepeatConfig() != nil {
+		repeatOptions = append([]*ipb.RepeatConfig{options.fc.GetRepeatConfig()}, repeatOptions...)
+	}
+	repeatConfigs, err := repeatconfig.CreateCombinedRepeatConfigsWithFacts(ctx, repeatOptions, options.api, options.collector)
+	if err != nil {
+		return err
+	}
+	exports, err := compileExports(options.fc.GetExports())
 	if err != nil {
 		return err
 	}
@@ -211,6 +250,7 @@ func addFileCheckToBatchMap(ctx context.Context, options addFileCheckToBatchMapO
 					filesToCheck:          filesToCheck,
 					contentOptoutRegexes:  contentOptoutRegexes,
 					filenameOptoutRegexes: filenameOptoutRegexes,
+					exports:               exports,
 					err:                   repeatConfig.Err,
 				})
 		}
@@ -221,11 +261,20 @@ func addFileCheckToBatchMap(ctx context.Context, options addFileCheckToBatchMapO
 type fileCheckers struct {
 	existenceFileCheckers    []*existenceFileChecker
 	permissionFileCheckers   []*permissionFileChecker
+	fileMetadataFileCheckers []*fileMetadataFileChecker
+	aclFileCheckers          []*aclFileChecker
+	selinuxLabelFileCheckers []*selinuxLabelFileChecker
+	capabilityFileCheckers   []*fileCapabilityFileChecker
+	packageFileCheckers      []*packageIntegrityFileChecker
 	contentFileCheckers      []*contentFileChecker
 	contentEntryFileCheckers []*contentEntryFileChecker
+	auditRuleFileCheckers    []*auditRuleFileChecker
+	hashFileCheckers         []*hashFileChecker
 }
 
-func newFileCheckers(fileChecks []*fileCheck) (*fileCheckers, error) {
+// newFileCheckers creates the checkers of the file checks. The package
+// integrity checkers read the packaged files through pkgs.
+func newFileCheckers(fileChecks []*fileCheck, pkgs *packages.Reader) (*fileCheckers, error) {
 	result := &fileCheckers{}
 	for _, fc := range fileChecks {
 		if fc.err != nil { // The check couldn't properly be created because of an error.
@@ -243,6 +292,48 @@ func newFileCheckers(fileChecks []*fileCheck) (*fileCheckers, error) {
 				return nil, err
 			}
 			result.contentEntryFileCheckers = append(result.contentEntryFileCheckers, checker)
+		} else if fc.checkInstruction.GetAuditRule() != nil {
+			checker, err := newAuditRuleFileChecker(fc)
+			if err != nil {
+				return nil, err
+			}
+			result.auditRuleFileCheckers = append(result.auditRuleFileCheckers, checker)
+		} else if fc.checkInstruction.GetHash() != nil {
+			checker, err := newHashFileChecker(fc)
+			if err != nil {
+				return nil, err
+			}
+			result.hashFileCheckers = append(result.hashFileCheckers, checker)
+		} else if fc.checkInstruction.GetFileMetadata() != nil {
+			checker, err := newFileMetadataFileChecker(fc)
+			if err != nil {
+				return nil, err
+			}
+			result.fileMetadataFileCheckers = append(result.fileMetadataFileCheckers, checker)
+		} else if fc.checkInstruction.GetAcl() != nil {
+			checker, err := newACLFileChecker(fc)
+			if err != nil {
+				return nil, err
+			}
+			result.aclFileCheckers = append(result.aclFileCheckers, checker)
+		} else if fc.checkInstruction.GetSelinuxLabel() != nil {
+			checker, err := newSelinuxLabelFileChecker(fc)
+			if err != nil {
+				return nil, err
+			}
+			result.selinuxLabelFileCheckers = append(result.selinuxLabelFileCheckers, checker)
+		} else if fc.checkInstruction.GetFileCapabilities() != nil {
+			checker, err := newFileCapabilityFileChecker(fc)
+			if err != nil {
+				return nil, err
+			}
+			result.capabilityFileCheckers = append(result.capabilityFileCheckers, checker)
+		} else if fc.checkInstruction.GetPackageIntegrity() != nil {
+			checker, err := newPackageIntegrityFileChecker(fc, pkgs)
+			if err != nil {
+				return nil, err
+			}
+			result.packageFileCheckers = append(result.packageFileCheckers, checker)
 		} else {
 			return nil, fmt.Errorf("Received FileCheck with unexpected type: %v", fc.checkInstruction)
 		}
@@ -269,6 +360,11 @@ func (c *fileCheckers) execChecksOnFile(ctx context.Context, path string, isDir
 	if f != nil {
 		defer f.Close()
 	}
+	// The hashes are computed from the same reader the content checks use.
+	hashes := c.newHashes()
+	if f != nil && len(hashes) > 0 {
+		f = newHashingReadCloser(f, hashes)
+	}
 
 	for _, checker := range c.existenceFileCheckers {
 		if err := checker.exec(path, openError, isDir, traversingDir); err != nil {
@@ -280,12 +376,54 @@ func (c *fileCheckers) execChecksOnFile(ctx context.Context, path string, isDir
 			return err
 		}
 	}
+	for _, checker := range c.fileMetadataFileCheckers {
+		if err := checker.exec(ctx, path, fs); err != nil {
+			return err
+		}
+	}
+	for _, checker := range c.aclFileCheckers {
+		if err := checker.exec(ctx, path, fs); err != nil {
+			return err
+		}
+	}
+	for _, checker := range c.selinuxLabelFileCheckers {
+		if err := checker.exec(ctx, path, fs); err != nil {
+			return err
+		}
+	}
+	for _, checker := range c.capabilityFileCheckers {
+		if err := checker.exec(ctx, path, fs); err != nil {
+			return err
+		}
+	}
+	for _, checker := range c.packageFileCheckers {
+		if err := checker.exec(ctx, path, fs); err != nil {
+			return err
+		}
+	}
+	if len(c.auditRuleFileCheckers) > 0 && f != nil &&
+		(len(c.contentFileCheckers) > 0 || len(c.contentEntryFileCheckers) > 0) {
+		// Keep the content in memory since the other content checks need to read it too.
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		if err := c.execAuditRuleChecksOnFile(path, openError, bytes.NewReader(content)); err != nil {
+			return err
+		}
+		f = io.NopCloser(bytes.NewReader(content))
+	} else if err := c.execAuditRuleChecksOnFile(path, openError, f); err != nil {
+		return err
+	}
 	if err := c.execContentChecksOnFile(path, openError, f); err != nil {
 		return err
 	}
 	if err := c.execContentEntryChecksOnFile(path, openError, f); err != nil {
 		return err
 	}
+	if err := c.execHashChecksOnFile(path, openError, f, hashes); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -294,10 +432,12 @@ func (c *fileCheckers) execChecksAfterFileTraversal(filesToCheck *ipb.FileSet) {
 		checker.execAfterFileTraversal()
 	}
 	c.execContentEntryChecksAfterFileTraversal(filesToCheck)
+	c.execAuditRuleChecksAfterFileTraversal(filesToCheck)
 }
 
 func (c *fileCheckers) openFileForCheckExec(ctx context.Context, path string, fs scanapi.Filesystem) (io.ReadCloser, error) {
-	if len(c.contentFileCheckers) == 0 && len(c.contentEntryFileCheckers) == 0 {
+	if len(c.contentFileCheckers) == 0 && len(c.contentEntryFileCheckers) == 0 &&
+		len(c.auditRuleFileCheckers) == 0 && len(c.hashFileCheckers) == 0 {
 		// We won't read the file, we only care about whether it could successfully be opened.
 		f, openError := fs.OpenFile(ctx, path)
 		if f != nil {
@@ -322,7 +462,7 @@ func strToRegex(strs []string) ([]*regexp.Regexp, error) {
 
 // newFileCheckBatch creates a FileCheckBatch from fileChecks that perform checks on the same files.
 func newFileCheckBatch(
-	ctx context.Context, fileChecks []*fileCheck, filesToCheck *ipb.FileSet, timeout *timeoutOptions, fs scanapi.Filesystem) (*FileCheckBatch, error) {
+	ctx context.Context, fileChecks []*fileCheck, filesToCheck *ipb.FileSet, timeout *timeoutOptions, fs scanapi.Filesystem, pkgs *packages.Reader, vars variables) (*FileCheckBatch, error) {
 	// De-duplicate the benchmark IDs.
 	benchmarkIDMap := make(map[string]bool)
 	for _, fc := range fileChecks {
@@ -333,7 +473,7 @@ func newFileCheckBatch(
 		benchmarkIDs = append(benchmarkIDs, id)
 	}
 
-	fileCheckers, err := newFileCheckers(fileChecks)
+	fileCheckers, err := newFileCheckers(fileChecks, pkgs)
 	if err != nil {
 		return nil, err
 	}
@@ -345,7 +485,9 @@ func newFileCheckBatch(
 		filesToCheck: filesToCheck,
 		timeout:      timeout,
 		fs:           fs,
+		pkgs:         pkgs,
 		benchmarkIDs: benchmarkIDs,
+		vars:         vars,
 	}, nil
 }
 
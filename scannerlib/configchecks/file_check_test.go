@@ -651,10 +651,22 @@ func (dirWithUnreadableFile) FilePermissions(ctx context.Context, filePath strin
 	return nil, errors.New("not implemented")
 }
 
+func (dirWithUnreadableFile) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (dirWithUnreadableFile) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (dirWithUnreadableFile) SQLQuery(ctx context.Context, query string) (string, error) {
 	return "", errors.New("not implemented")
 }
 
+func (dirWithUnreadableFile) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (dirWithUnreadableFile) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	return ipb.SQLCheck_DB_UNSPECIFIED, errors.New("not implemented")
 }
@@ -1451,6 +1463,84 @@ func TestRepeatConfigApplied(t *testing.T) {
 	}
 }
 
+func TestCombinedRepeatConfigsApplied(t *testing.T) {
+	files := map[string]string{
+		"/etc/passwd": "user1:x:1337:1338::/home/user1:/bin/bash\n" +
+			"user2:x:1339:1340::/home/user2:/bin/bash\n",
+		"/proc/self/mountinfo": "21 1 8:0 / / rw - ext4 /dev/sda rw\n" +
+			"22 21 8:1 / /home rw - ext4 /dev/sda1 rw\n" +
+			"23 21 8:2 / /srv rw - ext4 /dev/sda2 rw\n",
+		"/home/user1/.profile": "",
+		"/home/user2/.profile": "",
+		"/srv/user1/.profile":  "",
+	}
+	fileChecks := []*ipb.FileCheck{{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("$mountpoint/$user/.profile")},
+		CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+		RepeatConfig: &ipb.RepeatConfig{Type: ipb.RepeatConfig_FOR_EACH_USER_WITH_LOGIN},
+		RepeatConfigs: []*ipb.RepeatConfig{{
+			Type:   ipb.RepeatConfig_FOR_EACH_MOUNT,
+			OptOut: []*ipb.RepeatConfig_OptOutSubstitution{{Wildcard: "$mountpoint", Value: "/"}},
+		}},
+	}}
+	scanInstruction := testconfigcreator.NewFileScanInstruction(fileChecks)
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+	checks, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI(withFiles(files)))
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig([%v]) returned an error: %v", config, err)
+	}
+	if len(checks) != 4 {
+		t.Fatalf("configchecks.CreateChecksFromConfig([%v]) created %d checks, expected 4", config, len(checks))
+	}
+
+	// The checks are created in an arbitrary order, collect the non-compliant files of all.
+	got := []*cpb.NonCompliantFile{}
+	for _, c := range checks {
+		resultMap, _, err := c.Exec("")
+		if err != nil {
+			t.Fatalf("%v.Exec() returned an error: %v", c, err)
+		}
+		result, gotSingleton := singleComplianceResult(resultMap)
+		if !gotSingleton {
+			t.Fatalf("%v.Exec() expected to return 1 result, got %d", c, len(resultMap))
+		}
+		got = append(got, result.GetComplianceOccurrence().GetNonCompliantFiles()...)
+	}
+	want := []*cpb.NonCompliantFile{{
+		Path:   fileset.FileSetToString(testconfigcreator.SingleFileWithPath("/srv/user2/.profile")),
+		Reason: "File doesn't exist but it should",
+	}}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestCombinedRepeatConfigsWithCollidingWildcardsReturnError(t *testing.T) {
+	fileChecks := []*ipb.FileCheck{{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/run/user/$uid/$gid")},
+		CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+		RepeatConfigs: []*ipb.RepeatConfig{
+			{Type: ipb.RepeatConfig_FOR_EACH_USER},
+			{Type: ipb.RepeatConfig_FOR_EACH_GROUP},
+		},
+	}}
+	scanInstruction := testconfigcreator.NewFileScanInstruction(fileChecks)
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+	if _, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI()); err == nil {
+		t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+	}
+}
+
 func TestRepeatConfigCreationFails(t *testing.T) {
 	passwdContent := "invalid\n"
 	fileChecks := []*ipb.FileCheck{&ipb.FileCheck{
@@ -1514,10 +1604,22 @@ func (manyFilesAPI) FilePermissions(ctx context.Context, filePath string) (*apb.
 	return &apb.PosixPermissions{User: "root"}, nil
 }
 
+func (manyFilesAPI) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	return &apb.FileStat{Type: apb.FileStat_REGULAR_FILE, Nlink: 1}, nil
+}
+
+func (manyFilesAPI) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return nil, nil
+}
+
 func (manyFilesAPI) SQLQuery(ctx context.Context, query string) (string, error) {
 	return "", errors.New("not implemented")
 }
 
+func (manyFilesAPI) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (manyFilesAPI) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	return 0, errors.New("not implemented")
 }
@@ -0,0 +1,70 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// fileMetadataFileChecker performs checks on the type and link metadata of files.
+type fileMetadataFileChecker struct {
+	fc *fileCheck
+}
+
+func newFileMetadataFileChecker(fc *fileCheck) (*fileMetadataFileChecker, error) {
+	mc := fc.checkInstruction.GetFileMetadata()
+	if mc.GetFileType() == ipb.FileMetadataCheck_TYPE_UNSPECIFIED && mc.GetLinkTarget() == "" && !mc.GetNoHardLinks() {
+		return nil, fmt.Errorf("file metadata check %v has no assertions", fc.checkInstruction)
+	}
+	if mc.GetLinkTarget() != "" && mc.GetFileType() != ipb.FileMetadataCheck_TYPE_UNSPECIFIED &&
+		mc.GetFileType() != ipb.FileMetadataCheck_SYMLINK {
+		return nil, fmt.Errorf("file metadata check %v expects a link target for a file that's not a symlink", fc.checkInstruction)
+	}
+	return &fileMetadataFileChecker{fc: fc}, nil
+}
+
+func (c *fileMetadataFileChecker) exec(ctx context.Context, path string, fs scanapi.Filesystem) error {
+	stat, err := fs.FileStat(ctx, path)
+	if err != nil {
+		// Return a non-compliance instead of an error if the file doesn't exist.
+		if errors.Is(err, os.ErrNotExist) {
+			c.fc.addNonCompliantFile(path, "File doesn't exist")
+			return nil
+		}
+		return err
+	}
+	mc := c.fc.checkInstruction.GetFileMetadata()
+	if mc.GetFileType() != ipb.FileMetadataCheck_TYPE_UNSPECIFIED && stat.GetType().String() != mc.GetFileType().String() {
+		c.fc.addNonCompliantFile(path, fmt.Sprintf("File type is %s, expected %s", stat.GetType(), mc.GetFileType()))
+	}
+	if mc.GetLinkTarget() != "" {
+		if stat.GetType() != apb.FileStat_SYMLINK {
+			c.fc.addNonCompliantFile(path, fmt.Sprintf("File is not a symlink, expected it to link to %s", mc.GetLinkTarget()))
+		} else if stat.GetLinkTarget() != mc.GetLinkTarget() {
+			c.fc.addNonCompliantFile(path, fmt.Sprintf("File links to %s, expected %s", stat.GetLinkTarget(), mc.GetLinkTarget()))
+		}
+	}
+	if mc.GetNoHardLinks() && stat.GetType() != apb.FileStat_DIRECTORY && stat.GetNlink() > 1 {
+		c.fc.addNonCompliantFile(path, fmt.Sprintf("File has %d hard links, expected it to have none", stat.GetNlink()))
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+func TestFileMetadataCheckInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		check *ipb.FileMetadataCheck
+	}{
+		{
+			desc:  "no assertions",
+			check: &ipb.FileMetadataCheck{},
+		},
+		{
+			desc: "link target on regular file",
+			check: &ipb.FileMetadataCheck{
+				FileType:   ipb.FileMetadataCheck_REGULAR_FILE,
+				LinkTarget: "/proc/self/mounts",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(testFilePath)},
+				CheckType:    &ipb.FileCheck_FileMetadata{FileMetadata: tc.check},
+			}})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestFileMetadataCheckComplianceResults(t *testing.T) {
+	fileStats := map[string]*apb.FileStat{
+		"/etc/mtab":        {Type: apb.FileStat_SYMLINK, LinkTarget: "../proc/self/mounts", Nlink: 1},
+		"/etc/passwd":      {Type: apb.FileStat_REGULAR_FILE, Nlink: 1},
+		"/etc/shadow":      {Type: apb.FileStat_REGULAR_FILE, Nlink: 2},
+		"/etc/initctl":     {Type: apb.FileStat_FIFO, Nlink: 1},
+		"/etc/ssh":         {Type: apb.FileStat_DIRECTORY, Nlink: 4},
+		"/etc/resolv.conf": {Type: apb.FileStat_SYMLINK, LinkTarget: "/proc/self/mounts", Nlink: 1},
+	}
+	testCases := []struct {
+		desc                      string
+		filePath                  string
+		check                     *ipb.FileMetadataCheck
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc:     "symlink with expected target",
+			filePath: "/etc/resolv.conf",
+			check: &ipb.FileMetadataCheck{
+				FileType:   ipb.FileMetadataCheck_SYMLINK,
+				LinkTarget: "/proc/self/mounts",
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "symlink with different target",
+			filePath: "/etc/mtab",
+			check:    &ipb.FileMetadataCheck{LinkTarget: "/proc/self/mounts"},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/mtab",
+				Reason: "File links to ../proc/self/mounts, expected /proc/self/mounts",
+			}},
+		},
+		{
+			desc:     "regular file instead of symlink",
+			filePath: "/etc/passwd",
+			check:    &ipb.FileMetadataCheck{LinkTarget: "/proc/self/mounts"},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/passwd",
+				Reason: "File is not a symlink, expected it to link to /proc/self/mounts",
+			}},
+		},
+		{
+			desc:     "FIFO instead of regular file",
+			filePath: "/etc/initctl",
+			check:    &ipb.FileMetadataCheck{FileType: ipb.FileMetadataCheck_REGULAR_FILE},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/initctl",
+				Reason: "File type is FIFO, expected REGULAR_FILE",
+			}},
+		},
+		{
+			desc:     "hard linked file",
+			filePath: "/etc/shadow",
+			check: &ipb.FileMetadataCheck{
+				FileType:    ipb.FileMetadataCheck_REGULAR_FILE,
+				NoHardLinks: true,
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/shadow",
+				Reason: "File has 2 hard links, expected it to have none",
+			}},
+		},
+		{
+			desc:                      "directory link count ignored",
+			filePath:                  "/etc/ssh",
+			check:                     &ipb.FileMetadataCheck{NoHardLinks: true},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "file doesn't exist",
+			filePath: "/etc/nonexistent",
+			check:    &ipb.FileMetadataCheck{FileType: ipb.FileMetadataCheck_REGULAR_FILE},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/nonexistent",
+				Reason: "File doesn't exist",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(tc.filePath)},
+				CheckType:    &ipb.FileCheck_FileMetadata{FileMetadata: tc.check},
+			}}, newFakeAPI(withFileStats(fileStats)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
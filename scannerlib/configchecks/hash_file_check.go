@@ -0,0 +1,119 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// hashFileChecker checks whether the digest of the files is in an allow-list.
+type hashFileChecker struct {
+	fc              *fileCheck
+	algorithm       ipb.HashCheck_Algorithm
+	expectedDigests map[string]bool
+}
+
+func newHashFileChecker(fc *fileCheck) (*hashFileChecker, error) {
+	hc := fc.checkInstruction.GetHash()
+	if len(hc.GetExpectedDigests()) == 0 {
+		return nil, fmt.Errorf("hash check %v has no expected digests", fc.checkInstruction)
+	}
+	digestLen := newHash(hc.GetAlgorithm()).Size() * 2
+	expectedDigests := make(map[string]bool)
+	for _, d := range hc.GetExpectedDigests() {
+		d = strings.ToLower(d)
+		if _, err := hex.DecodeString(d); err != nil || len(d) != digestLen {
+			return nil, fmt.Errorf("hash check %v has invalid %s digest %q", fc.checkInstruction, hc.GetAlgorithm(), d)
+		}
+		expectedDigests[d] = true
+	}
+	return &hashFileChecker{
+		fc:              fc,
+		algorithm:       hc.GetAlgorithm(),
+		expectedDigests: expectedDigests,
+	}, nil
+}
+
+func newHash(algorithm ipb.HashCheck_Algorithm) hash.Hash {
+	if algorithm == ipb.HashCheck_SHA512 {
+		return sha512.New()
+	}
+	return sha256.New()
+}
+
+// newHashes creates a hash for each algorithm used by the hash checks.
+func (c *fileCheckers) newHashes() map[ipb.HashCheck_Algorithm]hash.Hash {
+	hashes := make(map[ipb.HashCheck_Algorithm]hash.Hash)
+	for _, checker := range c.hashFileCheckers {
+		if _, ok := hashes[checker.algorithm]; !ok {
+			hashes[checker.algorithm] = newHash(checker.algorithm)
+		}
+	}
+	return hashes
+}
+
+// hashingReadCloser writes everything read from the file into the hashes.
+type hashingReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newHashingReadCloser(f io.ReadCloser, hashes map[ipb.HashCheck_Algorithm]hash.Hash) io.ReadCloser {
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	return &hashingReadCloser{
+		Reader: io.TeeReader(f, io.MultiWriter(writers...)),
+		Closer: f,
+	}
+}
+
+func (c *fileCheckers) execHashChecksOnFile(path string, openError error, f io.Reader, hashes map[ipb.HashCheck_Algorithm]hash.Hash) error {
+	if len(c.hashFileCheckers) == 0 {
+		return nil
+	}
+	exists, err := fileExists(openError)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		for _, checker := range c.hashFileCheckers {
+			checker.fc.addNonCompliantFile(path, "File doesn't exist")
+		}
+		return nil
+	}
+
+	// Hash the rest of the file the other checks didn't read.
+	if _, err := io.Copy(io.Discard, f); err != nil {
+		return err
+	}
+	for _, checker := range c.hashFileCheckers {
+		digest := hex.EncodeToString(hashes[checker.algorithm].Sum(nil))
+		if !checker.expectedDigests[digest] {
+			checker.fc.addNonCompliantFile(path, fmt.Sprintf("Got %s digest %s, expected one of [%s]",
+				checker.algorithm, digest, strings.Join(checker.fc.checkInstruction.GetHash().GetExpectedDigests(), ", ")))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+const (
+	hashedFilePath    = "/etc/ssh/moduli"
+	hashedFileContent = "# Time Type Tests Tries Size Generator Modulus\n20230101000000 2 6 100 2047 2 C0FFEE\n"
+)
+
+func sha256Hex(content string) string {
+	digest := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(digest[:])
+}
+
+func sha512Hex(content string) string {
+	digest := sha512.Sum512([]byte(content))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestHashCheckInvalidDigestsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		check *ipb.HashCheck
+	}{
+		{
+			desc:  "no digests",
+			check: &ipb.HashCheck{},
+		},
+		{
+			desc:  "not hex",
+			check: &ipb.HashCheck{ExpectedDigests: []string{strings.Repeat("z", 64)}},
+		},
+		{
+			desc: "sha256 digest for sha512",
+			check: &ipb.HashCheck{
+				Algorithm:       ipb.HashCheck_SHA512,
+				ExpectedDigests: []string{sha256Hex(hashedFileContent)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(hashedFilePath)},
+				CheckType:    &ipb.FileCheck_Hash{Hash: tc.check},
+			}})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestHashCheckComplianceResults(t *testing.T) {
+	testFiles := map[string]string{hashedFilePath: hashedFileContent}
+	wrongDigest := sha256Hex("other content")
+	testCases := []struct {
+		desc                      string
+		filePath                  string
+		check                     *ipb.HashCheck
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc:     "sha256 in allow-list",
+			filePath: hashedFilePath,
+			check: &ipb.HashCheck{
+				ExpectedDigests: []string{wrongDigest, sha256Hex(hashedFileContent)},
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "upper case sha512",
+			filePath: hashedFilePath,
+			check: &ipb.HashCheck{
+				Algorithm:       ipb.HashCheck_SHA512,
+				ExpectedDigests: []string{strings.ToUpper(sha512Hex(hashedFileContent))},
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "digest not in allow-list",
+			filePath: hashedFilePath,
+			check: &ipb.HashCheck{
+				ExpectedDigests: []string{wrongDigest},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   hashedFilePath,
+				Reason: "Got SHA256 digest " + sha256Hex(hashedFileContent) + ", expected one of [" + wrongDigest + "]",
+			}},
+		},
+		{
+			desc:     "file doesn't exist",
+			filePath: "/etc/sudoers",
+			check: &ipb.HashCheck{
+				ExpectedDigests: []string{wrongDigest},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/sudoers",
+				Reason: "File doesn't exist",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(tc.filePath)},
+				CheckType:    &ipb.FileCheck_Hash{Hash: tc.check},
+			}}, newFakeAPI(withFiles(testFiles)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHashCheckBatchedWithContentChecks(t *testing.T) {
+	testFiles := map[string]string{hashedFilePath: hashedFileContent}
+	fileSet := []*ipb.FileSet{testconfigcreator.SingleFileWithPath(hashedFilePath)}
+	check := createFileCheckBatch(t, "id", []*ipb.FileCheck{
+		{
+			FilesToCheck: fileSet,
+			CheckType: &ipb.FileCheck_Hash{Hash: &ipb.HashCheck{
+				ExpectedDigests: []string{sha256Hex(hashedFileContent)},
+			}},
+		},
+		{
+			FilesToCheck: fileSet,
+			CheckType: &ipb.FileCheck_Hash{Hash: &ipb.HashCheck{
+				Algorithm:       ipb.HashCheck_SHA512,
+				ExpectedDigests: []string{sha512Hex(hashedFileContent)},
+			}},
+		},
+		{
+			FilesToCheck: fileSet,
+			CheckType: &ipb.FileCheck_Content{Content: &ipb.ContentCheck{
+				Content: hashedFileContent,
+			}},
+		},
+	}, newFakeAPI(withFiles(testFiles)))
+
+	resultMap, _, err := check.Exec("")
+	if err != nil {
+		t.Fatalf("check.Exec() returned an error: %v", err)
+	}
+	result, gotSingleton := singleComplianceResult(resultMap)
+	if !gotSingleton {
+		t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+	}
+	want := &apb.ComplianceResult{
+		Id:                   "id",
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{},
+	}
+	if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+		t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
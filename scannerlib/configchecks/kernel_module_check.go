@@ -0,0 +1,407 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+const (
+	procModulesPath   = "/proc/modules"
+	kernelReleasePath = "/proc/sys/kernel/osrelease"
+	libModulesPath    = "/lib/modules"
+)
+
+// modprobeConfigDirs lists the directories modprobe reads its config files
+// from. If files with the same name exist in several directories, the one in
+// the directory listed first takes precedence.
+var modprobeConfigDirs = []string{
+	"/etc/modprobe.d",
+	"/run/modprobe.d",
+	"/usr/local/lib/modprobe.d",
+	"/lib/modprobe.d",
+	"/usr/lib/modprobe.d",
+}
+
+// KernelModuleCheck is an implementation of configchecks.BenchmarkCheck.
+// It checks whether a kernel module is loadable, blacklisted, loaded, or
+// available for the running kernel.
+type KernelModuleCheck struct {
+	ctx              context.Context
+	benchmarkID      string
+	alternativeID    int
+	checkInstruction *ipb.KernelModuleCheck
+	fs               scanapi.Filesystem
+}
+
+// Exec executes the kernel module check and returns the compliance status.
+func (c *KernelModuleCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	name := normalizeModuleName(c.checkInstruction.GetModuleName())
+	reasons := []string{}
+
+	if c.checkInstruction.GetLoadable() != ipb.KernelModuleCheck_DONT_CARE ||
+		c.checkInstruction.GetBlacklisted() != ipb.KernelModuleCheck_DONT_CARE {
+		config, err := readModprobeConfig(c.ctx, c.fs)
+		if err != nil {
+			return nil, "", err
+		}
+		install := config.installCommand(name)
+		loadable := install == nil || !isDisabledInstallCommand(install.args)
+		source := ""
+		if install != nil {
+			source = install.source
+		}
+		reasons = appendModuleStateReason(reasons, name, "loadable", source, loadable, c.checkInstruction.GetLoadable())
+
+		blacklist := config.blacklistEntry(name)
+		source = ""
+		if blacklist != nil {
+			source = blacklist.source
+		}
+		reasons = appendModuleStateReason(reasons, name, "blacklisted", source, blacklist != nil, c.checkInstruction.GetBlacklisted())
+	}
+
+	if c.checkInstruction.GetLoaded() != ipb.KernelModuleCheck_DONT_CARE {
+		loaded, err := isModuleLoaded(c.ctx, c.fs, name)
+		if err != nil {
+			return nil, "", err
+		}
+		reasons = appendModuleStateReason(reasons, name, "loaded", "", loaded, c.checkInstruction.GetLoaded())
+	}
+
+	if c.checkInstruction.GetModuleFilePresent() != ipb.KernelModuleCheck_DONT_CARE {
+		modulePath, err := findModuleFile(c.ctx, c.fs, name, c.checkInstruction.GetKernelRelease())
+		if err != nil {
+			return nil, "", err
+		}
+		reasons = appendModuleStateReason(reasons, name, "available", modulePath, modulePath != "", c.checkInstruction.GetModuleFilePresent())
+	}
+
+	reason := strings.Join(reasons, "\n")
+	if reason != "" && c.checkInstruction.GetNonComplianceMsg() != "" {
+		reason = c.checkInstruction.GetNonComplianceMsg()
+	}
+	r := &apb.ComplianceResult{
+		Id: c.benchmarkID,
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{
+			NonComplianceReason: reason,
+		},
+	}
+	return ComplianceMap{c.alternativeID: r}, "", nil
+}
+
+// BenchmarkIDs returns the IDs of the benchmarks associated with this check.
+func (c *KernelModuleCheck) BenchmarkIDs() []string {
+	return []string{c.benchmarkID}
+}
+
+func (c *KernelModuleCheck) String() string {
+	return fmt.Sprintf("[kernel module check on %q]", c.checkInstruction.GetModuleName())
+}
+
+// appendModuleStateReason adds a non-compliance reason to the list if the
+// given module property doesn't match its expectation.
+func appendModuleStateReason(reasons []string, module, property, source string, state bool, expectation ipb.KernelModuleCheck_Expectation) []string {
+	if expectation == ipb.KernelModuleCheck_DONT_CARE {
+		return reasons
+	}
+	wantState := expectation == ipb.KernelModuleCheck_SHOULD_BE
+	if state == wantState {
+		return reasons
+	}
+	sourceStr := ""
+	if source != "" {
+		sourceStr = fmt.Sprintf(" (%s)", source)
+	}
+	if state {
+		return append(reasons, fmt.Sprintf("Module %s is %s%s, expected it not to be", module, property, sourceStr))
+	}
+	return append(reasons, fmt.Sprintf("Module %s is not %s%s, expected it to be", module, property, sourceStr))
+}
+
+// normalizeModuleName returns the module name in the form used by the kernel,
+// which treats dashes and underscores in module names as equivalent.
+func normalizeModuleName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// modprobeDirective is a single command parsed from a modprobe.d config file.
+type modprobeDirective struct {
+	module string   // The (normalized) module name or wildcard pattern.
+	args   []string // The arguments following the module name.
+	source string   // The config file the directive was read from.
+}
+
+// modprobeConfig contains the modprobe.d directives relevant for checking
+// the load state of modules, in the order modprobe processes them.
+type modprobeConfig struct {
+	installs   []*modprobeDirective
+	blacklists []*modprobeDirective
+}
+
+// installCommand returns the install directive modprobe uses for the given
+// module or nil if there's none. Like modprobe, only the first matching
+// directive is considered.
+func (c *modprobeConfig) installCommand(module string) *modprobeDirective {
+	return firstMatchingDirective(c.installs, module)
+}
+
+// blacklistEntry returns the first directive blacklisting the given module or
+// nil if the module is not blacklisted.
+func (c *modprobeConfig) blacklistEntry(module string) *modprobeDirective {
+	return firstMatchingDirective(c.blacklists, module)
+}
+
+func firstMatchingDirective(directives []*modprobeDirective, module string) *modprobeDirective {
+	for _, d := range directives {
+		if matched, err := path.Match(d.module, module); err == nil && matched {
+			return d
+		}
+	}
+	return nil
+}
+
+// isDisabledInstallCommand returns true if the given install command prevents
+// the module from being loaded, e.g. "install cramfs /bin/false".
+func isDisabledInstallCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	cmd := path.Base(args[0])
+	return cmd == "false" || cmd == "true"
+}
+
+// readModprobeConfig parses the .conf files in all modprobe.d directories.
+// The files are processed in lexical order of their names, with files in
+// higher-priority directories overriding the ones with the same name in
+// lower-priority directories.
+func readModprobeConfig(ctx context.Context, fs scanapi.Filesystem) (*modprobeConfig, error) {
+	configFiles := make(map[string]string) // Filename -> full path
+	for _, dir := range modprobeConfigDirs {
+		d, err := fs.OpenDir(ctx, dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		entries, err := scanapi.DirReaderToSlice(d)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.GetIsDir() || path.Ext(e.GetName()) != ".conf" {
+				continue
+			}
+			if _, ok := configFiles[e.GetName()]; !ok {
+				configFiles[e.GetName()] = path.Join(dir, e.GetName())
+			}
+		}
+	}
+	names := make([]string, 0, len(configFiles))
+	for name := range configFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	config := &modprobeConfig{}
+	for _, name := range names {
+		if err := parseModprobeConfigFile(ctx, fs, configFiles[name], config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func parseModprobeConfigFile(ctx context.Context, fs scanapi.Filesystem, filePath string, config *modprobeConfig) error {
+	f, err := fs.OpenFile(ctx, filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Dangling symlink, ignore like modprobe does.
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	lines, err := readContinuedLines(f)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens := strings.Fields(line)
+		if len(tokens) < 2 {
+			continue
+		}
+		directive := &modprobeDirective{
+			module: normalizeModuleName(tokens[1]),
+			args:   tokens[2:],
+			source: filePath,
+		}
+		switch tokens[0] {
+		case "install":
+			config.installs = append(config.installs, directive)
+		case "blacklist":
+			config.blacklists = append(config.blacklists, directive)
+		}
+	}
+	return nil
+}
+
+// isModuleLoaded returns whether the given module is listed in /proc/modules.
+func isModuleLoaded(ctx context.Context, fs scanapi.Filesystem, module string) (bool, error) {
+	f, err := fs.OpenFile(ctx, procModulesPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// The kernel was built without module support.
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) > 0 && normalizeModuleName(tokens[0]) == module {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// findModuleFile returns the path of the module file for the given module or
+// an empty string if the module is not available for the kernel release(s).
+func findModuleFile(ctx context.Context, fs scanapi.Filesystem, module string, release string) (string, error) {
+	releases, err := kernelReleases(ctx, fs, release)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range releases {
+		for _, index := range []string{"modules.dep", "modules.builtin"} {
+			indexPath := path.Join(libModulesPath, r, index)
+			modulePath, err := findModuleInIndex(ctx, fs, indexPath, module)
+			if err != nil {
+				return "", err
+			}
+			if modulePath != "" {
+				return path.Join(libModulesPath, r, modulePath), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// kernelReleases returns the kernel releases whose modules should be checked.
+func kernelReleases(ctx context.Context, fs scanapi.Filesystem, release string) ([]string, error) {
+	if release != "" {
+		return []string{release}, nil
+	}
+	f, err := fs.OpenFile(ctx, kernelReleasePath)
+	if err == nil {
+		defer f.Close()
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return []string{strings.TrimSpace(string(content))}, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	// We're not scanning a running system, check all installed kernels.
+	d, err := fs.OpenDir(ctx, libModulesPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries, err := scanapi.DirReaderToSlice(d)
+	if err != nil {
+		return nil, err
+	}
+	releases := []string{}
+	for _, e := range entries {
+		if e.GetIsDir() {
+			releases = append(releases, e.GetName())
+		}
+	}
+	return releases, nil
+}
+
+// findModuleInIndex looks for the given module in a modules.dep or
+// modules.builtin file and returns its path relative to the index file.
+func findModuleInIndex(ctx context.Context, fs scanapi.Filesystem, indexPath string, module string) (string, error) {
+	f, err := fs.OpenFile(ctx, indexPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// modules.dep entries have the format "path/to/module.ko.xz: deps..."
+		modulePath := strings.TrimSpace(strings.SplitN(scanner.Text(), ":", 2)[0])
+		name := path.Base(modulePath)
+		if i := strings.Index(name, ".ko"); i >= 0 {
+			name = name[:i]
+		}
+		if normalizeModuleName(name) == module {
+			return modulePath, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// createKernelModuleChecksFromConfig parses the benchmark config and creates
+// the kernel module checks that it defines.
+func createKernelModuleChecksFromConfig(ctx context.Context, benchmarks []*benchmark, fs scanapi.Filesystem) ([]*KernelModuleCheck, error) {
+	checks := []*KernelModuleCheck{}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, instruction := range alt.proto.GetKernelModuleChecks() {
+				if instruction.GetModuleName() == "" {
+					return nil, fmt.Errorf("kernel module check %v in benchmark %s has no module name", instruction, b.id)
+				}
+				checks = append(checks, &KernelModuleCheck{
+					ctx:              ctx,
+					benchmarkID:      b.id,
+					alternativeID:    alt.id,
+					checkInstruction: instruction,
+					fs:               fs,
+				})
+			}
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+func TestKernelModuleCheckWithoutModuleNameReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewKernelModuleScanInstruction([]*ipb.KernelModuleCheck{
+		{Loaded: ipb.KernelModuleCheck_SHOULD_NOT_BE},
+	})
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+
+	if _, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI()); err == nil {
+		t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+	}
+}
+
+func TestKernelModuleCheckComplianceResults(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		check          *ipb.KernelModuleCheck
+		files          map[string]string
+		expectedReason string
+	}{
+		{
+			desc: "module disabled with /bin/false",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loadable:   ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/etc/modprobe.d/cramfs.conf": "install cramfs /bin/false\n",
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "module disabled with /bin/true",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loadable:   ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/etc/modprobe.d/cramfs.conf": "install cramfs /bin/true\n",
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "module not disabled",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loadable:   ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/etc/modprobe.d/other.conf": "install squashfs /bin/false\n",
+			},
+			expectedReason: "Module cramfs is loadable, expected it not to be",
+		},
+		{
+			desc: "first install command wins",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loadable:   ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/etc/modprobe.d/a.conf": "install cramfs /sbin/modprobe --ignore-install cramfs\n",
+				"/etc/modprobe.d/b.conf": "install cramfs /bin/false\n",
+			},
+			expectedReason: "Module cramfs is loadable (/etc/modprobe.d/a.conf), expected it not to be",
+		},
+		{
+			desc: "files are processed in lexical order across directories",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loadable:   ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/lib/modprobe.d/a.conf": "install cramfs /bin/false\n",
+				"/etc/modprobe.d/b.conf": "install cramfs /sbin/modprobe --ignore-install cramfs\n",
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "file in /etc overrides file with the same name in /lib",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loadable:   ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/lib/modprobe.d/cramfs.conf": "install cramfs /bin/false\n",
+				"/etc/modprobe.d/cramfs.conf": "# Re-enabled\n",
+			},
+			expectedReason: "Module cramfs is loadable, expected it not to be",
+		},
+		{
+			desc: "non-.conf files are ignored",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loadable:   ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/etc/modprobe.d/cramfs.conf.bak": "install cramfs /bin/false\n",
+			},
+			expectedReason: "Module cramfs is loadable, expected it not to be",
+		},
+		{
+			desc: "commented out and continued lines",
+			check: &ipb.KernelModuleCheck{
+				ModuleName:  "cramfs",
+				Loadable:    ipb.KernelModuleCheck_SHOULD_NOT_BE,
+				Blacklisted: ipb.KernelModuleCheck_SHOULD_BE,
+			},
+			files: map[string]string{
+				"/etc/modprobe.d/cramfs.conf": "# blacklist cramfs\ninstall cramfs \\\n  /bin/false\n",
+			},
+			expectedReason: "Module cramfs is not blacklisted, expected it to be",
+		},
+		{
+			desc: "dashes and underscores are equivalent",
+			check: &ipb.KernelModuleCheck{
+				ModuleName:  "usb-storage",
+				Loadable:    ipb.KernelModuleCheck_SHOULD_NOT_BE,
+				Blacklisted: ipb.KernelModuleCheck_SHOULD_BE,
+				Loaded:      ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/etc/modprobe.d/usb.conf": "install usb_storage /bin/false\nblacklist usb-storage\n",
+				"/proc/modules":            "usb_storage 77824 0 - Live 0x0000000000000000\n",
+			},
+			expectedReason: "Module usb_storage is loaded, expected it not to be",
+		},
+		{
+			desc: "module not loaded",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loaded:     ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/proc/modules": "ext4 12345 1 - Live 0x0000000000000000\n",
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "no /proc/modules",
+			check: &ipb.KernelModuleCheck{
+				ModuleName: "cramfs",
+				Loaded:     ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files:          map[string]string{},
+			expectedReason: "",
+		},
+		{
+			desc: "module file present for running kernel",
+			check: &ipb.KernelModuleCheck{
+				ModuleName:        "cramfs",
+				ModuleFilePresent: ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/proc/sys/kernel/osrelease":        "5.15.0-1\n",
+				"/lib/modules/5.15.0-1/modules.dep": "kernel/fs/cramfs/cramfs.ko.zst:\n",
+				"/lib/modules/5.10.0-1/modules.dep": "",
+			},
+			expectedReason: "Module cramfs is available (/lib/modules/5.15.0-1/kernel/fs/cramfs/cramfs.ko.zst), expected it not to be",
+		},
+		{
+			desc: "module file only present for other kernel",
+			check: &ipb.KernelModuleCheck{
+				ModuleName:        "cramfs",
+				ModuleFilePresent: ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/proc/sys/kernel/osrelease":        "5.15.0-1\n",
+				"/lib/modules/5.15.0-1/modules.dep": "kernel/fs/ext4/ext4.ko: kernel/lib/crc16.ko\n",
+				"/lib/modules/5.10.0-1/modules.dep": "kernel/fs/cramfs/cramfs.ko:\n",
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "built-in module with explicit kernel release",
+			check: &ipb.KernelModuleCheck{
+				ModuleName:        "cramfs",
+				ModuleFilePresent: ipb.KernelModuleCheck_SHOULD_NOT_BE,
+				KernelRelease:     "5.10.0-1",
+			},
+			files: map[string]string{
+				"/proc/sys/kernel/osrelease":            "5.15.0-1\n",
+				"/lib/modules/5.10.0-1/modules.builtin": "kernel/fs/cramfs/cramfs.ko\n",
+			},
+			expectedReason: "Module cramfs is available (/lib/modules/5.10.0-1/kernel/fs/cramfs/cramfs.ko), expected it not to be",
+		},
+		{
+			desc: "all kernels are checked when scanning an image",
+			check: &ipb.KernelModuleCheck{
+				ModuleName:        "cramfs",
+				ModuleFilePresent: ipb.KernelModuleCheck_SHOULD_NOT_BE,
+			},
+			files: map[string]string{
+				"/lib/modules/5.15.0-1/modules.dep": "kernel/fs/ext4/ext4.ko:\n",
+				"/lib/modules/5.10.0-1/modules.dep": "kernel/fs/cramfs/cramfs.ko:\n",
+			},
+			expectedReason: "Module cramfs is available (/lib/modules/5.10.0-1/kernel/fs/cramfs/cramfs.ko), expected it not to be",
+		},
+		{
+			desc: "custom non-compliance message",
+			check: &ipb.KernelModuleCheck{
+				ModuleName:       "cramfs",
+				Loadable:         ipb.KernelModuleCheck_SHOULD_NOT_BE,
+				Blacklisted:      ipb.KernelModuleCheck_SHOULD_BE,
+				NonComplianceMsg: "cramfs is not disabled",
+			},
+			files:          map[string]string{},
+			expectedReason: "cramfs is not disabled",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewKernelModuleScanInstruction([]*ipb.KernelModuleCheck{tc.check})
+			check := createSingleCheck(t, scanInstruction, newFakeAPI(withFiles(tc.files)))
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonComplianceReason: tc.expectedReason,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,455 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"github.com/google/localtoast/scannerlib/fileset"
+	"github.com/google/localtoast/scannerlib/repeatconfig"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// LintProblem is a problem in a scan config found by Lint.
+type LintProblem struct {
+	// The IDs of the benchmarks with the problem. Empty if the problem is in
+	// the scan config itself, e.g. an unused opt-out.
+	BenchmarkIDs []string
+	Message      string
+}
+
+func (p *LintProblem) String() string {
+	if len(p.BenchmarkIDs) == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(p.BenchmarkIDs, ","), p.Message)
+}
+
+// Lint validates the scan config without accessing the scanned machine and
+// returns all problems found in it. Besides the validation done when the
+// checks are created, it reports check alternatives that can't affect the
+// compliance of their benchmark and opt-outs that can't apply to any check.
+func Lint(scanConfig *apb.ScanConfig) []*LintProblem {
+	l := &linter{}
+	benchmarks := make([]*benchmark, 0, len(scanConfig.GetBenchmarkConfigs()))
+	benchmarkParams := make(map[string][]*apb.ParameterValue)
+	for _, c := range scanConfig.GetBenchmarkConfigs() {
+		alts, params, err := parseCheckAlternatives(c, 0, scanConfig.GetTailoringConfig())
+		benchmarkParams[c.GetId()] = params
+		if err != nil {
+			l.add(err.Error(), c.GetId())
+			continue
+		}
+		b := &benchmark{id: c.GetId(), alts: alts}
+		l.lintBenchmark(b)
+		benchmarks = append(benchmarks, b)
+	}
+	if err := validateTailoring(scanConfig.GetTailoringConfig(), benchmarkParams); err != nil {
+		l.add(err.Error())
+	}
+	if _, err := collectVariableExports(benchmarks); err != nil {
+		l.add(err.Error())
+	}
+	l.lintSharedFiles(benchmarks, scanConfig)
+	l.lintOptOutConfig(benchmarks, scanConfig.GetOptOutConfig())
+	return l.problems
+}
+
+type linter struct {
+	problems []*LintProblem
+}
+
+func (l *linter) add(message string, benchmarkIDs ...string) {
+	l.problems = append(l.problems, &LintProblem{BenchmarkIDs: benchmarkIDs, Message: message})
+}
+
+func (l *linter) lintBenchmark(b *benchmark) {
+	ctx := context.Background()
+	for i, alt := range b.alts {
+		addErr := func(err error) {
+			l.add(fmt.Sprintf("alternative #%d: %v", i, err), b.id)
+		}
+		if !hasChecks(alt.proto) {
+			addErr(errors.New("doesn't have any checks"))
+		}
+		if _, err := validateFactUsage(alt.proto); err != nil {
+			addErr(err)
+		}
+		for _, fc := range alt.proto.GetFileChecks() {
+			for _, err := range lintFileCheck(b.id, fc) {
+				addErr(err)
+			}
+		}
+		for _, sc := range alt.proto.GetSqlChecks() {
+			for _, err := range lintSQLCheck(sc) {
+				addErr(err)
+			}
+		}
+		// These checks only access the scanned machine when executed.
+		single := []*benchmark{{id: b.id, alts: []*checkAlternative{alt}}}
+		if _, err := createKernelModuleChecksFromConfig(ctx, single, nil); err != nil {
+			addErr(err)
+		}
+		if _, err := createMountChecksFromConfig(ctx, single, nil); err != nil {
+			addErr(err)
+		}
+		if _, err := createPamChecksFromConfig(ctx, single, nil); err != nil {
+			addErr(err)
+		}
+		if _, err := createAccountChecksFromConfig(ctx, single, nil); err != nil {
+			addErr(err)
+		}
+		if _, err := createListeningServicesChecksFromConfig(ctx, single, nil); err != nil {
+			addErr(err)
+		}
+	}
+	l.lintUnreachableAlternatives(b)
+}
+
+// lintFileCheck returns the problems of a single file check.
+func lintFileCheck(benchmarkID string, fc *ipb.FileCheck) []error {
+	errs := []error{}
+	if err := validateFileCheckInstruction(fc); err != nil {
+		errs = append(errs, err)
+	}
+	repeatOptions := fc.GetRepeatConfigs()
+	if fc.GetRepeatConfig() != nil {
+		repeatOptions = append([]*ipb.RepeatConfig{fc.GetRepeatConfig()}, repeatOptions...)
+	}
+	errs = append(errs, lintRepeatConfigs(fc, repeatOptions)...)
+	for _, filesToCheck := range fc.GetFilesToCheck() {
+		if err := lintFileSet(filesToCheck); err != nil {
+			errs = append(errs, err)
+		}
+		// The file checkers validate the check type specific fields, e.g. the
+		// regexes of content entry checks.
+		if _, err := newFileCheckers([]*fileCheck{{benchmarkID: benchmarkID, checkInstruction: fc, filesToCheck: filesToCheck}}, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func lintSQLCheck(sc *ipb.SQLCheck) []error {
+	errs := []error{}
+	if sc.GetTargetDatabase() == ipb.SQLCheck_DB_UNSPECIFIED {
+		errs = append(errs, errors.New("SQL check has no target database"))
+	}
+	if sc.GetTargetDatabase() == ipb.SQLCheck_DB_ELASTICSEARCH && sc.GetFilterRegex() == "" {
+		errs = append(errs, errors.New("no regex provided for ElasticSearch database SQLCheck"))
+	}
+	if _, err := regexp.Compile("^" + sc.GetFilterRegex() + "$"); err != nil {
+		errs = append(errs, fmt.Errorf("SQL check has invalid filter regex: %w", err))
+	}
+	repeatOptions := []*ipb.RepeatConfig{}
+	if sc.GetRepeatConfig() != nil {
+		repeatOptions = append(repeatOptions, sc.GetRepeatConfig())
+	}
+	if err := repeatconfig.ValidateSQLRepeatConfigs(repeatOptions, sc.GetTargetDatabase()); err != nil {
+		return append(errs, err)
+	}
+	return append(errs, lintRepeatConfigs(sc, repeatOptions)...)
+}
+
+// lintRepeatConfigs validates the repeat configs of a check and returns
+// problems for the configs whose wildcards the check doesn't use and for
+// opt-outs of values the configs never substitute.
+func lintRepeatConfigs(check proto.Message, repeatOptions []*ipb.RepeatConfig) []error {
+	if err := repeatconfig.ValidateRepeatConfigs(repeatOptions); err != nil {
+		return []error{err}
+	}
+	// The wildcards are only substituted in the check itself.
+	withoutRepeat := proto.Clone(check)
+	if fc, ok := withoutRepeat.(*ipb.FileCheck); ok {
+		fc.RepeatConfig, fc.RepeatConfigs = nil, nil
+	} else if sc, ok := withoutRepeat.(*ipb.SQLCheck); ok {
+		sc.RepeatConfig = nil
+	}
+	strs := []string{}
+	transformStrings(withoutRepeat.ProtoReflect(), func(s string) string {
+		strs = append(strs, s)
+		return s
+	})
+	content := strings.Join(strs, "\n")
+
+	errs := []error{}
+	for i, o := range repeatOptions {
+		// Validated above.
+		wildcards, _ := repeatconfig.Wildcards(o)
+		used := len(wildcards) == 0
+		// The repeat configs after o can use its wildcards as well.
+		usable := content
+		for _, nested := range repeatOptions[i+1:] {
+			usable += "\n" + strings.Join(nestedFields(nested), "\n")
+		}
+		for _, w := range wildcards {
+			used = used || strings.Contains(usable, w)
+		}
+		if !used {
+			errs = append(errs, fmt.Errorf("repeat config %s substitutes the wildcards %v which the check doesn't use", o, wildcards))
+		}
+		if o.GetType() != ipb.RepeatConfig_FOR_EACH_VALUE {
+			continue
+		}
+		for _, optOut := range o.GetOptOut() {
+			if !containsValue(o.GetValues(), optOut.GetValue()) {
+				errs = append(errs, fmt.Errorf("repeat config %s has an unused opt-out for value %q", o, optOut.GetValue()))
+			}
+		}
+	}
+	return errs
+}
+
+// nestedFields returns the fields of the repeat config in which the wildcards
+// of the configs before it are substituted.
+func nestedFields(o *ipb.RepeatConfig) []string {
+	return append([]string{o.GetFilePath(), o.GetRegex(), o.GetQuery()}, o.GetValues()...)
+}
+
+func containsValue(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// lintFileSet returns an error if the opt-outs of the file set are invalid or
+// can't match any file in it.
+func lintFileSet(filesToCheck *ipb.FileSet) error {
+	dir := filesToCheck.GetFilesInDir()
+	for _, r := range dir.GetOptOutPathRegexes() {
+		if _, err := regexp.Compile("^" + r + "$"); err != nil {
+			return fmt.Errorf("file set %v has invalid opt-out regex: %w", filesToCheck, err)
+		}
+		if !canMatchUnder(r, dir.GetDirPath()) {
+			return fmt.Errorf("opt-out regex %q can't match any file in %s", r, dir.GetDirPath())
+		}
+	}
+	return nil
+}
+
+// lintSharedFiles reports conflicting checks of the same files, e.g. content
+// entry checks with different delimiters. The checks on the same files are
+// batched together, even across benchmarks.
+func (l *linter) lintSharedFiles(benchmarks []*benchmark, scanConfig *apb.ScanConfig) {
+	batches := make(map[string][]*fileCheck)
+	keys := []string{}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, fc := range alt.proto.GetFileChecks() {
+				if len(lintFileCheck(b.id, fc)) > 0 {
+					// Already reported.
+					continue
+				}
+				for _, filesToCheck := range fc.GetFilesToCheck() {
+					filesToCheck = proto.Clone(filesToCheck).(*ipb.FileSet)
+					fileset.ApplyOptOutConfig(filesToCheck, scanConfig.GetOptOutConfig())
+					fileset.ApplyReplacementConfig(filesToCheck, scanConfig.GetReplacementConfig())
+					key, err := proto.MarshalOptions{Deterministic: true}.Marshal(filesToCheck)
+					if err != nil {
+						l.add(err.Error(), b.id)
+						continue
+					}
+					if _, ok := batches[string(key)]; !ok {
+						keys = append(keys, string(key))
+					}
+					batches[string(key)] = append(batches[string(key)],
+						&fileCheck{benchmarkID: b.id, checkInstruction: fc, filesToCheck: filesToCheck})
+				}
+			}
+		}
+	}
+	for _, k := range keys {
+		if _, err := newFileCheckers(batches[k], nil); err != nil {
+			ids := make(map[string]bool)
+			for _, fc := range batches[k] {
+				ids[fc.benchmarkID] = true
+			}
+			l.add(err.Error(), sortedKeys(ids)...)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lintOptOutConfig reports opt-out regexes of the scan config that are invalid
+// or can't match any of the checked files.
+func (l *linter) lintOptOutConfig(benchmarks []*benchmark, optOut *apb.OptOutConfig) {
+	fileSets := []*ipb.FileSet{}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, fc := range alt.proto.GetFileChecks() {
+				fileSets = append(fileSets, fc.GetFilesToCheck()...)
+			}
+		}
+	}
+	for _, regexes := range []struct {
+		name    string
+		regexes []string
+	}{
+		{"content", optOut.GetContentOptoutRegexes()},
+		{"filename", optOut.GetFilenameOptoutRegexes()},
+		{"traversal", optOut.GetTraversalOptoutRegexes()},
+	} {
+		for _, r := range regexes.regexes {
+			re, err := regexp.Compile("^" + r + "$")
+			if err != nil {
+				l.add(fmt.Sprintf("invalid %s opt-out regex %q: %v", regexes.name, r, err))
+				continue
+			}
+			used := false
+			for _, fs := range fileSets {
+				used = used || canMatchFileSet(re, r, fs)
+			}
+			if !used {
+				l.add(fmt.Sprintf("%s opt-out regex %q doesn't match any of the checked files", regexes.name, r))
+			}
+		}
+	}
+}
+
+// Tokens substituted in the file paths before the files are checked, e.g.
+// repeat config wildcards and variables.
+var pathTokenRe = regexp.MustCompile(`\$|%%|\{\{`)
+
+// canMatchFileSet returns whether the opt-out regex r, compiled as the
+// full-match regex re, can match a file of the file set. Returns true if the
+// files can't be determined statically.
+func canMatchFileSet(re *regexp.Regexp, r string, fs *ipb.FileSet) bool {
+	switch {
+	case fs.GetSingleFile() != nil:
+		p := fs.GetSingleFile().GetPath()
+		if pathTokenRe.MatchString(p) {
+			return canMatchUnder(r, p)
+		}
+		return re.MatchString(p)
+	case fs.GetFilesInDir() != nil:
+		return canMatchUnder(r, fs.GetFilesInDir().GetDirPath())
+	case fs.GetProcessPath() != nil:
+		return canMatchUnder(r, "/proc/")
+	}
+	return true
+}
+
+// canMatchUnder returns whether the opt-out regex r can match dir or a path
+// under it, based on the literal prefix of the regex.
+func canMatchUnder(r string, dir string) bool {
+	if loc := pathTokenRe.FindStringIndex(dir); loc != nil {
+		dir = dir[:loc[0]]
+	}
+	re, err := regexp.Compile(r)
+	if err != nil {
+		return true
+	}
+	prefix, _ := re.LiteralPrefix()
+	return strings.HasPrefix(prefix, dir) || strings.HasPrefix(dir, prefix)
+}
+
+// lintUnreachableAlternatives reports check alternatives that can't affect the
+// compliance of the benchmark: Alternatives whose conditions contradict each
+// other and alternatives that include all checks and conditions of another
+// one, since they can only pass if the other one passes too.
+func (l *linter) lintUnreachableAlternatives(b *benchmark) {
+	sets := make([]map[string]bool, 0, len(b.alts))
+	for _, alt := range b.alts {
+		sets = append(sets, alternativeElements(alt.proto))
+	}
+	for j, alt := range b.alts {
+		if c := contradictingCondition(alt.proto); c != "" {
+			l.add(fmt.Sprintf("alternative #%d is never applicable: it requires different values of fact %s", j, c), b.id)
+			continue
+		}
+		// Alternatives exporting variables are needed for the export.
+		if len(exportedVariableNames(alt.proto)) > 0 {
+			continue
+		}
+		for i := range b.alts {
+			if i == j || !isSubset(sets[i], sets[j]) {
+				continue
+			}
+			// Only the later one of identical alternatives is reported.
+			if len(sets[i]) == len(sets[j]) && i > j {
+				continue
+			}
+			l.add(fmt.Sprintf("alternative #%d is unreachable: it only passes if alternative #%d passes", j, i), b.id)
+			break
+		}
+	}
+}
+
+// alternativeElements returns the serialized checks and applicability
+// conditions of the check alternative.
+func alternativeElements(alt *ipb.CheckAlternative) map[string]bool {
+	result := make(map[string]bool)
+	alt.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if !fd.IsList() || fd.Kind() != protoreflect.MessageKind {
+			return true
+		}
+		for i := 0; i < v.List().Len(); i++ {
+			serialized, err := proto.MarshalOptions{Deterministic: true}.Marshal(v.List().Get(i).Message().Interface())
+			if err != nil {
+				continue
+			}
+			result[string(fd.Name())+":"+string(serialized)] = true
+		}
+		return true
+	})
+	return result
+}
+
+func isSubset(a, b map[string]bool) bool {
+	if len(a) == 0 {
+		return false
+	}
+	for k := range a {
+		if !b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// contradictingCondition returns the fact which the conditions of the check
+// alternative require to equal different values, or "" if there's none.
+func contradictingCondition(alt *ipb.CheckAlternative) string {
+	values := make(map[string]string)
+	for _, c := range alt.GetApplicableIf() {
+		if _, ok := c.GetCondition().(*ipb.FactCondition_Equals); !ok || c.GetNegate() {
+			continue
+		}
+		if v, ok := values[c.GetFact()]; ok && v != c.GetEquals() {
+			return c.GetFact()
+		}
+		values[c.GetFact()] = c.GetEquals()
+	}
+	return ""
+}
@@ -0,0 +1,259 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+func contentEntryCheck(path string, delimiter string, criteria ...*ipb.MatchCriterion) *ipb.FileCheck {
+	return &ipb.FileCheck{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(path)},
+		CheckType: &ipb.FileCheck_ContentEntry{ContentEntry: &ipb.ContentEntryCheck{
+			Delimiter:     []byte(delimiter),
+			MatchType:     ipb.ContentEntryCheck_ALL_MATCH_ANY_ORDER,
+			MatchCriteria: criteria,
+		}},
+	}
+}
+
+var validCriterion = &ipb.MatchCriterion{FilterRegex: "key.*", ExpectedRegex: "key=value"}
+
+func existenceCheck(path string) *ipb.FileCheck {
+	return &ipb.FileCheck{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(path)},
+		CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+	}
+}
+
+func TestLintValidConfigReturnsNoProblems(t *testing.T) {
+	config := &apb.ScanConfig{
+		BenchmarkConfigs: []*apb.BenchmarkConfig{
+			testconfigcreator.NewBenchmarkConfig(t, "id1", testconfigcreator.NewFileScanInstruction(
+				[]*ipb.FileCheck{contentEntryCheck("/etc/file", "", validCriterion)})),
+			testconfigcreator.NewBenchmarkConfig(t, "id2", &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{
+					{FileChecks: []*ipb.FileCheck{existenceCheck("/etc/a")}},
+					{FileChecks: []*ipb.FileCheck{existenceCheck("/etc/b")}},
+				},
+			}),
+			// $home is only used by the nested repeat config.
+			testconfigcreator.NewBenchmarkConfig(t, "id3", testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/$alias")},
+				CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: false}},
+				RepeatConfigs: []*ipb.RepeatConfig{
+					{Type: ipb.RepeatConfig_FOR_EACH_USER},
+					{Type: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH, FilePath: "$home/.bashrc", Regex: `^alias (?P<alias>\w+)=`},
+				},
+			}})),
+		},
+		OptOutConfig: &apb.OptOutConfig{ContentOptoutRegexes: []string{"/etc/.*"}},
+	}
+	if problems := configchecks.Lint(config); len(problems) != 0 {
+		t.Errorf("configchecks.Lint(%v) returned unexpected problems: %v", config, problems)
+	}
+}
+
+func TestLintReportsProblems(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		instruction *ipb.BenchmarkScanInstruction
+		wantMessage string
+	}{
+		{
+			desc: "invalid content entry regex",
+			instruction: testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{
+				contentEntryCheck("/etc/file", "", &ipb.MatchCriterion{FilterRegex: "key(", ExpectedRegex: "key"}),
+			}),
+			wantMessage: "error parsing regexp",
+		},
+		{
+			desc: "group index out of range",
+			instruction: testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{
+				contentEntryCheck("/etc/file", "", &ipb.MatchCriterion{
+					FilterRegex:   "key.*",
+					ExpectedRegex: `key=(\d+)`,
+					GroupCriteria: []*ipb.GroupCriterion{{
+						GroupIndex:      2,
+						Type:            ipb.GroupCriterion_LESS_THAN,
+						ComparisonValue: &ipb.GroupCriterion_Const{Const: 5},
+					}},
+				}),
+			}),
+			wantMessage: "group",
+		},
+		{
+			desc: "elasticsearch check without filter regex",
+			instruction: testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+				TargetDatabase: ipb.SQLCheck_DB_ELASTICSEARCH,
+				Query:          "/_cluster/settings",
+			}}),
+			wantMessage: "no regex provided",
+		},
+		{
+			desc: "elasticsearch check repeated over query results",
+			instruction: testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+				TargetDatabase: ipb.SQLCheck_DB_ELASTICSEARCH,
+				Query:          "/$index/_settings",
+				FilterRegex:    ".*",
+				RepeatConfig: &ipb.RepeatConfig{
+					Type:     ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+					Wildcard: "$index",
+					Query:    "/_cat/indices",
+				},
+			}}),
+			wantMessage: "unsupported for ElasticSearch",
+		},
+		{
+			desc: "unused repeat config wildcard",
+			instruction: testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/file")},
+				CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+				RepeatConfig: &ipb.RepeatConfig{Type: ipb.RepeatConfig_FOR_EACH_VALUE, Wildcard: "$module", Values: []string{"a"}},
+			}}),
+			wantMessage: "which the check doesn't use",
+		},
+		{
+			desc: "repeat config opt-out of unknown value",
+			instruction: testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/$module")},
+				CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+				RepeatConfig: &ipb.RepeatConfig{
+					Type:     ipb.RepeatConfig_FOR_EACH_VALUE,
+					Wildcard: "$module",
+					Values:   []string{"a"},
+					OptOut:   []*ipb.RepeatConfig_OptOutSubstitution{{Wildcard: "$module", Value: "b"}},
+				},
+			}}),
+			wantMessage: "unused opt-out",
+		},
+		{
+			desc: "directory opt-out outside of the directory",
+			instruction: testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{{FilePath: &ipb.FileSet_FilesInDir_{FilesInDir: &ipb.FileSet_FilesInDir{
+					DirPath:           "/etc/",
+					OptOutPathRegexes: []string{"/var/.*"},
+				}}}},
+				CheckType: &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+			}}),
+			wantMessage: "can't match any file",
+		},
+		{
+			desc: "alternative without checks",
+			instruction: &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{{}},
+			},
+			wantMessage: "doesn't have any checks",
+		},
+		{
+			desc: "alternative requiring more checks than another",
+			instruction: &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{
+					{FileChecks: []*ipb.FileCheck{existenceCheck("/etc/a")}},
+					{FileChecks: []*ipb.FileCheck{existenceCheck("/etc/a"), existenceCheck("/etc/b")}},
+				},
+			},
+			wantMessage: "alternative #1 is unreachable",
+		},
+		{
+			desc: "duplicate alternatives",
+			instruction: &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{
+					{FileChecks: []*ipb.FileCheck{existenceCheck("/etc/a")}},
+					{FileChecks: []*ipb.FileCheck{existenceCheck("/etc/a")}},
+				},
+			},
+			wantMessage: "alternative #1 is unreachable",
+		},
+		{
+			desc: "alternative with contradicting conditions",
+			instruction: &ipb.BenchmarkScanInstruction{
+				CheckAlternatives: []*ipb.CheckAlternative{{
+					FileChecks: []*ipb.FileCheck{existenceCheck("/etc/a")},
+					ApplicableIf: []*ipb.FactCondition{
+						{Fact: "os.id", Condition: &ipb.FactCondition_Equals{Equals: "ubuntu"}},
+						{Fact: "os.id", Condition: &ipb.FactCondition_Equals{Equals: "debian"}},
+					},
+				}},
+			},
+			wantMessage: "never applicable",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := &apb.ScanConfig{
+				BenchmarkConfigs: []*apb.BenchmarkConfig{testconfigcreator.NewBenchmarkConfig(t, "id", tc.instruction)},
+			}
+			problems := configchecks.Lint(config)
+			if len(problems) != 1 {
+				t.Fatalf("configchecks.Lint(%v) returned %d problems, expected 1: %v", config, len(problems), problems)
+			}
+			if diff := cmp.Diff([]string{"id"}, problems[0].BenchmarkIDs); diff != "" {
+				t.Errorf("configchecks.Lint(%v) returned unexpected benchmark IDs (-want +got):\n%s", config, diff)
+			}
+			if !strings.Contains(problems[0].Message, tc.wantMessage) {
+				t.Errorf("configchecks.Lint(%v) returned %q, expected it to contain %q", config, problems[0].Message, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func TestLintReportsConflictsAcrossBenchmarks(t *testing.T) {
+	config := &apb.ScanConfig{
+		BenchmarkConfigs: []*apb.BenchmarkConfig{
+			testconfigcreator.NewBenchmarkConfig(t, "id1", testconfigcreator.NewFileScanInstruction(
+				[]*ipb.FileCheck{contentEntryCheck("/etc/file", "\n", validCriterion)})),
+			testconfigcreator.NewBenchmarkConfig(t, "id2", testconfigcreator.NewFileScanInstruction(
+				[]*ipb.FileCheck{contentEntryCheck("/etc/file", ";", validCriterion)})),
+		},
+	}
+	problems := configchecks.Lint(config)
+	if len(problems) != 1 {
+		t.Fatalf("configchecks.Lint(%v) returned %d problems, expected 1: %v", config, len(problems), problems)
+	}
+	if diff := cmp.Diff([]string{"id1", "id2"}, problems[0].BenchmarkIDs); diff != "" {
+		t.Errorf("configchecks.Lint(%v) returned unexpected benchmark IDs (-want +got):\n%s", config, diff)
+	}
+}
+
+func TestLintReportsUnusedOptOuts(t *testing.T) {
+	config := &apb.ScanConfig{
+		BenchmarkConfigs: []*apb.BenchmarkConfig{
+			testconfigcreator.NewBenchmarkConfig(t, "id", testconfigcreator.NewFileScanInstruction(
+				[]*ipb.FileCheck{existenceCheck("/etc/file")})),
+		},
+		OptOutConfig: &apb.OptOutConfig{
+			ContentOptoutRegexes:   []string{"/etc/file"},
+			FilenameOptoutRegexes:  []string{"/var/.*"},
+			TraversalOptoutRegexes: []string{"("},
+		},
+	}
+	problems := configchecks.Lint(config)
+	got := []string{}
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	if len(got) != 2 || !strings.Contains(got[0], `filename opt-out regex "/var/.*"`) || !strings.Contains(got[1], "invalid traversal opt-out regex") {
+		t.Errorf("configchecks.Lint(%v) returned %v, expected an unused filename and an invalid traversal opt-out", config, got)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/sockets"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// The socket tables the listening services are read from.
+var listeningServiceProtocols = []string{sockets.TCP, sockets.TCP6, sockets.UDP, sockets.UDP6}
+
+// ListeningServicesCheck is an implementation of configchecks.BenchmarkCheck.
+// It checks that the listening TCP and UDP ports and their owning processes
+// are in an allow-list.
+type ListeningServicesCheck struct {
+	ctx              context.Context
+	benchmarkID      string
+	alternativeID    int
+	checkInstruction *ipb.ListeningServicesCheck
+	// The compiled process regexes of the allowed services, nil if unset.
+	processRegexes []*regexp.Regexp
+	fs             scanapi.Filesystem
+}
+
+// Exec executes the listening services check and returns the compliance status.
+func (c *ListeningServicesCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	listening := []*sockets.InetSocket{}
+	for _, protocol := range listeningServiceProtocols {
+		socketList, err := sockets.ReadInetSockets(c.ctx, c.fs, protocol)
+		if err != nil {
+			// The IPv6 tables don't exist if IPv6 is disabled.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, "", err
+		}
+		for _, s := range socketList {
+			if s.IsListening() && (c.checkInstruction.GetIncludeLoopback() || !s.IsLoopback()) {
+				listening = append(listening, s)
+			}
+		}
+	}
+
+	nonCompliantFiles := []*cpb.NonCompliantFile{}
+	if len(listening) > 0 {
+		owners, err := sockets.ReadSocketOwners(c.ctx, c.fs)
+		if err != nil {
+			return nil, "", err
+		}
+		reported := make(map[string]bool)
+		for _, s := range listening {
+			processes := owners[s.Inode]
+			if c.isAllowed(s, processes) {
+				continue
+			}
+			reason := fmt.Sprintf("%s port %d on %s is listened on by %s, which isn't allowed",
+				strings.ToUpper(s.Protocol), s.LocalPort, s.LocalIP, describeProcesses(processes))
+			// Sockets sharing the port with SO_REUSEPORT are reported once.
+			if reported[reason] {
+				continue
+			}
+			reported[reason] = true
+			nonCompliantFiles = append(nonCompliantFiles, &cpb.NonCompliantFile{
+				Path:   path.Join("/proc/net", s.Protocol),
+				Reason: reason,
+			})
+		}
+	}
+
+	// Report only the first N non-compliant services.
+	if len(nonCompliantFiles) > MaxNonCompliantFiles {
+		nonCompliantFiles = nonCompliantFiles[:MaxNonCompliantFiles]
+	}
+	if msg := c.checkInstruction.GetNonComplianceMsg(); msg != "" {
+		for _, f := range nonCompliantFiles {
+			f.Reason = msg
+		}
+	}
+	r := &apb.ComplianceResult{
+		Id: c.benchmarkID,
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{
+			NonCompliantFiles: nonCompliantFiles,
+		},
+	}
+	return ComplianceMap{c.alternativeID: r}, "", nil
+}
+
+// isAllowed returns whether the socket owned by the given processes matches
+// any of the allowed services.
+func (c *ListeningServicesCheck) isAllowed(s *sockets.InetSocket, processes []*sockets.Process) bool {
+	for i, a := range c.checkInstruction.GetAllowedServices() {
+		switch a.GetProtocol() {
+		case ipb.ListeningServicesCheck_TCP:
+			if s.Protocol != sockets.TCP && s.Protocol != sockets.TCP6 {
+				continue
+			}
+		case ipb.ListeningServicesCheck_UDP:
+			if s.Protocol != sockets.UDP && s.Protocol != sockets.UDP6 {
+				continue
+			}
+		}
+		if a.GetPort() != 0 && int(a.GetPort()) != s.LocalPort {
+			continue
+		}
+		if re := c.processRegexes[i]; re != nil && !allProcessesMatch(re, processes) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func allProcessesMatch(re *regexp.Regexp, processes []*sockets.Process) bool {
+	if len(processes) == 0 {
+		return false
+	}
+	for _, p := range processes {
+		if !re.MatchString(p.Comm) {
+			return false
+		}
+	}
+	return true
+}
+
+func describeProcesses(processes []*sockets.Process) string {
+	if len(processes) == 0 {
+		return "an unknown process"
+	}
+	descriptions := make([]string, 0, len(processes))
+	for _, p := range processes {
+		descriptions = append(descriptions, fmt.Sprintf("%s (PID %d)", p.Comm, p.PID))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// BenchmarkIDs returns the IDs of the benchmarks associated with this check.
+func (c *ListeningServicesCheck) BenchmarkIDs() []string {
+	return []string{c.benchmarkID}
+}
+
+func (c *ListeningServicesCheck) String() string {
+	return fmt.Sprintf("[listening services check with %d allowed services]", len(c.checkInstruction.GetAllowedServices()))
+}
+
+// createListeningServicesChecksFromConfig parses the benchmark config and
+// creates the listening services checks that it defines.
+func createListeningServicesChecksFromConfig(ctx context.Context, benchmarks []*benchmark, fs scanapi.Filesystem) ([]*ListeningServicesCheck, error) {
+	checks := []*ListeningServicesCheck{}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, instruction := range alt.proto.GetListeningServicesChecks() {
+				processRegexes := make([]*regexp.Regexp, 0, len(instruction.GetAllowedServices()))
+				for _, a := range instruction.GetAllowedServices() {
+					if a.GetPort() < 0 || a.GetPort() > 65535 {
+						return nil, fmt.Errorf("listening services check %v in benchmark %s has invalid port %d", instruction, b.id, a.GetPort())
+					}
+					var re *regexp.Regexp
+					if a.GetProcessRegex() != "" {
+						var err error
+						if re, err = regexp.Compile("^(?:" + a.GetProcessRegex() + ")$"); err != nil {
+							return nil, fmt.Errorf("listening services check %v in benchmark %s has invalid process regex: %w", instruction, b.id, err)
+						}
+					}
+					processRegexes = append(processRegexes, re)
+				}
+				checks = append(checks, &ListeningServicesCheck{
+					ctx:              ctx,
+					benchmarkID:      b.id,
+					alternativeID:    alt.id,
+					checkInstruction: instruction,
+					processRegexes:   processRegexes,
+					fs:               fs,
+				})
+			}
+		}
+	}
+	return checks, nil
+}
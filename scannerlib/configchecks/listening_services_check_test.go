@@ -0,0 +1,228 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+const procNetHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+var testListeningServicesFiles = map[string]string{
+	"/proc/net/tcp": procNetHeader +
+		// sshd on 0.0.0.0:22.
+		"  0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 100 1 0000000000000000 100 0 0 10 0\n" +
+		// cupsd on 127.0.0.1:631.
+		"  1: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 101 1 0000000000000000 100 0 0 10 0\n" +
+		// An established connection.
+		"  2: 0F02000A:0016 0202000A:C350 01 00000000:00000000 00:00000000 00000000     0        0 102 1 0000000000000000 100 0 0 10 0\n",
+	"/proc/net/udp": procNetHeader +
+		// snmpd on 0.0.0.0:161.
+		"  0: 00000000:00A1 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 103 2 0000000000000000 0\n" +
+		// A socket owned by a process we can't see.
+		"  1: 00000000:0045 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 104 2 0000000000000000 0\n",
+	// IPv6 is disabled, /proc/net/tcp6 and /proc/net/udp6 don't exist.
+	"/proc/10/comm": "sshd\n",
+	"/proc/10/fd/3": "",
+	"/proc/11/comm": "cupsd\n",
+	"/proc/11/fd/5": "",
+	"/proc/12/comm": "snmpd\n",
+	"/proc/12/fd/4": "",
+	"/proc/12/fd/7": "",
+}
+
+var testListeningServicesFileStats = map[string]*apb.FileStat{
+	"/proc/10/fd/3": {Type: apb.FileStat_SYMLINK, LinkTarget: "socket:[100]"},
+	"/proc/11/fd/5": {Type: apb.FileStat_SYMLINK, LinkTarget: "socket:[101]"},
+	"/proc/12/fd/4": {Type: apb.FileStat_SYMLINK, LinkTarget: "socket:[103]"},
+	"/proc/12/fd/7": {Type: apb.FileStat_SYMLINK, LinkTarget: "/var/log/snmpd.log"},
+}
+
+func TestListeningServicesCheckInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		check *ipb.ListeningServicesCheck
+	}{
+		{
+			desc: "invalid port",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{{Port: 70000}},
+			},
+		},
+		{
+			desc: "invalid process regex",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{{Port: 22, ProcessRegex: "("}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewListeningServicesScanInstruction([]*ipb.ListeningServicesCheck{tc.check})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestListeningServicesCheckComplianceResults(t *testing.T) {
+	sshd := &ipb.ListeningServicesCheck_AllowedService{
+		Protocol:     ipb.ListeningServicesCheck_TCP,
+		Port:         22,
+		ProcessRegex: "sshd",
+	}
+	testCases := []struct {
+		desc                      string
+		check                     *ipb.ListeningServicesCheck
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc: "unexpected UDP services",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{sshd},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{
+					Path:   "/proc/net/udp",
+					Reason: "UDP port 161 on 0.0.0.0 is listened on by snmpd (PID 12), which isn't allowed",
+				},
+				{
+					Path:   "/proc/net/udp",
+					Reason: "UDP port 69 on 0.0.0.0 is listened on by an unknown process, which isn't allowed",
+				},
+			},
+		},
+		{
+			desc: "all services allowed",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{
+					sshd,
+					{Protocol: ipb.ListeningServicesCheck_UDP, Port: 161},
+					{Port: 69},
+				},
+			},
+		},
+		{
+			desc: "wrong protocol",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{
+					sshd,
+					{Protocol: ipb.ListeningServicesCheck_TCP, Port: 161},
+					{Protocol: ipb.ListeningServicesCheck_TCP, Port: 69},
+				},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{
+					Path:   "/proc/net/udp",
+					Reason: "UDP port 161 on 0.0.0.0 is listened on by snmpd (PID 12), which isn't allowed",
+				},
+				{
+					Path:   "/proc/net/udp",
+					Reason: "UDP port 69 on 0.0.0.0 is listened on by an unknown process, which isn't allowed",
+				},
+			},
+		},
+		{
+			desc: "process regex required for unknown owners",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{
+					sshd,
+					{ProcessRegex: "snmp.*"},
+					{Port: 69, ProcessRegex: "tftpd"},
+				},
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{
+					Path:   "/proc/net/udp",
+					Reason: "UDP port 69 on 0.0.0.0 is listened on by an unknown process, which isn't allowed",
+				},
+			},
+		},
+		{
+			desc: "loopback included",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{
+					{Protocol: ipb.ListeningServicesCheck_TCP},
+				},
+				IncludeLoopback:  true,
+				NonComplianceMsg: "Unexpected UDP service",
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{Path: "/proc/net/udp", Reason: "Unexpected UDP service"},
+				{Path: "/proc/net/udp", Reason: "Unexpected UDP service"},
+			},
+		},
+		{
+			desc: "loopback services checked",
+			check: &ipb.ListeningServicesCheck{
+				AllowedServices: []*ipb.ListeningServicesCheck_AllowedService{
+					sshd,
+					{Protocol: ipb.ListeningServicesCheck_UDP},
+				},
+				IncludeLoopback: true,
+			},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{
+					Path:   "/proc/net/tcp",
+					Reason: "TCP port 631 on 127.0.0.1 is listened on by cupsd (PID 11), which isn't allowed",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewListeningServicesScanInstruction([]*ipb.ListeningServicesCheck{tc.check})
+			check := createSingleCheck(t, scanInstruction, newFakeAPI(
+				withFiles(testListeningServicesFiles),
+				withFileStats(testListeningServicesFileStats)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
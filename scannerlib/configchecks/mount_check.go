@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/mounts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// MountCheck is an implementation of configchecks.BenchmarkCheck.
+// It checks whether a mount point is a separate partition and has the
+// expected mount options.
+type MountCheck struct {
+	ctx              context.Context
+	benchmarkID      string
+	alternativeID    int
+	checkInstruction *ipb.MountCheck
+	fs               scanapi.Filesystem
+}
+
+// Exec executes the mount check and returns the compliance status.
+func (c *MountCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	mountList, err := c.readMounts()
+	if err != nil {
+		return nil, "", err
+	}
+	mountPoint := c.checkInstruction.GetMountPoint()
+	reasons := []string{}
+	if m := mounts.FindMount(mountList, mountPoint); m != nil {
+		for _, o := range c.checkInstruction.GetRequiredOptions() {
+			if !m.HasOption(o) {
+				reasons = append(reasons, fmt.Sprintf("Mount point %s doesn't have the %s option set (%s)", mountPoint, o, m.ConfigFile))
+			}
+		}
+		for _, o := range c.checkInstruction.GetForbiddenOptions() {
+			if m.HasOption(o) {
+				reasons = append(reasons, fmt.Sprintf("Mount point %s has the %s option set (%s), expected it not to", mountPoint, o, m.ConfigFile))
+			}
+		}
+	} else if c.checkInstruction.GetSeparatePartition() {
+		reasons = append(reasons, fmt.Sprintf("%s is not a separate partition", mountPoint))
+	}
+
+	reason := strings.Join(reasons, "\n")
+	if reason != "" && c.checkInstruction.GetNonComplianceMsg() != "" {
+		reason = c.checkInstruction.GetNonComplianceMsg()
+	}
+	r := &apb.ComplianceResult{
+		Id: c.benchmarkID,
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{
+			NonComplianceReason: reason,
+		},
+	}
+	return ComplianceMap{c.alternativeID: r}, "", nil
+}
+
+func (c *MountCheck) readMounts() ([]*mounts.Mount, error) {
+	switch c.checkInstruction.GetMountTable() {
+	case ipb.MountCheck_MOUNTINFO:
+		return mounts.ReadMountInfo(c.ctx, c.fs)
+	case ipb.MountCheck_CONFIGURED:
+		return mounts.ReadConfiguredMounts(c.ctx, c.fs)
+	default:
+		mountList, err := mounts.ReadMountInfo(c.ctx, c.fs)
+		if errors.Is(err, os.ErrNotExist) {
+			return mounts.ReadConfiguredMounts(c.ctx, c.fs)
+		}
+		return mountList, err
+	}
+}
+
+// BenchmarkIDs returns the IDs of the benchmarks associated with this check.
+func (c *MountCheck) BenchmarkIDs() []string {
+	return []string{c.benchmarkID}
+}
+
+func (c *MountCheck) String() string {
+	return fmt.Sprintf("[mount check on %q]", c.checkInstruction.GetMountPoint())
+}
+
+// createMountChecksFromConfig parses the benchmark config and creates the
+// mount checks that it defines.
+func createMountChecksFromConfig(ctx context.Context, benchmarks []*benchmark, fs scanapi.Filesystem) ([]*MountCheck, error) {
+	checks := []*MountCheck{}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, instruction := range alt.proto.GetMountChecks() {
+				if instruction.GetMountPoint() == "" {
+					return nil, fmt.Errorf("mount check %v in benchmark %s has no mount point", instruction, b.id)
+				}
+				checks = append(checks, &MountCheck{
+					ctx:              ctx,
+					benchmarkID:      b.id,
+					alternativeID:    alt.id,
+					checkInstruction: instruction,
+					fs:               fs,
+				})
+			}
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+const (
+	testMountInfo = "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro\n" +
+		"25 22 0:21 / /tmp rw,nosuid,nodev,noexec,relatime - tmpfs tmpfs rw\n" +
+		"26 22 0:22 / /dev/shm rw,nosuid - tmpfs tmpfs rw\n"
+	testFstab = "UUID=1234 / ext4 errors=remount-ro 0 1\n" +
+		"tmpfs /dev/shm tmpfs defaults,nodev,nosuid,noexec 0 0\n"
+)
+
+func TestMountCheckWithoutMountPointReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewMountScanInstruction([]*ipb.MountCheck{
+		{SeparatePartition: true},
+	})
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+
+	if _, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI()); err == nil {
+		t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+	}
+}
+
+func TestMountCheckComplianceResults(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		check          *ipb.MountCheck
+		files          map[string]string
+		expectedReason string
+	}{
+		{
+			desc: "separate partition with required options",
+			check: &ipb.MountCheck{
+				MountPoint:        "/tmp",
+				MountTable:        ipb.MountCheck_MOUNTINFO,
+				SeparatePartition: true,
+				RequiredOptions:   []string{"nodev", "nosuid", "noexec"},
+			},
+			files:          map[string]string{"/proc/self/mountinfo": testMountInfo},
+			expectedReason: "",
+		},
+		{
+			desc: "not a separate partition",
+			check: &ipb.MountCheck{
+				MountPoint:        "/var/tmp",
+				MountTable:        ipb.MountCheck_MOUNTINFO,
+				SeparatePartition: true,
+			},
+			files:          map[string]string{"/proc/self/mountinfo": testMountInfo},
+			expectedReason: "/var/tmp is not a separate partition",
+		},
+		{
+			desc: "options not checked if there's no separate partition",
+			check: &ipb.MountCheck{
+				MountPoint:      "/var/tmp",
+				MountTable:      ipb.MountCheck_MOUNTINFO,
+				RequiredOptions: []string{"nodev"},
+			},
+			files:          map[string]string{"/proc/self/mountinfo": testMountInfo},
+			expectedReason: "",
+		},
+		{
+			desc: "missing required options",
+			check: &ipb.MountCheck{
+				MountPoint:      "/dev/shm",
+				MountTable:      ipb.MountCheck_MOUNTINFO,
+				RequiredOptions: []string{"nodev", "nosuid", "noexec"},
+			},
+			files: map[string]string{"/proc/self/mountinfo": testMountInfo},
+			expectedReason: "Mount point /dev/shm doesn't have the nodev option set (/proc/self/mountinfo)\n" +
+				"Mount point /dev/shm doesn't have the noexec option set (/proc/self/mountinfo)",
+		},
+		{
+			desc: "forbidden option set",
+			check: &ipb.MountCheck{
+				MountPoint:       "/tmp",
+				MountTable:       ipb.MountCheck_MOUNTINFO,
+				ForbiddenOptions: []string{"relatime"},
+			},
+			files:          map[string]string{"/proc/self/mountinfo": testMountInfo},
+			expectedReason: "Mount point /tmp has the relatime option set (/proc/self/mountinfo), expected it not to",
+		},
+		{
+			desc: "configured mounts from fstab",
+			check: &ipb.MountCheck{
+				MountPoint:        "/dev/shm",
+				MountTable:        ipb.MountCheck_CONFIGURED,
+				SeparatePartition: true,
+				RequiredOptions:   []string{"nodev", "nosuid", "noexec"},
+			},
+			files: map[string]string{
+				"/proc/self/mountinfo": testMountInfo,
+				"/etc/fstab":           testFstab,
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "configured mounts from systemd unit",
+			check: &ipb.MountCheck{
+				MountPoint:        "/tmp",
+				MountTable:        ipb.MountCheck_CONFIGURED,
+				SeparatePartition: true,
+				RequiredOptions:   []string{"nodev", "nosuid", "noexec"},
+			},
+			files: map[string]string{
+				"/etc/fstab":                    testFstab,
+				"/etc/systemd/system/tmp.mount": "[Mount]\nWhat=tmpfs\nWhere=/tmp\nType=tmpfs\nOptions=mode=1777,nosuid,nodev\n",
+			},
+			expectedReason: "Mount point /tmp doesn't have the noexec option set (/etc/systemd/system/tmp.mount)",
+		},
+		{
+			desc: "mountinfo used by default",
+			check: &ipb.MountCheck{
+				MountPoint:      "/dev/shm",
+				RequiredOptions: []string{"noexec"},
+			},
+			files: map[string]string{
+				"/proc/self/mountinfo": testMountInfo,
+				"/etc/fstab":           testFstab,
+			},
+			expectedReason: "Mount point /dev/shm doesn't have the noexec option set (/proc/self/mountinfo)",
+		},
+		{
+			desc: "configured mounts used by default if there's no mountinfo",
+			check: &ipb.MountCheck{
+				MountPoint:      "/dev/shm",
+				RequiredOptions: []string{"noexec"},
+			},
+			files:          map[string]string{"/etc/fstab": testFstab},
+			expectedReason: "",
+		},
+		{
+			desc: "custom non-compliance message",
+			check: &ipb.MountCheck{
+				MountPoint:        "/var/tmp",
+				SeparatePartition: true,
+				NonComplianceMsg:  "/var/tmp should be a separate partition",
+			},
+			files:          map[string]string{"/proc/self/mountinfo": testMountInfo},
+			expectedReason: "/var/tmp should be a separate partition",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewMountScanInstruction([]*ipb.MountCheck{tc.check})
+			check := createSingleCheck(t, scanInstruction, newFakeAPI(withFiles(tc.files)))
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonComplianceReason: tc.expectedReason,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMountCheckWithoutMountInfoReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewMountScanInstruction([]*ipb.MountCheck{{
+		MountPoint:        "/tmp",
+		MountTable:        ipb.MountCheck_MOUNTINFO,
+		SeparatePartition: true,
+	}})
+	check := createSingleCheck(t, scanInstruction, newFakeAPI(withFiles(map[string]string{})))
+	if _, _, err := check.Exec(""); err == nil {
+		t.Errorf("check.Exec() didn't return an error")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/fileset"
+	"github.com/google/localtoast/scannerlib/packages"
+)
+
+// The hash functions used by the package managers for the file digests.
+var packageDigestHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha224": sha256.New224,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// packageIntegrityFileChecker compares files with the metadata of the package
+// that installed them.
+type packageIntegrityFileChecker struct {
+	fc   *fileCheck
+	pkgs *packages.Reader
+	// The packaged files by path, read when the first file is checked.
+	files map[string]*packages.File
+}
+
+func newPackageIntegrityFileChecker(fc *fileCheck, pkgs *packages.Reader) (*packageIntegrityFileChecker, error) {
+	if fc.filesToCheck.GetPackageFiles() == nil {
+		return nil, fmt.Errorf("package integrity check %v can only be used on package files", fc.checkInstruction)
+	}
+	pc := fc.checkInstruction.GetPackageIntegrity()
+	if !pc.GetCheckMode() && !pc.GetCheckOwner() && !pc.GetCheckDigest() {
+		return nil, fmt.Errorf("package integrity check %v has no properties to check", fc.checkInstruction)
+	}
+	return &packageIntegrityFileChecker{fc: fc, pkgs: pkgs}, nil
+}
+
+func (c *packageIntegrityFileChecker) exec(ctx context.Context, path string, fs scanapi.Filesystem) error {
+	if c.files == nil {
+		files, err := fileset.ReadPackageFiles(ctx, c.fc.filesToCheck.GetPackageFiles(), fs, c.pkgs)
+		if err != nil {
+			return err
+		}
+		c.files = make(map[string]*packages.File, len(files))
+		for _, f := range files {
+			c.files[f.Path] = f
+		}
+	}
+	f, ok := c.files[path]
+	pc := c.fc.checkInstruction.GetPackageIntegrity()
+	if !ok || (f.IsConfig && pc.GetSkipConfigFiles()) {
+		return nil
+	}
+	pkg := c.fc.filesToCheck.GetPackageFiles().GetPackageName()
+
+	checkMode := pc.GetCheckMode() && f.Mode >= 0 && f.Type != packages.Symlink
+	checkOwner := pc.GetCheckOwner() && (f.User != "" || f.Group != "")
+	if checkMode || checkOwner {
+		perms, err := fs.FilePermissions(ctx, path)
+		if err != nil {
+			// Return a non-compliance instead of an error if the file doesn't exist.
+			if errors.Is(err, os.ErrNotExist) {
+				c.fc.addNonCompliantFile(path, "File doesn't exist")
+				return nil
+			}
+			return err
+		}
+		if mode := perms.GetPermissionNum() & 07777; checkMode && mode != f.Mode {
+			c.fc.addNonCompliantFile(path, fmt.Sprintf("File permission is %04o, package %s expects %04o", mode, pkg, f.Mode))
+		}
+		if checkOwner && f.User != "" && perms.GetUser() != f.User {
+			c.fc.addNonCompliantFile(path, fmt.Sprintf("Owner is %s, package %s expects %s", perms.GetUser(), pkg, f.User))
+		}
+		if checkOwner && f.Group != "" && perms.GetGroup() != f.Group {
+			c.fc.addNonCompliantFile(path, fmt.Sprintf("Group is %s, package %s expects %s", perms.GetGroup(), pkg, f.Group))
+		}
+	}
+
+	if !pc.GetCheckDigest() || f.Digest == "" || f.Type == packages.Directory || f.Type == packages.Symlink {
+		return nil
+	}
+	newHash, ok := packageDigestHashes[f.DigestAlgorithm]
+	if !ok {
+		return fmt.Errorf("package %s has file %s with unsupported digest algorithm %q", pkg, path, f.DigestAlgorithm)
+	}
+	r, err := fs.OpenFile(ctx, path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			if !checkMode && !checkOwner {
+				c.fc.addNonCompliantFile(path, "File doesn't exist")
+			}
+			return nil
+		}
+		return err
+	}
+	defer r.Close()
+	h := newHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if digest := hex.EncodeToString(h.Sum(nil)); digest != strings.ToLower(f.Digest) {
+		c.fc.addNonCompliantFile(path, fmt.Sprintf("Got %s digest %s, package %s expects %s",
+			strings.ToUpper(f.DigestAlgorithm), digest, pkg, f.Digest))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	"github.com/google/localtoast/scannerlib/packages"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+func packageFileSet(name string) *ipb.FileSet {
+	return &ipb.FileSet{FilePath: &ipb.FileSet_PackageFiles_{PackageFiles: &ipb.FileSet_PackageFiles{
+		PackageName:    name,
+		PackageManager: ipb.FileSet_PackageFiles_DPKG,
+	}}}
+}
+
+func TestPackageIntegrityCheckInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		fileSet *ipb.FileSet
+		check   *ipb.PackageIntegrityCheck
+	}{
+		{
+			desc:    "no properties to check",
+			fileSet: packageFileSet("pkg"),
+			check:   &ipb.PackageIntegrityCheck{SkipConfigFiles: true},
+		},
+		{
+			desc:    "not a package file set",
+			fileSet: testconfigcreator.SingleFileWithPath(testFilePath),
+			check:   &ipb.PackageIntegrityCheck{CheckDigest: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{tc.fileSet},
+				CheckType:    &ipb.FileCheck_PackageIntegrity{PackageIntegrity: tc.check},
+			}})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestPackageIntegrityCheckComplianceResults(t *testing.T) {
+	files := map[string]string{
+		packages.DpkgInfoDir + "/pkg.list": "/usr/bin/good\n/usr/bin/modified\n/etc/pkg.conf\n/etc/pkg.d\n" +
+			nonExistentFilePath + "\n",
+		packages.DpkgInfoDir + "/pkg.md5sums": "9d7183f16acce70658f686ae7f1a4d20  usr/bin/good\n" +
+			"9d7183f16acce70658f686ae7f1a4d20  usr/bin/modified\n" +
+			"9d7183f16acce70658f686ae7f1a4d20  etc/pkg.conf\n" +
+			"9d7183f16acce70658f686ae7f1a4d20  " + nonExistentFilePath[1:] + "\n",
+		packages.DpkgInfoDir + "/pkg.conffiles": "/etc/pkg.conf\n",
+		packages.DpkgStatOverridePath:           "pkg adm 0750 /etc/pkg.d\n",
+		"/usr/bin/good":                         "binary",
+		"/usr/bin/modified":                     "modified binary",
+		"/etc/pkg.conf":                         "edited config",
+		"/etc/pkg.d/file":                       "",
+	}
+	testCases := []struct {
+		desc                      string
+		check                     *ipb.PackageIntegrityCheck
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc:  "digests",
+			check: &ipb.PackageIntegrityCheck{CheckDigest: true},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{
+					Path:   "/usr/bin/modified",
+					Reason: "Got MD5 digest aa2dd5f1a4d68b19303a5ba3015b0d1f, package pkg expects 9d7183f16acce70658f686ae7f1a4d20",
+				},
+				{
+					Path:   "/etc/pkg.conf",
+					Reason: "Got MD5 digest 61c5ec61003beaaa556fc9df101a1129, package pkg expects 9d7183f16acce70658f686ae7f1a4d20",
+				},
+				{Path: nonExistentFilePath, Reason: "File doesn't exist"},
+			},
+		},
+		{
+			desc:  "config files skipped",
+			check: &ipb.PackageIntegrityCheck{CheckDigest: true, SkipConfigFiles: true},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{
+					Path:   "/usr/bin/modified",
+					Reason: "Got MD5 digest aa2dd5f1a4d68b19303a5ba3015b0d1f, package pkg expects 9d7183f16acce70658f686ae7f1a4d20",
+				},
+				{Path: nonExistentFilePath, Reason: "File doesn't exist"},
+			},
+		},
+		{
+			desc:  "mode and owner from dpkg-statoverride",
+			check: &ipb.PackageIntegrityCheck{CheckMode: true, CheckOwner: true},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{
+				{Path: "/etc/pkg.d", Reason: "File permission is 0644, package pkg expects 0750"},
+				{Path: "/etc/pkg.d", Reason: "Owner is root, package pkg expects pkg"},
+				{Path: "/etc/pkg.d", Reason: "Group is root, package pkg expects adm"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{packageFileSet("pkg")},
+				CheckType:    &ipb.FileCheck_PackageIntegrity{PackageIntegrity: tc.check},
+			}}, newFakeAPI(withFiles(files)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+const pamConfigDir = "/etc/pam.d"
+
+// PamCheck is an implementation of configchecks.BenchmarkCheck.
+// It checks whether a module is present in the PAM stack of a service with
+// the expected control and arguments.
+type PamCheck struct {
+	ctx              context.Context
+	benchmarkID      string
+	alternativeID    int
+	checkInstruction *ipb.PamCheck
+	fs               scanapi.Filesystem
+}
+
+// pamEntry is a single module entry of a resolved PAM stack.
+type pamEntry struct {
+	control string
+	module  string
+	args    []string
+	// The file the entry was read from.
+	source string
+}
+
+// Exec executes the PAM check and returns the compliance status.
+func (c *PamCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	ci := c.checkInstruction
+	stack, err := resolvePamStack(c.ctx, c.fs, ci.GetService(), ci.GetModuleType(), nil)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+
+	reasons := []string{}
+	module := normalizePamModuleName(ci.GetModule())
+	entryReasons := []string{}
+	found := false
+	for i, e := range stack {
+		if normalizePamModuleName(e.module) != module {
+			continue
+		}
+		found = true
+		violations := c.entryViolations(stack, i)
+		if len(violations) == 0 {
+			entryReasons = nil
+			break
+		}
+		entryReasons = append(entryReasons, violations...)
+	}
+	if !found {
+		reasons = append(reasons, fmt.Sprintf("Module %s not found in the %s stack of the %s PAM service",
+			ci.GetModule(), ci.GetModuleType(), ci.GetService()))
+	}
+	reasons = append(reasons, entryReasons...)
+
+	reason := strings.Join(reasons, "\n")
+	if reason != "" && ci.GetNonComplianceMsg() != "" {
+		reason = ci.GetNonComplianceMsg()
+	}
+	r := &apb.ComplianceResult{
+		Id: c.benchmarkID,
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{
+			NonComplianceReason: reason,
+		},
+	}
+	return ComplianceMap{c.alternativeID: r}, "", nil
+}
+
+// entryViolations returns the reasons why the entry at the given position of
+// the stack doesn't satisfy the check.
+func (c *PamCheck) entryViolations(stack []*pamEntry, i int) []string {
+	ci := c.checkInstruction
+	e := stack[i]
+	violations := []string{}
+	if len(ci.GetAllowedControls()) > 0 {
+		allowed := false
+		for _, control := range ci.GetAllowedControls() {
+			if normalizePamControl(control) == normalizePamControl(e.control) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("%s has control %s in %s, expected one of [%s]",
+				e.module, e.control, e.source, strings.Join(ci.GetAllowedControls(), ", ")))
+		}
+	}
+	for _, constraint := range ci.GetArgumentConstraints() {
+		if v := pamArgumentViolation(e, constraint); v != "" {
+			violations = append(violations, v)
+		}
+	}
+	if ci.GetPrecedesModule() != "" {
+		next := normalizePamModuleName(ci.GetPrecedesModule())
+		for _, prev := range stack[:i] {
+			if normalizePamModuleName(prev.module) == next {
+				violations = append(violations, fmt.Sprintf("%s in %s comes after %s in %s, expected it to come before",
+					e.module, e.source, prev.module, prev.source))
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// pamArgumentViolation returns the reason why the given entry doesn't satisfy
+// the argument constraint or an empty string if it does.
+func pamArgumentViolation(e *pamEntry, constraint *ipb.PamArgumentConstraint) string {
+	value, present := pamArgument(e.args, constraint.GetName())
+	switch constraint.GetOperator() {
+	case ipb.PamArgumentConstraint_PRESENT:
+		if !present {
+			return fmt.Sprintf("%s in %s doesn't have the %s argument", e.module, e.source, constraint.GetName())
+		}
+	case ipb.PamArgumentConstraint_ABSENT:
+		if present {
+			return fmt.Sprintf("%s in %s has the %s argument, expected it not to", e.module, e.source, constraint.GetName())
+		}
+	case ipb.PamArgumentConstraint_EQUAL:
+		if !present || value != constraint.GetValue() {
+			return fmt.Sprintf("%s in %s doesn't have %s=%s", e.module, e.source, constraint.GetName(), constraint.GetValue())
+		}
+	case ipb.PamArgumentConstraint_GREATER_OR_EQUAL, ipb.PamArgumentConstraint_LESS_OR_EQUAL:
+		op := ">="
+		if constraint.GetOperator() == ipb.PamArgumentConstraint_LESS_OR_EQUAL {
+			op = "<="
+		}
+		want, _ := strconv.ParseInt(constraint.GetValue(), 10, 64)
+		got, err := strconv.ParseInt(value, 10, 64)
+		if !present || err != nil ||
+			(op == ">=" && got < want) || (op == "<=" && got > want) {
+			return fmt.Sprintf("%s in %s doesn't have %s%s%s", e.module, e.source, constraint.GetName(), op, constraint.GetValue())
+		}
+	}
+	return ""
+}
+
+// pamArgument returns the value of the given module argument and whether the
+// argument is present.
+func pamArgument(args []string, name string) (string, bool) {
+	for _, a := range args {
+		kv := strings.SplitN(a, "=", 2)
+		if kv[0] != name {
+			continue
+		}
+		if len(kv) == 2 {
+			return kv[1], true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// resolvePamStack reads the entries of the given module type from the PAM
+// config of the service. Included stacks are resolved recursively. The
+// includeChain is used to detect include cycles.
+func resolvePamStack(ctx context.Context, fs scanapi.Filesystem, service string, moduleType string, includeChain []string) ([]*pamEntry, error) {
+	includeChain = append(includeChain, service)
+	for _, s := range includeChain[:len(includeChain)-1] {
+		if s == service {
+			return nil, fmt.Errorf("PAM include cycle: %s", strings.Join(includeChain, " -> "))
+		}
+	}
+	filePath := path.Join(pamConfigDir, service)
+	f, err := fs.OpenFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines, err := readContinuedLines(f)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*pamEntry{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens := tokenizePamLine(line)
+		if tokens[0] == "@include" {
+			if len(tokens) < 2 {
+				continue
+			}
+			included, err := resolvePamStack(ctx, fs, tokens[1], moduleType, includeChain)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			result = append(result, included...)
+			continue
+		}
+		if len(tokens) < 3 {
+			continue
+		}
+		// A leading dash means that missing modules shouldn't be logged.
+		if strings.TrimPrefix(tokens[0], "-") != moduleType {
+			continue
+		}
+		if tokens[1] == "include" || tokens[1] == "substack" {
+			included, err := resolvePamStack(ctx, fs, tokens[2], moduleType, includeChain)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			result = append(result, included...)
+			continue
+		}
+		result = append(result, &pamEntry{
+			control: tokens[1],
+			module:  tokens[2],
+			args:    tokens[3:],
+			source:  filePath,
+		})
+	}
+	return result, nil
+}
+
+// tokenizePamLine splits a PAM config line into whitespace-separated tokens.
+// Tokens enclosed in square brackets (e.g. "[success=1 default=ignore]") are
+// kept together.
+func tokenizePamLine(line string) []string {
+	tokens := []string{}
+	var current strings.Builder
+	inBrackets := false
+	for _, r := range line {
+		switch {
+		case r == '[' && current.Len() == 0:
+			inBrackets = true
+			current.WriteRune(r)
+		case r == ']' && inBrackets:
+			inBrackets = false
+			current.WriteRune(r)
+		case (r == ' ' || r == '\t') && !inBrackets:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// normalizePamModuleName strips the directory and the .so suffix from a module
+// path, e.g. "/lib/security/pam_unix.so" -> "pam_unix".
+func normalizePamModuleName(module string) string {
+	return strings.TrimSuffix(path.Base(module), ".so")
+}
+
+// normalizePamControl collapses the whitespace in a PAM control value.
+func normalizePamControl(control string) string {
+	control = strings.TrimSpace(control)
+	if !strings.HasPrefix(control, "[") {
+		return control
+	}
+	inner := strings.TrimSuffix(strings.TrimPrefix(control, "["), "]")
+	return "[" + strings.Join(strings.Fields(inner), " ") + "]"
+}
+
+// BenchmarkIDs returns the IDs of the benchmarks associated with this check.
+func (c *PamCheck) BenchmarkIDs() []string {
+	return []string{c.benchmarkID}
+}
+
+func (c *PamCheck) String() string {
+	return fmt.Sprintf("[PAM check for %s in %s %s]", c.checkInstruction.GetModule(), c.checkInstruction.GetService(), c.checkInstruction.GetModuleType())
+}
+
+// createPamChecksFromConfig parses the benchmark config and creates the PAM
+// checks that it defines.
+func createPamChecksFromConfig(ctx context.Context, benchmarks []*benchmark, fs scanapi.Filesystem) ([]*PamCheck, error) {
+	checks := []*PamCheck{}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, instruction := range alt.proto.GetPamChecks() {
+				if instruction.GetService() == "" || instruction.GetModuleType() == "" || instruction.GetModule() == "" {
+					return nil, fmt.Errorf("PAM check %v in benchmark %s needs a service, module type and module", instruction, b.id)
+				}
+				for _, constraint := range instruction.GetArgumentConstraints() {
+					switch constraint.GetOperator() {
+					case ipb.PamArgumentConstraint_GREATER_OR_EQUAL, ipb.PamArgumentConstraint_LESS_OR_EQUAL:
+						if _, err := strconv.ParseInt(constraint.GetValue(), 10, 64); err != nil {
+							return nil, fmt.Errorf("PAM check %v in benchmark %s has non-numeric value for argument %s: %w",
+								instruction, b.id, constraint.GetName(), err)
+						}
+					}
+				}
+				checks = append(checks, &PamCheck{
+					ctx:              ctx,
+					benchmarkID:      b.id,
+					alternativeID:    alt.id,
+					checkInstruction: instruction,
+					fs:               fs,
+				})
+			}
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+var testPamFiles = map[string]string{
+	"/etc/pam.d/passwd": "#%PAM-1.0\n@include common-password\n",
+	"/etc/pam.d/common-password": "# here are the per-package modules (the \"Primary\" block)\n" +
+		"password\trequisite\t\t\tpam_pwquality.so retry=3 minlen=14\n" +
+		"password\t[success=1 default=ignore]\tpam_unix.so obscure use_authtok try_first_pass yescrypt \\\n" +
+		"  remember=5\n" +
+		"password\trequisite\t\t\tpam_deny.so\n" +
+		"password\trequired\t\t\tpam_permit.so\n",
+	"/etc/pam.d/system-auth": "auth required pam_env.so\n" +
+		"auth required pam_faillock.so preauth silent deny=5\n" +
+		"auth substack password-auth-local\n" +
+		"-auth sufficient /usr/lib64/security/pam_sss.so forward_pass\n" +
+		"account required pam_unix.so\n",
+	"/etc/pam.d/password-auth-local": "auth sufficient pam_unix.so nullok\n" +
+		"auth [default=die] pam_faillock.so authfail\n" +
+		"account required pam_faillock.so\n",
+	"/etc/pam.d/loop-a": "auth include loop-b\n",
+	"/etc/pam.d/loop-b": "auth include loop-a\n",
+}
+
+func TestPamCheckInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		check *ipb.PamCheck
+	}{
+		{
+			desc:  "missing module",
+			check: &ipb.PamCheck{Service: "passwd", ModuleType: "password"},
+		},
+		{
+			desc: "non-numeric comparison",
+			check: &ipb.PamCheck{
+				Service:    "passwd",
+				ModuleType: "password",
+				Module:     "pam_pwquality.so",
+				ArgumentConstraints: []*ipb.PamArgumentConstraint{{
+					Name:     "minlen",
+					Operator: ipb.PamArgumentConstraint_GREATER_OR_EQUAL,
+					Value:    "fourteen",
+				}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewPamScanInstruction([]*ipb.PamCheck{tc.check})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestPamCheckComplianceResults(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		check          *ipb.PamCheck
+		expectedReason string
+	}{
+		{
+			desc: "module with argument constraints through @include",
+			check: &ipb.PamCheck{
+				Service:         "passwd",
+				ModuleType:      "password",
+				Module:          "pam_pwquality.so",
+				AllowedControls: []string{"required", "requisite"},
+				ArgumentConstraints: []*ipb.PamArgumentConstraint{
+					{Name: "minlen", Operator: ipb.PamArgumentConstraint_GREATER_OR_EQUAL, Value: "14"},
+					{Name: "retry", Operator: ipb.PamArgumentConstraint_LESS_OR_EQUAL, Value: "3"},
+				},
+				PrecedesModule: "pam_unix.so",
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "argument constraints not met",
+			check: &ipb.PamCheck{
+				Service:    "common-password",
+				ModuleType: "password",
+				Module:     "pam_unix",
+				ArgumentConstraints: []*ipb.PamArgumentConstraint{
+					{Name: "remember", Operator: ipb.PamArgumentConstraint_GREATER_OR_EQUAL, Value: "24"},
+					{Name: "obscure", Operator: ipb.PamArgumentConstraint_ABSENT},
+					{Name: "sha512", Operator: ipb.PamArgumentConstraint_PRESENT},
+					{Name: "use_authtok", Operator: ipb.PamArgumentConstraint_PRESENT},
+				},
+			},
+			expectedReason: "pam_unix.so in /etc/pam.d/common-password doesn't have remember>=24\n" +
+				"pam_unix.so in /etc/pam.d/common-password has the obscure argument, expected it not to\n" +
+				"pam_unix.so in /etc/pam.d/common-password doesn't have the sha512 argument",
+		},
+		{
+			desc: "bracketed control",
+			check: &ipb.PamCheck{
+				Service:         "common-password",
+				ModuleType:      "password",
+				Module:          "pam_unix.so",
+				AllowedControls: []string{"[success=1  default=ignore]"},
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "wrong control",
+			check: &ipb.PamCheck{
+				Service:         "common-password",
+				ModuleType:      "password",
+				Module:          "pam_unix.so",
+				AllowedControls: []string{"required"},
+			},
+			expectedReason: "pam_unix.so has control [success=1 default=ignore] in /etc/pam.d/common-password, expected one of [required]",
+		},
+		{
+			desc: "module in wrong type",
+			check: &ipb.PamCheck{
+				Service:    "common-password",
+				ModuleType: "auth",
+				Module:     "pam_pwquality.so",
+			},
+			expectedReason: "Module pam_pwquality.so not found in the auth stack of the common-password PAM service",
+		},
+		{
+			desc: "service doesn't exist",
+			check: &ipb.PamCheck{
+				Service:    "nonexistent",
+				ModuleType: "auth",
+				Module:     "pam_faillock.so",
+			},
+			expectedReason: "Module pam_faillock.so not found in the auth stack of the nonexistent PAM service",
+		},
+		{
+			desc: "substack with one of several entries matching",
+			check: &ipb.PamCheck{
+				Service:         "system-auth",
+				ModuleType:      "auth",
+				Module:          "pam_faillock.so",
+				AllowedControls: []string{"[default=die]"},
+				ArgumentConstraints: []*ipb.PamArgumentConstraint{
+					{Name: "authfail", Operator: ipb.PamArgumentConstraint_PRESENT},
+				},
+			},
+			expectedReason: "",
+		},
+		{
+			desc: "module ordering across substack",
+			check: &ipb.PamCheck{
+				Service:        "system-auth",
+				ModuleType:     "auth",
+				Module:         "pam_sss.so",
+				PrecedesModule: "pam_unix.so",
+			},
+			expectedReason: "/usr/lib64/security/pam_sss.so in /etc/pam.d/system-auth comes after pam_unix.so in /etc/pam.d/password-auth-local, expected it to come before",
+		},
+		{
+			desc: "value equality",
+			check: &ipb.PamCheck{
+				Service:    "system-auth",
+				ModuleType: "auth",
+				Module:     "pam_faillock.so",
+				ArgumentConstraints: []*ipb.PamArgumentConstraint{
+					{Name: "preauth", Operator: ipb.PamArgumentConstraint_PRESENT},
+					{Name: "deny", Operator: ipb.PamArgumentConstraint_EQUAL, Value: "3"},
+				},
+			},
+			expectedReason: "pam_faillock.so in /etc/pam.d/system-auth doesn't have deny=3\n" +
+				"pam_faillock.so in /etc/pam.d/password-auth-local doesn't have the preauth argument\n" +
+				"pam_faillock.so in /etc/pam.d/password-auth-local doesn't have deny=3",
+		},
+		{
+			desc: "custom non-compliance message",
+			check: &ipb.PamCheck{
+				Service:          "common-password",
+				ModuleType:       "password",
+				Module:           "pam_pwhistory.so",
+				NonComplianceMsg: "password history is not enforced",
+			},
+			expectedReason: "password history is not enforced",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewPamScanInstruction([]*ipb.PamCheck{tc.check})
+			check := createSingleCheck(t, scanInstruction, newFakeAPI(withFiles(testPamFiles)))
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonComplianceReason: tc.expectedReason,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPamCheckIncludeCycleReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewPamScanInstruction([]*ipb.PamCheck{{
+		Service:    "loop-a",
+		ModuleType: "auth",
+		Module:     "pam_unix.so",
+	}})
+	check := createSingleCheck(t, scanInstruction, newFakeAPI(withFiles(testPamFiles)))
+	if _, _, err := check.Exec(""); err == nil {
+		t.Errorf("check.Exec() didn't return an error")
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+var (
+	// Benchmark parameters are referenced as "{{<name>}}" in the scan instructions.
+	parameterReferenceRe = regexp.MustCompile(`\{\{(\w+)\}\}`)
+	parameterNameRe      = regexp.MustCompile(`^\w+$`)
+)
+
+// resolveParameters returns the values of the benchmark's parameters, taken
+// from the tailoring config if it sets them and from the defaults otherwise.
+func resolveParameters(benchmarkID string, params []*ipb.Parameter, tailoring *apb.TailoringConfig) ([]*apb.ParameterValue, error) {
+	benchmarkValues := tailoring.GetBenchmarkTailorings()[benchmarkID].GetParameterValues()
+	result := make([]*apb.ParameterValue, 0, len(params))
+	seen := make(map[string]bool)
+	for _, p := range params {
+		if !parameterNameRe.MatchString(p.GetName()) {
+			return nil, fmt.Errorf("invalid parameter name %q", p.GetName())
+		}
+		if seen[p.GetName()] {
+			return nil, fmt.Errorf("parameter %s is declared multiple times", p.GetName())
+		}
+		seen[p.GetName()] = true
+		if err := validateParameterValue(p, p.GetDefaultValue()); err != nil {
+			return nil, fmt.Errorf("invalid default value: %w", err)
+		}
+
+		value := &apb.ParameterValue{Name: p.GetName(), Value: p.GetDefaultValue()}
+		if v, ok := benchmarkValues[p.GetName()]; ok {
+			value.Value, value.Tailored = v, true
+		} else if v, ok := tailoring.GetParameterValues()[p.GetName()]; ok {
+			value.Value, value.Tailored = v, true
+		}
+		if err := validateParameterValue(p, value.GetValue()); err != nil {
+			return nil, fmt.Errorf("invalid tailored value: %w", err)
+		}
+		result = append(result, value)
+	}
+	for name := range benchmarkValues {
+		if !seen[name] {
+			return nil, fmt.Errorf("tailoring config sets undeclared parameter %s", name)
+		}
+	}
+	return result, nil
+}
+
+// validateTailoring returns an error if the tailoring config sets parameters
+// for benchmarks that aren't part of the scan. Parameters set for all
+// benchmarks which none of them declares only produce a warning, since the
+// same tailoring config can be used for scans of different benchmarks.
+// benchmarkParams maps the IDs of the scanned benchmarks to the values of
+// their parameters.
+func validateTailoring(tailoring *apb.TailoringConfig, benchmarkParams ParameterValues) error {
+	declared := make(map[string]bool)
+	for _, values := range benchmarkParams {
+		for _, v := range values {
+			declared[v.GetName()] = true
+		}
+	}
+	for name := range tailoring.GetParameterValues() {
+		if !declared[name] {
+			log.Printf("warning: tailoring config sets parameter %s which no benchmark declares", name)
+		}
+	}
+	for id := range tailoring.GetBenchmarkTailorings() {
+		if _, ok := benchmarkParams[id]; !ok {
+			return fmt.Errorf("tailoring config sets parameters for unknown benchmark %s", id)
+		}
+	}
+	return nil
+}
+
+func validateParameterValue(p *ipb.Parameter, value string) error {
+	var err error
+	switch p.GetType() {
+	case ipb.Parameter_INTEGER:
+		_, err = strconv.ParseInt(value, 10, 32)
+	case ipb.Parameter_BOOLEAN:
+		_, err = strconv.ParseBool(value)
+	case ipb.Parameter_REGEX:
+		_, err = regexp.Compile(value)
+	}
+	if err != nil {
+		return fmt.Errorf("parameter %s expects a value of type %s, got %q", p.GetName(), p.GetType(), value)
+	}
+	return nil
+}
+
+// substituteParameters replaces the parameter references in all strings of
+// the check alternative with the parameters' values and resolves the group
+// criteria comparing against parameters. In regex fields, the values of all
+// but REGEX parameters are escaped so that they're matched literally.
+func substituteParameters(alt *ipb.CheckAlternative, params []*ipb.Parameter, values []*apb.ParameterValue) error {
+	types := make(map[string]ipb.Parameter_Type)
+	for _, p := range params {
+		types[p.GetName()] = p.GetType()
+	}
+	nameToValue := make(map[string]string)
+	for _, v := range values {
+		nameToValue[v.GetName()] = v.GetValue()
+	}
+
+	var err error
+	forEachGroupCriterion(alt.ProtoReflect(), func(gc *ipb.GroupCriterion) {
+		if _, ok := gc.GetComparisonValue().(*ipb.GroupCriterion_ConstParam); !ok || err != nil {
+			return
+		}
+		m := parameterReferenceRe.FindStringSubmatch(gc.GetConstParam())
+		if m == nil || m[0] != gc.GetConstParam() || types[m[1]] != ipb.Parameter_INTEGER {
+			err = fmt.Errorf("group criterion compares against %q, expected a reference to an INTEGER parameter", gc.GetConstParam())
+			return
+		}
+		// Validated when the parameters were resolved.
+		value, _ := strconv.ParseInt(nameToValue[m[1]], 10, 32)
+		gc.ComparisonValue = &ipb.GroupCriterion_Const{Const: int32(value)}
+	})
+	if err != nil {
+		return err
+	}
+
+	transformStringFields(alt.ProtoReflect(), func(fd protoreflect.FieldDescriptor, s string) string {
+		if !strings.Contains(s, "{{") {
+			return s
+		}
+		return parameterReferenceRe.ReplaceAllStringFunc(s, func(ref string) string {
+			name := parameterReferenceRe.FindStringSubmatch(ref)[1]
+			value, ok := nameToValue[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("reference to undeclared parameter %s", name)
+			}
+			if isRegexField(fd) && types[name] != ipb.Parameter_REGEX {
+				return regexp.QuoteMeta(value)
+			}
+			return value
+		})
+	})
+	return err
+}
+
+// isRegexField returns whether the field holds regexes, e.g. filter_regex or
+// opt_out_path_regexes.
+func isRegexField(fd protoreflect.FieldDescriptor) bool {
+	name := string(fd.Name())
+	return strings.HasSuffix(name, "regex") || strings.HasSuffix(name, "regexes")
+}
+
+// forEachGroupCriterion calls f with the group criteria in the message and
+// its sub-messages.
+func forEachGroupCriterion(m protoreflect.Message, f func(*ipb.GroupCriterion)) {
+	if gc, ok := m.Interface().(*ipb.GroupCriterion); ok {
+		f(gc)
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Kind() == protoreflect.MessageKind {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					forEachGroupCriterion(mv.Message(), f)
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Kind() == protoreflect.MessageKind {
+				for i := 0; i < v.List().Len(); i++ {
+					forEachGroupCriterion(v.List().Get(i).Message(), f)
+				}
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			forEachGroupCriterion(v.Message(), f)
+		}
+		return true
+	})
+}
@@ -0,0 +1,275 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	"github.com/google/localtoast/scannerlib/facts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+var testParameters = []*ipb.Parameter{
+	{Name: "config_dir", DefaultValue: "/etc"},
+	{Name: "max_days", Type: ipb.Parameter_INTEGER, DefaultValue: "365"},
+}
+
+// maxDaysInstruction checks that /etc/login.defs sets PASS_MAX_DAYS to less
+// than the max_days parameter.
+func maxDaysInstruction() *ipb.BenchmarkScanInstruction {
+	return &ipb.BenchmarkScanInstruction{
+		Parameters: testParameters,
+		CheckAlternatives: []*ipb.CheckAlternative{{
+			FileChecks: []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("{{config_dir}}/login.defs")},
+				CheckType: &ipb.FileCheck_ContentEntry{ContentEntry: &ipb.ContentEntryCheck{
+					MatchType: ipb.ContentEntryCheck_ALL_MATCH_ANY_ORDER,
+					MatchCriteria: []*ipb.MatchCriterion{{
+						FilterRegex:   `PASS_MAX_DAYS.*`,
+						ExpectedRegex: `PASS_MAX_DAYS\s+(\d+)`,
+						GroupCriteria: []*ipb.GroupCriterion{{
+							GroupIndex:      1,
+							Type:            ipb.GroupCriterion_LESS_THAN,
+							ComparisonValue: &ipb.GroupCriterion_ConstParam{ConstParam: "{{max_days}}"},
+						}},
+					}},
+				}},
+			}},
+		}},
+	}
+}
+
+func createChecksWithTailoring(t *testing.T, instruction *ipb.BenchmarkScanInstruction, tailoring *apb.TailoringConfig) (configchecks.ParameterValues, []configchecks.BenchmarkCheck, error) {
+	t.Helper()
+	scanConfig := &apb.ScanConfig{
+		BenchmarkConfigs: []*apb.BenchmarkConfig{testconfigcreator.NewBenchmarkConfig(t, "id", instruction)},
+		TailoringConfig:  tailoring,
+	}
+	api := newFakeAPI(withFiles(map[string]string{
+		"/etc/login.defs":    "PASS_MAX_DAYS 400\n",
+		"/custom/login.defs": "PASS_MAX_DAYS 90\n",
+	}))
+	checks, params, err := configchecks.CreateChecksFromConfigWithFacts(context.Background(), scanConfig, api, facts.NewCollector(api))
+	return params, checks, err
+}
+
+func TestParametersAreSubstituted(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		tailoring     *apb.TailoringConfig
+		wantCompliant bool
+		wantValues    []*apb.ParameterValue
+	}{
+		{
+			desc: "defaults",
+			wantValues: []*apb.ParameterValue{
+				{Name: "config_dir", Value: "/etc"},
+				{Name: "max_days", Value: "365"},
+			},
+		},
+		{
+			desc:          "tailored for all benchmarks",
+			tailoring:     &apb.TailoringConfig{ParameterValues: map[string]string{"max_days": "500"}},
+			wantCompliant: true,
+			wantValues: []*apb.ParameterValue{
+				{Name: "config_dir", Value: "/etc"},
+				{Name: "max_days", Value: "500", Tailored: true},
+			},
+		},
+		{
+			desc:      "tailored parameter not declared by any benchmark",
+			tailoring: &apb.TailoringConfig{ParameterValues: map[string]string{"min_days": "7"}},
+			wantValues: []*apb.ParameterValue{
+				{Name: "config_dir", Value: "/etc"},
+				{Name: "max_days", Value: "365"},
+			},
+		},
+		{
+			desc: "tailored for the benchmark",
+			tailoring: &apb.TailoringConfig{
+				ParameterValues: map[string]string{"config_dir": "/unused"},
+				BenchmarkTailorings: map[string]*apb.BenchmarkTailoring{
+					"id": {ParameterValues: map[string]string{"config_dir": "/custom"}},
+				},
+			},
+			wantCompliant: true,
+			wantValues: []*apb.ParameterValue{
+				{Name: "config_dir", Value: "/custom", Tailored: true},
+				{Name: "max_days", Value: "365"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			params, checks, err := createChecksWithTailoring(t, maxDaysInstruction(), tc.tailoring)
+			if err != nil {
+				t.Fatalf("configchecks.CreateChecksFromConfig() returned an error: %v", err)
+			}
+			if len(checks) != 1 {
+				t.Fatalf("Created %d checks, expected only 1", len(checks))
+			}
+			resultMap, _, err := checks[0].Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			if gotCompliant := result.GetComplianceOccurrence().GetNonCompliantFiles() == nil; gotCompliant != tc.wantCompliant {
+				t.Errorf("check.Exec() returned %v, expected compliant: %t", result, tc.wantCompliant)
+			}
+
+			if err := configchecks.AddParameterValuesToResults([]*apb.ComplianceResult{result}, params); err != nil {
+				t.Fatalf("configchecks.AddParameterValuesToResults() returned an error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantValues, result.GetParameterValues(), protocmp.Transform()); diff != "" {
+				t.Errorf("configchecks.AddParameterValuesToResults() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParametersInRegexesAreEscaped(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		paramType     ipb.Parameter_Type
+		wantCompliant bool
+	}{
+		{
+			desc:          "STRING value is matched literally",
+			paramType:     ipb.Parameter_STRING,
+			wantCompliant: false,
+		},
+		{
+			desc:          "REGEX value is a pattern",
+			paramType:     ipb.Parameter_REGEX,
+			wantCompliant: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			instruction := &ipb.BenchmarkScanInstruction{
+				Parameters: []*ipb.Parameter{{Name: "max_days", Type: tc.paramType, DefaultValue: "4.0"}},
+				CheckAlternatives: []*ipb.CheckAlternative{{
+					FileChecks: []*ipb.FileCheck{{
+						FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/login.defs")},
+						CheckType: &ipb.FileCheck_ContentEntry{ContentEntry: &ipb.ContentEntryCheck{
+							MatchType: ipb.ContentEntryCheck_ALL_MATCH_ANY_ORDER,
+							MatchCriteria: []*ipb.MatchCriterion{{
+								FilterRegex:   `PASS_MAX_DAYS.*`,
+								ExpectedRegex: `PASS_MAX_DAYS {{max_days}}`,
+							}},
+						}},
+					}},
+				}},
+			}
+			_, checks, err := createChecksWithTailoring(t, instruction, nil)
+			if err != nil {
+				t.Fatalf("configchecks.CreateChecksFromConfig() returned an error: %v", err)
+			}
+			if len(checks) != 1 {
+				t.Fatalf("Created %d checks, expected only 1", len(checks))
+			}
+			resultMap, _, err := checks[0].Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			if gotCompliant := result.GetComplianceOccurrence().GetNonCompliantFiles() == nil; gotCompliant != tc.wantCompliant {
+				t.Errorf("check.Exec() returned %v, expected compliant: %t", result, tc.wantCompliant)
+			}
+		})
+	}
+}
+
+func TestInvalidParametersReturnError(t *testing.T) {
+	withParameters := func(params ...*ipb.Parameter) *ipb.BenchmarkScanInstruction {
+		instruction := maxDaysInstruction()
+		instruction.Parameters = params
+		return instruction
+	}
+	testCases := []struct {
+		desc        string
+		instruction *ipb.BenchmarkScanInstruction
+		tailoring   *apb.TailoringConfig
+	}{
+		{
+			desc:        "undeclared parameter",
+			instruction: withParameters(testParameters[1]),
+		},
+		{
+			desc: "group criterion references STRING parameter",
+			instruction: withParameters(testParameters[0],
+				&ipb.Parameter{Name: "max_days", DefaultValue: "365"}),
+		},
+		{
+			desc: "invalid default value",
+			instruction: withParameters(testParameters[0],
+				&ipb.Parameter{Name: "max_days", Type: ipb.Parameter_INTEGER, DefaultValue: "a year"}),
+		},
+		{
+			desc: "invalid REGEX default value",
+			instruction: withParameters(append(testParameters,
+				&ipb.Parameter{Name: "pattern", Type: ipb.Parameter_REGEX, DefaultValue: "("})...),
+		},
+		{
+			desc:        "invalid parameter name",
+			instruction: withParameters(append(testParameters, &ipb.Parameter{Name: "config-dir"})...),
+		},
+		{
+			desc:        "duplicate parameter",
+			instruction: withParameters(append(testParameters, testParameters[0])...),
+		},
+		{
+			desc:        "invalid tailored value",
+			instruction: maxDaysInstruction(),
+			tailoring:   &apb.TailoringConfig{ParameterValues: map[string]string{"max_days": "true"}},
+		},
+		{
+			desc:        "tailored parameter not declared by the benchmark",
+			instruction: maxDaysInstruction(),
+			tailoring: &apb.TailoringConfig{BenchmarkTailorings: map[string]*apb.BenchmarkTailoring{
+				"id": {ParameterValues: map[string]string{"min_days": "7"}},
+			}},
+		},
+		{
+			desc:        "tailoring for unknown benchmark",
+			instruction: maxDaysInstruction(),
+			tailoring: &apb.TailoringConfig{BenchmarkTailorings: map[string]*apb.BenchmarkTailoring{
+				"other-id": {ParameterValues: map[string]string{"max_days": "7"}},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, _, err := createChecksWithTailoring(t, tc.instruction, tc.tailoring); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig() didn't return an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/google/localtoast/scanapi"
+)
+
+const selinuxXattr = "security.selinux"
+
+// The names of the parts of an SELinux label, in order.
+var selinuxLabelParts = []string{"user", "role", "type", "level"}
+
+// selinuxLabelFileChecker checks the SELinux labels of files.
+type selinuxLabelFileChecker struct {
+	fc *fileCheck
+	// The regexes the parts of the label should match, nil if a part isn't checked.
+	regexes []*regexp.Regexp
+}
+
+func newSelinuxLabelFileChecker(fc *fileCheck) (*selinuxLabelFileChecker, error) {
+	sc := fc.checkInstruction.GetSelinuxLabel()
+	exprs := []string{sc.GetUserRegex(), sc.GetRoleRegex(), sc.GetTypeRegex(), sc.GetLevelRegex()}
+	regexes := make([]*regexp.Regexp, len(exprs))
+	hasRegex := false
+	for i, expr := range exprs {
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return nil, fmt.Errorf("SELinux label check %v has invalid %s regex: %w", fc.checkInstruction, selinuxLabelParts[i], err)
+		}
+		regexes[i] = re
+		hasRegex = true
+	}
+	if !hasRegex {
+		return nil, fmt.Errorf("SELinux label check %v has no regexes", fc.checkInstruction)
+	}
+	return &selinuxLabelFileChecker{fc: fc, regexes: regexes}, nil
+}
+
+func (c *selinuxLabelFileChecker) exec(ctx context.Context, path string, fs scanapi.Filesystem) error {
+	value, err := fs.FileXattr(ctx, path, selinuxXattr)
+	if err != nil {
+		// Return a non-compliance instead of an error if the file doesn't exist.
+		if errors.Is(err, os.ErrNotExist) {
+			c.fc.addNonCompliantFile(path, "File doesn't exist")
+			return nil
+		}
+		return err
+	}
+	if value == nil {
+		c.fc.addNonCompliantFile(path, "File has no SELinux label")
+		return nil
+	}
+	label := strings.TrimRight(string(value), "\x00")
+	// The level may contain colons itself, e.g. "s0-s0:c0.c1023".
+	parts := strings.SplitN(label, ":", len(selinuxLabelParts))
+	for i, re := range c.regexes {
+		if re == nil {
+			continue
+		}
+		part := ""
+		if i < len(parts) {
+			part = parts[i]
+		}
+		if !re.MatchString(part) {
+			c.fc.addNonCompliantFile(path, fmt.Sprintf("SELinux label is %s, expected the %s to match %q",
+				label, selinuxLabelParts[i], strings.TrimSuffix(strings.TrimPrefix(re.String(), "^"), "$")))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+func TestSelinuxLabelCheckInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		check *ipb.SelinuxLabelCheck
+	}{
+		{
+			desc:  "no regexes",
+			check: &ipb.SelinuxLabelCheck{},
+		},
+		{
+			desc:  "invalid regex",
+			check: &ipb.SelinuxLabelCheck{TypeRegex: "shadow_t("},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			scanInstruction := testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(testFilePath)},
+				CheckType:    &ipb.FileCheck_SelinuxLabel{SelinuxLabel: tc.check},
+			}})
+			config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+			if _, err := configchecks.CreateChecksFromConfig(
+				context.Background(),
+				&apb.ScanConfig{
+					BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+				},
+				newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+			}
+		})
+	}
+}
+
+func TestSelinuxLabelCheckComplianceResults(t *testing.T) {
+	xattrs := map[string]map[string][]byte{
+		"/etc/shadow":   {"security.selinux": []byte("system_u:object_r:shadow_t:s0\x00")},
+		"/etc/passwd":   {"security.selinux": []byte("system_u:object_r:passwd_file_t:s0")},
+		"/usr/bin/sudo": {"security.selinux": []byte("system_u:object_r:sudo_exec_t:s0-s0:c0.c1023\x00")},
+	}
+	testCases := []struct {
+		desc                      string
+		filePath                  string
+		check                     *ipb.SelinuxLabelCheck
+		expectedNonCompliantFiles []*cpb.NonCompliantFile
+	}{
+		{
+			desc:                      "matching type",
+			filePath:                  "/etc/shadow",
+			check:                     &ipb.SelinuxLabelCheck{TypeRegex: "shadow_t"},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "wrong type",
+			filePath: "/etc/passwd",
+			check:    &ipb.SelinuxLabelCheck{TypeRegex: "shadow_t", RoleRegex: "object_r"},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/passwd",
+				Reason: "SELinux label is system_u:object_r:passwd_file_t:s0, expected the type to match \"shadow_t\"",
+			}},
+		},
+		{
+			desc:     "level with colons",
+			filePath: "/usr/bin/sudo",
+			check: &ipb.SelinuxLabelCheck{
+				UserRegex:  "system_u",
+				TypeRegex:  ".*_exec_t",
+				LevelRegex: "s0-s0:c0\\.c1023",
+			},
+			expectedNonCompliantFiles: nil,
+		},
+		{
+			desc:     "unlabeled file",
+			filePath: "/etc/unlabeled",
+			check:    &ipb.SelinuxLabelCheck{TypeRegex: ".*"},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   "/etc/unlabeled",
+				Reason: "File has no SELinux label",
+			}},
+		},
+		{
+			desc:     "file doesn't exist",
+			filePath: nonExistentFilePath,
+			check:    &ipb.SelinuxLabelCheck{TypeRegex: ".*"},
+			expectedNonCompliantFiles: []*cpb.NonCompliantFile{{
+				Path:   nonExistentFilePath,
+				Reason: "File doesn't exist",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			check := createFileCheckBatch(t, "id", []*ipb.FileCheck{{
+				FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(tc.filePath)},
+				CheckType:    &ipb.FileCheck_SelinuxLabel{SelinuxLabel: tc.check},
+			}}, newFakeAPI(withFileXattrs(xattrs)))
+
+			resultMap, _, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			result, gotSingleton := singleComplianceResult(resultMap)
+			if !gotSingleton {
+				t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+			}
+			want := &apb.ComplianceResult{
+				Id: "id",
+				ComplianceOccurrence: &cpb.ComplianceOccurrence{
+					NonCompliantFiles: tc.expectedNonCompliantFiles,
+				},
+			}
+			if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+				t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
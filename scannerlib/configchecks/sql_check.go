@@ -22,6 +22,8 @@ import (
 
 	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
 	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/facts"
+	"github.com/google/localtoast/scannerlib/repeatconfig"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
 )
@@ -34,17 +36,43 @@ type SQLCheck struct {
 	alternativeID    int
 	checkInstruction *ipb.SQLCheck
 	querier          scanapi.SQLQuerier
+	vars             variables
+	exports          []*variableExport
+	// Set if the repeat config of the check couldn't be created.
+	err error
+	// Set if the repeat config of the check didn't produce any repetitions,
+	// e.g. because its query returned no values. There's nothing to check
+	// then, so the check is compliant.
+	noRepetitions bool
 }
 
-// Exec executes the SQL checks and returns the compliance status.
+// Exec executes the SQL checks and returns the compliance status. The query
+// output is returned as the input of the next check in the pipeline.
 func (c *SQLCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	if c.err != nil {
+		r := &apb.ComplianceResult{
+			Id: c.benchmarkID,
+			ComplianceOccurrence: &cpb.ComplianceOccurrence{
+				NonComplianceReason: c.err.Error(),
+			},
+		}
+		return ComplianceMap{c.alternativeID: r}, "", nil
+	}
+	if c.noRepetitions {
+		r := &apb.ComplianceResult{
+			Id:                   c.benchmarkID,
+			ComplianceOccurrence: &cpb.ComplianceOccurrence{},
+		}
+		return ComplianceMap{c.alternativeID: r}, "", nil
+	}
 	query := c.checkInstruction.GetQuery()
 	var resVal string = ""
 
 	var reason string
 	if c.checkInstruction.TargetDatabase == ipb.SQLCheck_DB_MYSQL || c.checkInstruction.TargetDatabase == ipb.SQLCheck_DB_CASSANDRA {
 		// Check number of returned rows for MySQL and Cassandra
-		resVal, err := c.querier.SQLQuery(c.ctx, query)
+		var err error
+		resVal, err = c.querier.SQLQuery(c.ctx, query)
 		if err != nil {
 			return nil, "", err
 		}
@@ -76,6 +104,7 @@ func (c *SQLCheck) Exec(prvRes string) (ComplianceMap, string, error) {
 		return nil, "", errors.New("unsupported database for SQLCheck")
 	}
 
+	c.vars.export(c.exports, resVal)
 	if reason != "" && c.checkInstruction.GetNonComplianceMsg() != "" {
 		reason = c.checkInstruction.GetNonComplianceMsg()
 	}
@@ -99,8 +128,10 @@ func (c *SQLCheck) String() string {
 }
 
 // createSQLChecksFromConfig parses the benchmark config and creates the executable
-// SQL checks that it defines.
-func createSQLChecksFromConfig(ctx context.Context, benchmarks []*benchmark, timeout *timeoutOptions, sq scanapi.SQLQuerier) ([]*SQLCheck, error) {
+// SQL checks that it defines. Checks with a repeat config are expanded into one
+// check per repetition.
+func createSQLChecksFromConfig(ctx context.Context, benchmarks []*benchmark, timeout *timeoutOptions, api scanapi.ScanAPI, collector *facts.Collector, vars variables) ([]*SQLCheck, error) {
+	var sq scanapi.SQLQuerier = api
 	// TODO(b/235991635): Use timeout.
 	checks := []*SQLCheck{}
 	for _, b := range benchmarks {
@@ -116,13 +147,46 @@ func createSQLChecksFromConfig(ctx context.Context, benchmarks []*benchmark, tim
 				if sqlCheckInstruction.GetTargetDatabase() == ipb.SQLCheck_DB_ELASTICSEARCH && sqlCheckInstruction.GetFilterRegex() == "" {
 					return nil, errors.New("no regex provided for ElasticSearch database SQLCheck")
 				}
-				checks = append(checks, &SQLCheck{
-					ctx:              ctx,
-					benchmarkID:      b.id,
-					alternativeID:    alt.id,
-					checkInstruction: sqlCheckInstruction,
-					querier:          sq,
-				})
+				repeatOptions := []*ipb.RepeatConfig{}
+				if sqlCheckInstruction.GetRepeatConfig() != nil {
+					repeatOptions = append(repeatOptions, sqlCheckInstruction.GetRepeatConfig())
+				}
+				if err := repeatconfig.ValidateSQLRepeatConfigs(repeatOptions, sqlCheckInstruction.GetTargetDatabase()); err != nil {
+					return nil, err
+				}
+				repeatConfigs, err := repeatconfig.CreateCombinedRepeatConfigsWithFacts(ctx, repeatOptions, api, collector)
+				if err != nil {
+					return nil, err
+				}
+				exports, err := compileExports(sqlCheckInstruction.GetExports())
+				if err != nil {
+					return nil, err
+				}
+				if len(repeatConfigs) == 0 {
+					checks = append(checks, &SQLCheck{
+						ctx:              ctx,
+						benchmarkID:      b.id,
+						alternativeID:    alt.id,
+						checkInstruction: sqlCheckInstruction,
+						querier:          sq,
+						vars:             vars,
+						exports:          exports,
+						noRepetitions:    true,
+					})
+					continue
+				}
+				for _, repeatConfig := range repeatConfigs {
+					checks = append(checks, &SQLCheck{
+						ctx:              ctx,
+						benchmarkID:      b.id,
+						alternativeID:    alt.id,
+						checkInstruction: repeatconfig.ApplyRepeatConfigToSQLCheck(sqlCheckInstruction, repeatConfig),
+						querier:          sq,
+						vars:             vars,
+						exports:          exports,
+						err:              repeatConfig.Err,
+					})
+				}
 			}
 		}
 	}
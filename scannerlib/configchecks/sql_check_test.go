@@ -294,3 +294,164 @@ func TestMySQLCheckPropagatesError(t *testing.T) {
 		t.Errorf("check.Exec returned the wrong error: want %q, got %v", queryErrorMsg, err)
 	}
 }
+
+func TestSQLCheckReturnsQueryOutputForPipeline(t *testing.T) {
+	for _, db := range []ipb.SQLCheck_SQLDatabase{ipb.SQLCheck_DB_MYSQL, ipb.SQLCheck_DB_CASSANDRA} {
+		t.Run(db.String(), func(t *testing.T) {
+			check := createMySQLCheck(t, "id", []*ipb.SQLCheck{{
+				TargetDatabase: db,
+				Query:          fakeQueryOneRow,
+				ExpectResults:  true,
+			}}, newFakeAPI(withSupportedDatabase(db)))
+			_, prvRes, err := check.Exec("")
+			if err != nil {
+				t.Fatalf("check.Exec() returned an error: %v", err)
+			}
+			// The output is passed to the %%pipeline%% token of the next check.
+			if prvRes != "testValue" {
+				t.Errorf("check.Exec() returned %q for the pipeline, expected %q", prvRes, "testValue")
+			}
+		})
+	}
+}
+
+func TestMySQLCheckWithRepeatConfig(t *testing.T) {
+	check := &ipb.SQLCheck{
+		TargetDatabase:   ipb.SQLCheck_DB_MYSQL,
+		Query:            "SELECT user FROM $db.users WHERE password = ''",
+		ExpectResults:    false,
+		NonComplianceMsg: "Users without password in $db",
+		RepeatConfig: &ipb.RepeatConfig{
+			Type:     ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+			Wildcard: "$db",
+			Query:    "SHOW DATABASES",
+		},
+	}
+	scanInstruction := testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{check})
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+	checks, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI(withQueryRows(map[string][]string{
+			"SHOW DATABASES": {"mysql", "wordpress"},
+			"SELECT user FROM mysql.users WHERE password = ''":     {},
+			"SELECT user FROM wordpress.users WHERE password = ''": {"admin"},
+		})))
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig([%v]) returned an error: %v", config, err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("Created %d checks, expected 2", len(checks))
+	}
+
+	want := []string{"", "Users without password in wordpress"}
+	got := []string{}
+	for _, c := range checks {
+		resultMap, _, err := c.Exec("")
+		if err != nil {
+			t.Fatalf("%v.Exec() returned an error: %v", c, err)
+		}
+		result, gotSingleton := singleComplianceResult(resultMap)
+		if !gotSingleton {
+			t.Fatalf("%v.Exec() expected to return 1 result, got %d", c, len(resultMap))
+		}
+		got = append(got, result.GetComplianceOccurrence().GetNonComplianceReason())
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Exec() of the repeated checks returned unexpected non-compliance reasons (-want +got):\n%s", diff)
+	}
+}
+
+func TestMySQLCheckWithEmptyRepeatQueryResultIsCompliant(t *testing.T) {
+	check := createMySQLCheck(t, "id", []*ipb.SQLCheck{{
+		TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+		Query:          "SELECT user FROM $db.users WHERE password = ''",
+		RepeatConfig: &ipb.RepeatConfig{
+			Type:     ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+			Wildcard: "$db",
+			Query:    "SHOW DATABASES",
+		},
+	}}, newFakeAPI(withQueryRows(map[string][]string{"SHOW DATABASES": {}})))
+	resultMap, _, err := check.Exec("")
+	if err != nil {
+		t.Fatalf("check.Exec() returned an error: %v", err)
+	}
+	result, gotSingleton := singleComplianceResult(resultMap)
+	if !gotSingleton {
+		t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+	}
+	want := &apb.ComplianceResult{
+		Id:                   "id",
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{},
+	}
+	if diff := cmp.Diff(want, result, protocmp.Transform()); diff != "" {
+		t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestMySQLCheckWithInvalidRepeatConfigReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+		TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+		Query:          "SELECT 1 FROM $db.users",
+		RepeatConfig: &ipb.RepeatConfig{
+			Type:     ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+			Wildcard: "$db",
+		},
+	}})
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+	if _, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI()); err == nil {
+		t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+	}
+}
+
+func TestElasticSearchCheckWithQueryResultRepeatConfigReturnsError(t *testing.T) {
+	scanInstruction := testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+		TargetDatabase: ipb.SQLCheck_DB_ELASTICSEARCH,
+		Query:          "/$index/_settings",
+		FilterRegex:    ".*",
+		RepeatConfig: &ipb.RepeatConfig{
+			Type:     ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+			Wildcard: "$index",
+			Query:    "/_cat/indices",
+		},
+	}})
+	config := testconfigcreator.NewBenchmarkConfig(t, "id", scanInstruction)
+	if _, err := configchecks.CreateChecksFromConfig(
+		context.Background(),
+		&apb.ScanConfig{
+			BenchmarkConfigs: []*apb.BenchmarkConfig{config},
+		},
+		newFakeAPI(withSupportedDatabase(ipb.SQLCheck_DB_ELASTICSEARCH))); err == nil {
+		t.Errorf("configchecks.CreateChecksFromConfig([%v]) didn't return an error", config)
+	}
+}
+
+func TestMySQLCheckWithFailingRepeatQueryIsNonCompliant(t *testing.T) {
+	check := createMySQLCheck(t, "id", []*ipb.SQLCheck{{
+		TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+		Query:          "SELECT 1 FROM $db.users",
+		RepeatConfig: &ipb.RepeatConfig{
+			Type:     ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+			Wildcard: "$db",
+			Query:    fakeQueryError,
+		},
+	}}, newFakeAPI())
+	resultMap, _, err := check.Exec("")
+	if err != nil {
+		t.Fatalf("check.Exec() returned an error: %v", err)
+	}
+	result, gotSingleton := singleComplianceResult(resultMap)
+	if !gotSingleton {
+		t.Fatalf("check.Exec() expected to return 1 result, got %d", len(resultMap))
+	}
+	if reason := result.GetComplianceOccurrence().GetNonComplianceReason(); !strings.Contains(reason, queryErrorMsg) {
+		t.Errorf("check.Exec() returned non-compliance reason %q, expected it to contain %q", reason, queryErrorMsg)
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+)
+
+// The variable name reserved for the legacy %%pipeline%% token.
+const pipelineVariable = "pipeline"
+
+var variableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// variables holds the values of the variables exported by the checks during a
+// scan, keyed by the variable names.
+type variables map[string]string
+
+// variableToken returns the token the checks reference the variable with.
+func variableToken(name string) string {
+	return "%%" + name + "%%"
+}
+
+// variableExport is a variable export of a check with its compiled regex.
+type variableExport struct {
+	name string
+	// nil if the whole output is exported.
+	re *regexp.Regexp
+}
+
+// compileExports compiles the regexes of the given variable exports.
+func compileExports(exports []*ipb.VariableExport) ([]*variableExport, error) {
+	result := make([]*variableExport, 0, len(exports))
+	for _, e := range exports {
+		ve := &variableExport{name: e.GetName()}
+		if e.GetRegex() != "" {
+			var err error
+			if ve.re, err = regexp.Compile(e.GetRegex()); err != nil {
+				return nil, fmt.Errorf("variable %q is exported with invalid regex: %w", e.GetName(), err)
+			}
+		}
+		result = append(result, ve)
+	}
+	return result, nil
+}
+
+// export sets the variables exported from the given check output. Nothing is
+// exported from an empty output.
+func (v variables) export(exports []*variableExport, output string) {
+	if output == "" {
+		return
+	}
+	for _, e := range exports {
+		if value, ok := e.extract(output); ok {
+			v[e.name] = value
+		}
+	}
+}
+
+// exportFromFiles sets the variables exported from the first matching line of
+// the given files. Variables that are already set aren't overwritten.
+func (v variables) exportFromFiles(ctx context.Context, exports []*variableExport, paths []string, fs scanapi.Filesystem) error {
+	for _, e := range exports {
+		if _, ok := v[e.name]; ok {
+			continue
+		}
+		for _, p := range paths {
+			value, ok, err := e.extractFromFile(ctx, p, fs)
+			if err != nil {
+				return err
+			}
+			if ok {
+				v[e.name] = value
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (e *variableExport) extractFromFile(ctx context.Context, filePath string, fs scanapi.Filesystem) (string, bool, error) {
+	f, err := openFileForReading(ctx, filePath, fs)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := e.extract(scanner.Text()); ok {
+			return value, true, nil
+		}
+	}
+	return "", false, scanner.Err()
+}
+
+// extract returns the value of the exported variable from the given text and
+// whether the export's regex matched.
+func (e *variableExport) extract(text string) (string, bool) {
+	if e.re == nil {
+		return text, true
+	}
+	groups := e.re.FindStringSubmatch(text)
+	if groups == nil {
+		return "", false
+	}
+	if len(groups) > 1 {
+		return groups[1], true
+	}
+	return groups[0], true
+}
+
+// collectVariableExports validates the variable exports of the benchmarks and
+// returns the names of the exported variables.
+func collectVariableExports(benchmarks []*benchmark) (map[string]bool, error) {
+	result := make(map[string]bool)
+	add := func(b *benchmark, exports []*ipb.VariableExport, regexRequired, repeated bool) error {
+		if len(exports) > 0 && repeated {
+			return fmt.Errorf("check in benchmark %s has both exports and a repeat config", b.id)
+		}
+		for _, e := range exports {
+			if !variableNameRe.MatchString(e.GetName()) || e.GetName() == pipelineVariable {
+				return fmt.Errorf("benchmark %s exports a variable with invalid name %q", b.id, e.GetName())
+			}
+			if result[e.GetName()] {
+				return fmt.Errorf("variable %q is exported more than once", e.GetName())
+			}
+			if regexRequired && e.GetRegex() == "" {
+				return fmt.Errorf("file check in benchmark %s exports variable %q without a regex", b.id, e.GetName())
+			}
+			if _, err := regexp.Compile(e.GetRegex()); err != nil {
+				return fmt.Errorf("benchmark %s exports variable %q with invalid regex: %w", b.id, e.GetName(), err)
+			}
+			result[e.GetName()] = true
+		}
+		return nil
+	}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			for _, c := range alt.proto.GetSqlChecks() {
+				if err := add(b, c.GetExports(), false, c.GetRepeatConfig() != nil); err != nil {
+					return nil, err
+				}
+			}
+			for _, c := range alt.proto.GetFileChecks() {
+				repeated := c.GetRepeatConfig() != nil || len(c.GetRepeatConfigs()) > 0
+				if err := add(b, c.GetExports(), true, repeated); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// exportedVariableNames returns the names of the variables exported by the
+// checks of the given check alternative.
+func exportedVariableNames(alt *ipb.CheckAlternative) []string {
+	result := []string{}
+	for _, c := range alt.GetSqlChecks() {
+		for _, e := range c.GetExports() {
+			result = append(result, e.GetName())
+		}
+	}
+	for _, c := range alt.GetFileChecks() {
+		for _, e := range c.GetExports() {
+			result = append(result, e.GetName())
+		}
+	}
+	return result
+}
+
+// referencedVariables returns the sorted names of the given variables that are
+// referenced anywhere in the message.
+func referencedVariables(m proto.Message, names map[string]bool) []string {
+	found := make(map[string]bool)
+	transformStrings(m.ProtoReflect(), func(s string) string {
+		if !strings.Contains(s, "%%") {
+			return s
+		}
+		for name := range names {
+			if strings.Contains(s, variableToken(name)) {
+				found[name] = true
+			}
+		}
+		return s
+	})
+	result := make([]string, 0, len(found))
+	for name := range found {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// substituteVariables replaces the references to the given variables in all
+// string fields of the message.
+func substituteVariables(m proto.Message, vars variables, names []string) {
+	transformStrings(m.ProtoReflect(), func(s string) string {
+		for _, name := range names {
+			s = strings.ReplaceAll(s, variableToken(name), vars[name])
+		}
+		return s
+	})
+}
+
+// transformStrings replaces the string fields of the message and its
+// sub-messages with the values returned by f.
+func transformStrings(m protoreflect.Message, f func(string) string) {
+	transformStringFields(m, func(_ protoreflect.FieldDescriptor, s string) string { return f(s) })
+}
+
+// transformStringFields is like transformStrings but also passes the
+// descriptor of the field containing the string to f.
+func transformStringFields(m protoreflect.Message, f func(protoreflect.FieldDescriptor, string) string) {
+	type field struct {
+		fd protoreflect.FieldDescriptor
+		v  protoreflect.Value
+	}
+	fields := []field{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		fields = append(fields, field{fd, v})
+		return true
+	})
+	for _, fl := range fields {
+		switch {
+		case fl.fd.IsMap():
+			mp := fl.v.Map()
+			keys := []protoreflect.MapKey{}
+			mp.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				keys = append(keys, k)
+				return true
+			})
+			for _, k := range keys {
+				switch fl.fd.MapValue().Kind() {
+				case protoreflect.StringKind:
+					mp.Set(k, protoreflect.ValueOfString(f(fl.fd, mp.Get(k).String())))
+				case protoreflect.MessageKind:
+					transformStringFields(mp.Get(k).Message(), f)
+				}
+			}
+		case fl.fd.IsList():
+			l := fl.v.List()
+			for i := 0; i < l.Len(); i++ {
+				switch fl.fd.Kind() {
+				case protoreflect.StringKind:
+					l.Set(i, protoreflect.ValueOfString(f(fl.fd, l.Get(i).String())))
+				case protoreflect.MessageKind:
+					transformStringFields(l.Get(i).Message(), f)
+				}
+			}
+		case fl.fd.Kind() == protoreflect.StringKind:
+			m.Set(fl.fd, protoreflect.ValueOfString(f(fl.fd, fl.v.String())))
+		case fl.fd.Kind() == protoreflect.MessageKind:
+			transformStringFields(fl.v.Message(), f)
+		}
+	}
+}
+
+// variableCheck is an implementation of configchecks.BenchmarkCheck that
+// wraps a single check instruction referencing exported variables. The
+// actual checks are created once the variables are known.
+type variableCheck struct {
+	benchmarkID   string
+	alternativeID int
+	// A check alternative containing only the wrapped instruction.
+	alt         *ipb.CheckAlternative
+	referenced  []string
+	exported    []string
+	vars        variables
+	createCheck func(benchmarks []*benchmark) ([]BenchmarkCheck, error)
+}
+
+// Exec substitutes the referenced variables into the wrapped instruction and
+// executes the checks it defines.
+func (c *variableCheck) Exec(prvRes string) (ComplianceMap, string, error) {
+	for _, name := range c.referenced {
+		if _, ok := c.vars[name]; !ok {
+			return nil, "", fmt.Errorf("variable %q wasn't exported", name)
+		}
+	}
+	alt := proto.Clone(c.alt).(*ipb.CheckAlternative)
+	substituteVariables(alt, c.vars, c.referenced)
+	checks, err := c.createCheck([]*benchmark{{
+		id:   c.benchmarkID,
+		alts: []*checkAlternative{{id: c.alternativeID, proto: alt}},
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	result := make(ComplianceMap)
+	res := ""
+	for _, check := range checks {
+		checkResults, checkRes, err := check.Exec(prvRes)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(checkRes) > 0 {
+			res = checkRes
+		}
+		for altID, compliance := range checkResults {
+			if prev, ok := result[altID]; ok {
+				mergeComplianceResults(prev, compliance)
+			} else {
+				result[altID] = compliance
+			}
+		}
+	}
+	return result, res, nil
+}
+
+// mergeComplianceResults appends the findings of a compliance result to
+// another one of the same check alternative.
+func mergeComplianceResults(current, new *apb.ComplianceResult) {
+	occ := current.GetComplianceOccurrence()
+	newOcc := new.GetComplianceOccurrence()
+	occ.NonCompliantFiles = append(occ.NonCompliantFiles, newOcc.GetNonCompliantFiles()...)
+	if newOcc.GetNonComplianceReason() == "" {
+		return
+	}
+	if occ.NonComplianceReason != "" {
+		occ.NonComplianceReason += "\n"
+	}
+	occ.NonComplianceReason += newOcc.GetNonComplianceReason()
+}
+
+// BenchmarkIDs returns the IDs of the benchmarks associated with this check.
+func (c *variableCheck) BenchmarkIDs() []string {
+	return []string{c.benchmarkID}
+}
+
+func (c *variableCheck) String() string {
+	return fmt.Sprintf("[check referencing variables %s]", strings.Join(c.referenced, ", "))
+}
+
+// splitVariableChecks removes the check instructions that reference any of the
+// exported variables from the benchmarks and returns them wrapped in
+// variableChecks.
+func splitVariableChecks(benchmarks []*benchmark, exported map[string]bool, vars variables, createCheck func([]*benchmark) ([]BenchmarkCheck, error)) []*variableCheck {
+	result := []*variableCheck{}
+	if len(exported) == 0 {
+		return result
+	}
+	for _, b := range benchmarks {
+		for _, alt := range b.alts {
+			m := alt.proto.ProtoReflect()
+			fields := m.Descriptor().Fields()
+			for i := 0; i < fields.Len(); i++ {
+				fd := fields.Get(i)
+				if !fd.IsList() || fd.Kind() != protoreflect.MessageKind {
+					continue
+				}
+				l := m.Get(fd).List()
+				kept := []protoreflect.Value{}
+				for j := 0; j < l.Len(); j++ {
+					instruction := l.Get(j)
+					referenced := referencedVariables(instruction.Message().Interface(), exported)
+					if len(referenced) == 0 {
+						kept = append(kept, instruction)
+						continue
+					}
+					wrapped := &ipb.CheckAlternative{}
+					wrapped.ProtoReflect().Mutable(fd).List().Append(instruction)
+					result = append(result, &variableCheck{
+						benchmarkID:   b.id,
+						alternativeID: alt.id,
+						alt:           wrapped,
+						referenced:    referenced,
+						exported:      exportedVariableNames(wrapped),
+						vars:          vars,
+						createCheck:   createCheck,
+					})
+				}
+				if len(kept) == l.Len() {
+					continue
+				}
+				l.Truncate(0)
+				for _, v := range kept {
+					l.Append(v)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// checkVariables returns the names of the variables exported and referenced
+// by the given check.
+func checkVariables(check BenchmarkCheck) (exported []string, referenced []string) {
+	switch c := check.(type) {
+	case *variableCheck:
+		return c.exported, c.referenced
+	case *SQLCheck:
+		for _, e := range c.checkInstruction.GetExports() {
+			exported = append(exported, e.GetName())
+		}
+	case *FileCheckBatch:
+		for _, fc := range c.fileChecks {
+			for _, e := range fc.checkInstruction.GetExports() {
+				exported = append(exported, e.GetName())
+			}
+		}
+	}
+	return exported, nil
+}
+
+// sortByVariableDependencies orders the checks so that each check runs after
+// the checks exporting the variables it references. The relative order of the
+// checks is kept where possible. Returns an error if the references are cyclic.
+func sortByVariableDependencies(checks []BenchmarkCheck) ([]BenchmarkCheck, error) {
+	exporters := make(map[string][]int)
+	references := make([][]string, len(checks))
+	hasReferences := false
+	for i, c := range checks {
+		exported, referenced := checkVariables(c)
+		for _, name := range exported {
+			exporters[name] = append(exporters[name], i)
+		}
+		references[i] = referenced
+		hasReferences = hasReferences || len(referenced) > 0
+	}
+	if !hasReferences {
+		return checks, nil
+	}
+
+	result := make([]BenchmarkCheck, 0, len(checks))
+	done := make([]bool, len(checks))
+	for len(result) < len(checks) {
+		progress := false
+		for i, c := range checks {
+			if done[i] || !dependenciesDone(references[i], exporters, done) {
+				continue
+			}
+			done[i] = true
+			result = append(result, c)
+			progress = true
+		}
+		if !progress {
+			cyclic := []string{}
+			for i, c := range checks {
+				if !done[i] {
+					cyclic = append(cyclic, c.String())
+				}
+			}
+			return nil, fmt.Errorf("checks have cyclic variable references: %s", strings.Join(cyclic, ", "))
+		}
+	}
+	return result, nil
+}
+
+func dependenciesDone(referenced []string, exporters map[string][]int, done []bool) bool {
+	for _, name := range referenced {
+		for _, i := range exporters[name] {
+			if !done[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configchecks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
+	"github.com/google/localtoast/scannerlib/configchecks"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
+	"github.com/google/localtoast/scannerlib/testconfigcreator"
+)
+
+func existenceFileCheck(path string, shouldExist bool) *ipb.FileCheck {
+	return &ipb.FileCheck{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(path)},
+		CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: shouldExist}},
+	}
+}
+
+func createChecksWithVariables(t *testing.T, instructions map[string]*ipb.BenchmarkScanInstruction, ids []string, api *fakeAPI) ([]configchecks.BenchmarkCheck, error) {
+	t.Helper()
+	configs := make([]*apb.BenchmarkConfig, 0, len(ids))
+	for _, id := range ids {
+		configs = append(configs, testconfigcreator.NewBenchmarkConfig(t, id, instructions[id]))
+	}
+	return configchecks.CreateChecksFromConfig(context.Background(), &apb.ScanConfig{BenchmarkConfigs: configs}, api)
+}
+
+func TestExportedVariablesAreSubstituted(t *testing.T) {
+	socketCheck := existenceFileCheck("%%datadir%%/my.cnf", true)
+	socketCheck.Exports = []*ipb.VariableExport{{Name: "socket", Regex: `^socket\s*=\s*(\S+)`}}
+	instructions := map[string]*ipb.BenchmarkScanInstruction{
+		// Listed before the checks exporting the variables it references.
+		"socket-removed": testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{
+			existenceFileCheck("%%socket%%", false),
+		}),
+		"socket-configured": testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{socketCheck}),
+		"datadir-set": testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+			TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+			Query:          "SELECT @@datadir",
+			ExpectResults:  true,
+			Exports:        []*ipb.VariableExport{{Name: "datadir"}},
+		}}),
+	}
+	checks, err := createChecksWithVariables(t, instructions, []string{"socket-removed", "socket-configured", "datadir-set"}, newFakeAPI(
+		withQueryRows(map[string][]string{"SELECT @@datadir": {"/var/lib/mysql"}}),
+		withFiles(map[string]string{
+			"/var/lib/mysql/my.cnf":   "[mysqld]\nsocket = /run/mysqld/mysqld.sock\n",
+			"/run/mysqld/mysqld.sock": "",
+		})))
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig() returned an error: %v", err)
+	}
+
+	gotOrder := []string{}
+	results := make(map[string]*apb.ComplianceResult)
+	for _, c := range checks {
+		resultMap, _, err := c.Exec("")
+		if err != nil {
+			t.Fatalf("%v.Exec() returned an error: %v", c, err)
+		}
+		result, gotSingleton := singleComplianceResult(resultMap)
+		if !gotSingleton {
+			t.Fatalf("%v.Exec() expected to return 1 result, got %d", c, len(resultMap))
+		}
+		gotOrder = append(gotOrder, result.GetId())
+		results[result.GetId()] = result
+	}
+
+	wantOrder := []string{"datadir-set", "socket-configured", "socket-removed"}
+	if diff := cmp.Diff(wantOrder, gotOrder); diff != "" {
+		t.Errorf("configchecks.CreateChecksFromConfig() returned checks in unexpected order (-want +got):\n%s", diff)
+	}
+	want := &apb.ComplianceResult{
+		Id: "socket-removed",
+		ComplianceOccurrence: &cpb.ComplianceOccurrence{
+			NonCompliantFiles: []*cpb.NonCompliantFile{
+				{Path: "/run/mysqld/mysqld.sock", Reason: "File exists but it shouldn't"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, results["socket-removed"], protocmp.Transform()); diff != "" {
+		t.Errorf("check.Exec() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnexportedVariableReturnsError(t *testing.T) {
+	instructions := map[string]*ipb.BenchmarkScanInstruction{
+		"datadir-set": testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+			TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+			Query:          fakeQueryNoRows,
+			Exports:        []*ipb.VariableExport{{Name: "datadir"}},
+		}}),
+		"config-exists": testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{
+			existenceFileCheck("%%datadir%%/my.cnf", true),
+		}),
+	}
+	checks, err := createChecksWithVariables(t, instructions, []string{"datadir-set", "config-exists"}, newFakeAPI())
+	if err != nil {
+		t.Fatalf("configchecks.CreateChecksFromConfig() returned an error: %v", err)
+	}
+	if len(checks) != 2 {
+		t.Fatalf("Created %d checks, expected 2", len(checks))
+	}
+	if _, _, err := checks[0].Exec(""); err != nil {
+		t.Fatalf("%v.Exec() returned an error: %v", checks[0], err)
+	}
+	if _, _, err := checks[1].Exec(""); err == nil {
+		t.Errorf("%v.Exec() didn't return an error", checks[1])
+	}
+}
+
+func TestInvalidVariablesReturnError(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		instructions map[string]*ipb.BenchmarkScanInstruction
+	}{
+		{
+			desc: "cyclic references",
+			instructions: map[string]*ipb.BenchmarkScanInstruction{
+				"a": testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+					FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/%%b%%")},
+					CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+					Exports:      []*ipb.VariableExport{{Name: "a", Regex: ".*"}},
+				}}),
+				"b": testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+					FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("/etc/%%a%%")},
+					CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+					Exports:      []*ipb.VariableExport{{Name: "b", Regex: ".*"}},
+				}}),
+			},
+		},
+		{
+			desc: "duplicate export",
+			instructions: map[string]*ipb.BenchmarkScanInstruction{
+				"a": testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+					TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+					Query:          fakeQueryOneRow,
+					Exports:        []*ipb.VariableExport{{Name: "value"}},
+				}}),
+				"b": testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+					TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+					Query:          fakeQueryOneRow,
+					Exports:        []*ipb.VariableExport{{Name: "value"}},
+				}}),
+			},
+		},
+		{
+			desc: "invalid name",
+			instructions: map[string]*ipb.BenchmarkScanInstruction{
+				"a": testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+					TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+					Query:          fakeQueryOneRow,
+					Exports:        []*ipb.VariableExport{{Name: "data dir"}},
+				}}),
+			},
+		},
+		{
+			desc: "reserved name",
+			instructions: map[string]*ipb.BenchmarkScanInstruction{
+				"a": testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+					TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+					Query:          fakeQueryOneRow,
+					Exports:        []*ipb.VariableExport{{Name: "pipeline"}},
+				}}),
+			},
+		},
+		{
+			desc: "invalid regex",
+			instructions: map[string]*ipb.BenchmarkScanInstruction{
+				"a": testconfigcreator.NewSQLScanInstruction([]*ipb.SQLCheck{{
+					TargetDatabase: ipb.SQLCheck_DB_MYSQL,
+					Query:          fakeQueryOneRow,
+					Exports:        []*ipb.VariableExport{{Name: "value", Regex: "("}},
+				}}),
+			},
+		},
+		{
+			desc: "file export without regex",
+			instructions: map[string]*ipb.BenchmarkScanInstruction{
+				"a": testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+					FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(testFilePath)},
+					CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+					Exports:      []*ipb.VariableExport{{Name: "value"}},
+				}}),
+			},
+		},
+		{
+			desc: "export from repeated check",
+			instructions: map[string]*ipb.BenchmarkScanInstruction{
+				"a": testconfigcreator.NewFileScanInstruction([]*ipb.FileCheck{{
+					FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath("$home")},
+					CheckType:    &ipb.FileCheck_Existence{Existence: &ipb.ExistenceCheck{ShouldExist: true}},
+					RepeatConfig: &ipb.RepeatConfig{Type: ipb.RepeatConfig_FOR_EACH_USER},
+					Exports:      []*ipb.VariableExport{{Name: "value", Regex: ".*"}},
+				}}),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ids := []string{}
+			for id := range tc.instructions {
+				ids = append(ids, id)
+			}
+			if _, err := createChecksWithVariables(t, tc.instructions, ids, newFakeAPI()); err == nil {
+				t.Errorf("configchecks.CreateChecksFromConfig() didn't return an error")
+			}
+		})
+	}
+}
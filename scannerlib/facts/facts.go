@@ -0,0 +1,369 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package facts collects facts about the scanned host, such as its OS
+// release, installed packages and listening ports, for use in the conditions
+// and substitutions of the scan instructions.
+package facts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/accounts"
+	"github.com/google/localtoast/scannerlib/mounts"
+	"github.com/google/localtoast/scannerlib/packages"
+	"github.com/google/localtoast/scannerlib/sockets"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+var (
+	// The os-release file, with the fallback used if it doesn't exist.
+	osReleasePaths = []string{"/etc/os-release", "/usr/lib/os-release"}
+	// The hostname, with the fallback used if /proc isn't available.
+	hostnamePaths      = []string{"/proc/sys/kernel/hostname", "/etc/hostname"}
+	kernelReleasePath  = "/proc/sys/kernel/osrelease"
+	listeningProtocols = []string{sockets.TCP, sockets.TCP6, sockets.UDP, sockets.UDP6}
+	// The facts listing the listening ports of each protocol.
+	listeningPortsFacts = map[string]string{
+		sockets.TCP:  "listening_ports.tcp",
+		sockets.TCP6: "listening_ports.tcp",
+		sockets.UDP:  "listening_ports.udp",
+		sockets.UDP6: "listening_ports.udp",
+	}
+)
+
+// Collector collects the facts about the scanned host. The facts are read
+// only once, on the first call to Collect, and cached for later calls. The
+// parsed files the facts are derived from are cached as well and can be
+// reused by other parts of the scan through the Collector's accessors.
+type Collector struct {
+	fs        scanapi.Filesystem
+	packages  *packages.Reader
+	once      sync.Once
+	collected atomic.Bool
+	facts     *apb.HostFacts
+	factErrs  map[string]error
+	err       error
+	mu        sync.Mutex
+	sources   map[string]*source
+}
+
+// source holds the cached result of reading one of the host's files.
+type source struct {
+	once  sync.Once
+	value any
+	err   error
+}
+
+// NewCollector creates a Collector that reads the facts through the given
+// filesystem.
+func NewCollector(fs scanapi.Filesystem) *Collector {
+	return &Collector{
+		fs:       fs,
+		packages: packages.NewReader(fs),
+		factErrs: make(map[string]error),
+		sources:  make(map[string]*source),
+	}
+}
+
+// Packages returns the reader of the host's package databases used for the
+// package facts, which caches the parsed databases.
+func (c *Collector) Packages() *packages.Reader {
+	return c.packages
+}
+
+// cached returns the result of read for the named source, calling it only
+// on the first request of the source.
+func (c *Collector) cached(name string, read func() (any, error)) (any, error) {
+	c.mu.Lock()
+	s, ok := c.sources[name]
+	if !ok {
+		s = &source{}
+		c.sources[name] = s
+	}
+	c.mu.Unlock()
+	s.once.Do(func() { s.value, s.err = read() })
+	return s.value, s.err
+}
+
+// Users returns the entries of /etc/passwd.
+func (c *Collector) Users(ctx context.Context) ([]*accounts.User, error) {
+	v, err := c.cached("passwd", func() (any, error) { return accounts.ReadPasswd(ctx, c.fs) })
+	users, _ := v.([]*accounts.User)
+	return users, err
+}
+
+// Groups returns the entries of /etc/group.
+func (c *Collector) Groups(ctx context.Context) ([]*accounts.Group, error) {
+	v, err := c.cached("group", func() (any, error) { return accounts.ReadGroups(ctx, c.fs) })
+	groups, _ := v.([]*accounts.Group)
+	return groups, err
+}
+
+// UIDRanges returns the UID ranges of the host's system and regular users.
+func (c *Collector) UIDRanges(ctx context.Context) (*accounts.UIDRanges, error) {
+	v, err := c.cached("uid_ranges", func() (any, error) { return accounts.ReadUIDRanges(ctx, c.fs) })
+	ranges, _ := v.(*accounts.UIDRanges)
+	return ranges, err
+}
+
+// MountInfo returns the currently mounted filesystems.
+func (c *Collector) MountInfo(ctx context.Context) ([]*mounts.Mount, error) {
+	v, err := c.cached("mountinfo", func() (any, error) { return mounts.ReadMountInfo(ctx, c.fs) })
+	ms, _ := v.([]*mounts.Mount)
+	return ms, err
+}
+
+// ConfiguredMounts returns the filesystems configured in /etc/fstab.
+func (c *Collector) ConfiguredMounts(ctx context.Context) ([]*mounts.Mount, error) {
+	v, err := c.cached("fstab", func() (any, error) { return mounts.ReadConfiguredMounts(ctx, c.fs) })
+	ms, _ := v.([]*mounts.Mount)
+	return ms, err
+}
+
+// InetSockets returns the host's sockets of the given protocol, e.g.
+// sockets.TCP.
+func (c *Collector) InetSockets(ctx context.Context, protocol string) ([]*sockets.InetSocket, error) {
+	v, err := c.cached("sockets."+protocol, func() (any, error) { return sockets.ReadInetSockets(ctx, c.fs, protocol) })
+	socketList, _ := v.([]*sockets.InetSocket)
+	return socketList, err
+}
+
+// UnixSockets returns the host's Unix domain sockets.
+func (c *Collector) UnixSockets(ctx context.Context) ([]*sockets.UnixSocket, error) {
+	v, err := c.cached("sockets.unix", func() (any, error) { return sockets.ReadUnixSockets(ctx, c.fs) })
+	socketList, _ := v.([]*sockets.UnixSocket)
+	return socketList, err
+}
+
+// Collect returns the facts about the scanned host. Facts whose source files
+// don't exist or can't be read due to missing permissions are left unset. If
+// some facts can't be collected for other reasons, the facts collected so far
+// are returned along with the error. FactErr returns which facts failed.
+func (c *Collector) Collect(ctx context.Context) (*apb.HostFacts, error) {
+	c.once.Do(func() {
+		c.facts, c.err = c.collect(ctx)
+		c.collected.Store(true)
+	})
+	return c.facts, c.err
+}
+
+// Collected returns the facts if they were already collected, e.g. because
+// the scan instructions use them, and nil otherwise. Doesn't collect any facts
+// itself.
+func (c *Collector) Collected() *apb.HostFacts {
+	if !c.collected.Load() {
+		return nil
+	}
+	return c.facts
+}
+
+// FactErr returns the error that prevented collecting the named fact, or nil
+// if the fact was collected or its source is missing on the host. Must only
+// be called after Collect.
+func (c *Collector) FactErr(name string) error {
+	switch {
+	case strings.HasPrefix(name, "os."):
+		name = "os"
+	case strings.HasPrefix(name, "package."):
+		name = "packages"
+	}
+	return c.factErrs[name]
+}
+
+func (c *Collector) collect(ctx context.Context) (*apb.HostFacts, error) {
+	f := &apb.HostFacts{}
+	errs := []error{}
+	// The errors are keyed by the fact names used in Lookup, or their prefix
+	// for the "os." and "package." facts.
+	addErr := func(fact string, err error) {
+		if err != nil && !isSkippable(err) {
+			err = fmt.Errorf("unable to collect fact %s: %w", fact, err)
+			errs = append(errs, err)
+			c.factErrs[fact] = errors.Join(c.factErrs[fact], err)
+		}
+	}
+
+	osRelease, err := ReadOSRelease(ctx, c.fs)
+	addErr("os", err)
+	f.OsRelease = osRelease
+
+	f.KernelRelease, err = readSingleLine(ctx, c.fs, kernelReleasePath)
+	addErr("kernel.release", err)
+
+	for _, p := range hostnamePaths {
+		f.Hostname, err = readSingleLine(ctx, c.fs, p)
+		if err == nil || !isSkippable(err) {
+			break
+		}
+	}
+	addErr("hostname", err)
+
+	users, err := c.Users(ctx)
+	addErr("users", err)
+	for _, u := range users {
+		f.Users = append(f.Users, u.Name)
+	}
+	groups, err := c.Groups(ctx)
+	addErr("groups", err)
+	for _, g := range groups {
+		f.Groups = append(f.Groups, g.Name)
+	}
+
+	pkgs, err := c.packages.InstalledPackages(ctx)
+	addErr("packages", err)
+	for _, p := range pkgs {
+		f.Packages = append(f.Packages, &apb.HostFacts_Package{Name: p.Name, Version: p.Version})
+	}
+
+	mountList, err := c.MountInfo(ctx)
+	addErr("mounts", err)
+	for _, m := range mountList {
+		f.MountPoints = append(f.MountPoints, m.MountPoint)
+	}
+
+	for _, protocol := range listeningProtocols {
+		socketList, err := c.InetSockets(ctx, protocol)
+		addErr(listeningPortsFacts[protocol], err)
+		for _, s := range socketList {
+			if s.IsListening() {
+				f.ListeningPorts = append(f.ListeningPorts, &apb.HostFacts_ListeningPort{
+					Protocol: protocol,
+					Port:     int32(s.LocalPort),
+					Address:  s.LocalIP.String(),
+				})
+			}
+		}
+	}
+	return f, errors.Join(errs...)
+}
+
+// isSkippable returns whether the fact's source is missing on the host.
+func isSkippable(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission)
+}
+
+// ReadOSRelease returns the key-value pairs of the host's os-release file,
+// e.g. ID=debian, with any quotes removed from the values.
+func ReadOSRelease(ctx context.Context, fs scanapi.Filesystem) (map[string]string, error) {
+	var err error
+	for _, p := range osReleasePaths {
+		var f io.ReadCloser
+		if f, err = fs.OpenFile(ctx, p); err != nil {
+			if isSkippable(err) {
+				continue
+			}
+			return nil, err
+		}
+		defer f.Close()
+		values, err := accounts.ParseKeyValues(f, "=")
+		if err != nil {
+			return nil, err
+		}
+		// Values can also be single-quoted.
+		for k, v := range values {
+			values[k] = strings.Trim(v, "'")
+		}
+		return values, nil
+	}
+	return nil, err
+}
+
+func readSingleLine(ctx context.Context, fs scanapi.Filesystem, path string) (string, error) {
+	f, err := fs.OpenFile(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// Lookup returns the value of the named fact. The supported names are
+// "os.<key>" for the lowercased keys of os-release (e.g. "os.version_id"),
+// "kernel.release", "hostname", "users", "groups", "mounts",
+// "package.<name>.version", "listening_ports.tcp" and "listening_ports.udp".
+// Lists are comma-separated and the listening ports are sorted numerically.
+// Returns false if the fact isn't set on the host and an error if the name
+// isn't supported.
+func Lookup(f *apb.HostFacts, name string) (string, bool, error) {
+	switch name {
+	case "kernel.release":
+		return f.GetKernelRelease(), f.GetKernelRelease() != "", nil
+	case "hostname":
+		return f.GetHostname(), f.GetHostname() != "", nil
+	case "users":
+		return joinList(f.GetUsers())
+	case "groups":
+		return joinList(f.GetGroups())
+	case "mounts":
+		return joinList(f.GetMountPoints())
+	case "listening_ports.tcp":
+		return listeningPorts(f, sockets.TCP, sockets.TCP6)
+	case "listening_ports.udp":
+		return listeningPorts(f, sockets.UDP, sockets.UDP6)
+	}
+	if key, found := strings.CutPrefix(name, "os."); found && key != "" {
+		v, ok := f.GetOsRelease()[strings.ToUpper(key)]
+		return v, ok, nil
+	}
+	// Package names can contain dots, e.g. "python3.11".
+	if pkg, found := strings.CutPrefix(name, "package."); found {
+		if pkg, found = strings.CutSuffix(pkg, ".version"); found && pkg != "" {
+			for _, p := range f.GetPackages() {
+				if p.GetName() == pkg {
+					return p.GetVersion(), true, nil
+				}
+			}
+			return "", false, nil
+		}
+	}
+	return "", false, fmt.Errorf("unknown fact %q", name)
+}
+
+func joinList(values []string) (string, bool, error) {
+	return strings.Join(values, ","), len(values) > 0, nil
+}
+
+func listeningPorts(f *apb.HostFacts, protocols ...string) (string, bool, error) {
+	seen := make(map[int32]bool)
+	ports := []int{}
+	for _, p := range f.GetListeningPorts() {
+		for _, protocol := range protocols {
+			if p.GetProtocol() == protocol && !seen[p.GetPort()] {
+				seen[p.GetPort()] = true
+				ports = append(ports, int(p.GetPort()))
+			}
+		}
+	}
+	sort.Ints(ports)
+	values := make([]string, 0, len(ports))
+	for _, p := range ports {
+		values = append(values, strconv.Itoa(p))
+	}
+	return joinList(values)
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facts_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/facts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+const procNetHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+// fakeFilesystem serves the files from a map of file paths to contents and
+// counts how often each file was opened.
+type fakeFilesystem struct {
+	files map[string]string
+	opens map[string]int
+}
+
+func newFakeFilesystem(files map[string]string) *fakeFilesystem {
+	return &fakeFilesystem{files: files, opens: make(map[string]int)}
+}
+
+func (f *fakeFilesystem) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	f.opens[path]++
+	content, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+func (f *fakeFilesystem) OpenDir(ctx context.Context, path string) (scanapi.DirReader, error) {
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeFilesystem) FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+var testFiles = map[string]string{
+	"/usr/lib/os-release": "# Fallback location.\n" +
+		"PRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n" +
+		"ID=debian\n" +
+		"VERSION_ID='12'\n",
+	"/proc/sys/kernel/osrelease": "6.1.0-18-amd64\n",
+	"/etc/hostname":              "db1\n",
+	"/etc/passwd": "root:x:0:0:root:/root:/bin/bash\n" +
+		"mysql:x:100:101::/nonexistent:/bin/false\n",
+	"/etc/group": "root:x:0:\n" +
+		"mysql:x:101:\n",
+	"/var/lib/dpkg/status": "Package: mariadb-server\n" +
+		"Status: install ok installed\n" +
+		"Version: 1:10.11.6-0+deb12u1\n",
+	"/proc/self/mountinfo": "22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n" +
+		"23 22 0:21 / /tmp rw,nosuid,nodev - tmpfs tmpfs rw\n",
+	"/proc/net/tcp": procNetHeader +
+		"  0: 00000000:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 100 1 0000000000000000 100 0 0 10 0\n" +
+		"  1: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 101 1 0000000000000000 100 0 0 10 0\n" +
+		// An established connection.
+		"  2: 0F02000A:0016 0202000A:C350 01 00000000:00000000 00:00000000 00000000     0        0 102 1 0000000000000000 100 0 0 10 0\n",
+	"/proc/net/tcp6": procNetHeader +
+		"  0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 103 1 0000000000000000 100 0 0 10 0\n",
+}
+
+func TestCollect(t *testing.T) {
+	fs := newFakeFilesystem(testFiles)
+	c := facts.NewCollector(fs)
+	got, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("c.Collect() returned an error: %v", err)
+	}
+	want := &apb.HostFacts{
+		OsRelease: map[string]string{
+			"PRETTY_NAME": "Debian GNU/Linux 12 (bookworm)",
+			"ID":          "debian",
+			"VERSION_ID":  "12",
+		},
+		KernelRelease: "6.1.0-18-amd64",
+		Hostname:      "db1",
+		Users:         []string{"root", "mysql"},
+		Groups:        []string{"root", "mysql"},
+		Packages:      []*apb.HostFacts_Package{{Name: "mariadb-server", Version: "1:10.11.6-0+deb12u1"}},
+		MountPoints:   []string{"/", "/tmp"},
+		ListeningPorts: []*apb.HostFacts_ListeningPort{
+			{Protocol: "tcp", Port: 3306, Address: "0.0.0.0"},
+			{Protocol: "tcp", Port: 22, Address: "0.0.0.0"},
+			{Protocol: "tcp6", Port: 22, Address: "::"},
+		},
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("c.Collect() returned unexpected diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("c.Collect() returned an error: %v", err)
+	}
+	if opens := fs.opens["/etc/passwd"]; opens != 1 {
+		t.Errorf("c.Collect() opened /etc/passwd %d times, expected the facts to be cached", opens)
+	}
+}
+
+func TestCollectInvalidFileReturnsError(t *testing.T) {
+	fs := newFakeFilesystem(map[string]string{
+		"/etc/hostname": "db1\n",
+		"/etc/passwd":   "invalid\n",
+	})
+	got, err := facts.NewCollector(fs).Collect(context.Background())
+	if err == nil {
+		t.Fatalf("c.Collect() didn't return an error")
+	}
+	if got.GetHostname() != "db1" {
+		t.Errorf("c.Collect() returned hostname %q, expected the other facts to be collected", got.GetHostname())
+	}
+}
+
+func TestFactErrReturnsErrorsOfAffectedFacts(t *testing.T) {
+	fs := newFakeFilesystem(map[string]string{
+		"/etc/os-release": "ID=debian\n",
+		"/etc/passwd":     "invalid\n",
+	})
+	c := facts.NewCollector(fs)
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Fatalf("c.Collect() didn't return an error")
+	}
+	if err := c.FactErr("users"); err == nil {
+		t.Errorf("c.FactErr(users) didn't return an error")
+	}
+	for _, name := range []string{"os.id", "hostname", "package.bash.version"} {
+		if err := c.FactErr(name); err != nil {
+			t.Errorf("c.FactErr(%s) returned an error: %v", name, err)
+		}
+	}
+}
+
+func TestCollectorCachesSources(t *testing.T) {
+	fs := newFakeFilesystem(map[string]string{"/etc/passwd": "root:x:0:0:root:/root:/bin/bash\n"})
+	c := facts.NewCollector(fs)
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatalf("c.Collect() returned an error: %v", err)
+	}
+	users, err := c.Users(context.Background())
+	if err != nil {
+		t.Fatalf("c.Users() returned an error: %v", err)
+	}
+	if len(users) != 1 || users[0].Name != "root" {
+		t.Errorf("c.Users() returned %v, expected the root user", users)
+	}
+	if opens := fs.opens["/etc/passwd"]; opens != 1 {
+		t.Errorf("c.Collect() and c.Users() opened /etc/passwd %d times, expected 1", opens)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f := &apb.HostFacts{
+		OsRelease:     map[string]string{"ID": "debian", "VERSION_ID": "12"},
+		KernelRelease: "6.1.0-18-amd64",
+		Users:         []string{"root", "mysql"},
+		Packages: []*apb.HostFacts_Package{
+			{Name: "python3.11", Version: "3.11.2-6"},
+		},
+		ListeningPorts: []*apb.HostFacts_ListeningPort{
+			{Protocol: "tcp", Port: 3306},
+			{Protocol: "tcp6", Port: 22},
+			{Protocol: "tcp", Port: 22},
+			{Protocol: "udp", Port: 161},
+		},
+	}
+	testCases := []struct {
+		name      string
+		wantValue string
+		wantOK    bool
+	}{
+		{name: "os.version_id", wantValue: "12", wantOK: true},
+		{name: "os.version_codename"},
+		{name: "kernel.release", wantValue: "6.1.0-18-amd64", wantOK: true},
+		{name: "hostname"},
+		{name: "users", wantValue: "root,mysql", wantOK: true},
+		{name: "groups"},
+		{name: "package.python3.11.version", wantValue: "3.11.2-6", wantOK: true},
+		{name: "package.openssh-server.version"},
+		{name: "listening_ports.tcp", wantValue: "22,3306", wantOK: true},
+		{name: "listening_ports.udp", wantValue: "161", wantOK: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotValue, gotOK, err := facts.Lookup(f, tc.name)
+			if err != nil {
+				t.Fatalf("facts.Lookup(%q) returned an error: %v", tc.name, err)
+			}
+			if gotValue != tc.wantValue || gotOK != tc.wantOK {
+				t.Errorf("facts.Lookup(%q) = %q, %t, want %q, %t", tc.name, gotValue, gotOK, tc.wantValue, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestLookupUnknownFactReturnsError(t *testing.T) {
+	for _, name := range []string{"os.", "kernel", "package.bash", "package..version", "uptime"} {
+		if _, _, err := facts.Lookup(&apb.HostFacts{}, name); err == nil {
+			t.Errorf("facts.Lookup(%q) didn't return an error", name)
+		}
+	}
+}
@@ -26,10 +26,13 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	"google.golang.org/protobuf/encoding/prototext"
 	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/packages"
+	"github.com/google/localtoast/scannerlib/sockets"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
 	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
 )
@@ -41,7 +44,6 @@ const maxTraversalDepth = 100
 const PipelineToken = "%%pipeline%%"
 
 var (
-	procDirMatcher     = regexp.MustCompile(`^\d+$`)
 	procEnvironMatcher = regexp.MustCompile("^(.*)=(.*)$")
 )
 
@@ -57,11 +59,23 @@ func FileSetToString(fileSet *ipb.FileSet) string {
 // ApplyOptOutConfig applies the directory traversal opt-out settings from an
 // OptOutConfig to a FileSet.
 func ApplyOptOutConfig(fileSet *ipb.FileSet, config *apb.OptOutConfig) {
-	if fileSet.GetFilesInDir() == nil {
-		return
+	switch {
+	case fileSet.GetFilesInDir() != nil:
+		fileSet.GetFilesInDir().OptOutPathRegexes =
+			append(fileSet.GetFilesInDir().GetOptOutPathRegexes(), config.GetTraversalOptoutRegexes()...)
+	case fileSet.GetFilesMatchingPredicate() != nil:
+		fileSet.GetFilesMatchingPredicate().OptOutPathRegexes =
+			append(fileSet.GetFilesMatchingPredicate().GetOptOutPathRegexes(), config.GetTraversalOptoutRegexes()...)
+	case fileSet.GetGlob() != nil:
+		fileSet.GetGlob().OptOutPathRegexes =
+			append(fileSet.GetGlob().GetOptOutPathRegexes(), config.GetTraversalOptoutRegexes()...)
+	case fileSet.GetIncludedFiles() != nil:
+		fileSet.GetIncludedFiles().OptOutPathRegexes =
+			append(fileSet.GetIncludedFiles().GetOptOutPathRegexes(), config.GetTraversalOptoutRegexes()...)
+	case fileSet.GetPackageFiles() != nil:
+		fileSet.GetPackageFiles().OptOutPathRegexes =
+			append(fileSet.GetPackageFiles().GetOptOutPathRegexes(), config.GetTraversalOptoutRegexes()...)
 	}
-	fileSet.GetFilesInDir().OptOutPathRegexes =
-		append(fileSet.GetFilesInDir().GetOptOutPathRegexes(), config.GetTraversalOptoutRegexes()...)
 }
 
 // ApplyReplacementConfig applies the path replacement settings from a
@@ -91,6 +105,17 @@ func applyPathPrefixReplacement(fileSet *ipb.FileSet, prefix, replacement string
 		fileSet.GetSingleFile().Path = replacePrefix(fileSet.GetSingleFile().GetPath(), prefix, replacement)
 	case fileSet.GetFilesInDir() != nil:
 		fileSet.GetFilesInDir().DirPath = replacePrefix(fileSet.GetFilesInDir().GetDirPath(), prefix, replacement)
+	case fileSet.GetFilesMatchingPredicate() != nil:
+		f := fileSet.GetFilesMatchingPredicate()
+		f.DirPath = replacePrefix(f.GetDirPath(), prefix, replacement)
+	case fileSet.GetGlob() != nil:
+		fileSet.GetGlob().Pattern = replacePrefix(fileSet.GetGlob().GetPattern(), prefix, replacement)
+	case fileSet.GetIncludedFiles() != nil:
+		f := fileSet.GetIncludedFiles()
+		f.RootPath = replacePrefix(f.GetRootPath(), prefix, replacement)
+		if f.GetBaseDir() != "" {
+			f.BaseDir = replacePrefix(f.GetBaseDir(), prefix, replacement)
+		}
 	}
 }
 
@@ -114,6 +139,13 @@ type WalkFunc func(path string, isDir bool, traversingDir bool) error
 
 // WalkFiles calls walkFunc for each file described by the provided FileSet.
 func WalkFiles(ctx context.Context, fileSet *ipb.FileSet, fs scanapi.Filesystem, timeout time.Time, walkFunc WalkFunc) error {
+	return WalkFilesWithPackages(ctx, fileSet, fs, packages.NewReader(fs), timeout, walkFunc)
+}
+
+// WalkFilesWithPackages is like WalkFiles but reads the files of installed
+// packages through the given package reader, e.g. to share the parsed package
+// databases between the checks of a scan.
+func WalkFilesWithPackages(ctx context.Context, fileSet *ipb.FileSet, fs scanapi.Filesystem, pkgs *packages.Reader, timeout time.Time, walkFunc WalkFunc) error {
 	if err := checkTimeout(timeout); err != nil {
 		return err
 	}
@@ -155,6 +187,14 @@ func WalkFiles(ctx context.Context, fileSet *ipb.FileSet, fs scanapi.Filesystem,
 		return walkProcessPaths(ctx, fileSet.GetProcessPath().GetProcName(), fileSet.GetProcessPath().GetFileName(), fileSet.GetProcessPath().GetCliArgRegex(), timeout, fs, walkFunc)
 	case fileSet.GetUnixEnvVarPaths() != nil:
 		return walkVarPaths(ctx, fileSet.GetUnixEnvVarPaths(), timeout, fs, walkFunc)
+	case fileSet.GetFilesMatchingPredicate() != nil:
+		return walkFilesMatchingPredicate(ctx, fileSet.GetFilesMatchingPredicate(), timeout, fs, walkFunc)
+	case fileSet.GetGlob() != nil:
+		return walkGlob(ctx, fileSet.GetGlob(), timeout, fs, walkFunc)
+	case fileSet.GetIncludedFiles() != nil:
+		return walkIncludedFiles(ctx, fileSet.GetIncludedFiles(), timeout, fs, walkFunc)
+	case fileSet.GetPackageFiles() != nil:
+		return walkPackageFiles(ctx, fileSet.GetPackageFiles(), timeout, fs, pkgs, walkFunc)
 	default:
 		return fmt.Errorf("Unknown FilePath type %v", fileSet.GetFilePath())
 	}
@@ -170,13 +210,9 @@ func createFilterRegexes(f *ipb.FileSet_FilesInDir) (*regexp.Regexp, []*regexp.R
 		}
 	}
 
-	optOutPathRegexes := make([]*regexp.Regexp, 0, len(f.GetOptOutPathRegexes()))
-	for _, o := range f.GetOptOutPathRegexes() {
-		re, err := regexp.Compile("^" + o + "$")
-		if err != nil {
-			return nil, nil, err
-		}
-		optOutPathRegexes = append(optOutPathRegexes, re)
+	optOutPathRegexes, err := compileRegexes(f.GetOptOutPathRegexes())
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return filenameRegex, optOutPathRegexes, nil
@@ -264,6 +300,536 @@ func pathInOptOutList(dirPath string, optOutPathRegexes []*regexp.Regexp) bool {
 	return false
 }
 
+// walkFilesMatchingPredicate traverses the files under the given directory
+// and calls the walkFunc on the ones that match all of the predicates and
+// aren't in the allow-list.
+func walkFilesMatchingPredicate(ctx context.Context, f *ipb.FileSet_FilesMatchingPredicate, timeout time.Time, fs scanapi.Filesystem, walkFunc WalkFunc) error {
+	m, err := newPredicateMatcher(f)
+	if err != nil {
+		return err
+	}
+	optOutPathRegexes, err := compileRegexes(f.GetOptOutPathRegexes())
+	if err != nil {
+		return err
+	}
+	if pathInOptOutList(f.GetDirPath(), optOutPathRegexes) {
+		return nil
+	}
+	filteredWalkFunc := func(path string, isDir bool, traversingDir bool) error {
+		matches, err := m.matches(ctx, path, fs)
+		if err != nil || !matches {
+			return err
+		}
+		return walkFunc(path, isDir, traversingDir)
+	}
+	if err := filteredWalkFunc(f.GetDirPath(), true, true); err != nil {
+		return err
+	}
+	return walkFilesInDir(&walkFilesInDirOptions{
+		ctx:               ctx,
+		dirPath:           f.GetDirPath(),
+		depth:             1,
+		recursive:         true,
+		optOutPathRegexes: optOutPathRegexes,
+		timeout:           timeout,
+		fs:                fs,
+		walkFunc:          filteredWalkFunc,
+	})
+}
+
+func compileRegexes(exprs []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(exprs))
+	for _, e := range exprs {
+		re, err := regexp.Compile("^" + e + "$")
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// predicateMatcher evaluates the predicates of a FilesMatchingPredicate FileSet.
+type predicateMatcher struct {
+	predicate      *ipb.FileSet_FilesMatchingPredicate
+	allowedRegexes []*regexp.Regexp
+	fileTypes      map[string]bool
+}
+
+func newPredicateMatcher(f *ipb.FileSet_FilesMatchingPredicate) (*predicateMatcher, error) {
+	if f.GetAllBitsSet() == 0 && f.GetAnyBitsSet() == 0 && !f.GetNoUser() && !f.GetNoGroup() &&
+		len(f.GetFileTypes()) == 0 && f.GetMinSizeBytes() == 0 && f.GetMaxSizeBytes() == 0 {
+		return nil, fmt.Errorf("FileSet %v has no predicates", f)
+	}
+	if f.GetAllBitsSet() & ^07777 != 0 || f.GetAnyBitsSet() & ^07777 != 0 {
+		return nil, fmt.Errorf("FileSet %v has invalid mode bits", f)
+	}
+	if f.GetMaxSizeBytes() != 0 && f.GetMaxSizeBytes() < f.GetMinSizeBytes() {
+		return nil, fmt.Errorf("FileSet %v has a max size smaller than its min size", f)
+	}
+	allowedRegexes, err := compileRegexes(f.GetAllowedPathRegexes())
+	if err != nil {
+		return nil, err
+	}
+	fileTypes := make(map[string]bool)
+	for _, t := range f.GetFileTypes() {
+		if t == ipb.FileMetadataCheck_TYPE_UNSPECIFIED {
+			return nil, fmt.Errorf("FileSet %v has an unspecified file type", f)
+		}
+		fileTypes[t.String()] = true
+	}
+	return &predicateMatcher{predicate: f, allowedRegexes: allowedRegexes, fileTypes: fileTypes}, nil
+}
+
+func (m *predicateMatcher) matches(ctx context.Context, filePath string, fs scanapi.Filesystem) (bool, error) {
+	if pathInOptOutList(filePath, m.allowedRegexes) {
+		return false, nil
+	}
+	f := m.predicate
+	if f.GetAllBitsSet() != 0 || f.GetAnyBitsSet() != 0 || f.GetNoUser() || f.GetNoGroup() {
+		perms, err := fs.FilePermissions(ctx, filePath)
+		if err != nil {
+			// The file got removed since we listed it, ignore.
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, err
+		}
+		mode := perms.GetPermissionNum()
+		if mode&f.GetAllBitsSet() != f.GetAllBitsSet() {
+			return false, nil
+		}
+		if f.GetAnyBitsSet() != 0 && mode&f.GetAnyBitsSet() == 0 {
+			return false, nil
+		}
+		if f.GetNoUser() && perms.GetUser() != "" {
+			return false, nil
+		}
+		if f.GetNoGroup() && perms.GetGroup() != "" {
+			return false, nil
+		}
+	}
+	if len(m.fileTypes) > 0 || f.GetMinSizeBytes() != 0 || f.GetMaxSizeBytes() != 0 {
+		stat, err := fs.FileStat(ctx, filePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, err
+		}
+		if len(m.fileTypes) > 0 && !m.fileTypes[stat.GetType().String()] {
+			return false, nil
+		}
+		if stat.GetSize() < f.GetMinSizeBytes() {
+			return false, nil
+		}
+		if f.GetMaxSizeBytes() != 0 && stat.GetSize() > f.GetMaxSizeBytes() {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// walkGlob calls the walkFunc on all paths matching the given glob pattern.
+func walkGlob(ctx context.Context, g *ipb.FileSet_Glob, timeout time.Time, fs scanapi.Filesystem, walkFunc WalkFunc) error {
+	components, err := splitGlobPattern(g.GetPattern())
+	if err != nil {
+		return err
+	}
+	optOutPathRegexes, err := compileRegexes(g.GetOptOutPathRegexes())
+	if err != nil {
+		return err
+	}
+	w := &globWalker{
+		ctx:               ctx,
+		optOutPathRegexes: optOutPathRegexes,
+		fs:                fs,
+		timeout:           timeout,
+		walkFunc:          walkFunc,
+		visited:           make(map[string]bool),
+	}
+	return w.expand("/", components, 1)
+}
+
+func splitGlobPattern(pattern string) ([]string, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("glob pattern %q is not an absolute path", pattern)
+	}
+	trimmed := strings.Trim(path.Clean(pattern), "/")
+	if trimmed == "" {
+		return nil, fmt.Errorf("glob pattern %q matches no files", pattern)
+	}
+	components := strings.Split(trimmed, "/")
+	for _, c := range components {
+		if _, err := path.Match(c, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+	return components, nil
+}
+
+type globWalker struct {
+	ctx               context.Context
+	optOutPathRegexes []*regexp.Regexp
+	fs                scanapi.Filesystem
+	timeout           time.Time
+	walkFunc          WalkFunc
+	// The paths already passed to walkFunc. Patterns with several "**"
+	// components can match the same path multiple ways.
+	visited map[string]bool
+}
+
+// expand calls the walkFunc on the paths under dirPath that match the
+// remaining pattern components.
+func (w *globWalker) expand(dirPath string, components []string, depth int) error {
+	if depth > maxTraversalDepth {
+		return fmt.Errorf("exceeded max traversal depth while traversing %s", dirPath)
+	}
+	if err := checkTimeout(w.timeout); err != nil {
+		return err
+	}
+	comp, rest := components[0], components[1:]
+	if comp == "**" && len(rest) > 0 {
+		// Match zero directories.
+		if err := w.expand(dirPath, rest, depth); err != nil {
+			return err
+		}
+	}
+	if !hasGlobMeta(comp) && len(rest) > 0 {
+		// No need to list the directory if the next component is a literal.
+		next := path.Join(dirPath, comp)
+		if pathInOptOutList(next, w.optOutPathRegexes) {
+			return nil
+		}
+		return w.expand(next, rest, depth+1)
+	}
+
+	d, err := w.fs.OpenDir(w.ctx, dirPath)
+	if err != nil {
+		// Non-existent paths and symlinks to files simply match nothing.
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+			return nil
+		}
+		return err
+	}
+	defer d.Close()
+	for d.Next() {
+		c, err := d.Entry()
+		if err != nil {
+			return err
+		}
+		contentPath := path.Join(dirPath, c.GetName())
+		if pathInOptOutList(contentPath, w.optOutPathRegexes) {
+			continue
+		}
+		if comp == "**" {
+			// Like in shells, "**" doesn't descend into hidden or symlinked directories.
+			if strings.HasPrefix(c.GetName(), ".") {
+				continue
+			}
+			if len(rest) == 0 {
+				if err := w.visit(contentPath, c.GetIsDir()); err != nil {
+					return err
+				}
+			}
+			if c.GetIsDir() {
+				if err := w.expand(contentPath, components, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !matchGlobComponent(comp, c.GetName()) {
+			continue
+		}
+		if len(rest) == 0 {
+			if err := w.visit(contentPath, c.GetIsDir()); err != nil {
+				return err
+			}
+		} else if c.GetIsDir() || c.GetIsSymlink() {
+			if err := w.expand(contentPath, rest, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *globWalker) visit(path string, isDir bool) error {
+	if w.visited[path] {
+		return nil
+	}
+	w.visited[path] = true
+	if err := w.walkFunc(path, isDir, true); err != nil {
+		return err
+	}
+	return checkTimeout(w.timeout)
+}
+
+func hasGlobMeta(component string) bool {
+	return strings.ContainsAny(component, `*?[\`)
+}
+
+func matchGlobComponent(pattern, name string) bool {
+	if strings.HasPrefix(name, ".") && !strings.HasPrefix(pattern, ".") {
+		return false
+	}
+	// The pattern was validated in splitGlobPattern.
+	matches, _ := path.Match(pattern, name)
+	return matches
+}
+
+// walkIncludedFiles calls the walkFunc on the root file and all files included
+// from it.
+func walkIncludedFiles(ctx context.Context, f *ipb.FileSet_IncludedFiles, timeout time.Time, fs scanapi.Filesystem, walkFunc WalkFunc) error {
+	if !path.IsAbs(f.GetRootPath()) {
+		return fmt.Errorf("included files root %q is not an absolute path", f.GetRootPath())
+	}
+	if len(f.GetDirectives()) == 0 {
+		return fmt.Errorf("FileSet %v has no include directives", f)
+	}
+	directives := make([]*includeDirective, 0, len(f.GetDirectives()))
+	for _, d := range f.GetDirectives() {
+		re, err := regexp.Compile(d.GetRegex())
+		if err != nil {
+			return err
+		}
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("include directive regex %q has no capture group", d.GetRegex())
+		}
+		var filenameRegex *regexp.Regexp
+		if d.GetFilenameRegex() != "" {
+			if filenameRegex, err = regexp.Compile("^" + d.GetFilenameRegex() + "$"); err != nil {
+				return err
+			}
+		}
+		directives = append(directives, &includeDirective{
+			regex:         re,
+			directiveType: d.GetDirectiveType(),
+			filenameRegex: filenameRegex,
+		})
+	}
+	optOutPathRegexes, err := compileRegexes(f.GetOptOutPathRegexes())
+	if err != nil {
+		return err
+	}
+	w := &includeWalker{
+		ctx:               ctx,
+		directives:        directives,
+		baseDir:           f.GetBaseDir(),
+		optOutPathRegexes: optOutPathRegexes,
+		fs:                fs,
+		timeout:           timeout,
+		walkFunc:          walkFunc,
+		visited:           make(map[string]bool),
+	}
+	return w.walk(f.GetRootPath(), false, 1)
+}
+
+type includeDirective struct {
+	regex         *regexp.Regexp
+	directiveType ipb.FileSet_IncludedFiles_IncludeDirective_DirectiveType
+	filenameRegex *regexp.Regexp
+}
+
+type includeWalker struct {
+	ctx               context.Context
+	directives        []*includeDirective
+	baseDir           string
+	optOutPathRegexes []*regexp.Regexp
+	fs                scanapi.Filesystem
+	timeout           time.Time
+	walkFunc          WalkFunc
+	// The files already walked, used to detect include cycles.
+	visited map[string]bool
+}
+
+// walk calls the walkFunc on the given file and then recursively on the
+// files it includes.
+func (w *includeWalker) walk(filePath string, traversingDir bool, depth int) error {
+	filePath = path.Clean(filePath)
+	if w.visited[filePath] || pathInOptOutList(filePath, w.optOutPathRegexes) {
+		return nil
+	}
+	w.visited[filePath] = true
+	if depth > maxTraversalDepth {
+		return fmt.Errorf("exceeded max include depth while reading %s", filePath)
+	}
+	if err := w.walkFunc(filePath, false, traversingDir); err != nil {
+		return err
+	}
+	if err := checkTimeout(w.timeout); err != nil {
+		return err
+	}
+
+	includes, err := w.readIncludes(filePath)
+	if err != nil {
+		return err
+	}
+	for _, inc := range includes {
+		target := inc.target
+		if !path.IsAbs(target) {
+			baseDir := w.baseDir
+			if baseDir == "" {
+				baseDir = path.Dir(filePath)
+			}
+			target = path.Join(baseDir, target)
+		}
+		switch inc.directive.directiveType {
+		case ipb.FileSet_IncludedFiles_IncludeDirective_DIR:
+			err = w.walkIncludedDir(target, inc.directive.filenameRegex, depth)
+		case ipb.FileSet_IncludedFiles_IncludeDirective_GLOB:
+			err = w.walkIncludedGlob(target, depth)
+		default:
+			err = w.walk(target, false, depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type include struct {
+	directive *includeDirective
+	target    string
+}
+
+// readIncludes returns the include targets in the given file, in the order
+// they appear in. Files that don't exist include nothing.
+func (w *includeWalker) readIncludes(filePath string) ([]*include, error) {
+	r, err := w.fs.OpenFile(w.ctx, filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	result := []*include{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		for _, d := range w.directives {
+			m := d.regex.FindStringSubmatch(scanner.Text())
+			if m == nil {
+				continue
+			}
+			if target := strings.TrimSpace(m[1]); target != "" {
+				result = append(result, &include{directive: d, target: target})
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", filePath, err)
+	}
+	return result, nil
+}
+
+func (w *includeWalker) walkIncludedDir(dirPath string, filenameRegex *regexp.Regexp, depth int) error {
+	d, err := w.fs.OpenDir(w.ctx, dirPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer d.Close()
+	files := []string{}
+	for d.Next() {
+		c, err := d.Entry()
+		if err != nil {
+			return err
+		}
+		if c.GetIsDir() || (filenameRegex != nil && !filenameRegex.MatchString(c.GetName())) {
+			continue
+		}
+		files = append(files, path.Join(dirPath, c.GetName()))
+	}
+	for _, f := range files {
+		if err := w.walk(f, true, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *includeWalker) walkIncludedGlob(pattern string, depth int) error {
+	files := []string{}
+	glob := &ipb.FileSet_Glob{Pattern: pattern}
+	if err := walkGlob(w.ctx, glob, w.timeout, w.fs, func(path string, isDir bool, traversingDir bool) error {
+		if !isDir {
+			files = append(files, path)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := w.walk(f, true, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPackageFiles returns the files installed by the package described by the
+// FileSet along with their packaged metadata, read through the given package
+// reader.
+func ReadPackageFiles(ctx context.Context, p *ipb.FileSet_PackageFiles, fs scanapi.Filesystem, pkgs *packages.Reader) ([]*packages.File, error) {
+	if p.GetPackageName() == "" {
+		return nil, fmt.Errorf("FileSet %v has no package name", p)
+	}
+	manager := p.GetPackageManager()
+	if manager == ipb.FileSet_PackageFiles_PACKAGE_MANAGER_UNSPECIFIED {
+		manager = ipb.FileSet_PackageFiles_RPM
+		d, err := fs.OpenDir(ctx, packages.DpkgInfoDir)
+		if err == nil {
+			d.Close()
+			manager = ipb.FileSet_PackageFiles_DPKG
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if manager == ipb.FileSet_PackageFiles_DPKG {
+		return pkgs.DpkgPackageFiles(ctx, p.GetPackageName())
+	}
+	return pkgs.RpmPackageFiles(ctx, p.GetPackageName())
+}
+
+// walkPackageFiles calls the walkFunc on the files installed by a package.
+func walkPackageFiles(ctx context.Context, p *ipb.FileSet_PackageFiles, timeout time.Time, fs scanapi.Filesystem, pkgs *packages.Reader, walkFunc WalkFunc) error {
+	files, err := ReadPackageFiles(ctx, p, fs, pkgs)
+	if err != nil {
+		return err
+	}
+	optOutPathRegexes, err := compileRegexes(p.GetOptOutPathRegexes())
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if pathInOptOutList(f.Path, optOutPathRegexes) {
+			continue
+		}
+		isDir := f.Type == packages.Directory
+		if f.Type == packages.UnknownType {
+			// dpkg doesn't record the file types so they're looked up on disk.
+			stat, err := fs.FileStat(ctx, f.Path)
+			isDir = err == nil && stat.GetType() == apb.FileStat_DIRECTORY
+		}
+		if isDir && p.GetFilesOnly() {
+			continue
+		}
+		if err := walkFunc(f.Path, isDir, false); err != nil {
+			return err
+		}
+		if err := checkTimeout(timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // walkProcessPaths iterates over all directories in /proc/ that have a numeric identifier
 // and calls the walkFunc on all of them for which the procName is set in the stat file.
 //
@@ -281,7 +847,7 @@ func walkProcessPaths(ctx context.Context, procName string, fileName string, cli
 		if err != nil {
 			return err
 		}
-		if !f.GetIsDir() || !procDirMatcher.MatchString(f.GetName()) {
+		if !f.GetIsDir() || !sockets.IsProcessDir(f.GetName()) {
 			continue
 		}
 		dirName := path.Join("/proc", f.GetName())
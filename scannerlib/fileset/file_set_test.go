@@ -48,6 +48,14 @@ func (fakeDirectoryReader) FilePermissions(ctx context.Context, path string) (*a
 	return nil, errors.New("Not implemented")
 }
 
+func (fakeDirectoryReader) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (fakeDirectoryReader) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("Not implemented")
+}
+
 func (fakeDirectoryReader) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
 	switch path {
 	case procEnvironPath:
@@ -286,6 +294,14 @@ func (infiniteLoopFSReader) FilePermissions(ctx context.Context, path string) (*
 	return nil, errors.New("Not implemented")
 }
 
+func (infiniteLoopFSReader) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (infiniteLoopFSReader) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("Not implemented")
+}
+
 func (infiniteLoopFSReader) OpenDir(ctx context.Context, path string) (scanapi.DirReader, error) {
 	return scanapi.SliceToDirReader([]*apb.DirContent{
 		&apb.DirContent{Name: "dir", IsDir: true},
@@ -315,6 +331,14 @@ func (fakeProcessPathReader) FilePermissions(ctx context.Context, path string) (
 	return nil, errors.New("Not implemented")
 }
 
+func (fakeProcessPathReader) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (fakeProcessPathReader) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("Not implemented")
+}
+
 func (r fakeProcessPathReader) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
 	if r.removeFilesAfterQuery {
 		return nil, os.ErrNotExist
@@ -618,6 +642,632 @@ func TestUnixEnvVarPaths(t *testing.T) {
 	}
 }
 
+// fakePredicateReader extends fakeDirectoryReader with the permissions and
+// metadata of the files in its fake dir structure.
+type fakePredicateReader struct {
+	fakeDirectoryReader
+}
+
+func (fakePredicateReader) FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, error) {
+	switch path {
+	case "/root", "/root/subdir":
+		return &apb.PosixPermissions{PermissionNum: 0755, User: "root", Group: "root"}, nil
+	case "/root/file1.txt":
+		return &apb.PosixPermissions{PermissionNum: 04755, User: "root", Group: "root"}, nil
+	case "/root/file2.gif":
+		return &apb.PosixPermissions{PermissionNum: 02755, Uid: 1234, Group: "root"}, nil
+	case "/root/symlink":
+		return &apb.PosixPermissions{PermissionNum: 0777, User: "root", Group: "root"}, nil
+	case "/root/subdir/file3.txt":
+		return &apb.PosixPermissions{PermissionNum: 04711, User: "root", Gid: 1234}, nil
+	default:
+		return nil, os.ErrNotExist
+	}
+}
+
+func (fakePredicateReader) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	switch path {
+	case "/root", "/root/subdir":
+		return &apb.FileStat{Type: apb.FileStat_DIRECTORY, Size: 4096}, nil
+	case "/root/file1.txt", "/root/subdir/file3.txt":
+		return &apb.FileStat{Type: apb.FileStat_REGULAR_FILE, Size: 10}, nil
+	case "/root/file2.gif":
+		return &apb.FileStat{Type: apb.FileStat_REGULAR_FILE, Size: 20000}, nil
+	case "/root/symlink":
+		return &apb.FileStat{Type: apb.FileStat_SYMLINK, Size: 9}, nil
+	default:
+		return nil, os.ErrNotExist
+	}
+}
+
+func TestFilesMatchingPredicate(t *testing.T) {
+	testCases := []struct {
+		description   string
+		predicate     *ipb.FileSet_FilesMatchingPredicate
+		expectedPaths []string
+	}{
+		{
+			description:   "all bits set",
+			predicate:     &ipb.FileSet_FilesMatchingPredicate{DirPath: "/root", AllBitsSet: 04000},
+			expectedPaths: []string{"/root/file1.txt", "/root/subdir/file3.txt"},
+		},
+		{
+			description:   "any bits set",
+			predicate:     &ipb.FileSet_FilesMatchingPredicate{DirPath: "/root", AnyBitsSet: 06000},
+			expectedPaths: []string{"/root/file1.txt", "/root/file2.gif", "/root/subdir/file3.txt"},
+		},
+		{
+			description:   "no user",
+			predicate:     &ipb.FileSet_FilesMatchingPredicate{DirPath: "/root", NoUser: true},
+			expectedPaths: []string{"/root/file2.gif"},
+		},
+		{
+			description:   "no group",
+			predicate:     &ipb.FileSet_FilesMatchingPredicate{DirPath: "/root", NoGroup: true},
+			expectedPaths: []string{"/root/subdir/file3.txt"},
+		},
+		{
+			description: "file types",
+			predicate: &ipb.FileSet_FilesMatchingPredicate{
+				DirPath:   "/root",
+				FileTypes: []ipb.FileMetadataCheck_FileType{ipb.FileMetadataCheck_DIRECTORY, ipb.FileMetadataCheck_SYMLINK},
+			},
+			expectedPaths: []string{"/root", "/root/symlink", "/root/subdir"},
+		},
+		{
+			description: "size range",
+			predicate: &ipb.FileSet_FilesMatchingPredicate{
+				DirPath:      "/root",
+				MinSizeBytes: 10,
+				MaxSizeBytes: 10000,
+			},
+			expectedPaths: []string{"/root", "/root/file1.txt", "/root/subdir", "/root/subdir/file3.txt"},
+		},
+		{
+			description: "combined predicates",
+			predicate: &ipb.FileSet_FilesMatchingPredicate{
+				DirPath:    "/root",
+				AllBitsSet: 04000,
+				FileTypes:  []ipb.FileMetadataCheck_FileType{ipb.FileMetadataCheck_REGULAR_FILE},
+				NoGroup:    true,
+			},
+			expectedPaths: []string{"/root/subdir/file3.txt"},
+		},
+		{
+			description: "allowed paths are skipped",
+			predicate: &ipb.FileSet_FilesMatchingPredicate{
+				DirPath:            "/root",
+				AllBitsSet:         04000,
+				AllowedPathRegexes: []string{"/root/file1\\.txt"},
+			},
+			expectedPaths: []string{"/root/subdir/file3.txt"},
+		},
+		{
+			description: "opted out paths are not traversed",
+			predicate: &ipb.FileSet_FilesMatchingPredicate{
+				DirPath:           "/root",
+				AllBitsSet:        04000,
+				OptOutPathRegexes: []string{"/root/subdir"},
+			},
+			expectedPaths: []string{"/root/file1.txt"},
+		},
+		{
+			description:   "nonexistent dir",
+			predicate:     &ipb.FileSet_FilesMatchingPredicate{DirPath: "/nonexistent", AllBitsSet: 04000},
+			expectedPaths: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fileSet := &ipb.FileSet{
+				FilePath: &ipb.FileSet_FilesMatchingPredicate_{FilesMatchingPredicate: tc.predicate},
+			}
+			gotPaths := []string{}
+			err := fileset.WalkFiles(context.Background(), fileSet, &fakePredicateReader{}, time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error {
+				gotPaths = append(gotPaths, walkedPath)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("fileset.WalkFiles(%v) returned an error: %v", fileSet, err)
+			}
+			if diff := cmp.Diff(tc.expectedPaths, gotPaths); diff != "" {
+				t.Errorf("fileset.WalkFiles(%v) made an unexpected traversal diff (-want +got):\n%s",
+					fileSet, diff)
+			}
+		})
+	}
+}
+
+func TestFilesMatchingPredicateInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		description string
+		predicate   *ipb.FileSet_FilesMatchingPredicate
+	}{
+		{
+			description: "no predicates",
+			predicate:   &ipb.FileSet_FilesMatchingPredicate{DirPath: "/root"},
+		},
+		{
+			description: "invalid mode bits",
+			predicate:   &ipb.FileSet_FilesMatchingPredicate{DirPath: "/root", AllBitsSet: 010000},
+		},
+		{
+			description: "max size smaller than min size",
+			predicate:   &ipb.FileSet_FilesMatchingPredicate{DirPath: "/root", MinSizeBytes: 10, MaxSizeBytes: 5},
+		},
+		{
+			description: "unspecified file type",
+			predicate: &ipb.FileSet_FilesMatchingPredicate{
+				DirPath:   "/root",
+				FileTypes: []ipb.FileMetadataCheck_FileType{ipb.FileMetadataCheck_TYPE_UNSPECIFIED},
+			},
+		},
+		{
+			description: "invalid allow-list regex",
+			predicate: &ipb.FileSet_FilesMatchingPredicate{
+				DirPath:            "/root",
+				AllBitsSet:         04000,
+				AllowedPathRegexes: []string{"("},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fileSet := &ipb.FileSet{
+				FilePath: &ipb.FileSet_FilesMatchingPredicate_{FilesMatchingPredicate: tc.predicate},
+			}
+			err := fileset.WalkFiles(context.Background(), fileSet, &fakePredicateReader{}, time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error { return nil })
+			if err == nil {
+				t.Errorf("fileset.WalkFiles(%v) didn't return an error", fileSet)
+			}
+		})
+	}
+}
+
+// fakeGlobReader serves a fake dir structure from a map of directory paths
+// to their contents.
+type fakeGlobReader struct {
+	fakeDirectoryReader
+	dirs map[string][]*apb.DirContent
+}
+
+func (r fakeGlobReader) OpenDir(ctx context.Context, path string) (scanapi.DirReader, error) {
+	contents, ok := r.dirs[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return scanapi.SliceToDirReader(contents), nil
+}
+
+func newFakeGlobReader() *fakeGlobReader {
+	return &fakeGlobReader{dirs: map[string][]*apb.DirContent{
+		"/": {
+			{Name: "etc", IsDir: true},
+			{Name: "home", IsDir: true},
+		},
+		"/etc": {
+			{Name: "ssh", IsDir: true},
+			{Name: "passwd"},
+		},
+		"/etc/ssh": {
+			{Name: "sshd_config"},
+			{Name: "sshd_config.d", IsDir: true},
+		},
+		"/etc/ssh/sshd_config.d": {
+			{Name: "10-custom.conf"},
+			{Name: "20-other.conf"},
+			{Name: ".hidden.conf"},
+			{Name: "README"},
+		},
+		"/home": {
+			{Name: "alice", IsDir: true},
+			{Name: "bob", IsDir: true},
+			{Name: "carol", IsSymlink: true},
+		},
+		"/home/alice": {
+			{Name: ".ssh", IsDir: true},
+			{Name: "notes.txt"},
+		},
+		"/home/alice/.ssh": {
+			{Name: "authorized_keys"},
+		},
+		"/home/bob": {
+			{Name: "projects", IsDir: true},
+		},
+		"/home/bob/projects": {
+			{Name: "a", IsDir: true},
+			{Name: "notes.txt"},
+		},
+		"/home/bob/projects/a": {
+			{Name: "notes.txt"},
+		},
+		"/home/carol": {
+			{Name: ".ssh", IsDir: true},
+		},
+		"/home/carol/.ssh": {
+			{Name: "authorized_keys"},
+		},
+	}}
+}
+
+func TestGlob(t *testing.T) {
+	testCases := []struct {
+		description       string
+		glob              *ipb.FileSet_Glob
+		expectedTraversal []*traversal
+	}{
+		{
+			description: "wildcard in filename",
+			glob:        &ipb.FileSet_Glob{Pattern: "/etc/ssh/sshd_config.d/*.conf"},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/ssh/sshd_config.d/10-custom.conf", IsDir: false, TraversingDir: true},
+				{Path: "/etc/ssh/sshd_config.d/20-other.conf", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description: "hidden files matched explicitly",
+			glob:        &ipb.FileSet_Glob{Pattern: "/etc/ssh/sshd_config.d/.*"},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/ssh/sshd_config.d/.hidden.conf", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description: "wildcard in directory follows symlinks",
+			glob:        &ipb.FileSet_Glob{Pattern: "/home/*/.ssh/authorized_keys"},
+			expectedTraversal: []*traversal{
+				{Path: "/home/alice/.ssh/authorized_keys", IsDir: false, TraversingDir: true},
+				{Path: "/home/carol/.ssh/authorized_keys", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description: "question mark and character class",
+			glob:        &ipb.FileSet_Glob{Pattern: "/etc/ssh/sshd_config.d/[0-9]?-*"},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/ssh/sshd_config.d/10-custom.conf", IsDir: false, TraversingDir: true},
+				{Path: "/etc/ssh/sshd_config.d/20-other.conf", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description: "double star matches zero or more directories",
+			glob:        &ipb.FileSet_Glob{Pattern: "/home/**/notes.txt"},
+			expectedTraversal: []*traversal{
+				{Path: "/home/alice/notes.txt", IsDir: false, TraversingDir: true},
+				{Path: "/home/bob/projects/notes.txt", IsDir: false, TraversingDir: true},
+				{Path: "/home/bob/projects/a/notes.txt", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description: "trailing double star",
+			glob:        &ipb.FileSet_Glob{Pattern: "/home/bob/**"},
+			expectedTraversal: []*traversal{
+				{Path: "/home/bob/projects", IsDir: true, TraversingDir: true},
+				{Path: "/home/bob/projects/a", IsDir: true, TraversingDir: true},
+				{Path: "/home/bob/projects/a/notes.txt", IsDir: false, TraversingDir: true},
+				{Path: "/home/bob/projects/notes.txt", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description: "repeated double stars visit files once",
+			glob:        &ipb.FileSet_Glob{Pattern: "/home/**/**/notes.txt"},
+			expectedTraversal: []*traversal{
+				{Path: "/home/alice/notes.txt", IsDir: false, TraversingDir: true},
+				{Path: "/home/bob/projects/notes.txt", IsDir: false, TraversingDir: true},
+				{Path: "/home/bob/projects/a/notes.txt", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description: "literal path",
+			glob:        &ipb.FileSet_Glob{Pattern: "/etc/passwd"},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/passwd", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description:       "no matches",
+			glob:              &ipb.FileSet_Glob{Pattern: "/nonexistent/*/file"},
+			expectedTraversal: []*traversal{},
+		},
+		{
+			description: "opted out paths are skipped",
+			glob: &ipb.FileSet_Glob{
+				Pattern:           "/home/**/notes.txt",
+				OptOutPathRegexes: []string{"/home/bob/projects/a"},
+			},
+			expectedTraversal: []*traversal{
+				{Path: "/home/alice/notes.txt", IsDir: false, TraversingDir: true},
+				{Path: "/home/bob/projects/notes.txt", IsDir: false, TraversingDir: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fileSet := &ipb.FileSet{FilePath: &ipb.FileSet_Glob_{Glob: tc.glob}}
+			gotTraversal := []*traversal{}
+			err := fileset.WalkFiles(context.Background(), fileSet, newFakeGlobReader(), time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error {
+				gotTraversal = append(gotTraversal, &traversal{walkedPath, isDir, traversingDir})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("fileset.WalkFiles(%v) returned an error: %v", fileSet, err)
+			}
+			if diff := cmp.Diff(tc.expectedTraversal, gotTraversal); diff != "" {
+				t.Errorf("fileset.WalkFiles(%v) made an unexpected traversal diff (-want +got):\n%s",
+					fileSet, diff)
+			}
+		})
+	}
+}
+
+func TestGlobInvalidPatternsReturnError(t *testing.T) {
+	for _, pattern := range []string{"", "relative/*", "/", "/etc/[a-"} {
+		t.Run(pattern, func(t *testing.T) {
+			fileSet := &ipb.FileSet{FilePath: &ipb.FileSet_Glob_{Glob: &ipb.FileSet_Glob{Pattern: pattern}}}
+			err := fileset.WalkFiles(context.Background(), fileSet, newFakeGlobReader(), time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error { return nil })
+			if err == nil {
+				t.Errorf("fileset.WalkFiles(%v) didn't return an error", fileSet)
+			}
+		})
+	}
+}
+
+func TestGlobWithInfiniteLoop(t *testing.T) {
+	files := &ipb.FileSet{FilePath: &ipb.FileSet_Glob_{Glob: &ipb.FileSet_Glob{Pattern: "/**/file"}}}
+	err := fileset.WalkFiles(context.Background(), files, &infiniteLoopFSReader{}, time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error { return nil })
+	if err == nil {
+		t.Fatalf("fileset.WalkFiles(%v) didn't return an error", files)
+	}
+}
+
+// fakeIncludeReader extends fakeGlobReader with file contents.
+type fakeIncludeReader struct {
+	*fakeGlobReader
+	files map[string]string
+}
+
+func (r fakeIncludeReader) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	content, ok := r.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func newFakeIncludeReader() *fakeIncludeReader {
+	r := &fakeIncludeReader{
+		fakeGlobReader: newFakeGlobReader(),
+		files: map[string]string{
+			"/etc/sudoers":                "Defaults env_reset\n#includedir /etc/sudoers.d\n@include extra\n",
+			"/etc/sudoers.d/10-ok":        "%admin ALL=(ALL) ALL\n",
+			"/etc/sudoers.d/20-loop":      "#include /etc/sudoers\n",
+			"/etc/sudoers.d/old~":         "",
+			"/etc/extra":                  "",
+			"/etc/ld.so.conf":             "include /etc/ld.so.conf.d/*.conf\n",
+			"/etc/ld.so.conf.d/libc.conf": "/usr/local/lib\n",
+		},
+	}
+	r.dirs["/etc/sudoers.d"] = []*apb.DirContent{
+		{Name: "10-ok"},
+		{Name: "20-loop"},
+		{Name: "old~"},
+		{Name: "subdir", IsDir: true},
+	}
+	r.dirs["/etc/ld.so.conf.d"] = []*apb.DirContent{{Name: "libc.conf"}}
+	r.dirs["/etc"] = append(r.dirs["/etc"], &apb.DirContent{Name: "ld.so.conf.d", IsDir: true})
+	return r
+}
+
+func TestIncludedFiles(t *testing.T) {
+	sudoersDirectives := []*ipb.FileSet_IncludedFiles_IncludeDirective{
+		{
+			Regex:         `^[#@]includedir\s+(\S+)`,
+			DirectiveType: ipb.FileSet_IncludedFiles_IncludeDirective_DIR,
+			FilenameRegex: `[^.~]+`,
+		},
+		{
+			Regex:         `^[#@]include\s+(\S+)`,
+			DirectiveType: ipb.FileSet_IncludedFiles_IncludeDirective_FILE,
+		},
+	}
+	testCases := []struct {
+		description       string
+		includedFiles     *ipb.FileSet_IncludedFiles
+		expectedTraversal []*traversal
+	}{
+		{
+			description:   "included dirs and files with a cycle",
+			includedFiles: &ipb.FileSet_IncludedFiles{RootPath: "/etc/sudoers", Directives: sudoersDirectives},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/sudoers", IsDir: false, TraversingDir: false},
+				{Path: "/etc/sudoers.d/10-ok", IsDir: false, TraversingDir: true},
+				{Path: "/etc/sudoers.d/20-loop", IsDir: false, TraversingDir: true},
+				{Path: "/etc/extra", IsDir: false, TraversingDir: false},
+			},
+		},
+		{
+			description: "relative targets resolved against the base dir",
+			includedFiles: &ipb.FileSet_IncludedFiles{
+				RootPath:   "/etc/sudoers",
+				Directives: sudoersDirectives[1:],
+				BaseDir:    "/opt",
+			},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/sudoers", IsDir: false, TraversingDir: false},
+				{Path: "/opt/extra", IsDir: false, TraversingDir: false},
+			},
+		},
+		{
+			description: "opted out files are skipped",
+			includedFiles: &ipb.FileSet_IncludedFiles{
+				RootPath:          "/etc/sudoers",
+				Directives:        sudoersDirectives,
+				OptOutPathRegexes: []string{"/etc/sudoers.d/.*"},
+			},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/sudoers", IsDir: false, TraversingDir: false},
+				{Path: "/etc/extra", IsDir: false, TraversingDir: false},
+			},
+		},
+		{
+			description: "included globs",
+			includedFiles: &ipb.FileSet_IncludedFiles{
+				RootPath: "/etc/ld.so.conf",
+				Directives: []*ipb.FileSet_IncludedFiles_IncludeDirective{{
+					Regex:         `^include\s+(\S+)`,
+					DirectiveType: ipb.FileSet_IncludedFiles_IncludeDirective_GLOB,
+				}},
+			},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/ld.so.conf", IsDir: false, TraversingDir: false},
+				{Path: "/etc/ld.so.conf.d/libc.conf", IsDir: false, TraversingDir: true},
+			},
+		},
+		{
+			description:   "root file doesn't exist",
+			includedFiles: &ipb.FileSet_IncludedFiles{RootPath: "/etc/nonexistent", Directives: sudoersDirectives},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/nonexistent", IsDir: false, TraversingDir: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fileSet := &ipb.FileSet{FilePath: &ipb.FileSet_IncludedFiles_{IncludedFiles: tc.includedFiles}}
+			gotTraversal := []*traversal{}
+			err := fileset.WalkFiles(context.Background(), fileSet, newFakeIncludeReader(), time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error {
+				gotTraversal = append(gotTraversal, &traversal{walkedPath, isDir, traversingDir})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("fileset.WalkFiles(%v) returned an error: %v", fileSet, err)
+			}
+			if diff := cmp.Diff(tc.expectedTraversal, gotTraversal); diff != "" {
+				t.Errorf("fileset.WalkFiles(%v) made an unexpected traversal diff (-want +got):\n%s",
+					fileSet, diff)
+			}
+		})
+	}
+}
+
+func TestIncludedFilesInvalidInstructionsReturnError(t *testing.T) {
+	testCases := []struct {
+		description   string
+		includedFiles *ipb.FileSet_IncludedFiles
+	}{
+		{
+			description:   "relative root path",
+			includedFiles: &ipb.FileSet_IncludedFiles{RootPath: "etc/sudoers", Directives: []*ipb.FileSet_IncludedFiles_IncludeDirective{{Regex: "(.*)"}}},
+		},
+		{
+			description:   "no directives",
+			includedFiles: &ipb.FileSet_IncludedFiles{RootPath: "/etc/sudoers"},
+		},
+		{
+			description:   "no capture group",
+			includedFiles: &ipb.FileSet_IncludedFiles{RootPath: "/etc/sudoers", Directives: []*ipb.FileSet_IncludedFiles_IncludeDirective{{Regex: "include"}}},
+		},
+		{
+			description:   "invalid regex",
+			includedFiles: &ipb.FileSet_IncludedFiles{RootPath: "/etc/sudoers", Directives: []*ipb.FileSet_IncludedFiles_IncludeDirective{{Regex: "(("}}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			fileSet := &ipb.FileSet{FilePath: &ipb.FileSet_IncludedFiles_{IncludedFiles: tc.includedFiles}}
+			err := fileset.WalkFiles(context.Background(), fileSet, newFakeIncludeReader(), time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error { return nil })
+			if err == nil {
+				t.Errorf("fileset.WalkFiles(%v) didn't return an error", fileSet)
+			}
+		})
+	}
+}
+
+// fakePackageReader extends fakeIncludeReader with the file types of the
+// directories.
+type fakePackageReader struct {
+	*fakeIncludeReader
+}
+
+func (r fakePackageReader) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	if _, ok := r.dirs[path]; ok {
+		return &apb.FileStat{Type: apb.FileStat_DIRECTORY}, nil
+	}
+	return &apb.FileStat{Type: apb.FileStat_REGULAR_FILE}, nil
+}
+
+func TestPackageFiles(t *testing.T) {
+	testCases := []struct {
+		description       string
+		packageFiles      *ipb.FileSet_PackageFiles
+		expectedTraversal []*traversal
+	}{
+		{
+			description:  "all files",
+			packageFiles: &ipb.FileSet_PackageFiles{PackageName: "openssh-server"},
+			expectedTraversal: []*traversal{
+				{Path: "/etc", IsDir: true, TraversingDir: false},
+				{Path: "/etc/ssh", IsDir: true, TraversingDir: false},
+				{Path: "/etc/ssh/sshd_config", IsDir: false, TraversingDir: false},
+				{Path: "/usr/sbin/sshd", IsDir: false, TraversingDir: false},
+			},
+		},
+		{
+			description:  "files only",
+			packageFiles: &ipb.FileSet_PackageFiles{PackageName: "openssh-server", FilesOnly: true},
+			expectedTraversal: []*traversal{
+				{Path: "/etc/ssh/sshd_config", IsDir: false, TraversingDir: false},
+				{Path: "/usr/sbin/sshd", IsDir: false, TraversingDir: false},
+			},
+		},
+		{
+			description: "opted out files",
+			packageFiles: &ipb.FileSet_PackageFiles{
+				PackageName:       "openssh-server",
+				PackageManager:    ipb.FileSet_PackageFiles_DPKG,
+				OptOutPathRegexes: []string{"/etc.*"},
+			},
+			expectedTraversal: []*traversal{
+				{Path: "/usr/sbin/sshd", IsDir: false, TraversingDir: false},
+			},
+		},
+		{
+			description:       "package not installed",
+			packageFiles:      &ipb.FileSet_PackageFiles{PackageName: "telnetd"},
+			expectedTraversal: []*traversal{},
+		},
+		{
+			description:       "no rpm database",
+			packageFiles:      &ipb.FileSet_PackageFiles{PackageName: "openssh-server", PackageManager: ipb.FileSet_PackageFiles_RPM},
+			expectedTraversal: []*traversal{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r := fakePackageReader{newFakeIncludeReader()}
+			r.files["/var/lib/dpkg/info/openssh-server.list"] = "/.\n/etc\n/etc/ssh\n/etc/ssh/sshd_config\n/usr/sbin/sshd\n"
+			r.dirs["/var/lib/dpkg/info"] = []*apb.DirContent{{Name: "openssh-server.list"}}
+			fileSet := &ipb.FileSet{FilePath: &ipb.FileSet_PackageFiles_{PackageFiles: tc.packageFiles}}
+			gotTraversal := []*traversal{}
+			err := fileset.WalkFiles(context.Background(), fileSet, r, time.Time{}, func(walkedPath string, isDir bool, traversingDir bool) error {
+				gotTraversal = append(gotTraversal, &traversal{walkedPath, isDir, traversingDir})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("fileset.WalkFiles(%v) returned an error: %v", fileSet, err)
+			}
+			if diff := cmp.Diff(tc.expectedTraversal, gotTraversal); diff != "" {
+				t.Errorf("fileset.WalkFiles(%v) made an unexpected traversal diff (-want +got):\n%s",
+					fileSet, diff)
+			}
+		})
+	}
+}
+
 func TestTimeout(t *testing.T) {
 	testCases := []struct {
 		description string
@@ -687,6 +1337,26 @@ func TestApplyReplacementConfig(t *testing.T) {
 				FilePath: &ipb.FileSet_FilesInDir_{FilesInDir: &ipb.FileSet_FilesInDir{DirPath: "/new/path"}},
 			},
 		},
+		{
+			name:   "Replace prefix in FilesMatchingPredicate",
+			config: &apb.ReplacementConfig{PathPrefixReplacements: replacements},
+			fileSet: &ipb.FileSet{
+				FilePath: &ipb.FileSet_FilesMatchingPredicate_{FilesMatchingPredicate: &ipb.FileSet_FilesMatchingPredicate{DirPath: "/old/path"}},
+			},
+			want: &ipb.FileSet{
+				FilePath: &ipb.FileSet_FilesMatchingPredicate_{FilesMatchingPredicate: &ipb.FileSet_FilesMatchingPredicate{DirPath: "/new/path"}},
+			},
+		},
+		{
+			name:   "Replace prefix in Glob",
+			config: &apb.ReplacementConfig{PathPrefixReplacements: replacements},
+			fileSet: &ipb.FileSet{
+				FilePath: &ipb.FileSet_Glob_{Glob: &ipb.FileSet_Glob{Pattern: "/old/*/file"}},
+			},
+			want: &ipb.FileSet{
+				FilePath: &ipb.FileSet_Glob_{Glob: &ipb.FileSet_Glob{Pattern: "/new/*/file"}},
+			},
+		},
 		{
 			name:   "Replacements with leading slashes",
 			config: &apb.ReplacementConfig{PathPrefixReplacements: map[string]string{"/old/": "/new/"}},
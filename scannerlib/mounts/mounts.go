@@ -0,0 +1,459 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mounts provides utilities for reading the mounted and the configured
+// filesystems of the scanned machine.
+package mounts
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/localtoast/scanapi"
+)
+
+const (
+	// MountInfoPath is the path of the file listing the mounts of the running system.
+	MountInfoPath = "/proc/self/mountinfo"
+	// FstabPath is the path of the static filesystem configuration file.
+	FstabPath = "/etc/fstab"
+)
+
+// systemdUnitDirs lists the directories systemd loads units from. If units
+// with the same name exist in several directories, the one in the directory
+// listed first takes precedence.
+var systemdUnitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/system",
+	"/usr/local/lib/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+// systemdAdminUnitDirs are the unit directories whose units are considered
+// to be in use even if they're not enabled through a .wants directory.
+var systemdAdminUnitDirs = map[string]bool{
+	"/etc/systemd/system": true,
+	"/run/systemd/system": true,
+}
+
+// Mount describes a single mounted or configured filesystem.
+type Mount struct {
+	// The device or other source of the filesystem, e.g. "/dev/sda1" or "tmpfs".
+	Source string
+	// The path the filesystem is mounted on.
+	MountPoint string
+	// The type of the filesystem, e.g. "ext4".
+	FSType string
+	// The mount options, e.g. ["rw", "nodev"].
+	Options []string
+	// The file the mount was read from.
+	ConfigFile string
+}
+
+// defaultOptions are the options the "defaults" option of fstab stands for.
+var defaultOptions = []string{"rw", "suid", "dev", "exec", "auto", "nouser", "async"}
+
+// opposingOptions maps options to the options they override.
+var opposingOptions = map[string]string{
+	"rw":     "ro",
+	"ro":     "rw",
+	"suid":   "nosuid",
+	"nosuid": "suid",
+	"dev":    "nodev",
+	"nodev":  "dev",
+	"exec":   "noexec",
+	"noexec": "exec",
+	"auto":   "noauto",
+	"noauto": "auto",
+	"user":   "nouser",
+	"nouser": "user",
+	"async":  "sync",
+	"sync":   "async",
+}
+
+// HasOption returns whether the mount has the given option set. An option
+// without a value also matches options of the form "option=value". The
+// "defaults" option is expanded to the options it stands for, which later
+// options can override.
+func (m *Mount) HasOption(option string) bool {
+	for _, o := range m.effectiveOptions() {
+		if o == option {
+			return true
+		}
+		if !strings.Contains(option, "=") && strings.HasPrefix(o, option+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveOptions returns the options of the mount with "defaults" expanded
+// and the options overridden by later ones removed.
+func (m *Mount) effectiveOptions() []string {
+	result := []string{}
+	for _, o := range m.Options {
+		added := []string{o}
+		if o == "defaults" {
+			added = append(added, defaultOptions...)
+		}
+		for _, a := range added {
+			if opposing, ok := opposingOptions[a]; ok {
+				result = removeOption(result, opposing)
+			}
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func removeOption(options []string, option string) []string {
+	result := options[:0]
+	for _, o := range options {
+		if o != option {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// FindMount returns the mount for the given mount point or nil if the mount
+// point is not a separate mount. If there are several mounts on the same
+// mount point, the last one is returned since it shadows the others.
+func FindMount(mounts []*Mount, mountPoint string) *Mount {
+	mountPoint = path.Clean(mountPoint)
+	var result *Mount
+	for _, m := range mounts {
+		if path.Clean(m.MountPoint) == mountPoint {
+			result = m
+		}
+	}
+	return result
+}
+
+// ReadMountInfo returns the mounts of the running system.
+func ReadMountInfo(ctx context.Context, fs scanapi.Filesystem) ([]*Mount, error) {
+	f, err := fs.OpenFile(ctx, MountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// ReadConfiguredMounts returns the mounts configured in /etc/fstab and in the
+// systemd .mount units in use. Mounts defined by systemd units are listed after
+// the ones from /etc/fstab.
+func ReadConfiguredMounts(ctx context.Context, fs scanapi.Filesystem) ([]*Mount, error) {
+	result := []*Mount{}
+	f, err := fs.OpenFile(ctx, FstabPath)
+	if err == nil {
+		defer f.Close()
+		fstabMounts, err := ParseFstab(f)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, fstabMounts...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	unitMounts, err := readSystemdMountUnits(ctx, fs)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, unitMounts...), nil
+}
+
+// ParseMountInfo parses the mounts from the contents of a
+// /proc/<pid>/mountinfo file. The per-mount options and the superblock
+// options are merged into a single option list.
+func ParseMountInfo(r io.Reader) ([]*Mount, error) {
+	result := []*Mount{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		// Format: ID parentID major:minor root mountpoint options [optional fields...] - fstype source superoptions
+		fields := strings.Fields(line)
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 6 || sep == -1 || len(fields) < sep+3 {
+			return nil, fmt.Errorf("invalid mountinfo line %q", line)
+		}
+		options := strings.Split(fields[5], ",")
+		if len(fields) > sep+3 {
+			for _, o := range strings.Split(fields[sep+3], ",") {
+				if !containsString(options, o) {
+					options = append(options, o)
+				}
+			}
+		}
+		result = append(result, &Mount{
+			Source:     unescapeOctal(fields[sep+2]),
+			MountPoint: unescapeOctal(fields[4]),
+			FSType:     fields[sep+1],
+			Options:    options,
+			ConfigFile: MountInfoPath,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ParseFstab parses the mounts from the contents of an fstab file. Swap
+// entries are skipped.
+func ParseFstab(r io.Reader) ([]*Mount, error) {
+	result := []*Mount{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Format: source mountpoint fstype [options [dump [pass]]]
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid fstab line %q", line)
+		}
+		if fields[2] == "swap" || fields[1] == "none" {
+			continue
+		}
+		options := []string{"defaults"}
+		if len(fields) > 3 {
+			options = strings.Split(fields[3], ",")
+		}
+		result = append(result, &Mount{
+			Source:     unescapeOctal(fields[0]),
+			MountPoint: unescapeOctal(fields[1]),
+			FSType:     fields[2],
+			Options:    options,
+			ConfigFile: FstabPath,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ParseSystemdMountUnit parses the mount described in the [Mount] section of
+// a systemd .mount unit file.
+func ParseSystemdMountUnit(r io.Reader) (*Mount, error) {
+	result := &Mount{}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line
+			continue
+		}
+		if section != "[Mount]" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "What":
+			result.Source = value
+		case "Where":
+			result.MountPoint = value
+		case "Type":
+			result.FSType = value
+		case "Options":
+			if value == "" {
+				// An empty assignment resets the list.
+				result.Options = nil
+				continue
+			}
+			result.Options = append(result.Options, strings.Split(value, ",")...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if result.MountPoint == "" {
+		return nil, errors.New("mount unit has no Where= setting")
+	}
+	return result, nil
+}
+
+// readSystemdMountUnits returns the mounts defined by the systemd .mount units
+// that are in use, i.e. that are either defined by the admin in /etc or /run
+// or enabled through a .wants directory.
+func readSystemdMountUnits(ctx context.Context, fs scanapi.Filesystem) ([]*Mount, error) {
+	units := make(map[string]string) // Unit name -> full path
+	enabled := make(map[string]bool)
+	for _, dir := range systemdUnitDirs {
+		d, err := fs.OpenDir(ctx, dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		entries, err := scanapi.DirReaderToSlice(d)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.GetIsDir() && strings.HasSuffix(e.GetName(), ".wants") {
+				wanted, err := readDirNames(ctx, fs, path.Join(dir, e.GetName()))
+				if err != nil {
+					return nil, err
+				}
+				for _, w := range wanted {
+					enabled[w] = true
+				}
+				continue
+			}
+			if e.GetIsDir() || !strings.HasSuffix(e.GetName(), ".mount") {
+				continue
+			}
+			if _, ok := units[e.GetName()]; ok {
+				continue
+			}
+			units[e.GetName()] = path.Join(dir, e.GetName())
+			if systemdAdminUnitDirs[dir] {
+				enabled[e.GetName()] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(units))
+	for name := range units {
+		if enabled[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	result := make([]*Mount, 0, len(names))
+	for _, name := range names {
+		content, err := readUnit(ctx, fs, units[name])
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// Dangling unit.
+				continue
+			}
+			return nil, err
+		}
+		if content == nil {
+			// Masked unit. Since the unit with the highest precedence was picked
+			// above, the same-named units it masks are ignored too.
+			continue
+		}
+		m, err := ParseSystemdMountUnit(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", units[name], err)
+		}
+		m.ConfigFile = units[name]
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// readUnit returns the content of the systemd unit file or nil if the unit is
+// masked, i.e. it's a symlink to /dev/null or an empty file.
+func readUnit(ctx context.Context, fs scanapi.Filesystem, unitPath string) ([]byte, error) {
+	stat, err := fs.FileStat(ctx, unitPath)
+	if err != nil {
+		return nil, err
+	}
+	if stat.GetLinkTarget() == "/dev/null" {
+		return nil, nil
+	}
+	f, err := fs.OpenFile(ctx, unitPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, nil
+	}
+	return content, nil
+}
+
+func readDirNames(ctx context.Context, fs scanapi.Filesystem, dirPath string) ([]string, error) {
+	d, err := fs.OpenDir(ctx, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := scanapi.DirReaderToSlice(d)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.GetName())
+	}
+	return names, nil
+}
+
+// unescapeOctal replaces the octal escape sequences (e.g. "\040" for space)
+// used in fstab and mountinfo files with the characters they represent.
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,309 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mounts_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/mounts"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// fakeFilesystem serves the files from a map of file paths to contents.
+// Directories are derived from the parent paths of the files. Symlinks are
+// listed in links and can only point to files or /dev/null.
+type fakeFilesystem struct {
+	files map[string]string
+	links map[string]string
+}
+
+func (f *fakeFilesystem) OpenFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	if target, ok := f.links[filePath]; ok {
+		if target == "/dev/null" {
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+		filePath = target
+	}
+	content, ok := f.files[filePath]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+func (f *fakeFilesystem) OpenDir(ctx context.Context, dirPath string) (scanapi.DirReader, error) {
+	prefix := dirPath + "/"
+	entries := make(map[string]bool)
+	paths := make([]string, 0, len(f.files)+len(f.links))
+	for p := range f.files {
+		paths = append(paths, p)
+	}
+	for p := range f.links {
+		paths = append(paths, p)
+	}
+	for _, p := range paths {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(p, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			entries[rest[:i]] = true
+		} else {
+			entries[rest] = false
+		}
+	}
+	if len(entries) == 0 {
+		return nil, os.ErrNotExist
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	contents := make([]*apb.DirContent, 0, len(names))
+	for _, name := range names {
+		contents = append(contents, &apb.DirContent{Name: name, IsDir: entries[name]})
+	}
+	return scanapi.SliceToDirReader(contents), nil
+}
+
+func (f *fakeFilesystem) FilePermissions(ctx context.Context, filePath string) (*apb.PosixPermissions, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	if target, ok := f.links[filePath]; ok {
+		return &apb.FileStat{Type: apb.FileStat_SYMLINK, LinkTarget: target}, nil
+	}
+	if _, ok := f.files[filePath]; ok {
+		return &apb.FileStat{Type: apb.FileStat_REGULAR_FILE}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (f *fakeFilesystem) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestParseMountInfo(t *testing.T) {
+	content := "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro\n" +
+		"25 22 0:21 / /tmp rw,nosuid,nodev - tmpfs tmpfs rw,size=1024k\n" +
+		"26 22 0:22 / /mnt/my\\040disk rw - vfat /dev/sdb1 rw\n"
+	want := []*mounts.Mount{
+		{
+			Source:     "/dev/sda1",
+			MountPoint: "/",
+			FSType:     "ext4",
+			Options:    []string{"rw", "relatime", "errors=remount-ro"},
+			ConfigFile: mounts.MountInfoPath,
+		},
+		{
+			Source:     "tmpfs",
+			MountPoint: "/tmp",
+			FSType:     "tmpfs",
+			Options:    []string{"rw", "nosuid", "nodev", "size=1024k"},
+			ConfigFile: mounts.MountInfoPath,
+		},
+		{
+			Source:     "/dev/sdb1",
+			MountPoint: "/mnt/my disk",
+			FSType:     "vfat",
+			Options:    []string{"rw"},
+			ConfigFile: mounts.MountInfoPath,
+		},
+	}
+	got, err := mounts.ParseMountInfo(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("mounts.ParseMountInfo(%q) returned an error: %v", content, err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mounts.ParseMountInfo(%q) returned unexpected diff (-want +got):\n%s", content, diff)
+	}
+}
+
+func TestParseMountInfoInvalidLine(t *testing.T) {
+	content := "22 1 8:1 / / rw,relatime shared:1 ext4 /dev/sda1 rw\n"
+	if _, err := mounts.ParseMountInfo(strings.NewReader(content)); err == nil {
+		t.Errorf("mounts.ParseMountInfo(%q) didn't return an error", content)
+	}
+}
+
+func TestParseFstab(t *testing.T) {
+	content := "# <file system> <mount point> <type> <options> <dump> <pass>\n" +
+		"UUID=1234 / ext4 errors=remount-ro 0 1\n" +
+		"/swapfile none swap sw 0 0\n" +
+		"\n" +
+		"tmpfs /tmp tmpfs\n" +
+		"tmpfs  /dev/shm  tmpfs  defaults,nodev,nosuid,noexec  0 0\n"
+	want := []*mounts.Mount{
+		{
+			Source:     "UUID=1234",
+			MountPoint: "/",
+			FSType:     "ext4",
+			Options:    []string{"errors=remount-ro"},
+			ConfigFile: mounts.FstabPath,
+		},
+		{
+			Source:     "tmpfs",
+			MountPoint: "/tmp",
+			FSType:     "tmpfs",
+			Options:    []string{"defaults"},
+			ConfigFile: mounts.FstabPath,
+		},
+		{
+			Source:     "tmpfs",
+			MountPoint: "/dev/shm",
+			FSType:     "tmpfs",
+			Options:    []string{"defaults", "nodev", "nosuid", "noexec"},
+			ConfigFile: mounts.FstabPath,
+		},
+	}
+	got, err := mounts.ParseFstab(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("mounts.ParseFstab(%q) returned an error: %v", content, err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mounts.ParseFstab(%q) returned unexpected diff (-want +got):\n%s", content, diff)
+	}
+}
+
+func TestReadConfiguredMounts(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		"/etc/fstab": "tmpfs /tmp tmpfs defaults 0 0\n",
+		"/etc/systemd/system/var-tmp.mount": "[Unit]\nDescription=/var/tmp\n\n" +
+			"[Mount]\nWhat=tmpfs\nWhere=/var/tmp\nType=tmpfs\nOptions=mode=1777,strictatime\nOptions=nodev\n",
+		// Enabled through a .wants directory.
+		"/usr/lib/systemd/system/tmp.mount":                   "[Mount]\nWhat=tmpfs\nWhere=/tmp\nType=tmpfs\nOptions=nodev,nosuid\n",
+		"/etc/systemd/system/local-fs.target.wants/tmp.mount": "",
+		// Not enabled.
+		"/usr/lib/systemd/system/dev-hugepages.mount": "[Mount]\nWhat=hugetlbfs\nWhere=/dev/hugepages\nType=hugetlbfs\n",
+	}}
+	want := []*mounts.Mount{
+		{
+			Source:     "tmpfs",
+			MountPoint: "/tmp",
+			FSType:     "tmpfs",
+			Options:    []string{"defaults"},
+			ConfigFile: mounts.FstabPath,
+		},
+		{
+			Source:     "tmpfs",
+			MountPoint: "/tmp",
+			FSType:     "tmpfs",
+			Options:    []string{"nodev", "nosuid"},
+			ConfigFile: "/usr/lib/systemd/system/tmp.mount",
+		},
+		{
+			Source:     "tmpfs",
+			MountPoint: "/var/tmp",
+			FSType:     "tmpfs",
+			Options:    []string{"mode=1777", "strictatime", "nodev"},
+			ConfigFile: "/etc/systemd/system/var-tmp.mount",
+		},
+	}
+	got, err := mounts.ReadConfiguredMounts(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("mounts.ReadConfiguredMounts() returned an error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mounts.ReadConfiguredMounts() returned unexpected diff (-want +got):\n%s", diff)
+	}
+	if m := mounts.FindMount(got, "/tmp/"); m == nil || m.ConfigFile != want[1].ConfigFile {
+		t.Errorf("mounts.FindMount(%v, /tmp/) returned %v, want %v", got, m, want[1])
+	}
+}
+
+func TestReadConfiguredMountsSkipsMaskedUnits(t *testing.T) {
+	fs := &fakeFilesystem{
+		files: map[string]string{
+			"/usr/lib/systemd/system/tmp.mount":                       "[Mount]\nWhat=tmpfs\nWhere=/tmp\nType=tmpfs\n",
+			"/etc/systemd/system/local-fs.target.wants/tmp.mount":     "",
+			"/usr/lib/systemd/system/var-tmp.mount":                   "[Mount]\nWhat=tmpfs\nWhere=/var/tmp\nType=tmpfs\n",
+			"/etc/systemd/system/local-fs.target.wants/var-tmp.mount": "",
+			// Masked through an empty file.
+			"/etc/systemd/system/var-tmp.mount": "",
+			"/run/systemd/system/home.mount":    "[Mount]\nWhat=/dev/sda2\nWhere=/home\nType=ext4\n",
+		},
+		links: map[string]string{
+			"/etc/systemd/system/tmp.mount": "/dev/null",
+		},
+	}
+	want := []*mounts.Mount{
+		{
+			Source:     "/dev/sda2",
+			MountPoint: "/home",
+			FSType:     "ext4",
+			ConfigFile: "/run/systemd/system/home.mount",
+		},
+	}
+	got, err := mounts.ReadConfiguredMounts(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("mounts.ReadConfiguredMounts() returned an error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mounts.ReadConfiguredMounts() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestHasOption(t *testing.T) {
+	m := &mounts.Mount{Options: []string{"rw", "nodev", "size=2G"}}
+	testCases := []struct {
+		option string
+		want   bool
+	}{
+		{option: "nodev", want: true},
+		{option: "noexec", want: false},
+		{option: "size", want: true},
+		{option: "size=2G", want: true},
+		{option: "size=1G", want: false},
+		{option: "no", want: false},
+	}
+	for _, tc := range testCases {
+		if got := m.HasOption(tc.option); got != tc.want {
+			t.Errorf("%v.HasOption(%q) = %t, want %t", m, tc.option, got, tc.want)
+		}
+	}
+}
+
+func TestHasOptionExpandsDefaults(t *testing.T) {
+	m := &mounts.Mount{Options: []string{"defaults", "nodev", "ro"}}
+	testCases := []struct {
+		option string
+		want   bool
+	}{
+		{option: "defaults", want: true},
+		{option: "suid", want: true},
+		{option: "exec", want: true},
+		{option: "nodev", want: true},
+		{option: "dev", want: false},
+		{option: "ro", want: true},
+		{option: "rw", want: false},
+		{option: "noexec", want: false},
+	}
+	for _, tc := range testCases {
+		if got := m.HasOption(tc.option); got != tc.want {
+			t.Errorf("%v.HasOption(%q) = %t, want %t", m, tc.option, got, tc.want)
+		}
+	}
+}
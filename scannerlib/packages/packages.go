@@ -0,0 +1,311 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package packages provides utilities for reading the dpkg and rpm packages
+// installed on the scanned machine and the files they install.
+package packages
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/localtoast/scanapi"
+)
+
+const (
+	// DpkgInfoDir is the directory containing dpkg's per-package metadata.
+	DpkgInfoDir = "/var/lib/dpkg/info"
+	// DpkgStatOverridePath is the path of dpkg's ownership and mode overrides.
+	DpkgStatOverridePath = "/var/lib/dpkg/statoverride"
+	// DpkgStatusPath is the path of dpkg's database of package states.
+	DpkgStatusPath = "/var/lib/dpkg/status"
+)
+
+// Package is an installed package.
+type Package struct {
+	Name string
+	// The full version, e.g. "1:2.3-4" for dpkg or "2.3-4.el9" for rpm.
+	Version string
+}
+
+// FileType is the type of a packaged file.
+type FileType int
+
+// The file types packages can describe.
+const (
+	// UnknownType is used if the package metadata doesn't include file types.
+	UnknownType FileType = iota
+	RegularFile
+	Directory
+	Symlink
+	OtherType
+)
+
+// File is a file installed by a package.
+type File struct {
+	Path string
+	Type FileType
+	// The packaged permission bits, -1 if unknown.
+	Mode int32
+	// The packaged owner and group names, "" if unknown.
+	User  string
+	Group string
+	// The hex-encoded digest of the packaged content, "" if unknown or if the
+	// file isn't a regular file.
+	Digest string
+	// The hash function of the digest, e.g. "md5" or "sha256".
+	DigestAlgorithm string
+	// Whether the file is a configuration file that's expected to be edited.
+	IsConfig bool
+}
+
+// Reader reads the installed packages and their files from the package
+// databases of the scanned machine. The rpm database is only read and parsed
+// once, so the checks of a scan should share a Reader.
+type Reader struct {
+	fs         scanapi.Filesystem
+	rpmOnce    sync.Once
+	rpmHeaders []*rpmHeader
+	rpmErr     error
+}
+
+// NewReader creates a Reader that reads the package databases through the
+// given filesystem.
+func NewReader(fs scanapi.Filesystem) *Reader {
+	return &Reader{fs: fs}
+}
+
+// ReadInstalledPackages returns the packages installed by dpkg and rpm. Returns
+// an empty list if neither package database exists.
+func ReadInstalledPackages(ctx context.Context, fs scanapi.Filesystem) ([]*Package, error) {
+	return NewReader(fs).InstalledPackages(ctx)
+}
+
+// InstalledPackages returns the packages installed by dpkg and rpm. Returns an
+// empty list if neither package database exists.
+func (r *Reader) InstalledPackages(ctx context.Context) ([]*Package, error) {
+	result, err := readDpkgPackages(ctx, r.fs)
+	if err != nil {
+		return nil, err
+	}
+	rpmPackages, err := r.readRpmPackages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, rpmPackages...), nil
+}
+
+// readDpkgPackages parses the stanzas of the dpkg status database and returns
+// the packages in the "installed" state.
+func readDpkgPackages(ctx context.Context, fs scanapi.Filesystem) ([]*Package, error) {
+	f, err := fs.OpenFile(ctx, DpkgStatusPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	result := []*Package{}
+	var name, version, status string
+	addPackage := func() {
+		if name != "" && strings.HasSuffix(status, " installed") {
+			result = append(result, &Package{Name: name, Version: version})
+		}
+		name, version, status = "", "", ""
+	}
+	scanner := bufio.NewScanner(f)
+	// The descriptions of some packages have long lines.
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if strings.TrimSpace(l) == "" {
+			addPackage()
+			continue
+		}
+		key, value, found := strings.Cut(l, ":")
+		// Skip the continuation lines of multi-line fields.
+		if !found || strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t") {
+			continue
+		}
+		switch key {
+		case "Package":
+			name = strings.TrimSpace(value)
+		case "Version":
+			version = strings.TrimSpace(value)
+		case "Status":
+			status = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", DpkgStatusPath, err)
+	}
+	addPackage()
+	return result, nil
+}
+
+// ReadDpkgPackageFiles returns the files installed by the given dpkg package.
+// Returns an empty list if the package isn't installed. dpkg only records the
+// MD5 digests of the files and the mode and ownership from dpkg-statoverride.
+func ReadDpkgPackageFiles(ctx context.Context, fs scanapi.Filesystem, name string) ([]*File, error) {
+	infoPrefix, err := findDpkgInfoPrefix(ctx, fs, name)
+	if err != nil || infoPrefix == "" {
+		return nil, err
+	}
+	paths, err := readLines(ctx, fs, infoPrefix+".list")
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string)
+	md5sums, err := readLines(ctx, fs, infoPrefix+".md5sums")
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range md5sums {
+		digest, filePath, found := strings.Cut(l, "  ")
+		if !found {
+			return nil, fmt.Errorf("%s.md5sums: invalid line %q", infoPrefix, l)
+		}
+		digests[path.Join("/", filePath)] = digest
+	}
+	conffiles := make(map[string]bool)
+	conffileLines, err := readLines(ctx, fs, infoPrefix+".conffiles")
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range conffileLines {
+		// Obsolete conffiles are followed by a flag, e.g. "/etc/foo obsolete".
+		conffiles[strings.Fields(l)[0]] = true
+	}
+	overrides, err := readDpkgStatOverrides(ctx, fs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*File, 0, len(paths))
+	for _, p := range paths {
+		if p == "/." {
+			continue
+		}
+		f := &File{Path: p, Mode: -1, IsConfig: conffiles[p]}
+		if digest, ok := digests[p]; ok {
+			f.Type = RegularFile
+			f.Digest = digest
+			f.DigestAlgorithm = "md5"
+		}
+		if o, ok := overrides[p]; ok {
+			f.User, f.Group, f.Mode = o.User, o.Group, o.Mode
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// DpkgPackageFiles returns the files installed by the given dpkg package, see
+// ReadDpkgPackageFiles.
+func (r *Reader) DpkgPackageFiles(ctx context.Context, name string) ([]*File, error) {
+	return ReadDpkgPackageFiles(ctx, r.fs, name)
+}
+
+// findDpkgInfoPrefix returns the path of the package's metadata files without
+// the extension. Packages of foreign architectures have the architecture in
+// the name, e.g. "libc6:i386.list". Returns "" if the package isn't installed.
+func findDpkgInfoPrefix(ctx context.Context, fs scanapi.Filesystem, name string) (string, error) {
+	prefix := path.Join(DpkgInfoDir, name)
+	f, err := fs.OpenFile(ctx, prefix+".list")
+	if err == nil {
+		f.Close()
+		return prefix, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	d, err := fs.OpenDir(ctx, DpkgInfoDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer d.Close()
+	for d.Next() {
+		e, err := d.Entry()
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(e.GetName(), name+":") && strings.HasSuffix(e.GetName(), ".list") {
+			return path.Join(DpkgInfoDir, strings.TrimSuffix(e.GetName(), ".list")), nil
+		}
+	}
+	return "", nil
+}
+
+type statOverride struct {
+	User  string
+	Group string
+	Mode  int32
+}
+
+// readDpkgStatOverrides parses the "user group mode path" lines of the
+// dpkg-statoverride database.
+func readDpkgStatOverrides(ctx context.Context, fs scanapi.Filesystem) (map[string]*statOverride, error) {
+	lines, err := readLines(ctx, fs, DpkgStatOverridePath)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*statOverride)
+	for _, l := range lines {
+		tokens := strings.Fields(l)
+		if len(tokens) != 4 {
+			return nil, fmt.Errorf("%s: invalid line %q", DpkgStatOverridePath, l)
+		}
+		mode, err := strconv.ParseInt(tokens[2], 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid mode in line %q: %v", DpkgStatOverridePath, l, err)
+		}
+		result[tokens[3]] = &statOverride{User: tokens[0], Group: tokens[1], Mode: int32(mode) & 07777}
+	}
+	return result, nil
+}
+
+// readLines returns the non-empty lines of a file, or nothing if the file
+// doesn't exist.
+func readLines(ctx context.Context, fs scanapi.Filesystem, filePath string) ([]string, error) {
+	f, err := fs.OpenFile(ctx, filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	result := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if l := strings.TrimSpace(scanner.Text()); l != "" {
+			result = append(result, l)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", filePath, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,349 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/packages"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+)
+
+// fakeFilesystem serves the files from a map of file paths to contents and
+// lists the files of directories.
+type fakeFilesystem struct {
+	files map[string]string
+}
+
+func (f *fakeFilesystem) OpenFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	content, ok := f.files[filePath]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+func (f *fakeFilesystem) OpenDir(ctx context.Context, dirPath string) (scanapi.DirReader, error) {
+	contents := []*apb.DirContent{}
+	for p := range f.files {
+		if name, found := strings.CutPrefix(p, dirPath+"/"); found && !strings.Contains(name, "/") {
+			contents = append(contents, &apb.DirContent{Name: name})
+		}
+	}
+	if len(contents) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return scanapi.SliceToDirReader(contents), nil
+}
+
+func (f *fakeFilesystem) FilePermissions(ctx context.Context, filePath string) (*apb.PosixPermissions, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileStat(ctx context.Context, filePath string) (*apb.FileStat, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileXattr(ctx context.Context, filePath string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestReadDpkgPackageFiles(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		"/var/lib/dpkg/info/openssh-server.list": "/.\n/etc\n/etc/ssh/sshd_config.d\n/usr/sbin/sshd\n/etc/ssh/moduli\n",
+		"/var/lib/dpkg/info/openssh-server.md5sums": "0123456789abcdef0123456789abcdef  usr/sbin/sshd\n" +
+			"fedcba9876543210fedcba9876543210  etc/ssh/moduli\n",
+		"/var/lib/dpkg/info/openssh-server.conffiles": "/etc/ssh/moduli\n/etc/ssh/old remove-on-upgrade\n",
+		"/var/lib/dpkg/info/libc6:i386.list":          "/lib/i386-linux-gnu/libc.so.6\n",
+		packages.DpkgStatOverridePath:                 "root sshd 0750 /etc/ssh/sshd_config.d\n",
+	}}
+	testCases := []struct {
+		pkg  string
+		want []*packages.File
+	}{
+		{
+			pkg: "openssh-server",
+			want: []*packages.File{
+				{Path: "/etc", Mode: -1},
+				{Path: "/etc/ssh/sshd_config.d", Mode: 0750, User: "root", Group: "sshd"},
+				{Path: "/usr/sbin/sshd", Type: packages.RegularFile, Mode: -1, Digest: "0123456789abcdef0123456789abcdef", DigestAlgorithm: "md5"},
+				{Path: "/etc/ssh/moduli", Type: packages.RegularFile, Mode: -1, Digest: "fedcba9876543210fedcba9876543210", DigestAlgorithm: "md5", IsConfig: true},
+			},
+		},
+		{
+			pkg:  "libc6",
+			want: []*packages.File{{Path: "/lib/i386-linux-gnu/libc.so.6", Mode: -1}},
+		},
+		{
+			pkg:  "not-installed",
+			want: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pkg, func(t *testing.T) {
+			got, err := packages.ReadDpkgPackageFiles(context.Background(), fs, tc.pkg)
+			if err != nil {
+				t.Fatalf("packages.ReadDpkgPackageFiles(%q) returned an error: %v", tc.pkg, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("packages.ReadDpkgPackageFiles(%q) returned unexpected diff (-want +got):\n%s", tc.pkg, diff)
+			}
+		})
+	}
+}
+
+func TestReadDpkgPackageFilesInvalidFileReturnsError(t *testing.T) {
+	for path, content := range map[string]string{
+		"/var/lib/dpkg/info/pkg.md5sums": "invalid",
+		packages.DpkgStatOverridePath:    "root root 0x75 /etc",
+	} {
+		fs := &fakeFilesystem{files: map[string]string{"/var/lib/dpkg/info/pkg.list": "/etc\n", path: content}}
+		if _, err := packages.ReadDpkgPackageFiles(context.Background(), fs, "pkg"); err == nil {
+			t.Errorf("packages.ReadDpkgPackageFiles() with %s %q didn't return an error", path, content)
+		}
+	}
+}
+
+func TestReadRpmPackageFiles(t *testing.T) {
+	// The test database was created with SQLite using small pages so that the
+	// tables span several pages and the larger headers use overflow pages.
+	db, err := os.ReadFile("testdata/rpmdb.sqlite")
+	if err != nil {
+		t.Fatalf("os.ReadFile() returned an error: %v", err)
+	}
+	fs := &fakeFilesystem{files: map[string]string{packages.RpmDBPath: string(db)}}
+
+	docs := []*packages.File{}
+	for i := 0; i < 20; i++ {
+		docs = append(docs, &packages.File{
+			Path:            fmt.Sprintf("/usr/share/doc/openssh-server/doc%02d.txt", i),
+			Type:            packages.RegularFile,
+			Mode:            0644,
+			User:            "root",
+			Group:           "root",
+			Digest:          strings.Repeat("c", 64),
+			DigestAlgorithm: "sha256",
+		})
+	}
+	testCases := []struct {
+		pkg  string
+		want []*packages.File
+	}{
+		{
+			pkg: "openssh-server",
+			want: append([]*packages.File{
+				{
+					Path:            "/etc/ssh/sshd_config",
+					Type:            packages.RegularFile,
+					Mode:            0600,
+					User:            "root",
+					Group:           "root",
+					Digest:          strings.Repeat("a", 64),
+					DigestAlgorithm: "sha256",
+					IsConfig:        true,
+				},
+				{
+					Path:            "/usr/sbin/sshd",
+					Type:            packages.RegularFile,
+					Mode:            0755,
+					User:            "root",
+					Group:           "root",
+					Digest:          strings.Repeat("b", 64),
+					DigestAlgorithm: "sha256",
+				},
+				{Path: "/usr/lib/ssh", Type: packages.Directory, Mode: 0755, User: "root", Group: "root"},
+			}, docs...),
+		},
+		{
+			pkg: "bash",
+			want: []*packages.File{{
+				Path:            "/usr/bin/bash",
+				Type:            packages.RegularFile,
+				Mode:            0755,
+				User:            "root",
+				Group:           "root",
+				Digest:          strings.Repeat("d", 32),
+				DigestAlgorithm: "md5",
+			}},
+		},
+		{
+			pkg:  "not-installed",
+			want: []*packages.File{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pkg, func(t *testing.T) {
+			got, err := packages.ReadRpmPackageFiles(context.Background(), fs, tc.pkg)
+			if err != nil {
+				t.Fatalf("packages.ReadRpmPackageFiles(%q) returned an error: %v", tc.pkg, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("packages.ReadRpmPackageFiles(%q) returned unexpected diff (-want +got):\n%s", tc.pkg, diff)
+			}
+		})
+	}
+}
+
+func TestReadRpmPackageFilesInvalidDatabaseReturnsError(t *testing.T) {
+	db, err := os.ReadFile("testdata/rpmdb.sqlite")
+	if err != nil {
+		t.Fatalf("os.ReadFile() returned an error: %v", err)
+	}
+	for desc, content := range map[string]string{
+		"not a database": "invalid",
+		"truncated":      string(db[:1024]),
+	} {
+		fs := &fakeFilesystem{files: map[string]string{packages.RpmDBPath: content}}
+		if _, err := packages.ReadRpmPackageFiles(context.Background(), fs, "bash"); err == nil {
+			t.Errorf("packages.ReadRpmPackageFiles() with %s database didn't return an error", desc)
+		}
+	}
+}
+
+func TestReadRpmPackageFilesWithWriteAheadLog(t *testing.T) {
+	db, err := os.ReadFile("testdata/rpmdb.sqlite")
+	if err != nil {
+		t.Fatalf("os.ReadFile() returned an error: %v", err)
+	}
+	fs := &fakeFilesystem{files: map[string]string{
+		packages.RpmDBPath:          string(db),
+		packages.RpmDBPath + "-wal": "",
+	}}
+	if _, err := packages.ReadRpmPackageFiles(context.Background(), fs, "bash"); err != nil {
+		t.Errorf("packages.ReadRpmPackageFiles() with empty write-ahead log returned an error: %v", err)
+	}
+
+	fs.files[packages.RpmDBPath+"-wal"] = "uncheckpointed changes"
+	if _, err := packages.ReadRpmPackageFiles(context.Background(), fs, "bash"); !errors.Is(err, packages.ErrRpmDBNotCheckpointed) {
+		t.Errorf("packages.ReadRpmPackageFiles() with non-empty write-ahead log returned %v, expected %v", err, packages.ErrRpmDBNotCheckpointed)
+	}
+}
+
+// countingFilesystem counts how often each file is opened.
+type countingFilesystem struct {
+	*fakeFilesystem
+	opened map[string]int
+}
+
+func (f *countingFilesystem) OpenFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	f.opened[filePath]++
+	return f.fakeFilesystem.OpenFile(ctx, filePath)
+}
+
+func TestReaderReadsRpmDatabaseOnce(t *testing.T) {
+	db, err := os.ReadFile("testdata/rpmdb.sqlite")
+	if err != nil {
+		t.Fatalf("os.ReadFile() returned an error: %v", err)
+	}
+	fs := &countingFilesystem{
+		fakeFilesystem: &fakeFilesystem{files: map[string]string{packages.RpmDBPath: string(db)}},
+		opened:         make(map[string]int),
+	}
+	r := packages.NewReader(fs)
+	for _, pkg := range []string{"bash", "openssh-server"} {
+		if _, err := r.RpmPackageFiles(context.Background(), pkg); err != nil {
+			t.Fatalf("r.RpmPackageFiles(%q) returned an error: %v", pkg, err)
+		}
+	}
+	if _, err := r.InstalledPackages(context.Background()); err != nil {
+		t.Fatalf("r.InstalledPackages() returned an error: %v", err)
+	}
+	if got := fs.opened[packages.RpmDBPath]; got != 1 {
+		t.Errorf("The rpm database was opened %d times, expected once", got)
+	}
+}
+
+func TestReadRpmPackageFilesWithoutDatabase(t *testing.T) {
+	got, err := packages.ReadRpmPackageFiles(context.Background(), &fakeFilesystem{}, "bash")
+	if err != nil {
+		t.Fatalf("packages.ReadRpmPackageFiles() returned an error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("packages.ReadRpmPackageFiles() returned %v, expected no files", got)
+	}
+}
+
+func TestReadRpmPackageFilesWithUnsupportedDatabaseReturnsError(t *testing.T) {
+	for _, dbPath := range []string{"/var/lib/rpm/Packages", "/var/lib/rpm/rpmdb.bdb"} {
+		fs := &fakeFilesystem{files: map[string]string{dbPath: "Berkeley DB"}}
+		if _, err := packages.ReadRpmPackageFiles(context.Background(), fs, "bash"); !errors.Is(err, packages.ErrUnsupportedRpmDB) {
+			t.Errorf("packages.ReadRpmPackageFiles() with database %s returned %v, expected %v", dbPath, err, packages.ErrUnsupportedRpmDB)
+		}
+		if _, err := packages.ReadInstalledPackages(context.Background(), fs); !errors.Is(err, packages.ErrUnsupportedRpmDB) {
+			t.Errorf("packages.ReadInstalledPackages() with database %s returned %v, expected %v", dbPath, err, packages.ErrUnsupportedRpmDB)
+		}
+	}
+}
+
+func TestReadInstalledPackages(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		packages.DpkgStatusPath: "Package: openssh-server\n" +
+			"Status: install ok installed\n" +
+			"Architecture: amd64\n" +
+			"Version: 1:9.2p1-2+deb12u2\n" +
+			"Description: secure shell (SSH) server\n" +
+			" Version: not a field\n" +
+			"\n" +
+			"Package: telnetd\n" +
+			"Status: deinstall ok config-files\n" +
+			"Version: 0.17-44\n" +
+			"\n" +
+			"Package: libc6\n" +
+			"Status: install ok installed\n" +
+			"Version: 2.36-9\n",
+	}}
+	got, err := packages.ReadInstalledPackages(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("packages.ReadInstalledPackages() returned an error: %v", err)
+	}
+	want := []*packages.Package{
+		{Name: "openssh-server", Version: "1:9.2p1-2+deb12u2"},
+		{Name: "libc6", Version: "2.36-9"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("packages.ReadInstalledPackages() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadInstalledRpmPackages(t *testing.T) {
+	// The filler packages of the test database have no version and are skipped.
+	db, err := os.ReadFile("testdata/rpmdb.sqlite")
+	if err != nil {
+		t.Fatalf("os.ReadFile() returned an error: %v", err)
+	}
+	fs := &fakeFilesystem{files: map[string]string{packages.RpmDBPath: string(db)}}
+	got, err := packages.ReadInstalledPackages(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("packages.ReadInstalledPackages() returned an error: %v", err)
+	}
+	want := []*packages.Package{
+		{Name: "openssh-server", Version: "9.0p1-19.el9"},
+		{Name: "bash", Version: "5.1.8-9.el9"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("packages.ReadInstalledPackages() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,420 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/localtoast/scanapi"
+)
+
+// RpmDBPath is the path of the SQLite rpm database. The older Berkeley DB and
+// NDB formats aren't supported.
+const RpmDBPath = "/var/lib/rpm/rpmdb.sqlite"
+
+// The databases of the unsupported formats, e.g. used by CentOS 7 and Rocky
+// Linux 8.
+var unsupportedRpmDBPaths = []string{
+	"/var/lib/rpm/Packages",
+	"/var/lib/rpm/rpmdb.bdb",
+	"/var/lib/rpm/Packages.db",
+}
+
+// ErrRpmDBNotCheckpointed is returned if the rpm database has changes in its
+// write-ahead log which aren't supported.
+var ErrRpmDBNotCheckpointed = errors.New("rpm database has changes in its write-ahead log that aren't supported")
+
+// ErrUnsupportedRpmDB is returned if the scanned machine has an rpm database
+// in a format other than SQLite.
+var ErrUnsupportedRpmDB = errors.New("unsupported rpm database format")
+
+// The header tags used to describe the packages and their files, see rpmtag.h.
+const (
+	rpmTagName           = 1000
+	rpmTagVersion        = 1001
+	rpmTagRelease        = 1002
+	rpmTagFileModes      = 1030
+	rpmTagFileDigests    = 1035
+	rpmTagFileFlags      = 1037
+	rpmTagFileUserName   = 1039
+	rpmTagFileGroupName  = 1040
+	rpmTagDirIndexes     = 1116
+	rpmTagBaseNames      = 1117
+	rpmTagDirNames       = 1118
+	rpmTagFileDigestAlgo = 5011
+)
+
+// The header data types, see rpmtag.h.
+const (
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+)
+
+const (
+	rpmFileFlagConfig = 1 << 0
+
+	fileTypeMask    = 0170000
+	fileTypeDir     = 0040000
+	fileTypeReg     = 0100000
+	fileTypeSymlink = 0120000
+)
+
+// The names of the digest algorithms used by rpm, see PGPHASHALGO_* in rpmpgp.h.
+var rpmDigestAlgorithms = map[int32]string{
+	1:  "md5",
+	2:  "sha1",
+	8:  "sha256",
+	9:  "sha384",
+	10: "sha512",
+	11: "sha224",
+}
+
+// ReadRpmPackageFiles returns the files installed by the given rpm package.
+// Returns an empty list if the package isn't installed.
+func ReadRpmPackageFiles(ctx context.Context, fs scanapi.Filesystem, name string) ([]*File, error) {
+	return NewReader(fs).RpmPackageFiles(ctx, name)
+}
+
+// RpmPackageFiles returns the files installed by the given rpm package.
+// Returns an empty list if the package isn't installed.
+func (r *Reader) RpmPackageFiles(ctx context.Context, name string) ([]*File, error) {
+	headers, err := r.readRpmHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []*File{}
+	for _, h := range headers {
+		if h.stringValue(rpmTagName) != name {
+			continue
+		}
+		files, err := h.files()
+		if err != nil {
+			return nil, fmt.Errorf("%s: package %s: %w", RpmDBPath, name, err)
+		}
+		result = append(result, files...)
+	}
+	return result, nil
+}
+
+// readRpmPackages returns the packages in the rpm database.
+func (r *Reader) readRpmPackages(ctx context.Context) ([]*Package, error) {
+	headers, err := r.readRpmHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Package, 0, len(headers))
+	for _, h := range headers {
+		// The gpg-pubkey pseudo-packages have no files or version tags.
+		name := h.stringValue(rpmTagName)
+		version := h.stringValue(rpmTagVersion)
+		if name == "" || version == "" {
+			continue
+		}
+		if release := h.stringValue(rpmTagRelease); release != "" {
+			version += "-" + release
+		}
+		result = append(result, &Package{Name: name, Version: version})
+	}
+	return result, nil
+}
+
+// readRpmHeaders returns the headers of all packages in the rpm database. The
+// database is only read on the first call.
+func (r *Reader) readRpmHeaders(ctx context.Context) ([]*rpmHeader, error) {
+	r.rpmOnce.Do(func() {
+		r.rpmHeaders, r.rpmErr = readRpmHeaders(ctx, r.fs)
+	})
+	return r.rpmHeaders, r.rpmErr
+}
+
+// readRpmHeaders returns the headers of all packages in the rpm database, or
+// nothing if the database doesn't exist. Returns ErrUnsupportedRpmDB if there's
+// only a database in an unsupported format.
+func readRpmHeaders(ctx context.Context, fs scanapi.Filesystem) ([]*rpmHeader, error) {
+	f, err := fs.OpenFile(ctx, RpmDBPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, checkUnsupportedRpmDB(ctx, fs)
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if err := checkRpmWAL(ctx, fs); err != nil {
+		return nil, err
+	}
+	// The database is read into memory since the scan API only provides
+	// sequential access to files.
+	db, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := readSQLiteTable(db, "Packages")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", RpmDBPath, err)
+	}
+	result := []*rpmHeader{}
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		blob, ok := row[1].([]byte)
+		if !ok {
+			continue
+		}
+		h, err := parseRpmHeader(blob)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", RpmDBPath, err)
+		}
+		result = append(result, h)
+	}
+	return result, nil
+}
+
+// checkRpmWAL returns an error if the rpm database has changes in its
+// write-ahead log. They aren't visible to the SQLite parser, so the packages
+// read from the database would be outdated.
+func checkRpmWAL(ctx context.Context, fs scanapi.Filesystem) error {
+	walPath := RpmDBPath + "-wal"
+	f, err := fs.OpenFile(ctx, walPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	n, err := f.Read(make([]byte, 1))
+	if n > 0 {
+		return fmt.Errorf("%s: %w", walPath, ErrRpmDBNotCheckpointed)
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// checkUnsupportedRpmDB returns an error if an rpm database in one of the
+// unsupported formats exists. Reading no packages from such databases would
+// make the package checks pass silently.
+func checkUnsupportedRpmDB(ctx context.Context, fs scanapi.Filesystem) error {
+	for _, p := range unsupportedRpmDBPaths {
+		f, err := fs.OpenFile(ctx, p)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		f.Close()
+		return fmt.Errorf("%s: %w, only %s is supported", p, ErrUnsupportedRpmDB, RpmDBPath)
+	}
+	return nil
+}
+
+type rpmHeaderEntry struct {
+	dataType uint32
+	offset   uint32
+	count    uint32
+}
+
+// rpmHeader is a parsed rpm header as stored in the database: The number of
+// index entries and the size of the data store, followed by the index entries
+// and the data store, all big-endian.
+type rpmHeader struct {
+	entries map[uint32]*rpmHeaderEntry
+	data    []byte
+}
+
+func parseRpmHeader(blob []byte) (*rpmHeader, error) {
+	if len(blob) < 8 {
+		return nil, fmt.Errorf("invalid rpm header of size %d", len(blob))
+	}
+	indexCount := binary.BigEndian.Uint32(blob)
+	dataSize := binary.BigEndian.Uint32(blob[4:])
+	dataStart := 8 + uint64(indexCount)*16
+	if dataStart+uint64(dataSize) > uint64(len(blob)) {
+		return nil, fmt.Errorf("invalid rpm header of size %d with %d entries and %d bytes of data", len(blob), indexCount, dataSize)
+	}
+	h := &rpmHeader{
+		entries: make(map[uint32]*rpmHeaderEntry, indexCount),
+		data:    blob[dataStart : dataStart+uint64(dataSize)],
+	}
+	for i := uint64(0); i < uint64(indexCount); i++ {
+		e := blob[8+i*16:]
+		h.entries[binary.BigEndian.Uint32(e)] = &rpmHeaderEntry{
+			dataType: binary.BigEndian.Uint32(e[4:]),
+			offset:   binary.BigEndian.Uint32(e[8:]),
+			count:    binary.BigEndian.Uint32(e[12:]),
+		}
+	}
+	return h, nil
+}
+
+// stringValue returns the value of a string tag, or "" if it's not set.
+func (h *rpmHeader) stringValue(tag uint32) string {
+	values, err := h.stringArray(tag)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// stringArray returns the values of a string or string array tag.
+func (h *rpmHeader) stringArray(tag uint32) ([]string, error) {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil, nil
+	}
+	if e.dataType != rpmTypeString && e.dataType != rpmTypeStringArray && e.dataType != rpmTypeI18NString {
+		return nil, fmt.Errorf("rpm header tag %d has type %d, expected a string", tag, e.dataType)
+	}
+	if e.offset > uint32(len(h.data)) {
+		return nil, fmt.Errorf("rpm header tag %d has invalid offset %d", tag, e.offset)
+	}
+	rest := h.data[e.offset:]
+	result := make([]string, 0, e.count)
+	for i := uint32(0); i < e.count; i++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return nil, fmt.Errorf("rpm header tag %d has unterminated strings", tag)
+		}
+		result = append(result, string(rest[:end]))
+		rest = rest[end+1:]
+	}
+	return result, nil
+}
+
+// intArray returns the values of an int16 or int32 tag.
+func (h *rpmHeader) intArray(tag uint32) ([]int32, error) {
+	e, ok := h.entries[tag]
+	if !ok {
+		return nil, nil
+	}
+	size := uint64(4)
+	if e.dataType == rpmTypeInt16 {
+		size = 2
+	} else if e.dataType != rpmTypeInt32 {
+		return nil, fmt.Errorf("rpm header tag %d has type %d, expected an integer", tag, e.dataType)
+	}
+	if uint64(e.offset)+uint64(e.count)*size > uint64(len(h.data)) {
+		return nil, fmt.Errorf("rpm header tag %d exceeds the data store", tag)
+	}
+	result := make([]int32, 0, e.count)
+	for i := uint64(0); i < uint64(e.count); i++ {
+		v := h.data[uint64(e.offset)+i*size:]
+		if size == 2 {
+			result = append(result, int32(binary.BigEndian.Uint16(v)))
+		} else {
+			result = append(result, int32(binary.BigEndian.Uint32(v)))
+		}
+	}
+	return result, nil
+}
+
+// files returns the files described by the header's file tags.
+func (h *rpmHeader) files() ([]*File, error) {
+	baseNames, err := h.stringArray(rpmTagBaseNames)
+	if err != nil {
+		return nil, err
+	}
+	dirNames, err := h.stringArray(rpmTagDirNames)
+	if err != nil {
+		return nil, err
+	}
+	dirIndexes, err := h.intArray(rpmTagDirIndexes)
+	if err != nil {
+		return nil, err
+	}
+	modes, err := h.intArray(rpmTagFileModes)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := h.intArray(rpmTagFileFlags)
+	if err != nil {
+		return nil, err
+	}
+	users, err := h.stringArray(rpmTagFileUserName)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := h.stringArray(rpmTagFileGroupName)
+	if err != nil {
+		return nil, err
+	}
+	digests, err := h.stringArray(rpmTagFileDigests)
+	if err != nil {
+		return nil, err
+	}
+	// Packages without the algorithm tag use MD5.
+	digestAlgorithm := "md5"
+	if algo, err := h.intArray(rpmTagFileDigestAlgo); err != nil {
+		return nil, err
+	} else if len(algo) > 0 {
+		name, ok := rpmDigestAlgorithms[algo[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown file digest algorithm %d", algo[0])
+		}
+		digestAlgorithm = name
+	}
+	if len(dirIndexes) != len(baseNames) {
+		return nil, fmt.Errorf("got %d file names and %d directory indexes", len(baseNames), len(dirIndexes))
+	}
+
+	result := make([]*File, 0, len(baseNames))
+	for i, baseName := range baseNames {
+		if dirIndexes[i] < 0 || int(dirIndexes[i]) >= len(dirNames) {
+			return nil, fmt.Errorf("file %s has invalid directory index %d", baseName, dirIndexes[i])
+		}
+		f := &File{Path: dirNames[dirIndexes[i]] + baseName, Mode: -1}
+		if i < len(modes) {
+			mode := uint16(modes[i])
+			f.Mode = int32(mode & 07777)
+			switch mode & fileTypeMask {
+			case fileTypeReg:
+				f.Type = RegularFile
+			case fileTypeDir:
+				f.Type = Directory
+			case fileTypeSymlink:
+				f.Type = Symlink
+			default:
+				f.Type = OtherType
+			}
+		}
+		if i < len(flags) {
+			f.IsConfig = flags[i]&rpmFileFlagConfig != 0
+		}
+		if i < len(users) {
+			f.User = users[i]
+		}
+		if i < len(groups) {
+			f.Group = groups[i]
+		}
+		if i < len(digests) && digests[i] != "" {
+			f.Digest = digests[i]
+			f.DigestAlgorithm = digestAlgorithm
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
@@ -0,0 +1,306 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packages
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// A minimal read-only parser of the SQLite file format
+// (https://www.sqlite.org/fileformat.html), just enough to read the rows of
+// the rpm database's tables without a database driver. Changes that are still
+// in the write-ahead log aren't visible, so the rpm database is only read if
+// its write-ahead log is empty.
+
+const (
+	sqliteMagic          = "SQLite format 3\x00"
+	sqliteHeaderSize     = 100
+	sqliteLeafTablePage  = 0x0d
+	sqliteInteriorTable  = 0x05
+	sqliteSchemaRootPage = 1
+)
+
+var errSQLiteCorrupt = errors.New("corrupt SQLite database")
+
+type sqliteDB struct {
+	data       []byte
+	pageSize   int
+	usableSize int
+}
+
+// readSQLiteTable returns the rows of the given table in the database. Each
+// row is a list of column values of type nil, int64, float64, string or []byte.
+func readSQLiteTable(data []byte, table string) ([][]interface{}, error) {
+	if len(data) < sqliteHeaderSize || string(data[:len(sqliteMagic)]) != sqliteMagic {
+		return nil, errors.New("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	db := &sqliteDB{data: data, pageSize: pageSize, usableSize: pageSize - int(data[20])}
+	if pageSize < 512 || db.usableSize < 480 {
+		return nil, errSQLiteCorrupt
+	}
+
+	// The schema table has the columns type, name, tbl_name, rootpage and sql.
+	schema, err := db.tableRows(sqliteSchemaRootPage)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range schema {
+		if len(row) < 4 || row[0] != "table" || row[1] != table {
+			continue
+		}
+		rootPage, ok := row[3].(int64)
+		if !ok {
+			return nil, errSQLiteCorrupt
+		}
+		return db.tableRows(int(rootPage))
+	}
+	return nil, fmt.Errorf("table %s not found", table)
+}
+
+// page returns the contents of the given 1-based page.
+func (db *sqliteDB) page(n int) ([]byte, error) {
+	if n < 1 || n > len(db.data)/db.pageSize {
+		return nil, errSQLiteCorrupt
+	}
+	return db.data[(n-1)*db.pageSize : n*db.pageSize], nil
+}
+
+// tableRows returns the records of the table B-tree with the given root page.
+func (db *sqliteDB) tableRows(rootPage int) ([][]interface{}, error) {
+	result := [][]interface{}{}
+	visited := make(map[int]bool)
+	pages := []int{rootPage}
+	for len(pages) > 0 {
+		n := pages[0]
+		pages = pages[1:]
+		if visited[n] {
+			return nil, errSQLiteCorrupt
+		}
+		visited[n] = true
+		page, err := db.page(n)
+		if err != nil {
+			return nil, err
+		}
+		headerStart := 0
+		if n == 1 {
+			headerStart = sqliteHeaderSize
+		}
+		header := page[headerStart:]
+		cellCount := int(binary.BigEndian.Uint16(header[3:]))
+		switch header[0] {
+		case sqliteInteriorTable:
+			// Each cell is a child page number followed by a key.
+			children := make([]int, 0, cellCount+1)
+			for i := 0; i < cellCount; i++ {
+				off, err := cellOffset(page, headerStart+12, i)
+				if err != nil {
+					return nil, err
+				}
+				children = append(children, int(binary.BigEndian.Uint32(page[off:])))
+			}
+			children = append(children, int(binary.BigEndian.Uint32(header[8:])))
+			// Keep the rows in key order.
+			pages = append(children, pages...)
+		case sqliteLeafTablePage:
+			// Each cell is the payload size, the row ID and the payload.
+			for i := 0; i < cellCount; i++ {
+				off, err := cellOffset(page, headerStart+8, i)
+				if err != nil {
+					return nil, err
+				}
+				payloadSize, n1 := sqliteVarint(page[off:])
+				if n1 == 0 {
+					return nil, errSQLiteCorrupt
+				}
+				_, n2 := sqliteVarint(page[off+n1:])
+				if n2 == 0 {
+					return nil, errSQLiteCorrupt
+				}
+				payload, err := db.payload(page, off+n1+n2, payloadSize)
+				if err != nil {
+					return nil, err
+				}
+				record, err := parseSQLiteRecord(payload)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, record)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected SQLite page type %#x", header[0])
+		}
+	}
+	return result, nil
+}
+
+// cellOffset returns the offset of the i-th cell of the page. The cell pointer
+// array starts at the given offset.
+func cellOffset(page []byte, pointerArrayStart int, i int) (int, error) {
+	p := pointerArrayStart + 2*i
+	if p+2 > len(page) {
+		return 0, errSQLiteCorrupt
+	}
+	off := int(binary.BigEndian.Uint16(page[p:]))
+	// Leave room for at least a child page number or two varints.
+	if off+4 > len(page) {
+		return 0, errSQLiteCorrupt
+	}
+	return off, nil
+}
+
+// payload returns the payload of a table leaf cell starting at the given
+// offset, following the overflow pages if the payload doesn't fit the page.
+func (db *sqliteDB) payload(page []byte, start int, size uint64) ([]byte, error) {
+	if size > uint64(len(db.data)) {
+		return nil, errSQLiteCorrupt
+	}
+	u := uint64(db.usableSize)
+	maxLocal := u - 35
+	local := size
+	if size > maxLocal {
+		minLocal := (u-12)*32/255 - 23
+		local = minLocal + (size-minLocal)%(u-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	end := start + int(local)
+	if size > local {
+		end += 4
+	}
+	if end > len(page) {
+		return nil, errSQLiteCorrupt
+	}
+	result := make([]byte, 0, size)
+	result = append(result, page[start:start+int(local)]...)
+	if size == local {
+		return result, nil
+	}
+	next := int(binary.BigEndian.Uint32(page[start+int(local):]))
+	visited := make(map[int]bool)
+	for uint64(len(result)) < size {
+		if next == 0 || visited[next] {
+			return nil, errSQLiteCorrupt
+		}
+		visited[next] = true
+		overflow, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = int(binary.BigEndian.Uint32(overflow))
+		chunk := overflow[4:db.usableSize]
+		if remaining := size - uint64(len(result)); uint64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		result = append(result, chunk...)
+	}
+	return result, nil
+}
+
+// parseSQLiteRecord decodes a record: A header with the serial types of the
+// columns followed by the column values.
+func parseSQLiteRecord(payload []byte) ([]interface{}, error) {
+	headerSize, n := sqliteVarint(payload)
+	if n == 0 || headerSize > uint64(len(payload)) {
+		return nil, errSQLiteCorrupt
+	}
+	serialTypes := []uint64{}
+	for pos := n; pos < int(headerSize); {
+		t, n := sqliteVarint(payload[pos:int(headerSize)])
+		if n == 0 {
+			return nil, errSQLiteCorrupt
+		}
+		serialTypes = append(serialTypes, t)
+		pos += n
+	}
+	body := payload[headerSize:]
+	result := make([]interface{}, 0, len(serialTypes))
+	for _, t := range serialTypes {
+		size := sqliteSerialTypeSize(t)
+		if size < 0 || size > len(body) {
+			return nil, errSQLiteCorrupt
+		}
+		v := body[:size]
+		body = body[size:]
+		switch {
+		case t == 0:
+			result = append(result, nil)
+		case t <= 6:
+			// Big-endian two's complement integers.
+			i := int64(int8(v[0]))
+			for _, b := range v[1:] {
+				i = i<<8 | int64(b)
+			}
+			result = append(result, i)
+		case t == 7:
+			result = append(result, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t == 8:
+			result = append(result, int64(0))
+		case t == 9:
+			result = append(result, int64(1))
+		case t%2 == 0:
+			result = append(result, v)
+		default:
+			result = append(result, string(v))
+		}
+	}
+	return result, nil
+}
+
+// sqliteSerialTypeSize returns the size of a value with the given serial type,
+// or -1 if the type is invalid.
+func sqliteSerialTypeSize(t uint64) int {
+	switch {
+	case t <= 4:
+		return []int{0, 1, 2, 3, 4}[t]
+	case t == 5:
+		return 6
+	case t == 6 || t == 7:
+		return 8
+	case t == 8 || t == 9:
+		return 0
+	case t == 10 || t == 11:
+		return -1
+	case t > math.MaxInt32:
+		return -1
+	case t%2 == 0:
+		return int(t-12) / 2
+	default:
+		return int(t-13) / 2
+	}
+}
+
+// sqliteVarint decodes a big-endian variable-length integer of 1 to 9 bytes.
+// Returns the number of bytes read, or 0 if the input is too short.
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
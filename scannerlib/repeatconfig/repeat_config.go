@@ -18,7 +18,9 @@ package repeatconfig
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path"
 	"regexp"
 	"strconv"
@@ -26,6 +28,10 @@ import (
 
 	"google.golang.org/protobuf/proto"
 	"github.com/google/localtoast/scanapi"
+	"github.com/google/localtoast/scannerlib/accounts"
+	"github.com/google/localtoast/scannerlib/facts"
+	"github.com/google/localtoast/scannerlib/mounts"
+	"github.com/google/localtoast/scannerlib/sockets"
 	ipb "github.com/google/localtoast/scannerlib/proto/scan_instructions_go_proto"
 )
 
@@ -36,20 +42,42 @@ const (
 	homeDirWildcard  = "$home"
 	portWildcard     = "$port"
 	shellWildcard    = "$shell"
-	defaultUIDMin    = 1000
+	groupWildcard    = "$group"
+	membersWildcard  = "$members"
+	mountWildcard    = "$mountpoint"
+	fsTypeWildcard   = "$fstype"
+	optionsWildcard  = "$options"
+	pidWildcard      = "$pid"
+	commWildcard     = "$comm"
+	exeWildcard      = "$exe"
+	socketWildcard   = "$socket"
 )
 
 var (
-	// Regexp for capturing the local ip:port and TCP state from the
-	// /proc/net/tcp(6) file. See https://regex101.com/r/MFk3qd/1 for how to
-	// read this regex.
-	procTCPRe = regexp.MustCompile("^\\s*[0-9]+:\\s+([0-9A-F]+):([0-9A-F]+)\\s+[0-9A-F]+:[0-9A-F]+\\s+([0-9A-F]+)\\s+.*$")
-	// Regexp for capturing the UID_MIN value from the /etc/login.defs file.
-	uidMinRe = regexp.MustCompile("^UID_MIN\\s+(\\d+)$")
-	// Regexp for capturing the SYS_UID_MIN value from the /etc/login.defs file.
-	sysUIDMinRe = regexp.MustCompile("^SYS_UID_MIN\\s+(\\d+)$")
-	// Regexp for capturing the SYS_UID_MAX value from the /etc/login.defs file.
-	sysUIDMaxRe = regexp.MustCompile("^SYS_UID_MAX\\s+(\\d+)$")
+	// The syntax of the custom wildcards of FOR_EACH_VALUE.
+	wildcardRe = regexp.MustCompile(`^\$[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// The wildcards substituted by each repeat type.
+var repeatTypeWildcards = map[ipb.RepeatConfig_RepeatType][]string{
+	ipb.RepeatConfig_ONCE:                            nil,
+	ipb.RepeatConfig_FOR_EACH_USER:                   userWildcards,
+	ipb.RepeatConfig_FOR_EACH_USER_WITH_LOGIN:        userWildcards,
+	ipb.RepeatConfig_FOR_EACH_SYSTEM_USER_WITH_LOGIN: userWildcards,
+	ipb.RepeatConfig_FOR_EACH_OPEN_IPV4_PORT:         {portWildcard},
+	ipb.RepeatConfig_FOR_EACH_OPEN_IPV6_PORT:         {portWildcard},
+	ipb.RepeatConfig_FOR_EACH_GROUP:                  {groupWildcard, gidWildcard, membersWildcard},
+	ipb.RepeatConfig_FOR_EACH_MOUNT:                  mountWildcards,
+	ipb.RepeatConfig_FOR_EACH_CONFIGURED_MOUNT:       mountWildcards,
+	ipb.RepeatConfig_FOR_EACH_PROCESS:                {pidWildcard, commWildcard, exeWildcard},
+	ipb.RepeatConfig_FOR_EACH_OPEN_UDP_IPV4_PORT:     {portWildcard},
+	ipb.RepeatConfig_FOR_EACH_OPEN_UDP_IPV6_PORT:     {portWildcard},
+	ipb.RepeatConfig_FOR_EACH_UNIX_SOCKET:            {socketWildcard},
+}
+
+var (
+	userWildcards  = []string{usernameWildcard, uidWildcard, gidWildcard, homeDirWildcard, shellWildcard}
+	mountWildcards = []string{mountWildcard, fsTypeWildcard, optionsWildcard}
 )
 
 // RepeatConfig is a single repeat config that specifies what tokens to replace
@@ -67,7 +95,15 @@ type TokenReplacement struct {
 
 // CreateRepeatConfigs creates a list of configs with the appropriate token
 // substitutions based on the supplied repeat config enum.
-func CreateRepeatConfigs(ctx context.Context, repeatOptions *ipb.RepeatConfig, fs scanapi.Filesystem) ([]*RepeatConfig, error) {
+func CreateRepeatConfigs(ctx context.Context, repeatOptions *ipb.RepeatConfig, api scanapi.ScanAPI) ([]*RepeatConfig, error) {
+	return CreateRepeatConfigsWithFacts(ctx, repeatOptions, api, facts.NewCollector(api))
+}
+
+// CreateRepeatConfigsWithFacts is like CreateRepeatConfigs but reads the
+// users, groups, mounts and sockets through the given collector, which caches
+// them for the rest of the scan.
+func CreateRepeatConfigsWithFacts(ctx context.Context, repeatOptions *ipb.RepeatConfig, api scanapi.ScanAPI, collector *facts.Collector) ([]*RepeatConfig, error) {
+	var fs scanapi.Filesystem = api
 	var rc []*RepeatConfig
 	var err error
 	switch repeatOptions.GetType() {
@@ -75,20 +111,40 @@ func CreateRepeatConfigs(ctx context.Context, repeatOptions *ipb.RepeatConfig, f
 		rc, err = []*RepeatConfig{&RepeatConfig{}}, nil
 	case ipb.RepeatConfig_FOR_EACH_USER:
 		rc, err = createRepeatConfigForEachUser(ctx, userRepeatConfigOptions{
-			fileReader: fs, loginOnly: false, systemOnly: false,
+			collector: collector, loginOnly: false, systemOnly: false,
 		})
 	case ipb.RepeatConfig_FOR_EACH_USER_WITH_LOGIN:
 		rc, err = createRepeatConfigForEachUser(ctx, userRepeatConfigOptions{
-			fileReader: fs, loginOnly: true, systemOnly: false,
+			collector: collector, loginOnly: true, systemOnly: false,
 		})
 	case ipb.RepeatConfig_FOR_EACH_SYSTEM_USER_WITH_LOGIN:
 		rc, err = createRepeatConfigForEachUser(ctx, userRepeatConfigOptions{
-			fileReader: fs, loginOnly: true, systemOnly: true,
+			collector: collector, loginOnly: true, systemOnly: true,
 		})
 	case ipb.RepeatConfig_FOR_EACH_OPEN_IPV4_PORT:
-		rc, err = createRepeatConfigForEachOpenTCPPort(ctx, fs, false)
+		rc, err = createRepeatConfigForEachOpenPort(ctx, collector, sockets.TCP)
 	case ipb.RepeatConfig_FOR_EACH_OPEN_IPV6_PORT:
-		rc, err = createRepeatConfigForEachOpenTCPPort(ctx, fs, true)
+		rc, err = createRepeatConfigForEachOpenPort(ctx, collector, sockets.TCP6)
+	case ipb.RepeatConfig_FOR_EACH_OPEN_UDP_IPV4_PORT:
+		rc, err = createRepeatConfigForEachOpenPort(ctx, collector, sockets.UDP)
+	case ipb.RepeatConfig_FOR_EACH_OPEN_UDP_IPV6_PORT:
+		rc, err = createRepeatConfigForEachOpenPort(ctx, collector, sockets.UDP6)
+	case ipb.RepeatConfig_FOR_EACH_UNIX_SOCKET:
+		rc, err = createRepeatConfigForEachUnixSocket(ctx, collector)
+	case ipb.RepeatConfig_FOR_EACH_VALUE:
+		rc, err = createRepeatConfigForEachValue(repeatOptions)
+	case ipb.RepeatConfig_FOR_EACH_REGEX_MATCH:
+		rc, err = createRepeatConfigForEachRegexMatch(ctx, repeatOptions, fs)
+	case ipb.RepeatConfig_FOR_EACH_QUERY_RESULT:
+		rc, err = createRepeatConfigForEachQueryResult(ctx, repeatOptions, api)
+	case ipb.RepeatConfig_FOR_EACH_GROUP:
+		rc, err = createRepeatConfigForEachGroup(ctx, collector)
+	case ipb.RepeatConfig_FOR_EACH_MOUNT:
+		rc, err = createRepeatConfigForEachMount(ctx, collector, false)
+	case ipb.RepeatConfig_FOR_EACH_CONFIGURED_MOUNT:
+		rc, err = createRepeatConfigForEachMount(ctx, collector, true)
+	case ipb.RepeatConfig_FOR_EACH_PROCESS:
+		rc, err = createRepeatConfigForEachProcess(ctx, fs)
 	default:
 		return nil, fmt.Errorf("unknown repeat option type %s", repeatOptions)
 	}
@@ -98,12 +154,203 @@ func CreateRepeatConfigs(ctx context.Context, repeatOptions *ipb.RepeatConfig, f
 	return applyOptOutConfig(rc, repeatOptions.GetOptOut()), nil
 }
 
+// CreateCombinedRepeatConfigs creates the combinations of the repeat configs
+// created for each of the supplied repeat config enums: Each returned config
+// has the token substitutions of one config of each enum, in the order of the
+// enums. The enums are nested, so the substitutions of the previous enums are
+// applied to the file path, regex, query and values of each enum before its
+// configs are created, e.g. to read "$home/.bashrc" for each user. Returns a
+// single config without substitutions if no enums are supplied.
+func CreateCombinedRepeatConfigs(ctx context.Context, repeatOptions []*ipb.RepeatConfig, api scanapi.ScanAPI) ([]*RepeatConfig, error) {
+	return CreateCombinedRepeatConfigsWithFacts(ctx, repeatOptions, api, facts.NewCollector(api))
+}
+
+// CreateCombinedRepeatConfigsWithFacts is like CreateCombinedRepeatConfigs
+// but reads the users, groups, mounts and sockets through the given collector.
+func CreateCombinedRepeatConfigsWithFacts(ctx context.Context, repeatOptions []*ipb.RepeatConfig, api scanapi.ScanAPI, collector *facts.Collector) ([]*RepeatConfig, error) {
+	if err := ValidateRepeatConfigs(repeatOptions); err != nil {
+		return nil, err
+	}
+	result := []*RepeatConfig{&RepeatConfig{}}
+	for _, o := range repeatOptions {
+		// The configs of o if it doesn't use the wildcards of the previous
+		// enums, in which case they're only created once.
+		var unchanged []*RepeatConfig
+		product := make([]*RepeatConfig, 0, len(result))
+		for _, outer := range result {
+			if outer.Err != nil {
+				product = append(product, outer)
+				continue
+			}
+			nested := applyRepeatConfigToRepeatOptions(o, outer)
+			var rcs []*RepeatConfig
+			var err error
+			if nested != o {
+				rcs, err = CreateRepeatConfigsWithFacts(ctx, nested, api, collector)
+			} else if unchanged != nil {
+				rcs = unchanged
+			} else {
+				rcs, err = CreateRepeatConfigsWithFacts(ctx, o, api, collector)
+				unchanged = rcs
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, inner := range rcs {
+				replacements := make([]*TokenReplacement, 0, len(outer.TokenReplacements)+len(inner.TokenReplacements))
+				replacements = append(replacements, outer.TokenReplacements...)
+				replacements = append(replacements, inner.TokenReplacements...)
+				product = append(product, &RepeatConfig{TokenReplacements: replacements, Err: inner.Err})
+			}
+		}
+		result = product
+	}
+	return result, nil
+}
+
+// applyRepeatConfigToRepeatOptions applies the substitutions in the given
+// repeat config to the file path, regex, query and values of the given repeat
+// options. Returns the original options if none of them change, and a copy
+// otherwise.
+func applyRepeatConfigToRepeatOptions(repeatOptions *ipb.RepeatConfig, config *RepeatConfig) *ipb.RepeatConfig {
+	result := proto.Clone(repeatOptions).(*ipb.RepeatConfig)
+	for _, r := range config.TokenReplacements {
+		result.FilePath = applyReplacement(result.GetFilePath(), r)
+		result.Regex = applyReplacement(result.GetRegex(), r)
+		result.Query = applyReplacement(result.GetQuery(), r)
+		for i, v := range result.GetValues() {
+			result.Values[i] = applyReplacement(v, r)
+		}
+	}
+	if proto.Equal(result, repeatOptions) {
+		return repeatOptions
+	}
+	return result
+}
+
+// ValidateRepeatConfigs checks that the given repeat configs have known types,
+// that their opt-outs refer to wildcards they substitute and that no two of
+// them substitute the same wildcard or wildcards that are prefixes of one
+// another, since the substitutions of one would then overwrite parts of the
+// other's wildcards.
+func ValidateRepeatConfigs(repeatOptions []*ipb.RepeatConfig) error {
+	// Wildcard -> index of the config substituting it.
+	seen := make(map[string]int)
+	for i, o := range repeatOptions {
+		wildcards, err := Wildcards(o)
+		if err != nil {
+			return err
+		}
+		for _, optOut := range o.GetOptOut() {
+			if !containsString(wildcards, optOut.GetWildcard()) {
+				return fmt.Errorf("repeat config %s has an opt-out for wildcard %q which it doesn't substitute", o, optOut.GetWildcard())
+			}
+		}
+		for _, w := range wildcards {
+			for other, j := range seen {
+				if w == other {
+					return fmt.Errorf("repeat configs #%d and #%d both substitute %s", j, i, w)
+				}
+				if strings.HasPrefix(w, other) || strings.HasPrefix(other, w) {
+					return fmt.Errorf("repeat configs #%d and #%d substitute the overlapping wildcards %s and %s", j, i, other, w)
+				}
+			}
+			seen[w] = i
+		}
+	}
+	return nil
+}
+
+// ValidateSQLRepeatConfigs is like ValidateRepeatConfigs but also checks that
+// the repeat configs of a SQL check targeting the given database are supported
+// by it. ElasticSearch responses aren't split into rows, so they can't be
+// repeated over.
+func ValidateSQLRepeatConfigs(repeatOptions []*ipb.RepeatConfig, database ipb.SQLCheck_SQLDatabase) error {
+	if err := ValidateRepeatConfigs(repeatOptions); err != nil {
+		return err
+	}
+	for _, o := range repeatOptions {
+		if o.GetType() == ipb.RepeatConfig_FOR_EACH_QUERY_RESULT && database == ipb.SQLCheck_DB_ELASTICSEARCH {
+			return fmt.Errorf("repeat config %s is unsupported for ElasticSearch", o)
+		}
+	}
+	return nil
+}
+
+// Wildcards returns the wildcards substituted by the given repeat
+// config and validates the type-specific fields of the config.
+func Wildcards(o *ipb.RepeatConfig) ([]string, error) {
+	switch o.GetType() {
+	case ipb.RepeatConfig_FOR_EACH_VALUE:
+		if !wildcardRe.MatchString(o.GetWildcard()) {
+			return nil, fmt.Errorf("repeat config %s has invalid wildcard %q", o, o.GetWildcard())
+		}
+		if len(o.GetValues()) == 0 {
+			return nil, fmt.Errorf("repeat config %s has no values", o)
+		}
+		return []string{o.GetWildcard()}, nil
+	case ipb.RepeatConfig_FOR_EACH_REGEX_MATCH:
+		if o.GetFilePath() == "" {
+			return nil, fmt.Errorf("repeat config %s has no file path", o)
+		}
+		re, err := regexp.Compile(o.GetRegex())
+		if err != nil {
+			return nil, fmt.Errorf("repeat config %s has invalid regex: %w", o, err)
+		}
+		return regexWildcards(re, o)
+	case ipb.RepeatConfig_FOR_EACH_QUERY_RESULT:
+		if !wildcardRe.MatchString(o.GetWildcard()) {
+			return nil, fmt.Errorf("repeat config %s has invalid wildcard %q", o, o.GetWildcard())
+		}
+		if o.GetQuery() == "" {
+			return nil, fmt.Errorf("repeat config %s has no query", o)
+		}
+		return []string{o.GetWildcard()}, nil
+	}
+	wildcards, ok := repeatTypeWildcards[o.GetType()]
+	if !ok {
+		return nil, fmt.Errorf("unknown repeat option type %s", o)
+	}
+	return wildcards, nil
+}
+
+// regexWildcards returns the wildcards bound to the named capture groups of
+// the regex of a FOR_EACH_REGEX_MATCH config.
+func regexWildcards(re *regexp.Regexp, o *ipb.RepeatConfig) ([]string, error) {
+	wildcards := []string{}
+	for _, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		w := "$" + name
+		for _, other := range wildcards {
+			if strings.HasPrefix(w, other) || strings.HasPrefix(other, w) {
+				return nil, fmt.Errorf("repeat config %s binds the overlapping wildcards %s and %s", o, other, w)
+			}
+		}
+		wildcards = append(wildcards, w)
+	}
+	if len(wildcards) == 0 {
+		return nil, fmt.Errorf("repeat config %s has a regex without named capture groups", o)
+	}
+	return wildcards, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
 func repeatConfigWithError(err error) []*RepeatConfig {
 	return []*RepeatConfig{{Err: fmt.Errorf("error creating RepeatConfig: %v", err)}}
 }
 
 type userRepeatConfigOptions struct {
-	fileReader scanapi.Filesystem
+	collector  *facts.Collector
 	loginOnly  bool
 	systemOnly bool
 }
@@ -112,57 +359,32 @@ type userRepeatConfigOptions struct {
 // repeat configs that have the usernames as the substitution. if systemOnly is
 // true, only the system users are included in the config.
 func createRepeatConfigForEachUser(ctx context.Context, opt userRepeatConfigOptions) ([]*RepeatConfig, error) {
-	uidMin, sysUIDMin, sysUIDMax := -1, -1, -1
+	var uidRanges *accounts.UIDRanges
 	if opt.systemOnly {
 		var err error
-		uidMin, sysUIDMin, sysUIDMax, err = readUID(ctx, opt.fileReader)
+		uidRanges, err = opt.collector.UIDRanges(ctx)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	r, err := opt.fileReader.OpenFile(ctx, "/etc/passwd")
+	users, err := opt.collector.Users(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer r.Close()
 
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
 	result := []*RepeatConfig{}
-
-	i := 0
-	for scanner.Scan() {
-		i++
-		if scanner.Err() != nil {
-			return nil, scanner.Err()
-		}
-		line := scanner.Text()
-		tokens := strings.Split(line, ":")
-		if len(tokens) < 7 {
-			return nil, fmt.Errorf("can't parse line %d in /etc/passwd: expected at least 7 tokens, got %d", i, len(tokens))
-		}
-		user := tokens[0]
-		uid := tokens[2]
-		gid := tokens[3]
-		homeDir := tokens[5]
-		shell := tokens[6]
-
-		if opt.systemOnly {
-			uidInt, err := strconv.Atoi(uid)
-			if err != nil {
-				return nil, err
-			}
-			if sysUIDMin == -1 || sysUIDMax == -1 {
-				if uidInt >= uidMin { // Non-system users' uid starts from UID_MIN.
-					continue
-				}
-			} else if uidInt < sysUIDMin || uidInt > sysUIDMax {
-				continue
-			}
-		}
+	for _, user := range users {
 		// Ignore users with no shell.
-		if opt.loginOnly && (shell == "/bin/false" || path.Base(shell) == "nologin") {
+		if opt.loginOnly && !user.HasLoginShell() {
+			continue
+		}
+		if user.Err != nil {
+			// Only the checks of the malformed user fail.
+			result = append(result, &RepeatConfig{Err: user.Err})
+			continue
+		}
+		if opt.systemOnly && !uidRanges.IsSystemUID(user.UID) {
 			continue
 		}
 
@@ -170,23 +392,23 @@ func createRepeatConfigForEachUser(ctx context.Context, opt userRepeatConfigOpti
 			TokenReplacements: []*TokenReplacement{
 				{
 					TextToReplace: usernameWildcard,
-					ReplaceWith:   user,
+					ReplaceWith:   user.Name,
 				},
 				{
 					TextToReplace: uidWildcard,
-					ReplaceWith:   uid,
+					ReplaceWith:   strconv.Itoa(user.UID),
 				},
 				{
 					TextToReplace: gidWildcard,
-					ReplaceWith:   gid,
+					ReplaceWith:   strconv.Itoa(user.GID),
 				},
 				{
 					TextToReplace: homeDirWildcard,
-					ReplaceWith:   homeDir,
+					ReplaceWith:   user.Home,
 				},
 				{
 					TextToReplace: shellWildcard,
-					ReplaceWith:   shell,
+					ReplaceWith:   user.Shell,
 				},
 			},
 		})
@@ -194,117 +416,233 @@ func createRepeatConfigForEachUser(ctx context.Context, opt userRepeatConfigOpti
 	return result, nil
 }
 
-func readUID(ctx context.Context, f scanapi.Filesystem) (uidMin, sysUIDMin, sysUIDMax int, err error) {
-	r, err := f.OpenFile(ctx, "/etc/login.defs")
+// createRepeatConfigForEachOpenPort creates repeat configs that have the
+// ports of the listening non-loopback sockets of the given protocol as the
+// substitution.
+func createRepeatConfigForEachOpenPort(ctx context.Context, collector *facts.Collector, protocol string) ([]*RepeatConfig, error) {
+	socketList, err := collector.InetSockets(ctx, protocol)
 	if err != nil {
-		return 0, 0, 0, err
+		return nil, err
 	}
-	defer r.Close()
-
-	uidMin = defaultUIDMin
-	sysUIDMin = -1
-	sysUIDMax = -1
-
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		if scanner.Err() != nil {
-			return 0, 0, 0, scanner.Err()
+	ports := make(map[int]bool)
+	for _, s := range socketList {
+		if !s.IsListening() || s.IsLoopback() {
+			continue
 		}
+		ports[s.LocalPort] = true
+	}
 
-		line := scanner.Text()
-
-		// Look for UID_MIN value
-		groups := uidMinRe.FindStringSubmatch(line)
-		if groups != nil {
-			uidMin, err = strconv.Atoi(groups[1])
-			if err != nil {
-				return 0, 0, 0, err
-			}
-		}
+	result := []*RepeatConfig{}
+	for port, _ := range ports {
+		result = append(result, &RepeatConfig{
+			TokenReplacements: []*TokenReplacement{
+				&TokenReplacement{
+					TextToReplace: portWildcard,
+					ReplaceWith:   strconv.Itoa(port),
+				},
+			},
+		})
+	}
+	return result, nil
+}
 
-		// Look for SYS_UID_MIN value
-		groups = sysUIDMinRe.FindStringSubmatch(line)
-		if groups != nil {
-			sysUIDMin, err = strconv.Atoi(groups[1])
-			if err != nil {
-				return 0, 0, 0, err
-			}
+// createRepeatConfigForEachUnixSocket creates repeat configs that have the
+// filesystem paths of the listening Unix domain sockets as the substitution.
+func createRepeatConfigForEachUnixSocket(ctx context.Context, collector *facts.Collector) ([]*RepeatConfig, error) {
+	socketList, err := collector.UnixSockets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []*RepeatConfig{}
+	seen := make(map[string]bool)
+	for _, s := range socketList {
+		if !s.IsListening() || s.Path == "" || s.IsAbstract() || seen[s.Path] {
+			continue
 		}
+		seen[s.Path] = true
+		result = append(result, &RepeatConfig{
+			TokenReplacements: []*TokenReplacement{
+				{TextToReplace: socketWildcard, ReplaceWith: s.Path},
+			},
+		})
+	}
+	return result, nil
+}
 
-		// Look for SYS_UID_MAX value
-		groups = sysUIDMaxRe.FindStringSubmatch(line)
-		if groups != nil {
-			sysUIDMax, err = strconv.Atoi(groups[1])
-			if err != nil {
-				return 0, 0, 0, err
-			}
-		}
+// createRepeatConfigForEachGroup creates repeat configs that have the groups
+// in /etc/group as the substitution.
+func createRepeatConfigForEachGroup(ctx context.Context, collector *facts.Collector) ([]*RepeatConfig, error) {
+	groups, err := collector.Groups(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return uidMin, sysUIDMin, sysUIDMax, nil
+	result := []*RepeatConfig{}
+	for _, g := range groups {
+		result = append(result, &RepeatConfig{
+			TokenReplacements: []*TokenReplacement{
+				{TextToReplace: groupWildcard, ReplaceWith: g.Name},
+				{TextToReplace: gidWildcard, ReplaceWith: strconv.Itoa(g.GID)},
+				{TextToReplace: membersWildcard, ReplaceWith: strings.Join(g.Members, ",")},
+			},
+		})
+	}
+	return result, nil
 }
 
-// createRepeatConfigForEachPort creates repeat configs that have the currently
-// open TCP ports as the substitution.
-func createRepeatConfigForEachOpenTCPPort(ctx context.Context, f scanapi.Filesystem, isIpv6 bool) ([]*RepeatConfig, error) {
-	tcpFile := "/proc/net/tcp"
-	if isIpv6 {
-		tcpFile = "/proc/net/tcp6"
+// createRepeatConfigForEachMount creates repeat configs that have the mounted
+// or, if configured is true, the configured filesystems as the substitution.
+func createRepeatConfigForEachMount(ctx context.Context, collector *facts.Collector, configured bool) ([]*RepeatConfig, error) {
+	var ms []*mounts.Mount
+	var err error
+	if configured {
+		ms, err = collector.ConfiguredMounts(ctx)
+	} else {
+		ms, err = collector.MountInfo(ctx)
 	}
-	r, err := f.OpenFile(ctx, tcpFile)
 	if err != nil {
 		return nil, err
 	}
-	defer r.Close()
-
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
+	result := []*RepeatConfig{}
+	for _, m := range ms {
+		result = append(result, &RepeatConfig{
+			TokenReplacements: []*TokenReplacement{
+				{TextToReplace: mountWildcard, ReplaceWith: m.MountPoint},
+				{TextToReplace: fsTypeWildcard, ReplaceWith: m.FSType},
+				{TextToReplace: optionsWildcard, ReplaceWith: strings.Join(m.Options, ",")},
+			},
+		})
+	}
+	return result, nil
+}
 
-	scanner.Scan() // Skip the header line.
-	ports := make(map[int]bool)
-	for scanner.Scan() {
-		if scanner.Err() != nil {
-			return nil, scanner.Err()
-		}
-		line := scanner.Text()
-		if !procTCPRe.MatchString(line) {
-			return nil, fmt.Errorf("unable to parse %s", tcpFile)
-		}
-		groups := procTCPRe.FindStringSubmatch(line)
-		address := groups[1]
-		port, err := strconv.ParseInt(groups[2], 16, 32)
+// createRepeatConfigForEachProcess creates repeat configs that have the
+// processes listed in /proc as the substitution.
+func createRepeatConfigForEachProcess(ctx context.Context, fs scanapi.Filesystem) ([]*RepeatConfig, error) {
+	d, err := fs.OpenDir(ctx, "/proc")
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	result := []*RepeatConfig{}
+	for d.Next() {
+		e, err := d.Entry()
 		if err != nil {
 			return nil, err
 		}
-		state, err := strconv.ParseInt(groups[3], 16, 32)
+		if !e.GetIsDir() || !sockets.IsProcessDir(e.GetName()) {
+			continue
+		}
+		procDir := path.Join("/proc", e.GetName())
+		comm, err := sockets.ReadComm(ctx, fs, procDir)
 		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// The process exited since we listed it.
+				continue
+			}
 			return nil, err
 		}
-		// We only care about ports in state LISTENING (0xa).
-		if state != 0xa {
-			continue
+		// Kernel threads don't have an executable and reading it fails with
+		// ENOENT, as it does for processes of other users without privileges.
+		exe := ""
+		if stat, err := fs.FileStat(ctx, path.Join(procDir, "exe")); err == nil {
+			exe = stat.GetLinkTarget()
+		}
+		result = append(result, &RepeatConfig{
+			TokenReplacements: []*TokenReplacement{
+				{TextToReplace: pidWildcard, ReplaceWith: e.GetName()},
+				{TextToReplace: commWildcard, ReplaceWith: comm},
+				{TextToReplace: exeWildcard, ReplaceWith: exe},
+			},
+		})
+	}
+	return result, nil
+}
+
+// createRepeatConfigForEachValue creates repeat configs that have the values
+// listed in the config as the substitution of its wildcard.
+func createRepeatConfigForEachValue(repeatOptions *ipb.RepeatConfig) ([]*RepeatConfig, error) {
+	if _, err := Wildcards(repeatOptions); err != nil {
+		return nil, err
+	}
+	result := make([]*RepeatConfig, 0, len(repeatOptions.GetValues()))
+	for _, v := range repeatOptions.GetValues() {
+		result = append(result, &RepeatConfig{
+			TokenReplacements: []*TokenReplacement{
+				{TextToReplace: repeatOptions.GetWildcard(), ReplaceWith: v},
+			},
+		})
+	}
+	return result, nil
+}
+
+// createRepeatConfigForEachRegexMatch creates repeat configs that have the
+// values of the named capture groups as the substitution for each line of the
+// file that matches the config's regex. Lines that produce the same values
+// are only included once. A file that doesn't exist has no matches.
+func createRepeatConfigForEachRegexMatch(ctx context.Context, repeatOptions *ipb.RepeatConfig, fs scanapi.Filesystem) ([]*RepeatConfig, error) {
+	re, err := regexp.Compile(repeatOptions.GetRegex())
+	if err != nil {
+		return nil, err
+	}
+	wildcards, err := regexWildcards(re, repeatOptions)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.OpenFile(ctx, repeatOptions.GetFilePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*RepeatConfig{}, nil
 		}
-		// Skip loopback addresses.
-		// 127.0.0.0/8 for IPv4. The address consists of one little-endian word.
-		if !isIpv6 && strings.HasSuffix(address, "7F") {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := []*RepeatConfig{}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		groups := re.FindStringSubmatch(scanner.Text())
+		if groups == nil {
 			continue
 		}
-		// ::1 for IPv6. The address consists of 4 little-endian words.
-		if isIpv6 && address == "00000000000000000000000001000000" {
+		replacements := make([]*TokenReplacement, 0, len(wildcards))
+		values := make([]string, 0, len(wildcards))
+		for i, name := range re.SubexpNames() {
+			if name == "" {
+				continue
+			}
+			replacements = append(replacements, &TokenReplacement{TextToReplace: "$" + name, ReplaceWith: groups[i]})
+			values = append(values, groups[i])
+		}
+		// The values can't contain newlines since they're matched on single lines.
+		key := strings.Join(values, "\n")
+		if seen[key] {
 			continue
 		}
-
-		ports[int(port)] = true
+		seen[key] = true
+		result = append(result, &RepeatConfig{TokenReplacements: replacements})
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	result := []*RepeatConfig{}
-	for port, _ := range ports {
+// createRepeatConfigForEachQueryResult creates repeat configs that have the
+// rows returned by the config's query as the substitution of its wildcard.
+func createRepeatConfigForEachQueryResult(ctx context.Context, repeatOptions *ipb.RepeatConfig, sq scanapi.SQLQuerier) ([]*RepeatConfig, error) {
+	if _, err := Wildcards(repeatOptions); err != nil {
+		return nil, err
+	}
+	rows, err := sq.SQLQueryRows(ctx, repeatOptions.GetQuery())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*RepeatConfig, 0, len(rows))
+	for _, row := range rows {
 		result = append(result, &RepeatConfig{
 			TokenReplacements: []*TokenReplacement{
-				&TokenReplacement{
-					TextToReplace: portWildcard,
-					ReplaceWith:   strconv.Itoa(port),
-				},
+				{TextToReplace: repeatOptions.GetWildcard(), ReplaceWith: row},
 			},
 		})
 	}
@@ -326,11 +664,34 @@ func ApplyRepeatConfigToInstruction(instruction *ipb.FileCheck, config *RepeatCo
 			applyRepeatConfigToContentCheck(result.GetContent(), r)
 		case instruction.GetContentEntry() != nil:
 			applyRepeatConfigToContentEntryCheck(result.GetContentEntry(), r)
+		case instruction.GetAuditRule() != nil:
+			applyRepeatConfigToAuditRuleCheck(result.GetAuditRule(), r)
+		case instruction.GetFileMetadata() != nil:
+			f := result.GetFileMetadata()
+			f.LinkTarget = applyReplacement(f.GetLinkTarget(), r)
+		case instruction.GetSelinuxLabel() != nil:
+			applyRepeatConfigToSelinuxLabelCheck(result.GetSelinuxLabel(), r)
 		}
 	}
 	return result
 }
 
+// ApplyRepeatConfigToSQLCheck applies the substitutions in the given repeat
+// config to the query, filter regex and non-compliance message of the given
+// SQL check. The returned SQL check proto is a copy of the original.
+func ApplyRepeatConfigToSQLCheck(check *ipb.SQLCheck, config *RepeatConfig) *ipb.SQLCheck {
+	if len(config.TokenReplacements) == 0 {
+		return check
+	}
+	result := proto.Clone(check).(*ipb.SQLCheck)
+	for _, r := range config.TokenReplacements {
+		result.Query = applyReplacement(result.GetQuery(), r)
+		result.FilterRegex = applyReplacement(result.GetFilterRegex(), r)
+		result.NonComplianceMsg = applyReplacement(result.GetNonComplianceMsg(), r)
+	}
+	return result
+}
+
 // ApplyRepeatConfigToFile applies the substitutions in the given repeat config
 // to the given FileSet. The returned FileSet proto is a copy of the original.
 func ApplyRepeatConfigToFile(fileSet *ipb.FileSet, config *RepeatConfig) *ipb.FileSet {
@@ -351,6 +712,44 @@ func ApplyRepeatConfigToFile(fileSet *ipb.FileSet, config *RepeatConfig) *ipb.Fi
 				optOut[i] = applyReplacement(o, r)
 			}
 			f.OptOutPathRegexes = optOut
+		case fileSet.GetFilesMatchingPredicate() != nil:
+			f := result.GetFilesMatchingPredicate()
+			f.DirPath = applyReplacement(f.GetDirPath(), r)
+			optOut := f.GetOptOutPathRegexes()
+			for i, o := range optOut {
+				optOut[i] = applyReplacement(o, r)
+			}
+			f.OptOutPathRegexes = optOut
+			allowed := f.GetAllowedPathRegexes()
+			for i, a := range allowed {
+				allowed[i] = applyReplacement(a, r)
+			}
+			f.AllowedPathRegexes = allowed
+		case fileSet.GetGlob() != nil:
+			g := result.GetGlob()
+			g.Pattern = applyReplacement(g.GetPattern(), r)
+			optOut := g.GetOptOutPathRegexes()
+			for i, o := range optOut {
+				optOut[i] = applyReplacement(o, r)
+			}
+			g.OptOutPathRegexes = optOut
+		case fileSet.GetIncludedFiles() != nil:
+			f := result.GetIncludedFiles()
+			f.RootPath = applyReplacement(f.GetRootPath(), r)
+			f.BaseDir = applyReplacement(f.GetBaseDir(), r)
+			optOut := f.GetOptOutPathRegexes()
+			for i, o := range optOut {
+				optOut[i] = applyReplacement(o, r)
+			}
+			f.OptOutPathRegexes = optOut
+		case fileSet.GetPackageFiles() != nil:
+			f := result.GetPackageFiles()
+			f.PackageName = applyReplacement(f.GetPackageName(), r)
+			optOut := f.GetOptOutPathRegexes()
+			for i, o := range optOut {
+				optOut[i] = applyReplacement(o, r)
+			}
+			f.OptOutPathRegexes = optOut
 		}
 	}
 	return result
@@ -376,6 +775,19 @@ func applyRepeatConfigToContentEntryCheck(check *ipb.ContentEntryCheck, replacem
 	}
 }
 
+func applyRepeatConfigToAuditRuleCheck(check *ipb.AuditRuleCheck, replacement *TokenReplacement) {
+	for i, rule := range check.GetExpectedRules() {
+		check.ExpectedRules[i] = applyReplacement(rule, replacement)
+	}
+}
+
+func applyRepeatConfigToSelinuxLabelCheck(check *ipb.SelinuxLabelCheck, replacement *TokenReplacement) {
+	check.UserRegex = applyReplacement(check.GetUserRegex(), replacement)
+	check.RoleRegex = applyReplacement(check.GetRoleRegex(), replacement)
+	check.TypeRegex = applyReplacement(check.GetTypeRegex(), replacement)
+	check.LevelRegex = applyReplacement(check.GetLevelRegex(), replacement)
+}
+
 func applyReplacement(str string, replacement *TokenReplacement) string {
 	return strings.ReplaceAll(str, replacement.TextToReplace, replacement.ReplaceWith)
 }
@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"os"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -49,10 +51,30 @@ func (fakeFileReader) FilePermissions(ctx context.Context, path string) (*apb.Po
 	return nil, errors.New("Not implemented")
 }
 
+func (fakeFileReader) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (fakeFileReader) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("Not implemented")
+}
+
 func (fakeFileReader) OpenDir(ctx context.Context, path string) (scanapi.DirReader, error) {
 	return nil, errors.New("Not implemented")
 }
 
+func (fakeFileReader) SQLQuery(ctx context.Context, query string) (string, error) {
+	return "", errors.New("Not implemented")
+}
+
+func (fakeFileReader) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (fakeFileReader) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
+	return ipb.SQLCheck_DB_UNSPECIFIED, errors.New("Not implemented")
+}
+
 func configHasError(config []*repeatconfig.RepeatConfig) bool {
 	for _, c := range config {
 		if c.Err != nil {
@@ -306,7 +328,7 @@ func TestCreateRepeatConfigsForEachSystemUser(t *testing.T) {
 }
 
 func TestCreateRepeatConfigsInvalidRepeatOption(t *testing.T) {
-	config := &ipb.RepeatConfig{Type: 10}
+	config := &ipb.RepeatConfig{Type: 100}
 	if _, err := repeatconfig.CreateRepeatConfigs(context.Background(), config, &fakeFileReader{}); err == nil {
 		t.Fatalf("repeatconfig.CreateRepeatConfigs(%v) didn't return an error: %v", config, err)
 	}
@@ -324,6 +346,34 @@ func TestCreateRepeatConfigsInvalidPasswd(t *testing.T) {
 	}
 }
 
+func TestCreateRepeatConfigsForEachUserMalformedIDOnlyFailsThatUser(t *testing.T) {
+	passwd := "user1:x:1337:1338::/home/user1:/bin/bash\n" +
+		"user2:x:4337:invalid::/home/user2:/bin/bash"
+	config := &ipb.RepeatConfig{Type: ipb.RepeatConfig_FOR_EACH_USER}
+	got, err := repeatconfig.CreateRepeatConfigs(context.Background(), config, &fakeFileReader{content: passwd})
+	if err != nil {
+		t.Fatalf("repeatconfig.CreateRepeatConfigs(%v) returned an error: %v", config, err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("repeatconfig.CreateRepeatConfigs(%v) returned %d configs, expected 2", config, len(got))
+	}
+	want := &repeatconfig.RepeatConfig{
+		TokenReplacements: []*repeatconfig.TokenReplacement{
+			{TextToReplace: "$user", ReplaceWith: "user1"},
+			{TextToReplace: "$uid", ReplaceWith: "1337"},
+			{TextToReplace: "$gid", ReplaceWith: "1338"},
+			{TextToReplace: "$home", ReplaceWith: "/home/user1"},
+			{TextToReplace: "$shell", ReplaceWith: "/bin/bash"},
+		},
+	}
+	if diff := cmp.Diff(want, got[0], cmp.AllowUnexported(repeatconfig.RepeatConfig{}, repeatconfig.TokenReplacement{})); diff != "" {
+		t.Errorf("repeatconfig.CreateRepeatConfigs(%v) returned unexpected diff for the valid user (-want +got):\n%s", config, diff)
+	}
+	if got[1].Err == nil {
+		t.Errorf("repeatconfig.CreateRepeatConfigs(%v) didn't return an error for the user with an invalid GID", config)
+	}
+}
+
 func TestCreateRepeatConfigForEachOpenIpv4Port(t *testing.T) {
 	testCases := []struct {
 		desc       string
@@ -500,6 +550,552 @@ func TestCreateRepeatConfigForEachOpenPortInvalidTCPContent(t *testing.T) {
 	}
 }
 
+// fakeFilesystem serves files, directory listings, symlink targets and query
+// results from maps.
+type fakeFilesystem struct {
+	files       map[string]string
+	dirs        map[string][]*apb.DirContent
+	linkTargets map[string]string
+	queryRows   map[string][]string
+}
+
+func (f *fakeFilesystem) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	content, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+func (f *fakeFilesystem) FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (f *fakeFilesystem) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	target, ok := f.linkTargets[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &apb.FileStat{Type: apb.FileStat_SYMLINK, LinkTarget: target}, nil
+}
+
+func (f *fakeFilesystem) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("Not implemented")
+}
+
+func (f *fakeFilesystem) OpenDir(ctx context.Context, path string) (scanapi.DirReader, error) {
+	contents, ok := f.dirs[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return scanapi.SliceToDirReader(contents), nil
+}
+
+func (f *fakeFilesystem) SQLQuery(ctx context.Context, query string) (string, error) {
+	return "", errors.New("Not implemented")
+}
+
+func (f *fakeFilesystem) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	rows, ok := f.queryRows[query]
+	if !ok {
+		return nil, fmt.Errorf("unsupported query %q", query)
+	}
+	return rows, nil
+}
+
+func (f *fakeFilesystem) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
+	return ipb.SQLCheck_DB_MYSQL, nil
+}
+
+func TestCreateRepeatConfigsForEachSystemObject(t *testing.T) {
+	fs := &fakeFilesystem{
+		files: map[string]string{
+			"/etc/group": "root:x:0:\nsudo:x:27:alice,bob\n",
+			"/proc/self/mountinfo": "22 1 8:1 / / rw,relatime - ext4 /dev/sda1 rw\n" +
+				"23 22 0:21 / /tmp rw,nosuid,nodev - tmpfs tmpfs rw\n",
+			"/etc/fstab": "/dev/sda2 /home ext4 defaults,nodev 0 2\n",
+			"/etc/ssh/sshd_config": "Port 22\n" +
+				"Subsystem sftp /usr/lib/openssh/sftp-server\n" +
+				"  Subsystem  backup /usr/bin/backup\n" +
+				"Subsystem sftp /usr/lib/openssh/sftp-server\n",
+			"/proc/1/comm": "systemd\n",
+			"/proc/2/comm": "kthreadd\n",
+			"/proc/net/udp": "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n" +
+				"  1: 00000000:00A1 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 16380 2 0000000000000000 0\n" +
+				"  2: 0100007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 16381 2 0000000000000000 0\n" +
+				"  3: 0F02000A:9C40 08080808:0035 01 00000000:00000000 00:00000000 00000000  1000        0 16382 2 0000000000000000 0\n",
+			"/proc/net/udp6": "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n" +
+				"  1: 00000000000000000000000000000000:0045 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 16383 2 0000000000000000 0\n",
+			"/proc/net/unix": "Num       RefCount Protocol Flags    Type St Inode Path\n" +
+				"0000000000000000: 00000002 00000000 00010000 0001 01 20001 /run/systemd/private\n" +
+				"0000000000000000: 00000002 00000000 00010000 0001 01 20002 @/tmp/.X11-unix/X0\n" +
+				"0000000000000000: 00000003 00000000 00000000 0001 03 20003 /run/systemd/private\n" +
+				"0000000000000000: 00000002 00000000 00000000 0002 01 20004 /run/systemd/journal/dev-log\n",
+		},
+		dirs: map[string][]*apb.DirContent{
+			"/proc": {
+				{Name: "1", IsDir: true},
+				{Name: "2", IsDir: true},
+				// Exited after being listed.
+				{Name: "3", IsDir: true},
+				{Name: "self", IsDir: true},
+				{Name: "cpuinfo"},
+			},
+		},
+		linkTargets: map[string]string{"/proc/1/exe": "/usr/lib/systemd/systemd"},
+		queryRows: map[string][]string{
+			"SHOW DATABASES": {"mysql", "wordpress"},
+			"SHOW USERS":     {},
+		},
+	}
+	testCases := []struct {
+		desc       string
+		configType ipb.RepeatConfig_RepeatType
+		wildcard   string
+		values     []string
+		filePath   string
+		regex      string
+		query      string
+		want       []*repeatconfig.RepeatConfig
+	}{
+		{
+			desc:       "groups",
+			configType: ipb.RepeatConfig_FOR_EACH_GROUP,
+			want: []*repeatconfig.RepeatConfig{
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$group", ReplaceWith: "root"},
+						{TextToReplace: "$gid", ReplaceWith: "0"},
+						{TextToReplace: "$members", ReplaceWith: ""},
+					},
+				},
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$group", ReplaceWith: "sudo"},
+						{TextToReplace: "$gid", ReplaceWith: "27"},
+						{TextToReplace: "$members", ReplaceWith: "alice,bob"},
+					},
+				},
+			},
+		},
+		{
+			desc:       "mounts",
+			configType: ipb.RepeatConfig_FOR_EACH_MOUNT,
+			want: []*repeatconfig.RepeatConfig{
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$mountpoint", ReplaceWith: "/"},
+						{TextToReplace: "$fstype", ReplaceWith: "ext4"},
+						{TextToReplace: "$options", ReplaceWith: "rw,relatime"},
+					},
+				},
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$mountpoint", ReplaceWith: "/tmp"},
+						{TextToReplace: "$fstype", ReplaceWith: "tmpfs"},
+						{TextToReplace: "$options", ReplaceWith: "rw,nosuid,nodev"},
+					},
+				},
+			},
+		},
+		{
+			desc:       "configured mounts",
+			configType: ipb.RepeatConfig_FOR_EACH_CONFIGURED_MOUNT,
+			want: []*repeatconfig.RepeatConfig{
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$mountpoint", ReplaceWith: "/home"},
+						{TextToReplace: "$fstype", ReplaceWith: "ext4"},
+						{TextToReplace: "$options", ReplaceWith: "defaults,nodev"},
+					},
+				},
+			},
+		},
+		{
+			desc:       "processes",
+			configType: ipb.RepeatConfig_FOR_EACH_PROCESS,
+			want: []*repeatconfig.RepeatConfig{
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$pid", ReplaceWith: "1"},
+						{TextToReplace: "$comm", ReplaceWith: "systemd"},
+						{TextToReplace: "$exe", ReplaceWith: "/usr/lib/systemd/systemd"},
+					},
+				},
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$pid", ReplaceWith: "2"},
+						{TextToReplace: "$comm", ReplaceWith: "kthreadd"},
+						{TextToReplace: "$exe", ReplaceWith: ""},
+					},
+				},
+			},
+		},
+		{
+			desc:       "values",
+			configType: ipb.RepeatConfig_FOR_EACH_VALUE,
+			wildcard:   "$module",
+			values:     []string{"cramfs", "udf"},
+			want: []*repeatconfig.RepeatConfig{
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$module", ReplaceWith: "cramfs"}}},
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$module", ReplaceWith: "udf"}}},
+			},
+		},
+		{
+			desc:       "regex matches",
+			configType: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH,
+			filePath:   "/etc/ssh/sshd_config",
+			regex:      `^\s*Subsystem\s+(?P<subsystem>\S+)\s+(\S+)`,
+			want: []*repeatconfig.RepeatConfig{
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$subsystem", ReplaceWith: "sftp"}}},
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$subsystem", ReplaceWith: "backup"}}},
+			},
+		},
+		{
+			desc:       "regex matches with several wildcards",
+			configType: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH,
+			filePath:   "/etc/ssh/sshd_config",
+			regex:      `^\s*Subsystem\s+(?P<subsystem>\S+)\s+(?P<command>\S+)`,
+			want: []*repeatconfig.RepeatConfig{
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$subsystem", ReplaceWith: "sftp"},
+						{TextToReplace: "$command", ReplaceWith: "/usr/lib/openssh/sftp-server"},
+					},
+				},
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$subsystem", ReplaceWith: "backup"},
+						{TextToReplace: "$command", ReplaceWith: "/usr/bin/backup"},
+					},
+				},
+			},
+		},
+		{
+			desc:       "regex matches in nonexistent file",
+			configType: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH,
+			filePath:   "/etc/nonexistent",
+			regex:      `(?P<line>.*)`,
+			want:       []*repeatconfig.RepeatConfig{},
+		},
+		{
+			desc:       "UDP IPv4 ports",
+			configType: ipb.RepeatConfig_FOR_EACH_OPEN_UDP_IPV4_PORT,
+			want: []*repeatconfig.RepeatConfig{
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$port", ReplaceWith: "161"}}},
+			},
+		},
+		{
+			desc:       "UDP IPv6 ports",
+			configType: ipb.RepeatConfig_FOR_EACH_OPEN_UDP_IPV6_PORT,
+			want: []*repeatconfig.RepeatConfig{
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$port", ReplaceWith: "69"}}},
+			},
+		},
+		{
+			desc:       "Unix sockets",
+			configType: ipb.RepeatConfig_FOR_EACH_UNIX_SOCKET,
+			want: []*repeatconfig.RepeatConfig{
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$socket", ReplaceWith: "/run/systemd/private"}}},
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$socket", ReplaceWith: "/run/systemd/journal/dev-log"}}},
+			},
+		},
+		{
+			desc:       "query results",
+			configType: ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+			wildcard:   "$database",
+			query:      "SHOW DATABASES",
+			want: []*repeatconfig.RepeatConfig{
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$database", ReplaceWith: "mysql"}}},
+				{TokenReplacements: []*repeatconfig.TokenReplacement{{TextToReplace: "$database", ReplaceWith: "wordpress"}}},
+			},
+		},
+		{
+			desc:       "no query results",
+			configType: ipb.RepeatConfig_FOR_EACH_QUERY_RESULT,
+			wildcard:   "$user",
+			query:      "SHOW USERS",
+			want:       []*repeatconfig.RepeatConfig{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			config := &ipb.RepeatConfig{
+				Type:     tc.configType,
+				Wildcard: tc.wildcard,
+				Values:   tc.values,
+				FilePath: tc.filePath,
+				Regex:    tc.regex,
+				Query:    tc.query,
+			}
+			got, err := repeatconfig.CreateRepeatConfigs(context.Background(), config, fs)
+			if err != nil {
+				t.Fatalf("repeatconfig.CreateRepeatConfigs(%v) returned an error: %v", config, err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(repeatconfig.RepeatConfig{}, repeatconfig.TokenReplacement{})); diff != "" {
+				t.Errorf("repeatconfig.CreateRepeatConfigs(%v) returned unexpected diff (-want +got):\n%s", config, diff)
+			}
+		})
+	}
+}
+
+func TestCreateCombinedRepeatConfigs(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		"/etc/group": "root:x:0:\nsudo:x:27:alice,bob\n",
+		"/proc/net/unix": "Num       RefCount Protocol Flags    Type St Inode Path\n" +
+			"0000000000000000: 00000002 00000000 00010000 0001 01 20001 /run/a.sock\n" +
+			"0000000000000000: 00000002 00000000 00010000 0001 01 20002 /run/b.sock\n",
+	}}
+	testCases := []struct {
+		desc          string
+		repeatOptions []*ipb.RepeatConfig
+		want          []*repeatconfig.RepeatConfig
+	}{
+		{
+			desc: "no configs",
+			want: []*repeatconfig.RepeatConfig{{}},
+		},
+		{
+			desc: "cartesian product",
+			repeatOptions: []*ipb.RepeatConfig{
+				{
+					Type:   ipb.RepeatConfig_FOR_EACH_GROUP,
+					OptOut: []*ipb.RepeatConfig_OptOutSubstitution{{Wildcard: "$group", Value: "root"}},
+				},
+				{Type: ipb.RepeatConfig_ONCE},
+				{Type: ipb.RepeatConfig_FOR_EACH_UNIX_SOCKET},
+			},
+			want: []*repeatconfig.RepeatConfig{
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$group", ReplaceWith: "sudo"},
+						{TextToReplace: "$gid", ReplaceWith: "27"},
+						{TextToReplace: "$members", ReplaceWith: "alice,bob"},
+						{TextToReplace: "$socket", ReplaceWith: "/run/a.sock"},
+					},
+				},
+				{
+					TokenReplacements: []*repeatconfig.TokenReplacement{
+						{TextToReplace: "$group", ReplaceWith: "sudo"},
+						{TextToReplace: "$gid", ReplaceWith: "27"},
+						{TextToReplace: "$members", ReplaceWith: "alice,bob"},
+						{TextToReplace: "$socket", ReplaceWith: "/run/b.sock"},
+					},
+				},
+			},
+		},
+		{
+			desc: "empty factor",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_UNIX_SOCKET},
+				{
+					Type:   ipb.RepeatConfig_FOR_EACH_GROUP,
+					OptOut: []*ipb.RepeatConfig_OptOutSubstitution{{Wildcard: "$members", Value: ""}, {Wildcard: "$gid", Value: "27"}},
+				},
+			},
+			want: []*repeatconfig.RepeatConfig{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := repeatconfig.CreateCombinedRepeatConfigs(context.Background(), tc.repeatOptions, fs)
+			if err != nil {
+				t.Fatalf("repeatconfig.CreateCombinedRepeatConfigs(%v) returned an error: %v", tc.repeatOptions, err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(repeatconfig.RepeatConfig{}, repeatconfig.TokenReplacement{})); diff != "" {
+				t.Errorf("repeatconfig.CreateCombinedRepeatConfigs(%v) returned unexpected diff (-want +got):\n%s", tc.repeatOptions, diff)
+			}
+		})
+	}
+}
+
+func TestCreateCombinedRepeatConfigsAreNested(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		"/etc/passwd":         "alice:x:1000:1000::/home/alice:/bin/bash\nbob:x:1001:1001::/home/bob:/bin/bash\n",
+		"/home/alice/.bashrc": "alias ll='ls -l'\n",
+		"/home/bob/.bashrc":   "alias la='ls -a'\nalias l='ls'\n",
+	}}
+	repeatOptions := []*ipb.RepeatConfig{
+		{Type: ipb.RepeatConfig_FOR_EACH_USER},
+		{
+			Type:     ipb.RepeatConfig_FOR_EACH_REGEX_MATCH,
+			FilePath: "$home/.bashrc",
+			Regex:    `^alias (?P<alias>\w+)=`,
+		},
+	}
+	got, err := repeatconfig.CreateCombinedRepeatConfigs(context.Background(), repeatOptions, fs)
+	if err != nil {
+		t.Fatalf("repeatconfig.CreateCombinedRepeatConfigs(%v) returned an error: %v", repeatOptions, err)
+	}
+	want := []string{"alice ll", "bob la", "bob l"}
+	gotPairs := []string{}
+	for _, rc := range got {
+		if rc.Err != nil {
+			t.Fatalf("repeatconfig.CreateCombinedRepeatConfigs(%v) returned a config with an error: %v", repeatOptions, rc.Err)
+		}
+		values := make(map[string]string)
+		for _, r := range rc.TokenReplacements {
+			values[r.TextToReplace] = r.ReplaceWith
+		}
+		gotPairs = append(gotPairs, values["$user"]+" "+values["$alias"])
+	}
+	if diff := cmp.Diff(want, gotPairs); diff != "" {
+		t.Errorf("repeatconfig.CreateCombinedRepeatConfigs(%v) returned unexpected users and aliases (-want +got):\n%s", repeatOptions, diff)
+	}
+}
+
+func TestCreateCombinedRepeatConfigsPropagatesErrors(t *testing.T) {
+	repeatOptions := []*ipb.RepeatConfig{
+		{Type: ipb.RepeatConfig_FOR_EACH_GROUP},
+		{Type: ipb.RepeatConfig_FOR_EACH_OPEN_IPV4_PORT},
+	}
+	fs := &fakeFilesystem{files: map[string]string{"/etc/group": "root:x:0:\n"}}
+	got, err := repeatconfig.CreateCombinedRepeatConfigs(context.Background(), repeatOptions, fs)
+	if err != nil {
+		t.Fatalf("repeatconfig.CreateCombinedRepeatConfigs(%v) returned an error: %v", repeatOptions, err)
+	}
+	if len(got) != 1 || !configHasError(got) {
+		t.Errorf("repeatconfig.CreateCombinedRepeatConfigs(%v) returned %v, expected a single config with an error", repeatOptions, got)
+	}
+}
+
+func TestValidateRepeatConfigs(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		repeatOptions []*ipb.RepeatConfig
+		wantErr       bool
+	}{
+		{
+			desc: "distinct wildcards",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_USER_WITH_LOGIN},
+				{Type: ipb.RepeatConfig_FOR_EACH_OPEN_IPV4_PORT},
+				{Type: ipb.RepeatConfig_FOR_EACH_MOUNT},
+			},
+		},
+		{
+			desc: "same wildcard",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_OPEN_IPV4_PORT},
+				{Type: ipb.RepeatConfig_FOR_EACH_OPEN_UDP_IPV4_PORT},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "shared wildcard between types",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_USER},
+				{Type: ipb.RepeatConfig_FOR_EACH_GROUP},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "opt-out for wildcard of another config",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_USER},
+				{
+					Type:   ipb.RepeatConfig_FOR_EACH_MOUNT,
+					OptOut: []*ipb.RepeatConfig_OptOutSubstitution{{Wildcard: "$user", Value: "root"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "custom wildcards",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_USER},
+				{Type: ipb.RepeatConfig_FOR_EACH_VALUE, Wildcard: "$module", Values: []string{"udf"}},
+				{
+					Type:     ipb.RepeatConfig_FOR_EACH_REGEX_MATCH,
+					FilePath: "/etc/ssh/sshd_config",
+					Regex:    `^Subsystem (?P<subsystem>\S+)`,
+					OptOut:   []*ipb.RepeatConfig_OptOutSubstitution{{Wildcard: "$subsystem", Value: "sftp"}},
+				},
+			},
+		},
+		{
+			desc: "custom wildcard overlapping with built-in one",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_USER},
+				{Type: ipb.RepeatConfig_FOR_EACH_VALUE, Wildcard: "$username", Values: []string{"root"}},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid custom wildcard",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_VALUE, Wildcard: "module", Values: []string{"udf"}},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "no values",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_VALUE, Wildcard: "$module"},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "no file path",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH, Regex: "(?P<value>.*)"},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid regex",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH, FilePath: "/etc/hosts", Regex: "(?P<value>"},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "regex without named groups",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH, FilePath: "/etc/hosts", Regex: "(.*)"},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "regex with overlapping named groups",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_REGEX_MATCH, FilePath: "/etc/hosts", Regex: "(?P<ip>\\S+) (?P<ipv6>\\S+)"},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid query wildcard",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_QUERY_RESULT, Query: "SHOW DATABASES"},
+			},
+			wantErr: true,
+		},
+		{
+			desc: "no query",
+			repeatOptions: []*ipb.RepeatConfig{
+				{Type: ipb.RepeatConfig_FOR_EACH_QUERY_RESULT, Wildcard: "$database"},
+			},
+			wantErr: true,
+		},
+		{
+			desc:          "unknown type",
+			repeatOptions: []*ipb.RepeatConfig{{Type: 100}},
+			wantErr:       true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := repeatconfig.ValidateRepeatConfigs(tc.repeatOptions)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("repeatconfig.ValidateRepeatConfigs(%v) returned error %v, want error: %t", tc.repeatOptions, err, tc.wantErr)
+			}
+		})
+	}
+}
+
 func TestCreateRepeatConfigsWithOptOut(t *testing.T) {
 	passwd := "user1:x:1337:1338::/home/user1:/bin/bash\n" +
 		"user2:x:2337:2338::/home/user2:/bin/bash\n" +
@@ -617,6 +1213,53 @@ func TestApplyRepeatConfigToInstruction(t *testing.T) {
 				}},
 			},
 		},
+		{
+			desc: "audit rule",
+			instruction: &ipb.FileCheck{
+				CheckType: &ipb.FileCheck_AuditRule{AuditRule: &ipb.AuditRuleCheck{
+					ExpectedRules: []string{"-w $home/.bashrc -p wa", "-a always,exit -F auid=$user"},
+				}},
+			},
+			want: &ipb.FileCheck{
+				CheckType: &ipb.FileCheck_AuditRule{AuditRule: &ipb.AuditRuleCheck{
+					ExpectedRules: []string{"-w /root/.bashrc -p wa", "-a always,exit -F auid=root"},
+				}},
+			},
+		},
+		{
+			desc: "file metadata",
+			instruction: &ipb.FileCheck{
+				CheckType: &ipb.FileCheck_FileMetadata{FileMetadata: &ipb.FileMetadataCheck{
+					FileType:   ipb.FileMetadataCheck_SYMLINK,
+					LinkTarget: "$home/.profile",
+				}},
+			},
+			want: &ipb.FileCheck{
+				CheckType: &ipb.FileCheck_FileMetadata{FileMetadata: &ipb.FileMetadataCheck{
+					FileType:   ipb.FileMetadataCheck_SYMLINK,
+					LinkTarget: "/root/.profile",
+				}},
+			},
+		},
+		{
+			desc: "selinux label",
+			instruction: &ipb.FileCheck{
+				CheckType: &ipb.FileCheck_SelinuxLabel{SelinuxLabel: &ipb.SelinuxLabelCheck{
+					UserRegex:  "$user_u",
+					RoleRegex:  "object_r",
+					TypeRegex:  "$user_home_t",
+					LevelRegex: "s0",
+				}},
+			},
+			want: &ipb.FileCheck{
+				CheckType: &ipb.FileCheck_SelinuxLabel{SelinuxLabel: &ipb.SelinuxLabelCheck{
+					UserRegex:  "root_u",
+					RoleRegex:  "object_r",
+					TypeRegex:  "root_home_t",
+					LevelRegex: "s0",
+				}},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -630,6 +1273,35 @@ func TestApplyRepeatConfigToInstruction(t *testing.T) {
 	}
 }
 
+func TestApplyRepeatConfigToSQLCheck(t *testing.T) {
+	config := &repeatconfig.RepeatConfig{
+		TokenReplacements: []*repeatconfig.TokenReplacement{
+			{TextToReplace: "$database", ReplaceWith: "wordpress"},
+		},
+	}
+	check := &ipb.SQLCheck{
+		TargetDatabase:   ipb.SQLCheck_DB_MYSQL,
+		Query:            "SELECT 1 FROM $database.users WHERE password = ''",
+		ExpectResults:    false,
+		NonComplianceMsg: "Users without password in $database",
+		FilterRegex:      ".*$database.*",
+	}
+	want := &ipb.SQLCheck{
+		TargetDatabase:   ipb.SQLCheck_DB_MYSQL,
+		Query:            "SELECT 1 FROM wordpress.users WHERE password = ''",
+		ExpectResults:    false,
+		NonComplianceMsg: "Users without password in wordpress",
+		FilterRegex:      ".*wordpress.*",
+	}
+	got := repeatconfig.ApplyRepeatConfigToSQLCheck(check, config)
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("repeatconfig.ApplyRepeatConfigToSQLCheck(%v %v) returned unexpected diff (-want +got):\n%s", check, config, diff)
+	}
+	if check.GetQuery() != "SELECT 1 FROM $database.users WHERE password = ''" {
+		t.Errorf("repeatconfig.ApplyRepeatConfigToSQLCheck(%v %v) modified the original check", check, config)
+	}
+}
+
 func TestApplyRepeatConfigToFile(t *testing.T) {
 	config := &repeatconfig.RepeatConfig{
 		TokenReplacements: []*repeatconfig.TokenReplacement{
@@ -689,6 +1361,55 @@ func TestApplyRepeatConfigToFile(t *testing.T) {
 				}},
 			},
 		},
+		{
+			desc: "files matching predicate",
+			file: &ipb.FileSet{
+				FilePath: &ipb.FileSet_FilesMatchingPredicate_{FilesMatchingPredicate: &ipb.FileSet_FilesMatchingPredicate{
+					DirPath:            "/home/$user",
+					OptOutPathRegexes:  []string{"/home/$user/tmp/.*"},
+					AllowedPathRegexes: []string{"/home/$user/\\.ssh/.*"},
+				}},
+			},
+			want: &ipb.FileSet{
+				FilePath: &ipb.FileSet_FilesMatchingPredicate_{FilesMatchingPredicate: &ipb.FileSet_FilesMatchingPredicate{
+					DirPath:            "/home/sundar",
+					OptOutPathRegexes:  []string{"/home/sundar/tmp/.*"},
+					AllowedPathRegexes: []string{"/home/sundar/\\.ssh/.*"},
+				}},
+			},
+		},
+		{
+			desc: "included files",
+			file: &ipb.FileSet{
+				FilePath: &ipb.FileSet_IncludedFiles_{IncludedFiles: &ipb.FileSet_IncludedFiles{
+					RootPath:          "/home/$user/.config/app.conf",
+					BaseDir:           "/home/$user",
+					OptOutPathRegexes: []string{"/home/$user/tmp/.*"},
+				}},
+			},
+			want: &ipb.FileSet{
+				FilePath: &ipb.FileSet_IncludedFiles_{IncludedFiles: &ipb.FileSet_IncludedFiles{
+					RootPath:          "/home/sundar/.config/app.conf",
+					BaseDir:           "/home/sundar",
+					OptOutPathRegexes: []string{"/home/sundar/tmp/.*"},
+				}},
+			},
+		},
+		{
+			desc: "package files",
+			file: &ipb.FileSet{
+				FilePath: &ipb.FileSet_PackageFiles_{PackageFiles: &ipb.FileSet_PackageFiles{
+					PackageName:       "$user-tools",
+					OptOutPathRegexes: []string{"/usr/share/doc/$user-tools/.*"},
+				}},
+			},
+			want: &ipb.FileSet{
+				FilePath: &ipb.FileSet_PackageFiles_{PackageFiles: &ipb.FileSet_PackageFiles{
+					PackageName:       "sundar-tools",
+					OptOutPathRegexes: []string{"/usr/share/doc/sundar-tools/.*"},
+				}},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -28,6 +28,7 @@ import (
 	cpb "github.com/google/localtoast/scannerlib/proto/compliance_go_proto"
 	"github.com/google/localtoast/scanapi"
 	"github.com/google/localtoast/scannerlib/configchecks"
+	"github.com/google/localtoast/scannerlib/facts"
 
 	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
@@ -44,13 +45,18 @@ func (Scanner) Scan(ctx context.Context, config *apb.ScanConfig, api scanapi.Sca
 		return nil, err
 	}
 	scanStartTime := time.Now()
-	checks, err := configchecks.CreateChecksFromConfig(ctx, config, &apiErrorWrapper{api: api})
+	wrappedAPI := &apiErrorWrapper{api: api}
+	collector := facts.NewCollector(wrappedAPI)
+	checks, paramValues, err := configchecks.CreateChecksFromConfigWithFacts(ctx, config, wrappedAPI, collector)
 	if err != nil {
 		return nil, err
 	}
 
 	checkResults, benchmarkErrors := executeChecks(checks)
 	configchecks.AddBenchmarkVersionToResults(checkResults, benchmarkConfigs)
+	if err := configchecks.AddParameterValuesToResults(checkResults, paramValues); err != nil {
+		return nil, err
+	}
 	complianceResults := determineBenchmarkCompliance(checks, checkResults, benchmarkErrors)
 
 	benchmarkVersion, err := oldestBenchmarkVersion(config.GetBenchmarkConfigs())
@@ -58,11 +64,15 @@ func (Scanner) Scan(ctx context.Context, config *apb.ScanConfig, api scanapi.Sca
 		fmt.Fprintf(os.Stderr, "Error while determining oldest benchmark version: %v\n", err)
 		benchmarkVersion = "0.0.0"
 	}
+	// The facts are only collected, and included in the results, if the checks
+	// use them.
+	hostFacts := collector.Collected()
 
 	options := newScanResultsOptions{
 		startTime:              scanStartTime,
 		benchmarkVersion:       benchmarkVersion,
 		benchmarkDocument:      getBenchmarkDocument(config.GetBenchmarkConfigs()),
+		facts:                  hostFacts,
 		compliantBenchmarks:    complianceResults.compliantBenchmarks,
 		nonCompliantBenchmarks: complianceResults.nonCompliantBenchmarks,
 	}
@@ -260,6 +270,7 @@ type newScanResultsOptions struct {
 	startTime              time.Time
 	benchmarkVersion       string
 	benchmarkDocument      string
+	facts                  *apb.HostFacts
 	compliantBenchmarks    []*apb.ComplianceResult
 	nonCompliantBenchmarks []*apb.ComplianceResult
 	status                 apb.ScanStatus_ScanStatusEnum
@@ -279,6 +290,7 @@ func newScanResults(options newScanResultsOptions) *apb.ScanResults {
 		},
 		CompliantBenchmarks:    options.compliantBenchmarks,
 		NonCompliantBenchmarks: options.nonCompliantBenchmarks,
+		Facts:                  options.facts,
 	}
 }
 
@@ -60,6 +60,12 @@ func (fakeAPIProvider) OpenDir(ctx context.Context, path string) (scanapi.DirRea
 func (fakeAPIProvider) FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, error) {
 	return nil, errors.New("not implemented")
 }
+func (fakeAPIProvider) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	return nil, errors.New("not implemented")
+}
+func (fakeAPIProvider) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
 func (fakeAPIProvider) SQLQuery(ctx context.Context, query string) (string, error) {
 	switch query {
 	case testQueryNoRows:
@@ -70,6 +76,9 @@ func (fakeAPIProvider) SQLQuery(ctx context.Context, query string) (string, erro
 		return "", fmt.Errorf("the query %q is not supported by fakeAPIProvider", query)
 	}
 }
+func (fakeAPIProvider) SQLQueryRows(ctx context.Context, query string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
 func (fakeAPIProvider) SupportedDatabase() (ipb.SQLCheck_SQLDatabase, error) {
 	return ipb.SQLCheck_DB_MYSQL, nil
 }
@@ -92,6 +101,40 @@ func TestScannerVersion(t *testing.T) {
 	}
 }
 
+// openRecordingAPIProvider records the files opened through it.
+type openRecordingAPIProvider struct {
+	fakeAPIProvider
+	opened []string
+}
+
+func (p *openRecordingAPIProvider) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	p.opened = append(p.opened, path)
+	return p.fakeAPIProvider.OpenFile(ctx, path)
+}
+
+func TestScanWithoutFactsDoesntCollectThem(t *testing.T) {
+	check := []*ipb.FileCheck{{
+		FilesToCheck: []*ipb.FileSet{testconfigcreator.SingleFileWithPath(testFilePath1)},
+		CheckType:    &ipb.FileCheck_Content{Content: &ipb.ContentCheck{Content: testFileContent1}},
+	}}
+	config := &apb.ScanConfig{
+		BenchmarkConfigs: []*apb.BenchmarkConfig{
+			testconfigcreator.NewBenchmarkConfig(t, "id", testconfigcreator.NewFileScanInstruction(check)),
+		},
+	}
+	api := &openRecordingAPIProvider{}
+	result, err := scannerlib.Scanner{}.Scan(context.Background(), config, api)
+	if err != nil {
+		t.Fatalf("scannerlib.Scan(%v) had unexpected error: %v", config, err)
+	}
+	if result.GetFacts() != nil {
+		t.Errorf("scannerlib.Scan(%v) returned facts %v, expected none", config, result.GetFacts())
+	}
+	if diff := cmp.Diff([]string{testFilePath1}, api.opened); diff != "" {
+		t.Errorf("scannerlib.Scan(%v) opened unexpected files (-want +got):\n%s", config, diff)
+	}
+}
+
 func TestCompliantScan(t *testing.T) {
 	compliantCheck := []*ipb.FileCheck{
 		&ipb.FileCheck{
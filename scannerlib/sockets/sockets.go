@@ -0,0 +1,353 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sockets provides utilities for reading the network and Unix domain
+// sockets of the scanned machine and the processes that own them from /proc.
+package sockets
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/localtoast/scanapi"
+)
+
+// The protocols of the inet socket tables in /proc/net.
+const (
+	TCP  = "tcp"
+	TCP6 = "tcp6"
+	UDP  = "udp"
+	UDP6 = "udp6"
+)
+
+// UnixSocketsPath is the path of the file listing the Unix domain sockets.
+const UnixSocketsPath = "/proc/net/unix"
+
+const (
+	// The TCP states used by the kernel, see include/net/tcp_states.h.
+	// Bound but unconnected UDP sockets are in the TCP_CLOSE state.
+	tcpListen = 0x0a
+	tcpClose  = 0x07
+
+	// The __SO_ACCEPTCON flag set on Unix sockets that accept connections.
+	unixAcceptCon = 0x10000
+	// The SS_UNCONNECTED state of Unix sockets.
+	unixUnconnected = 1
+	// The SOCK_DGRAM socket type.
+	unixDgram = 2
+)
+
+var (
+	// Regexp for capturing the local address, remote address, state and inode
+	// from a line of /proc/net/{tcp,udp}(6).
+	procInetRe = regexp.MustCompile(`^\s*[0-9]+:\s+([0-9A-F]+):([0-9A-F]{4})\s+([0-9A-F]+):([0-9A-F]{4})\s+([0-9A-F]{2})\s+[0-9A-F]+:[0-9A-F]+\s+[0-9A-F]+:[0-9A-F]+\s+[0-9A-F]+\s+[0-9]+\s+[0-9]+\s+([0-9]+)(\s.*)?$`)
+	// Regexp for capturing the flags, type, state, inode and path from a line
+	// of /proc/net/unix. The path is the rest of the line and may contain
+	// spaces.
+	procUnixRe = regexp.MustCompile(`^\s*[0-9a-f]+:\s+[0-9A-F]+\s+[0-9A-F]+\s+([0-9A-F]+)\s+([0-9A-F]+)\s+([0-9A-F]+)\s+([0-9]+)(?: (.*))?$`)
+	// Regexp for the link targets of socket file descriptors in /proc/<pid>/fd.
+	socketLinkRe = regexp.MustCompile(`^socket:\[([0-9]+)\]$`)
+	// Regexp for the names of the process directories in /proc.
+	procDirRe = regexp.MustCompile(`^\d+$`)
+)
+
+// InetSocket describes a single TCP or UDP socket.
+type InetSocket struct {
+	// One of TCP, TCP6, UDP and UDP6.
+	Protocol   string
+	LocalIP    net.IP
+	LocalPort  int
+	RemoteIP   net.IP
+	RemotePort int
+	// The TCP state, see include/net/tcp_states.h.
+	State int
+	Inode uint64
+}
+
+// IsListening returns whether the socket accepts connections or datagrams
+// from any remote address, i.e. whether it's a listening TCP socket or a bound
+// UDP socket that isn't connected.
+func (s *InetSocket) IsListening() bool {
+	switch s.Protocol {
+	case TCP, TCP6:
+		return s.State == tcpListen
+	default:
+		return s.State == tcpClose && s.RemotePort == 0
+	}
+}
+
+// IsLoopback returns whether the socket is bound to a loopback address.
+func (s *InetSocket) IsLoopback() bool {
+	return s.LocalIP.IsLoopback()
+}
+
+// UnixSocket describes a single Unix domain socket.
+type UnixSocket struct {
+	// The path the socket is bound to. Abstract socket names start with "@".
+	// Empty for unbound sockets.
+	Path  string
+	Type  int
+	State int
+	Flags int
+	Inode uint64
+}
+
+// IsListening returns whether the socket accepts connections, or for datagram
+// sockets, whether it's bound and not connected.
+func (s *UnixSocket) IsListening() bool {
+	if s.Flags&unixAcceptCon != 0 {
+		return true
+	}
+	return s.Type == unixDgram && s.State == unixUnconnected && s.Path != ""
+}
+
+// IsAbstract returns whether the socket is bound to a name in the abstract
+// namespace instead of a filesystem path.
+func (s *UnixSocket) IsAbstract() bool {
+	return strings.HasPrefix(s.Path, "@")
+}
+
+// Process describes a process that has a socket open.
+type Process struct {
+	PID int
+	// The command name from /proc/<pid>/comm.
+	Comm string
+}
+
+// ReadInetSockets returns the sockets of the given protocol from
+// /proc/net/<protocol>.
+func ReadInetSockets(ctx context.Context, fs scanapi.Filesystem, protocol string) ([]*InetSocket, error) {
+	filePath := path.Join("/proc/net", protocol)
+	f, err := fs.OpenFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := []*InetSocket{}
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip the header line.
+	for scanner.Scan() {
+		line := scanner.Text()
+		groups := procInetRe.FindStringSubmatch(line)
+		if groups == nil {
+			return nil, fmt.Errorf("unable to parse %s line %q", filePath, line)
+		}
+		localIP, err := parseProcIP(groups[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+		remoteIP, err := parseProcIP(groups[3])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+		// The ports and state are validated by the regex.
+		localPort, _ := strconv.ParseInt(groups[2], 16, 32)
+		remotePort, _ := strconv.ParseInt(groups[4], 16, 32)
+		state, _ := strconv.ParseInt(groups[5], 16, 32)
+		inode, err := strconv.ParseUint(groups[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid inode %q", filePath, groups[6])
+		}
+		result = append(result, &InetSocket{
+			Protocol:   protocol,
+			LocalIP:    localIP,
+			LocalPort:  int(localPort),
+			RemoteIP:   remoteIP,
+			RemotePort: int(remotePort),
+			State:      int(state),
+			Inode:      inode,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseProcIP parses an IP address from /proc/net. The address consists of
+// one (IPv4) or four (IPv6) 32-bit words in host byte order, which we assume
+// to be little-endian.
+func parseProcIP(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return net.IP(b), nil
+}
+
+// ReadUnixSockets returns the Unix domain sockets listed in /proc/net/unix.
+func ReadUnixSockets(ctx context.Context, fs scanapi.Filesystem) ([]*UnixSocket, error) {
+	f, err := fs.OpenFile(ctx, UnixSocketsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := []*UnixSocket{}
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Skip the header line.
+	for scanner.Scan() {
+		line := scanner.Text()
+		groups := procUnixRe.FindStringSubmatch(line)
+		if groups == nil {
+			return nil, fmt.Errorf("unable to parse %s line %q", UnixSocketsPath, line)
+		}
+		// The numbers are validated by the regex.
+		flags, _ := strconv.ParseInt(groups[1], 16, 64)
+		socketType, _ := strconv.ParseInt(groups[2], 16, 32)
+		state, _ := strconv.ParseInt(groups[3], 16, 32)
+		inode, err := strconv.ParseUint(groups[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid inode %q", UnixSocketsPath, groups[4])
+		}
+		s := &UnixSocket{
+			Path:  groups[5],
+			Type:  int(socketType),
+			State: int(state),
+			Flags: int(flags),
+			Inode: inode,
+		}
+		result = append(result, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReadSocketOwners returns the processes that have the sockets open, keyed by
+// the inodes of the sockets. The file descriptors are read from /proc/<pid>/fd,
+// so sockets of processes whose file descriptors can't be read with the
+// scanner's privileges don't have an owner.
+func ReadSocketOwners(ctx context.Context, fs scanapi.Filesystem) (map[uint64][]*Process, error) {
+	d, err := fs.OpenDir(ctx, "/proc")
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	result := make(map[uint64][]*Process)
+	for d.Next() {
+		e, err := d.Entry()
+		if err != nil {
+			return nil, err
+		}
+		if !e.GetIsDir() || !IsProcessDir(e.GetName()) {
+			continue
+		}
+		procDir := path.Join("/proc", e.GetName())
+		inodes, err := readSocketInodes(ctx, fs, procDir)
+		if err != nil {
+			if isSkippableProcError(err) {
+				continue
+			}
+			return nil, err
+		}
+		if len(inodes) == 0 {
+			continue
+		}
+		comm, err := ReadComm(ctx, fs, procDir)
+		if err != nil {
+			if isSkippableProcError(err) {
+				continue
+			}
+			return nil, err
+		}
+		pid, err := strconv.Atoi(e.GetName())
+		if err != nil {
+			return nil, err
+		}
+		p := &Process{PID: pid, Comm: comm}
+		for _, inode := range inodes {
+			result[inode] = append(result[inode], p)
+		}
+	}
+	return result, nil
+}
+
+// isSkippableProcError returns whether the error is caused by a process that
+// exited since we listed it or whose details we're not allowed to read.
+func isSkippableProcError(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission)
+}
+
+// readSocketInodes returns the inodes of the sockets in the file descriptor
+// table of the process.
+func readSocketInodes(ctx context.Context, fs scanapi.Filesystem, procDir string) ([]uint64, error) {
+	fdDir := path.Join(procDir, "fd")
+	d, err := fs.OpenDir(ctx, fdDir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	result := []uint64{}
+	for d.Next() {
+		e, err := d.Entry()
+		if err != nil {
+			return nil, err
+		}
+		stat, err := fs.FileStat(ctx, path.Join(fdDir, e.GetName()))
+		if err != nil {
+			// The file descriptor was closed since we listed it.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		groups := socketLinkRe.FindStringSubmatch(stat.GetLinkTarget())
+		if groups == nil {
+			continue
+		}
+		inode, err := strconv.ParseUint(groups[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, inode)
+	}
+	return result, nil
+}
+
+// IsProcessDir returns whether the entry of /proc with the given name is the
+// directory of a process.
+func IsProcessDir(name string) bool {
+	return procDirRe.MatchString(name)
+}
+
+// ReadComm returns the command name of the process from the comm file of its
+// /proc/<pid> directory.
+func ReadComm(ctx context.Context, fs scanapi.Filesystem, procDir string) (string, error) {
+	f, err := fs.OpenFile(ctx, path.Join(procDir, "comm"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	comm, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(comm), "\n"), nil
+}
@@ -0,0 +1,235 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sockets_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/localtoast/scanapi"
+	apb "github.com/google/localtoast/scannerlib/proto/api_go_proto"
+	"github.com/google/localtoast/scannerlib/sockets"
+)
+
+// fakeFilesystem serves files, directory listings and symlink targets from maps.
+type fakeFilesystem struct {
+	files       map[string]string
+	dirs        map[string][]*apb.DirContent
+	linkTargets map[string]string
+}
+
+func (f *fakeFilesystem) OpenFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	content, ok := f.files[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader([]byte(content))), nil
+}
+
+func (f *fakeFilesystem) FilePermissions(ctx context.Context, path string) (*apb.PosixPermissions, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) FileStat(ctx context.Context, path string) (*apb.FileStat, error) {
+	target, ok := f.linkTargets[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &apb.FileStat{Type: apb.FileStat_SYMLINK, LinkTarget: target}, nil
+}
+
+func (f *fakeFilesystem) FileXattr(ctx context.Context, path string, name string) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeFilesystem) OpenDir(ctx context.Context, path string) (scanapi.DirReader, error) {
+	if path == "/proc/3/fd" {
+		return nil, os.ErrPermission
+	}
+	contents, ok := f.dirs[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return scanapi.SliceToDirReader(contents), nil
+}
+
+func TestReadInetSockets(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		"/proc/net/udp": "   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n" +
+			"  123: 00000000:00A1 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 16380 2 0000000000000000 0\n" +
+			"  456: 0100007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 16381 2 0000000000000000 0\n" +
+			"  789: 0F02000A:9C40 08080808:0035 01 00000000:00000000 00:00000000 00000000  1000        0 16382 2 0000000000000000 0\n",
+		"/proc/net/udp6": "  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops\n" +
+			"  12: 00000000000000000000000001000000:0045 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 16383 2 0000000000000000 0\n" +
+			"  34: 0000000000000000FFFF00000100007F:0045 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 16384 2 0000000000000000 0\n",
+	}}
+	testCases := []struct {
+		protocol      string
+		wantPorts     []int
+		wantListening []bool
+		wantLoopback  []bool
+	}{
+		{
+			protocol:      sockets.UDP,
+			wantPorts:     []int{161, 53, 40000},
+			wantListening: []bool{true, true, false},
+			wantLoopback:  []bool{false, true, false},
+		},
+		{
+			protocol:      sockets.UDP6,
+			wantPorts:     []int{69, 69},
+			wantListening: []bool{true, true},
+			wantLoopback:  []bool{true, true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.protocol, func(t *testing.T) {
+			got, err := sockets.ReadInetSockets(context.Background(), fs, tc.protocol)
+			if err != nil {
+				t.Fatalf("sockets.ReadInetSockets(%s) returned an error: %v", tc.protocol, err)
+			}
+			gotPorts := []int{}
+			gotListening := []bool{}
+			gotLoopback := []bool{}
+			for _, s := range got {
+				gotPorts = append(gotPorts, s.LocalPort)
+				gotListening = append(gotListening, s.IsListening())
+				gotLoopback = append(gotLoopback, s.IsLoopback())
+			}
+			if diff := cmp.Diff(tc.wantPorts, gotPorts); diff != "" {
+				t.Errorf("sockets.ReadInetSockets(%s) returned unexpected port diff (-want +got):\n%s", tc.protocol, diff)
+			}
+			if diff := cmp.Diff(tc.wantListening, gotListening); diff != "" {
+				t.Errorf("sockets.ReadInetSockets(%s) returned unexpected IsListening() diff (-want +got):\n%s", tc.protocol, diff)
+			}
+			if diff := cmp.Diff(tc.wantLoopback, gotLoopback); diff != "" {
+				t.Errorf("sockets.ReadInetSockets(%s) returned unexpected IsLoopback() diff (-want +got):\n%s", tc.protocol, diff)
+			}
+		})
+	}
+
+	got, err := sockets.ReadInetSockets(context.Background(), fs, sockets.UDP)
+	if err != nil {
+		t.Fatalf("sockets.ReadInetSockets(%s) returned an error: %v", sockets.UDP, err)
+	}
+	want := &sockets.InetSocket{
+		Protocol:   sockets.UDP,
+		LocalIP:    net.IPv4(10, 0, 2, 15).To4(),
+		LocalPort:  40000,
+		RemoteIP:   net.IPv4(8, 8, 8, 8).To4(),
+		RemotePort: 53,
+		State:      1,
+		Inode:      16382,
+	}
+	if diff := cmp.Diff(want, got[2]); diff != "" {
+		t.Errorf("sockets.ReadInetSockets(%s) returned unexpected diff (-want +got):\n%s", sockets.UDP, diff)
+	}
+}
+
+func TestReadInetSocketsInvalidContentReturnsError(t *testing.T) {
+	for _, content := range []string{
+		"header\ninvalid\n",
+		"header\n  0: 0000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1\n",
+	} {
+		fs := &fakeFilesystem{files: map[string]string{"/proc/net/tcp": content}}
+		if _, err := sockets.ReadInetSockets(context.Background(), fs, sockets.TCP); err == nil {
+			t.Errorf("sockets.ReadInetSockets() with content %q didn't return an error", content)
+		}
+	}
+}
+
+func TestReadUnixSockets(t *testing.T) {
+	fs := &fakeFilesystem{files: map[string]string{
+		sockets.UnixSocketsPath: "Num       RefCount Protocol Flags    Type St Inode Path\n" +
+			"0000000000000000: 00000002 00000000 00010000 0001 01 20001 /run/systemd/private\n" +
+			"0000000000000000: 00000002 00000000 00010000 0001 01 20002 @/tmp/.X11-unix/X0\n" +
+			"0000000000000000: 00000002 00000000 00000000 0002 01 20003 /run/systemd/journal/dev log\n" +
+			"0000000000000000: 00000003 00000000 00000000 0001 03 20004\n" +
+			"0000000000000000: 00000003 00000000 00000000 0001 03 20005 /run/dbus/system_bus_socket\n",
+	}}
+	got, err := sockets.ReadUnixSockets(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("sockets.ReadUnixSockets() returned an error: %v", err)
+	}
+	want := []*sockets.UnixSocket{
+		{Path: "/run/systemd/private", Type: 1, State: 1, Flags: 0x10000, Inode: 20001},
+		{Path: "@/tmp/.X11-unix/X0", Type: 1, State: 1, Flags: 0x10000, Inode: 20002},
+		{Path: "/run/systemd/journal/dev log", Type: 2, State: 1, Inode: 20003},
+		{Type: 1, State: 3, Inode: 20004},
+		{Path: "/run/dbus/system_bus_socket", Type: 1, State: 3, Inode: 20005},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("sockets.ReadUnixSockets() returned unexpected diff (-want +got):\n%s", diff)
+	}
+	wantListening := []bool{true, true, true, false, false}
+	wantAbstract := []bool{false, true, false, false, false}
+	for i, s := range got {
+		if s.IsListening() != wantListening[i] {
+			t.Errorf("%v.IsListening() = %t, want %t", s, s.IsListening(), wantListening[i])
+		}
+		if s.IsAbstract() != wantAbstract[i] {
+			t.Errorf("%v.IsAbstract() = %t, want %t", s, s.IsAbstract(), wantAbstract[i])
+		}
+	}
+}
+
+func TestReadSocketOwners(t *testing.T) {
+	fs := &fakeFilesystem{
+		files: map[string]string{
+			"/proc/1/comm": "systemd\n",
+			"/proc/2/comm": "sshd\n",
+		},
+		dirs: map[string][]*apb.DirContent{
+			"/proc": {
+				{Name: "1", IsDir: true},
+				{Name: "2", IsDir: true},
+				// Not allowed to read the file descriptors.
+				{Name: "3", IsDir: true},
+				// Exited after being listed.
+				{Name: "4", IsDir: true},
+				{Name: "self", IsDir: true},
+			},
+			"/proc/1/fd": {{Name: "0"}, {Name: "3"}, {Name: "4"}},
+			"/proc/2/fd": {{Name: "3"}, {Name: "5"}},
+		},
+		linkTargets: map[string]string{
+			"/proc/1/fd/0": "/dev/null",
+			"/proc/1/fd/3": "socket:[100]",
+			"/proc/1/fd/4": "socket:[200]",
+			"/proc/2/fd/3": "socket:[200]",
+			"/proc/2/fd/5": "pipe:[300]",
+		},
+	}
+	got, err := sockets.ReadSocketOwners(context.Background(), fs)
+	if err != nil {
+		t.Fatalf("sockets.ReadSocketOwners() returned an error: %v", err)
+	}
+	systemd := &sockets.Process{PID: 1, Comm: "systemd"}
+	sshd := &sockets.Process{PID: 2, Comm: "sshd"}
+	want := map[uint64][]*sockets.Process{
+		100: {systemd},
+		200: {systemd, sshd},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("sockets.ReadSocketOwners() returned unexpected diff (-want +got):\n%s", diff)
+	}
+}
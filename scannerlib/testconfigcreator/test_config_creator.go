@@ -59,3 +59,43 @@ func NewSQLScanInstruction(sqlChecks []*ipb.SQLCheck) *ipb.BenchmarkScanInstruct
 		CheckAlternatives: []*ipb.CheckAlternative{{SqlChecks: sqlChecks}},
 	}
 }
+
+// NewKernelModuleScanInstruction creates a scan instruction with a single alternative
+// from the given kernel module checks.
+func NewKernelModuleScanInstruction(kernelModuleChecks []*ipb.KernelModuleCheck) *ipb.BenchmarkScanInstruction {
+	return &ipb.BenchmarkScanInstruction{
+		CheckAlternatives: []*ipb.CheckAlternative{{KernelModuleChecks: kernelModuleChecks}},
+	}
+}
+
+// NewMountScanInstruction creates a scan instruction with a single alternative from the
+// given mount checks.
+func NewMountScanInstruction(mountChecks []*ipb.MountCheck) *ipb.BenchmarkScanInstruction {
+	return &ipb.BenchmarkScanInstruction{
+		CheckAlternatives: []*ipb.CheckAlternative{{MountChecks: mountChecks}},
+	}
+}
+
+// NewPamScanInstruction creates a scan instruction with a single alternative from the
+// given PAM checks.
+func NewPamScanInstruction(pamChecks []*ipb.PamCheck) *ipb.BenchmarkScanInstruction {
+	return &ipb.BenchmarkScanInstruction{
+		CheckAlternatives: []*ipb.CheckAlternative{{PamChecks: pamChecks}},
+	}
+}
+
+// NewAccountScanInstruction creates a scan instruction with a single alternative from the
+// given account checks.
+func NewAccountScanInstruction(accountChecks []*ipb.AccountCheck) *ipb.BenchmarkScanInstruction {
+	return &ipb.BenchmarkScanInstruction{
+		CheckAlternatives: []*ipb.CheckAlternative{{AccountChecks: accountChecks}},
+	}
+}
+
+// NewListeningServicesScanInstruction creates a scan instruction with a single alternative
+// from the given listening services checks.
+func NewListeningServicesScanInstruction(listeningServicesChecks []*ipb.ListeningServicesCheck) *ipb.BenchmarkScanInstruction {
+	return &ipb.BenchmarkScanInstruction{
+		CheckAlternatives: []*ipb.CheckAlternative{{ListeningServicesChecks: listeningServicesChecks}},
+	}
+}
@@ -42,3 +42,42 @@ func Query(ctx context.Context, db *sql.DB, query string) (string, error) {
 	}
 	return result, nil
 }
+
+// QueryRows executes the query and returns the first column of every result
+// row. Any further columns are ignored and NULL values are returned as empty
+// strings.
+func QueryRows(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("no database specified. Please provide one using the --database flag")
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return []string{}, nil
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	result := []string{}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, values[0].String)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+	return result, nil
+}
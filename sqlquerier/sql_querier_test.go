@@ -19,6 +19,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/localtoast/fakedb"
 	"github.com/google/localtoast/sqlquerier"
 )
@@ -79,3 +80,49 @@ func TestSQLCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestSQLQueryRows(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		query       string
+		want        []string
+		expectError bool
+	}{
+		{
+			desc:  "no rows returned",
+			query: fakedb.QueryNoRows,
+			want:  []string{},
+		},
+		{
+			desc:  "several rows returned",
+			query: fakedb.QueryThreeRows,
+			want:  []string{"fakeValue", "fakeValue", "fakeValue"},
+		},
+		{
+			desc:  "several columns with NULL values returned",
+			query: fakedb.QueryTwoColumns,
+			want:  []string{"fakeValue", ""},
+		},
+		{
+			desc:        "errors propagated",
+			query:       fakedb.QueryError,
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			db, err := fakedb.Open(&fakedb.FakeDB{})
+			if err != nil {
+				t.Errorf("fakedb.Open had an unexpected error: %v", err)
+			}
+
+			got, err := sqlquerier.QueryRows(context.Background(), db, tc.query)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("sqlquerier.QueryRows(ctx, db, %q) returned error %v, expected error: %t", tc.query, err, tc.expectError)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("sqlquerier.QueryRows(ctx, db, %q) returned unexpected diff (-want +got):\n%s", tc.query, diff)
+			}
+		})
+	}
+}